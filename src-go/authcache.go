@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Minutes a rejected credential is not retried unless AUTH_FAILURE_TTL is set
+const DefaultAuthFailureTTL = 5
+
+// authFailure is a provider's rejection of one credential and until when it is remembered
+type authFailure struct {
+	err   error
+	until time.Time
+}
+
+// authFailureCache remembers credentials that providers rejected, so an invalid token costs
+// one failed request per AUTH_FAILURE_TTL rather than one per statusline tick. Entries are
+// keyed like responses by provider, account, and a hash of the credential, so the other
+// accounts of a provider are still asked and a fixed token takes effect at once.
+type authFailureCache struct {
+	mu       sync.Mutex
+	failures map[string]authFailure
+}
+
+var authFailures = &authFailureCache{failures: make(map[string]authFailure)}
+
+// isAuthFailure reports whether a provider rejected the credentials with a 401 or 403
+func isAuthFailure(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) &&
+		(apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden)
+}
+
+// get returns the remembered rejection of the credential behind a response cache key while
+// it is fresh
+func (a *authFailureCache) get(key string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	failure, ok := a.failures[credentialIdentity(key)]
+	if !ok || !clockNow().Before(failure.until) {
+		return nil
+	}
+	return fmt.Errorf("%w (not retried until %s or the credential changes)", failure.err, failure.until.Local().Format(time.TimeOnly))
+}
+
+// observe remembers the credential behind a response cache key for AUTH_FAILURE_TTL minutes
+// when err is a 401 or 403
+func (a *authFailureCache) observe(key string, err error) {
+	ttl := time.Duration(LoadConfig().AuthFailureTTL) * time.Minute
+	if ttl <= 0 || !isAuthFailure(err) {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.failures[credentialIdentity(key)] = authFailure{err: err, until: clockNow().Add(ttl)}
+}
+
+// forget drops the rejections of a provider's credentials, after auth set replaced one
+func (a *authFailureCache) forget(provider string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for identity := range a.failures {
+		if strings.HasPrefix(identity, provider+"/") {
+			delete(a.failures, identity)
+		}
+	}
+}
+
+// clear forgets every rejection, so the next fetch asks the providers again
+func (a *authFailureCache) clear() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	clear(a.failures)
+}
+
+// runAuthCommand replaces a provider's credential with "auth set <provider>", reading it from
+// the first line of stdin so it stays out of the shell history. The provider's remembered
+// rejections are dropped; a running server reloads the env file and asks with the new one.
+func runAuthCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("auth", flag.ContinueOnError)
+	envFile := flags.String("env-file", ".env", "file to set the credential in")
+	if len(args) < 2 || args[0] != "set" {
+		return errors.New("usage: auth set <provider> [--env-file .env] < credential")
+	}
+	provider := args[1]
+	if err := flags.Parse(args[2:]); err != nil {
+		return err
+	}
+	variable, ok := wizardVariables[provider]
+	if !ok {
+		return withFix(fmt.Errorf("%w: %s", ErrUnknownProvider, provider),
+			"Use one of: "+strings.Join(slices.Sorted(maps.Keys(wizardVariables)), ", "))
+	}
+
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	credential := strings.TrimSpace(line)
+	if credential == "" {
+		return fmt.Errorf("no credential on stdin for %s", variable)
+	}
+	if err := setEnvFileValue(*envFile, variable, credential); err != nil {
+		return err
+	}
+	os.Setenv(variable, credential)
+	authFailures.forget(provider)
+	fmt.Fprintf(stdout, "Set %s in %s\n", variable, *envFile)
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Badge colors, as on shields.io
+const (
+	badgeGreen  = "#4c1"
+	badgeYellow = "#dfb317"
+	badgeRed    = "#e05d44"
+	badgeGrey   = "#9f9f9f"
+	badgeLabel  = "#555"
+)
+
+// badgeTemplate is a flat shields.io-style badge: label width, value width, total width,
+// label, value, value color, and the text positions
+const badgeTemplate = `<svg xmlns="http://www.w3.org/2000/svg" width="%[3]d" height="20" role="img" aria-label="%[4]s: %[5]s">
+<title>%[4]s: %[5]s</title>
+<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="%[3]d" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)"><rect width="%[1]d" height="20" fill="` + badgeLabel + `"/><rect x="%[1]d" width="%[2]d" height="20" fill="%[6]s"/><rect width="%[3]d" height="20" fill="url(#s)"/></g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="%[7]d" y="15" fill="#010101" fill-opacity=".3">%[4]s</text><text x="%[7]d" y="14">%[4]s</text>
+<text x="%[8]d" y="15" fill="#010101" fill-opacity=".3">%[5]s</text><text x="%[8]d" y="14">%[5]s</text>
+</g>
+</svg>
+`
+
+// badgeTextWidth estimates the width of 11px Verdana text, which averages about 7 pixels a
+// character, plus padding
+func badgeTextWidth(text string) int {
+	return len([]rune(text))*7 + 10
+}
+
+// renderBadgeSVG draws a badge with a label and a value on a colored background
+func renderBadgeSVG(label, value, color string) []byte {
+	labelWidth, valueWidth := badgeTextWidth(label), badgeTextWidth(value)
+	return fmt.Appendf(nil, badgeTemplate, labelWidth, valueWidth, labelWidth+valueWidth,
+		html.EscapeString(label), html.EscapeString(value), color, labelWidth/2, labelWidth+valueWidth/2)
+}
+
+// badgeColor returns green from 50% left, yellow from 20%, and red below
+func badgeColor(pct float64) string {
+	switch {
+	case pct >= QuotaGood:
+		return badgeGreen
+	case pct >= QuotaWarning:
+		return badgeYellow
+	default:
+		return badgeRed
+	}
+}
+
+// GetBadge serves /badge/<model>.svg, the remaining quota of a model as an SVG badge to
+// embed in wikis and READMEs. The provider comes from ?provider= or the model name, and
+// ?label= replaces the model name on the badge. Errors are drawn as grey badges with status
+// 200, as image proxies such as GitHub's drop other responses.
+func (s *QuotaService) GetBadge(c *gin.Context) {
+	model, ok := strings.CutSuffix(c.Param("file"), ".svg")
+	if !ok || model == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "badges are served as /badge/<model>.svg"})
+		return
+	}
+	label := c.DefaultQuery("label", model)
+	provider := c.DefaultQuery("provider", requireProvider(model))
+
+	respond := func(value, color, cacheControl string) {
+		c.Header("Cache-Control", cacheControl)
+		c.Data(http.StatusOK, "image/svg+xml; charset=utf-8", renderBadgeSVG(label, value, color))
+	}
+
+	quota, err := s.fetchQuota(c.Request.Context(), provider)
+	if err != nil {
+		respond("unavailable", badgeGrey, "no-cache")
+		return
+	}
+	for _, candidate := range quota.Models {
+		if candidate.Name == model {
+			maxAge := s.client.Config().QueryDebounce * 60
+			respond(formatPercentage(candidate.Percentage)+"%", badgeColor(candidate.Percentage), fmt.Sprintf("max-age=%d", maxAge))
+			return
+		}
+	}
+	respond("not found", badgeGrey, "no-cache")
+}
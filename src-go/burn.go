@@ -0,0 +1,185 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// Samples older than this no longer count toward a burn rate
+	burnWindow = 6 * time.Hour
+
+	// Samples kept per model
+	maxBurnSamples = 64
+
+	// Usage events kept per model between provider refreshes
+	maxUsageEvents = 1024
+)
+
+// burnSample is a model's remaining percentage at the time its data was fetched
+type burnSample struct {
+	at  time.Time
+	pct float64
+}
+
+// usageEvent is usage reported by external tooling, in tokens or percentage points
+type usageEvent struct {
+	at     time.Time
+	tokens int64
+	points float64
+}
+
+// burnRegistry tracks recent percentages per provider model to estimate how fast quota is
+// used. Usage events reported between refreshes extend the last sample to the present: the
+// points per token they are worth is learned from the drop between two samples.
+type burnRegistry struct {
+	mu      sync.RWMutex
+	samples map[string][]burnSample
+
+	// Events newer than the model's last sample, and the points per token last observed
+	events         map[string][]usageEvent
+	pointsPerToken map[string]float64
+}
+
+var burnRates = &burnRegistry{
+	samples: make(map[string][]burnSample),
+}
+
+// burnKey identifies a model across providers
+func burnKey(provider, model string) string {
+	return provider + "/" + model
+}
+
+// record adds a sample for every model of quota. Cached data repeats its fetch time and is
+// skipped, and a rising percentage means the quota was reset, so older samples are dropped.
+func (b *burnRegistry) record(provider string, quota *FormattedQuota) {
+	at := time.Unix(quota.LastUpdated, 0)
+	cutoff := clockNow().Add(-burnWindow)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, model := range quota.Models {
+		key := burnKey(provider, model.Name)
+		samples := b.samples[key]
+
+		if n := len(samples); n > 0 {
+			last := samples[n-1]
+			if !at.After(last.at) {
+				continue
+			}
+			b.calibrate(key, last, at, model.Percentage)
+			if model.Percentage > last.pct {
+				samples = nil
+			}
+		} else {
+			b.settleEvents(key, at)
+		}
+		samples = append(samples, burnSample{at: at, pct: model.Percentage})
+
+		// Drop samples outside the window and beyond the cap
+		start := 0
+		for start < len(samples)-1 && (samples[start].at.Before(cutoff) || len(samples)-start > maxBurnSamples) {
+			start++
+		}
+		b.samples[key] = samples[start:]
+	}
+}
+
+// settleEvents drops the events a sample at at includes and returns their usage
+func (b *burnRegistry) settleEvents(key string, at time.Time) (int64, float64) {
+	events := b.events[key]
+	if len(events) == 0 {
+		return 0, 0
+	}
+
+	var tokens int64
+	var points float64
+	kept := events[:0]
+	for _, event := range events {
+		if event.at.After(at) {
+			kept = append(kept, event)
+			continue
+		}
+		tokens += event.tokens
+		points += event.points
+	}
+	b.events[key] = kept
+	return tokens, points
+}
+
+// calibrate learns the points per token from the events between the previous sample and
+// a new one at at
+func (b *burnRegistry) calibrate(key string, previous burnSample, at time.Time, pct float64) {
+	tokens, points := b.settleEvents(key, at)
+
+	// Points reported directly are known; the rest of the drop is the tokens' share. A reset
+	// raises the percentage, so it teaches nothing.
+	if drop := previous.pct - pct - points; tokens > 0 && drop > 0 {
+		b.pointsPerToken[key] = drop / float64(tokens)
+	}
+}
+
+// recordUsage adds usage reported by external tooling. Events at or before the model's last
+// sample are already part of it and are ignored.
+func (b *burnRegistry) recordUsage(provider, model string, event usageEvent) {
+	key := burnKey(provider, model)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.events == nil {
+		b.events = make(map[string][]usageEvent)
+		b.pointsPerToken = make(map[string]float64)
+	}
+	if samples := b.samples[key]; len(samples) > 0 && !event.at.After(samples[len(samples)-1].at) {
+		return
+	}
+	events := append(b.events[key], event)
+	if len(events) > maxUsageEvents {
+		events = events[len(events)-maxUsageEvents:]
+	}
+	b.events[key] = events
+}
+
+// estimate returns the model's percentage extended to now by the usage events since its last
+// sample, and whether there were any that could be converted
+func (b *burnRegistry) estimate(key string, last burnSample) (burnSample, bool) {
+	var used float64
+	found := false
+	for _, event := range b.events[key] {
+		if event.points > 0 || (event.tokens > 0 && b.pointsPerToken[key] > 0) {
+			used += event.points + float64(event.tokens)*b.pointsPerToken[key]
+			found = true
+		}
+	}
+	if !found {
+		return last, false
+	}
+	return burnSample{at: clockNow(), pct: max(last.pct-used, 0)}, true
+}
+
+// rate returns the percentage of quota used per hour, or 0 when there is not enough history
+func (b *burnRegistry) rate(provider, model string) float64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	key := burnKey(provider, model)
+	samples := b.samples[key]
+	if len(samples) == 0 {
+		return 0
+	}
+
+	first, last := samples[0], samples[len(samples)-1]
+	if current, ok := b.estimate(key, last); ok && current.at.After(last.at) {
+		last = current
+	}
+	if !last.at.After(first.at) {
+		return 0
+	}
+	hours := last.at.Sub(first.at).Hours()
+	if hours < time.Minute.Hours() {
+		return 0
+	}
+	return (first.pct - last.pct) / hours
+}
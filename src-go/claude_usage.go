@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ClaudeUsageWindow is one rate-limit window of a Claude subscription
+type ClaudeUsageWindow struct {
+	Utilization float64 `json:"utilization"`
+	ResetsAt    string  `json:"resets_at"`
+}
+
+// ClaudeUsage represents the claude.ai Pro/Max usage response
+type ClaudeUsage struct {
+	FiveHour     *ClaudeUsageWindow `json:"five_hour"`
+	SevenDay     *ClaudeUsageWindow `json:"seven_day"`
+	SevenDayOpus *ClaudeUsageWindow `json:"seven_day_opus"`
+
+	// FetchedAt is when the data was retrieved from the API
+	FetchedAt time.Time `json:"-"`
+}
+
+// claudeCredentials mirrors the credentials file written by the Claude CLI
+type claudeCredentials struct {
+	ClaudeAiOauth struct {
+		AccessToken string `json:"accessToken"`
+	} `json:"claudeAiOauth"`
+}
+
+// claudeSessionToken returns the session credential from CLAUDE_OAUTH_TOKEN or the credentials file
+func claudeSessionToken(ctx context.Context, config *Config) (string, error) {
+	if config.ClaudeOAuthToken != nil {
+		return config.ClaudeOAuthToken.Token(ctx)
+	}
+
+	path := config.ClaudeCredentialsFile
+	if path == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("CLAUDE_OAUTH_TOKEN is not set and home directory is unknown: %w", err)
+		}
+		path = filepath.Join(homeDir, ".claude", ".credentials.json")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("CLAUDE_OAUTH_TOKEN is not set and credentials file could not be read: %w", err)
+	}
+
+	var creds claudeCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if creds.ClaudeAiOauth.AccessToken == "" {
+		return "", fmt.Errorf("no claudeAiOauth.accessToken in %s", path)
+	}
+	return creds.ClaudeAiOauth.AccessToken, nil
+}
+
+// queryClaudeUsage fetches subscription usage with caching
+func queryClaudeUsage(ctx context.Context, usageURL, token string) (*ClaudeUsage, error) {
+	data, fetchedAt, err := cachedFetch(ctx, providerCache, ProviderClaudeAI, responseCacheKey(ProviderClaudeAI, "", token, usageURL), func() (interface{}, error) {
+		var usage ClaudeUsage
+		headers := map[string]string{"anthropic-beta": "oauth-2025-04-20"}
+		name, value := authHeader(LoadConfig(), ProviderClaudeAI, token)
+		headers[name] = value
+		if err := doJSON(ctx, ProviderClaudeAI, "GET", usageURL, headers, nil, &usage); err != nil {
+			return nil, err
+		}
+		return &usage, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	usage := *data.(*ClaudeUsage)
+	usage.FetchedAt = fetchedAt
+	return &usage, nil
+}
+
+// FormatClaudeUsage formats subscription usage to match antigravity quota format.
+// Each window reports its remaining share, so the 5-hour session is "claude-ai-session".
+func FormatClaudeUsage(usage *ClaudeUsage) FormattedQuota {
+	config := LoadConfig()
+
+	windows := []struct {
+		name   string
+		window *ClaudeUsageWindow
+	}{
+		{"claude-ai-session", usage.FiveHour},
+		{"claude-ai-weekly", usage.SevenDay},
+		{"claude-ai-weekly-opus", usage.SevenDayOpus},
+	}
+
+	var models []FormattedModel
+	for _, w := range windows {
+		if w.window == nil {
+			continue
+		}
+		model := FormattedModel{
+			Name:       w.name,
+			Percentage: roundPercentage(100-w.window.Utilization, config.PercentagePrecision),
+			ResetTime:  w.window.ResetsAt,
+		}
+		if w.window.ResetsAt != "" {
+			model.ResetTimeRelative = formatTimeRemaining(w.window.ResetsAt)
+		}
+		models = append(models, model)
+	}
+
+	models = applyModelAliases(models, config.ModelAliases)
+
+	quota := FormattedQuota{
+		Models:      models,
+		IsForbidden: false,
+	}
+	stampQuotaTime(&quota, usage.FetchedAt)
+	return quota
+}
+
+// GetClaudeUsage gets Pro/Max subscription usage for the configured Claude session
+func GetClaudeUsage(ctx context.Context) (FormattedQuota, error) {
+	config := LoadConfig()
+
+	token, err := claudeSessionToken(ctx, config)
+	if err != nil {
+		return FormattedQuota{}, err
+	}
+
+	usage, err := queryClaudeUsage(ctx, config.ClaudeUsageURL, token)
+	if err != nil {
+		return FormattedQuota{}, err
+	}
+
+	return FormatClaudeUsage(usage), nil
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cost estimate kinds: money spent on a metered key, or the list-price value of what a
+// subscription plan consumed
+const (
+	CostKindSpend = "spend"
+	CostKindValue = "value"
+)
+
+// ModelPrice is the blended price of a million tokens of a model
+type ModelPrice struct {
+	PerMillion float64 `json:"per_million"`
+	Currency   string  `json:"currency"`
+}
+
+// defaultPricing holds list prices per million tokens, blended assuming three input tokens
+// per output token. Keys are model globs; PRICING overrides or extends them.
+var defaultPricing = map[string]ModelPrice{
+	"glm*":           {PerMillion: 1.00, Currency: "USD"},
+	"grok-4*":        {PerMillion: 6.00, Currency: "USD"},
+	"claude-sonnet*": {PerMillion: 6.00, Currency: "USD"},
+	"claude-opus*":   {PerMillion: 30.00, Currency: "USD"},
+	"llama-3.3-70b*": {PerMillion: 0.64, Currency: "USD"},
+	"mistral-large*": {PerMillion: 3.00, Currency: "USD"},
+}
+
+// parsePricing parses "model=price [currency],..." pairs into a pricing table, defaulting to USD
+func parsePricing(value string) map[string]ModelPrice {
+	pricing := make(map[string]ModelPrice)
+	for _, pair := range strings.Split(trimQuotes(value), ",") {
+		model, price, found := strings.Cut(pair, "=")
+		model = strings.TrimSpace(model)
+		fields := strings.Fields(price)
+		if !found || model == "" || len(fields) == 0 || len(fields) > 2 {
+			continue
+		}
+		amount, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil || amount < 0 {
+			continue
+		}
+		currency := "USD"
+		if len(fields) == 2 {
+			currency = strings.ToUpper(fields[1])
+		}
+		pricing[model] = ModelPrice{PerMillion: amount, Currency: currency}
+	}
+	return pricing
+}
+
+// mergePricing returns the default pricing overridden by overrides
+func mergePricing(overrides map[string]ModelPrice) map[string]ModelPrice {
+	pricing := make(map[string]ModelPrice, len(defaultPricing)+len(overrides))
+	for model, price := range defaultPricing {
+		pricing[model] = price
+	}
+	for model, price := range overrides {
+		pricing[model] = price
+	}
+	return pricing
+}
+
+// priceFor looks up a model's price, preferring an exact key over the longest matching glob
+func priceFor(pricing map[string]ModelPrice, model string) (ModelPrice, bool) {
+	if price, ok := pricing[model]; ok {
+		return price, true
+	}
+
+	best := ""
+	for pattern := range pricing {
+		if matchesAnyGlob(model, []string{pattern}) && len(pattern) > len(best) {
+			best = pattern
+		}
+	}
+	if best == "" {
+		return ModelPrice{}, false
+	}
+	return pricing[best], true
+}
+
+// TokenUsage is the number of tokens a provider model used over a period
+type TokenUsage struct {
+	Model  string
+	Tokens int64
+	Since  time.Time
+	Until  time.Time
+}
+
+// CostEstimate is the estimated money value of a provider model's token usage
+type CostEstimate struct {
+	Provider string   `json:"provider"`
+	Model    string   `json:"model"`
+	Kind     string   `json:"kind"`
+	Tokens   int64    `json:"tokens"`
+	Since    int64    `json:"since"`
+	Until    int64    `json:"until"`
+	Estimate *Balance `json:"estimate,omitempty"`
+}
+
+// tokenUsageSource fetches a provider's token usage, over a default window or since a given
+// time, and says whether it is metered
+type tokenUsageSource struct {
+	kind       string
+	fetch      func(ctx context.Context) ([]TokenUsage, error)
+	fetchSince func(ctx context.Context, since time.Time) ([]TokenUsage, error)
+}
+
+// tokenUsageProviders maps providers that report token counts to their usage source
+var tokenUsageProviders = map[string]tokenUsageSource{
+	ProviderGLM: {kind: CostKindValue, fetch: GetGLMTokenUsage, fetchSince: GetGLMTokenUsageSince},
+}
+
+// GetGLMTokenUsage gets the tokens used by a GLM Coding Plan over the last 24 hours
+func GetGLMTokenUsage(ctx context.Context) ([]TokenUsage, error) {
+	return GetGLMTokenUsageSince(ctx, clockNow().Add(-24*time.Hour))
+}
+
+// GetGLMTokenUsageSince gets the tokens used by a GLM Coding Plan since the start of the
+// hour containing since, as Z.ai reports usage by the hour
+func GetGLMTokenUsageSince(ctx context.Context, since time.Time) ([]TokenUsage, error) {
+	_, baseDomain, authToken, err := zaiCredentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := clockNow()
+	totals, err := queryGLMUsageTotals(ctx, baseDomain, authToken, since, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return []TokenUsage{{
+		Model:  "glm",
+		Tokens: int64(firstNumber(totals, "totalTokensUsage")),
+		Since:  since,
+		Until:  now,
+	}}, nil
+}
+
+// estimateCost prices a provider's token usage. Models without a price have no estimate.
+func estimateCost(config *Config, provider, kind string, usage TokenUsage) CostEstimate {
+	estimate := CostEstimate{
+		Provider: provider,
+		Model:    usage.Model,
+		Kind:     kind,
+		Tokens:   usage.Tokens,
+		Since:    usage.Since.Unix(),
+		Until:    usage.Until.Unix(),
+	}
+	if price, ok := priceFor(config.Pricing, usage.Model); ok {
+		amount := float64(usage.Tokens) / 1e6 * price.PerMillion
+		estimate.Estimate = &Balance{
+			Amount:   amount,
+			Currency: price.Currency,
+			Display:  formatCurrency(config.NumberLocale, amount, price.Currency),
+		}
+	}
+	return estimate
+}
+
+// estimateCosts prices the token usage of the given providers (all that report tokens when
+// empty). Providers that fail are returned in the error map.
+func estimateCosts(ctx context.Context, config *Config, providers []string) ([]CostEstimate, map[string]string) {
+	if len(providers) == 0 {
+		for provider := range tokenUsageProviders {
+			providers = append(providers, provider)
+		}
+		sort.Strings(providers)
+	}
+
+	estimates := []CostEstimate{}
+	errs := make(map[string]string)
+	for _, provider := range providers {
+		source, ok := tokenUsageProviders[provider]
+		if !ok {
+			errs[provider] = localizer(config.Language).Sprintf(msgNoTokenUsageSource, provider)
+			continue
+		}
+		usages, err := source.fetch(ctx)
+		if err != nil {
+			errs[provider] = err.Error()
+			continue
+		}
+		for _, usage := range usages {
+			estimates = append(estimates, estimateCost(config, provider, source.kind, usage))
+		}
+	}
+	return estimates, errs
+}
+
+// formatCostEstimate renders an estimate as one line of text in the output language, with
+// locale-formatted counts
+func formatCostEstimate(config *Config, estimate CostEstimate) string {
+	printer := localizer(config.Language)
+	hours := strconv.FormatFloat(float64(estimate.Until-estimate.Since)/3600, 'g', -1, 64)
+	line := printer.Sprintf(msgCostTokens, estimate.Provider, estimate.Model, formatNumber(config.NumberLocale, estimate.Tokens), hours)
+	if estimate.Estimate == nil {
+		return printer.Sprintf(msgCostNoPrice, line)
+	}
+	if estimate.Kind == CostKindValue {
+		return printer.Sprintf(msgCostValueConsumed, line, estimate.Estimate.Display)
+	}
+	return printer.Sprintf(msgCostSpent, line, estimate.Estimate.Display)
+}
+
+// runCostCommand prints the estimated spend or consumed value of each provider reporting tokens
+func runCostCommand(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("cost", flag.ContinueOnError)
+	providers := flags.String("providers", "", "comma-separated providers to estimate (default: all that report tokens)")
+	asJSON := flags.Bool("json", false, "print estimates as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config := LoadConfig()
+	estimates, errs := estimateCosts(context.Background(), config, parseList(*providers))
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(map[string]interface{}{"costs": estimates, "errors": errs})
+	}
+
+	for _, estimate := range estimates {
+		fmt.Fprintln(stdout, formatCostEstimate(config, estimate))
+	}
+	failed := make([]string, 0, len(errs))
+	for provider := range errs {
+		failed = append(failed, provider)
+	}
+	sort.Strings(failed)
+	for _, provider := range failed {
+		fmt.Fprintf(stdout, "%s: %s\n", provider, errs[provider])
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CursorModelUsage is the per-model request usage reported by Cursor
+type CursorModelUsage struct {
+	NumRequests     int  `json:"numRequests"`
+	NumTokens       int  `json:"numTokens"`
+	MaxRequestUsage *int `json:"maxRequestUsage"`
+}
+
+// CursorUsage represents the Cursor usage response
+type CursorUsage struct {
+	Models       map[string]CursorModelUsage
+	StartOfMonth string
+
+	// FetchedAt is when the data was retrieved from the API
+	FetchedAt time.Time
+}
+
+// cursorUserID extracts the user ID from a WorkosCursorSessionToken value ("<user>::<jwt>", possibly URL-encoded)
+func cursorUserID(sessionToken string) (string, error) {
+	decoded, err := url.QueryUnescape(sessionToken)
+	if err != nil {
+		decoded = sessionToken
+	}
+	userID, _, found := strings.Cut(decoded, "::")
+	if !found || userID == "" {
+		return "", fmt.Errorf("CURSOR_SESSION_TOKEN must be the WorkosCursorSessionToken cookie value (<user>::<jwt>)")
+	}
+	return userID, nil
+}
+
+// parseCursorUsage splits the usage response into per-model entries and the billing period start
+func parseCursorUsage(raw map[string]interface{}) CursorUsage {
+	usage := CursorUsage{Models: make(map[string]CursorModelUsage)}
+
+	for key, value := range raw {
+		if key == "startOfMonth" {
+			usage.StartOfMonth, _ = value.(string)
+			continue
+		}
+		entry, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		model := CursorModelUsage{
+			NumRequests: int(firstNumber(entry, "numRequests")),
+			NumTokens:   int(firstNumber(entry, "numTokens")),
+		}
+		if max, ok := entry["maxRequestUsage"].(float64); ok {
+			limit := int(max)
+			model.MaxRequestUsage = &limit
+		}
+		usage.Models[key] = model
+	}
+	return usage
+}
+
+// cursorResetTime returns the end of the billing period that starts at startOfMonth, in RFC3339
+func cursorResetTime(startOfMonth string) string {
+	start, err := time.Parse(time.RFC3339, startOfMonth)
+	if err != nil {
+		return ""
+	}
+	return start.AddDate(0, 1, 0).UTC().Format(time.RFC3339)
+}
+
+// FormatCursorUsage formats Cursor usage to match antigravity quota format.
+// Models without a request limit are reported as full.
+func FormatCursorUsage(usage CursorUsage) FormattedQuota {
+	config := LoadConfig()
+	resetTime := cursorResetTime(usage.StartOfMonth)
+
+	var models []FormattedModel
+	for name, entry := range usage.Models {
+		pct := float64(QuotaFull)
+		if entry.MaxRequestUsage != nil && *entry.MaxRequestUsage > 0 {
+			used := float64(entry.NumRequests) / float64(*entry.MaxRequestUsage) * 100
+			pct = roundPercentage(clampPercentage(100-used), config.PercentagePrecision)
+		}
+
+		model := FormattedModel{
+			Name:       "cursor-" + name,
+			Percentage: pct,
+			ResetTime:  resetTime,
+		}
+		if resetTime != "" {
+			model.ResetTimeRelative = formatTimeRemaining(resetTime)
+		}
+		models = append(models, model)
+	}
+
+	models = applyModelAliases(models, config.ModelAliases)
+
+	sort.Slice(models, func(i, j int) bool {
+		return models[i].Name < models[j].Name
+	})
+
+	quota := FormattedQuota{
+		Models:      models,
+		IsForbidden: false,
+	}
+	stampQuotaTime(&quota, usage.FetchedAt)
+	return quota
+}
+
+// GetCursorUsage gets fast-request usage for the configured Cursor session
+func GetCursorUsage(ctx context.Context) (FormattedQuota, error) {
+	config := LoadConfig()
+	sessionToken, err := readToken(ctx, config.CursorSessionToken)
+	if err != nil {
+		return FormattedQuota{}, fmt.Errorf("failed to read CURSOR_SESSION_TOKEN: %w", err)
+	}
+	if sessionToken == "" {
+		return FormattedQuota{}, fmt.Errorf("CURSOR_SESSION_TOKEN environment variable is not set")
+	}
+
+	userID, err := cursorUserID(sessionToken)
+	if err != nil {
+		return FormattedQuota{}, err
+	}
+
+	usageURL := config.CursorUsageURL + "?user=" + url.QueryEscape(userID)
+	data, fetchedAt, err := cachedFetch(ctx, providerCache, ProviderCursor, responseCacheKey(ProviderCursor, "", sessionToken, usageURL), func() (interface{}, error) {
+		var raw map[string]interface{}
+		name, value := authHeader(config, ProviderCursor, sessionToken)
+		headers := map[string]string{name: value}
+		if err := doJSON(ctx, ProviderCursor, "GET", usageURL, headers, nil, &raw); err != nil {
+			return nil, err
+		}
+		return parseCursorUsage(raw), nil
+	})
+	if err != nil {
+		return FormattedQuota{}, err
+	}
+
+	usage := data.(CursorUsage)
+	usage.FetchedAt = fetchedAt
+	return FormatCursorUsage(usage), nil
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// Longest wait for a daemon to answer; it serves from its cache, so this is generous
+	daemonTimeout = 30 * time.Second
+
+	// Connecting gives up quickly, so a daemon that is not running costs little
+	daemonDialTimeout = 250 * time.Millisecond
+)
+
+// When the CLI gets quota from a running server (DAEMON_MODE)
+const (
+	daemonAuto   = "auto"
+	daemonAlways = "always"
+	daemonNever  = "never"
+)
+
+var daemonModes = []string{daemonAuto, daemonAlways, daemonNever}
+
+// Route on which a server proves it holds the daemon key; it needs no SERVER_TOKEN
+const daemonIdentityPath = "/daemon/identity"
+
+// ErrDaemonUnavailable means no quota server answered at the daemon address
+var ErrDaemonUnavailable = errors.New("daemon unavailable")
+
+// daemonAddress returns where the CLI reaches a running server: DAEMON_ADDRESS, else
+// LISTEN_ADDRESS, else localhost on PORT. Addresses are host:port or unix:/path, as for
+// --listen.
+func daemonAddress() string {
+	address := trimQuotes(os.Getenv("DAEMON_ADDRESS"))
+	if address == "" {
+		address = trimQuotes(os.Getenv("LISTEN_ADDRESS"))
+	}
+	if address == "" {
+		return "localhost:" + getEnvOrDefault("PORT", "8000")
+	}
+	if host, port, err := net.SplitHostPort(address); err == nil && (host == "" || host == "0.0.0.0" || host == "::") {
+		return net.JoinHostPort("localhost", port)
+	}
+	return address
+}
+
+// daemonClient returns an HTTP client and base URL for a daemon address, dialing the
+// socket for unix: addresses whatever host the URL names
+func daemonClient(address string) (*http.Client, string) {
+	dialer := &net.Dialer{Timeout: daemonDialTimeout}
+	transport := &http.Transport{DialContext: dialer.DialContext}
+	base := "http://" + address
+	if path, ok := strings.CutPrefix(address, unixListenPrefix); ok {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", path)
+		}
+		base = "http://localhost"
+	}
+	return &http.Client{Transport: transport, Timeout: daemonTimeout}, base
+}
+
+// daemonKeyPath returns the file holding the key servers prove themselves with, in the
+// prompt cache directory, or "" when there is none
+func daemonKeyPath() string {
+	dir := promptCacheDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "daemon.key")
+}
+
+// daemonKey reads the daemon key, generating it first when create is set and there is
+// none. Only the user can read the file, so only the user's own servers hold the key.
+func daemonKey(create bool) ([]byte, error) {
+	path := daemonKeyPath()
+	if path == "" {
+		return nil, errors.New("no cache directory for the daemon key")
+	}
+	if create {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, err
+		}
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		switch {
+		case err == nil:
+			key := make([]byte, 32)
+			rand.Read(key)
+			_, err = file.Write([]byte(hex.EncodeToString(key)))
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return nil, err
+			}
+		case !errors.Is(err, fs.ErrExist):
+			return nil, err
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(strings.TrimSpace(string(data)))
+}
+
+// daemonProof is the answer to a nonce sent to the server at address. The address is the
+// one the server accepted the connection on, so a process relaying the nonce to a server
+// elsewhere gets a proof that does not match the address the CLI dialed.
+func daemonProof(key []byte, nonce, address string) []byte {
+	return hmacSHA256(key, nonce+"\n"+address)
+}
+
+// GetDaemonIdentity proves the server holds the user's daemon key by answering the nonce of
+// a CLI run, which checks the proof before it sends SERVER_TOKEN
+func (s *QuotaService) GetDaemonIdentity(c *gin.Context) {
+	nonce := c.Query("nonce")
+	if len(nonce) < 32 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "nonce must be at least 32 characters"})
+		return
+	}
+	key, err := daemonKey(true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read the daemon key: %v", err)})
+		return
+	}
+	address := ""
+	if local, ok := c.Request.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		address = local.String()
+	}
+	c.JSON(http.StatusOK, gin.H{"proof": hex.EncodeToString(daemonProof(key, nonce, address))})
+}
+
+// verifyDaemon checks that the server at a daemon address holds the user's daemon key and
+// owns the address the CLI dialed, so SERVER_TOKEN is not sent to another process that
+// listens there
+func verifyDaemon(ctx context.Context, client *http.Client, base string) error {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	var dialed string
+	trace := &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) {
+		dialed = info.Conn.RemoteAddr().String()
+	}}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet,
+		base+daemonIdentityPath+"?nonce="+hex.EncodeToString(nonce), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Proof string `json:"proof"`
+	}
+	if err := decodeResponse(resp, &body); err != nil || resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("no identity proof (status %d)", resp.StatusCode)
+	}
+	// The key is read after the request, as a server creates it on its first proof
+	key, err := daemonKey(false)
+	if err != nil {
+		return err
+	}
+	proof, err := hex.DecodeString(body.Proof)
+	if err != nil || !hmac.Equal(proof, daemonProof(key, hex.EncodeToString(nonce), dialed)) {
+		return errors.New("wrong identity proof")
+	}
+	return nil
+}
+
+// fetchViaDaemon gets a provider's quota from a running server instead of the provider,
+// so the CLI shares the server's cache and credentials. Models come back unsorted and
+// ungrouped for the caller to arrange. With verify, SERVER_TOKEN is only sent once the
+// server proved it is the user's own.
+func fetchViaDaemon(ctx context.Context, config *Config, address, provider string, verify bool) (*FormattedQuota, error) {
+	client, base := daemonClient(address)
+	query := url.Values{"providers": {provider}, "sort": {""}, "group": {""}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/quota/combined?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if config.ServerToken != nil {
+		if verify {
+			if err := verifyDaemon(ctx, client, base); err != nil {
+				return nil, fmt.Errorf("%w: %s is not verified as this user's quota server: %v", ErrDaemonUnavailable, address, err)
+			}
+		}
+		token, err := readToken(ctx, config.ServerToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SERVER_TOKEN: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s is not reachable: %v", ErrDaemonUnavailable, address, err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Quota    *FormattedQuota   `json:"quota"`
+		Errors   map[string]string `json:"errors"`
+		Problems []Problem         `json:"problems"`
+		Error    string            `json:"error"`
+	}
+	if err := decodeResponse(resp, &body); err != nil || resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s does not answer as a quota server", ErrDaemonUnavailable, address)
+	}
+	switch {
+	case body.Error != "":
+		return nil, fmt.Errorf("daemon at %s: %s", address, body.Error)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("daemon at %s returned status %d", address, resp.StatusCode)
+	case body.Errors[provider] != "":
+		for _, problem := range body.Problems {
+			if problem.Provider == provider {
+				return nil, problemError(problem)
+			}
+		}
+		return nil, errors.New(body.Errors[provider])
+	case body.Quota == nil:
+		return nil, fmt.Errorf("daemon at %s returned no quota", address)
+	}
+	return body.Quota, nil
+}
+
+// fetchQuotaPreferringDaemon gets a provider's quota from the running server when there is
+// one, else from the provider. In auto mode a daemon that is down, is not a quota server, or
+// cannot prove it holds the daemon key when SERVER_TOKEN is set falls back to a direct
+// query; errors the daemon reports are returned as they are. Either way the quota is saved
+// for prompt segments, which are drawn from these CLI runs only.
+func fetchQuotaPreferringDaemon(ctx context.Context, config *Config, provider, mode string) (*FormattedQuota, error) {
+	quota, err := fetchQuotaFromDaemonOrProvider(ctx, config, provider, mode)
+	if err == nil {
+		writePromptCache(provider, quota)
+	}
+	return quota, err
+}
+
+// fetchQuotaFromDaemonOrProvider is fetchQuotaPreferringDaemon without the prompt cache
+func fetchQuotaFromDaemonOrProvider(ctx context.Context, config *Config, provider, mode string) (*FormattedQuota, error) {
+	if mode != daemonNever {
+		quota, err := fetchViaDaemon(ctx, config, daemonAddress(), provider, mode == daemonAuto)
+		if err == nil || mode == daemonAlways || !errors.Is(err, ErrDaemonUnavailable) {
+			return quota, err
+		}
+	}
+	return NewQuotaService(NewCloudCodeClient(config)).fetchQuota(ctx, provider)
+}
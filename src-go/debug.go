@@ -0,0 +1,271 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// httpTransport is the transport of every provider HTTP client; nil uses the default.
+// The debug dump replaces it to record responses, and --debug-http to trace requests.
+var httpTransport http.RoundTripper
+
+// redacted replaces secrets in debug bundles
+const redacted = "[redacted]"
+
+// sensitiveName matches JSON keys, query parameters, and headers whose values are secrets
+var sensitiveName = regexp.MustCompile(`(?i)(token|secret|password|api[_-]?key|authorization|cookie|email|signature)$|^(user|key)$`)
+
+// recordedExchange is a redacted HTTP request and its response
+type recordedExchange struct {
+	Method   string              `json:"method"`
+	URL      string              `json:"url"`
+	Status   int                 `json:"status,omitempty"`
+	Headers  map[string][]string `json:"headers,omitempty"`
+	Body     json.RawMessage     `json:"body,omitempty"`
+	BodyText string              `json:"body_text,omitempty"`
+	Error    string              `json:"error,omitempty"`
+	Duration string              `json:"duration"`
+}
+
+// recordingTransport records every exchange that passes through it
+type recordingTransport struct {
+	base http.RoundTripper
+
+	mu        sync.Mutex
+	exchanges []recordedExchange
+}
+
+// RoundTrip performs the request and records it with secrets redacted
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+
+	exchange := recordedExchange{
+		Method:   req.Method,
+		URL:      redactURL(req.URL),
+		Duration: time.Since(start).Round(time.Millisecond).String(),
+	}
+	if err != nil {
+		exchange.Error = err.Error()
+	} else {
+		// Only the part the client would read is recorded; the rest stays in the body
+		body, readErr := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		if readErr != nil {
+			exchange.Error = readErr.Error()
+		}
+
+		exchange.Status = resp.StatusCode
+		exchange.Headers = redactHeaders(resp.Header)
+		var decoded interface{}
+		if json.Unmarshal(body, &decoded) == nil {
+			exchange.Body, _ = json.Marshal(redactJSON(decoded))
+		} else {
+			exchange.BodyText = string(body)
+		}
+	}
+
+	t.mu.Lock()
+	t.exchanges = append(t.exchanges, exchange)
+	t.mu.Unlock()
+	return resp, err
+}
+
+// redactURL returns the URL with sensitive query parameters redacted
+func redactURL(u *url.URL) string {
+	redactedURL := *u
+	query := redactedURL.Query()
+	for name := range query {
+		if sensitiveName.MatchString(name) {
+			query.Set(name, redacted)
+		}
+	}
+	redactedURL.RawQuery = query.Encode()
+	return redactedURL.String()
+}
+
+// redactHeaders copies headers, redacting sensitive ones and those of auth header templates
+func redactHeaders(header http.Header) map[string][]string {
+	result := make(map[string][]string, len(header))
+	for name, values := range header {
+		if sensitiveName.MatchString(name) || isAuthHeaderName(name) {
+			result[name] = []string{redacted}
+		} else {
+			result[name] = values
+		}
+	}
+	return result
+}
+
+// redactJSON replaces the values of sensitive keys anywhere in a decoded JSON value
+func redactJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if sensitiveName.MatchString(key) {
+				result[key] = redacted
+			} else {
+				result[key] = redactJSON(item)
+			}
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = redactJSON(item)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// redactConfig returns the configuration as JSON-ready fields with secrets redacted
+func redactConfig(config *Config) map[string]interface{} {
+	result := make(map[string]interface{})
+	value := reflect.ValueOf(config).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		fieldValue := value.Field(i).Interface()
+		if (secretConfigFields[field.Name] || strings.HasPrefix(field.Name, "HookOn")) && !value.Field(i).IsZero() {
+			fieldValue = redacted
+		}
+		result[field.Name] = fieldValue
+	}
+	return result
+}
+
+// versionInfo describes the build and platform
+func versionInfo() map[string]string {
+	info := map[string]string{
+		"go":       runtime.Version(),
+		"platform": runtime.GOOS + "/" + runtime.GOARCH,
+		"created":  clockNow().UTC().Format(time.RFC3339),
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		info["module"] = build.Main.Path
+		info["version"] = build.Main.Version
+		for _, setting := range build.Settings {
+			if setting.Key == "vcs.revision" || setting.Key == "vcs.modified" {
+				info[setting.Key] = setting.Value
+			}
+		}
+	}
+	return info
+}
+
+// bundleFile is a JSON file in a debug bundle
+type bundleFile struct {
+	name  string
+	value interface{}
+}
+
+// writeDebugBundle queries every provider through a recording transport and writes the
+// redacted responses, parsed quota, configuration, and version info to a zip archive
+func writeDebugBundle(ctx context.Context, w io.Writer) error {
+	previous := httpTransport
+	recorder := &recordingTransport{base: http.DefaultTransport}
+	if previous != nil {
+		recorder.base = previous
+	}
+	httpTransport = recorder
+	defer func() { httpTransport = previous }()
+
+	config := LoadConfig()
+	service := NewQuotaService(NewCloudCodeClient(config))
+	service.invalidateCache()
+
+	parsed := make(map[string]interface{})
+	for _, provider := range providerNames() {
+		quota, err := service.fetchQuota(ctx, provider)
+		if err != nil {
+			parsed[provider] = map[string]string{"error": err.Error()}
+			continue
+		}
+		parsed[provider] = quota
+	}
+	costs, costErrors := estimateCosts(ctx, config, nil)
+	parsed["costs"] = map[string]interface{}{"costs": costs, "errors": costErrors}
+
+	archive := zip.NewWriter(w)
+	files := []bundleFile{
+		{"version.json", versionInfo()},
+		{"config.json", redactConfig(config)},
+		{"parsed.json", parsed},
+	}
+	recorder.mu.Lock()
+	for i, exchange := range recorder.exchanges {
+		host := "request"
+		if u, err := url.Parse(exchange.URL); err == nil && u.Host != "" {
+			host = u.Host
+		}
+		files = append(files, bundleFile{fmt.Sprintf("responses/%02d-%s.json", i+1, host), exchange})
+	}
+	recorder.mu.Unlock()
+
+	for _, file := range files {
+		data, err := json.MarshalIndent(file.value, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", file.name, err)
+		}
+		entry, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := entry.Write(data); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// runDebugCommand handles "debug dump [--output file.zip]"
+func runDebugCommand(args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] != "dump" {
+		return errors.New("usage: debug dump [--output file.zip]")
+	}
+
+	flags := flag.NewFlagSet("debug dump", flag.ContinueOnError)
+	output := flags.String("output", "", "zip file to write (default: quota-debug-<time>.zip)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *output == "" {
+		*output = fmt.Sprintf("quota-debug-%s.zip", clockNow().Format("20060102-150405"))
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := writeDebugBundle(context.Background(), file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Wrote %s; check it for anything private before attaching it to a bug report\n", *output)
+	return nil
+}
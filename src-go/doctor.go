@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Doctor check outcomes
+const (
+	DoctorOK   = "ok"
+	DoctorWarn = "warn"
+	DoctorFail = "fail"
+	DoctorSkip = "skip"
+)
+
+// Clock difference from a server's Date header beyond which time windows and token expiry
+// become unreliable
+const maxClockSkew = time.Minute
+
+// DoctorCheck is the outcome of one doctor check, with a fix when it did not pass
+type DoctorCheck struct {
+	Name   string
+	Status string
+	Detail string
+	Fix    string
+}
+
+// doctorProvider describes how to tell whether a provider is configured and how to fix it
+type doctorProvider struct {
+	configured func(config *Config) bool
+	endpoint   func(config *Config) string
+	fix        string
+}
+
+// zaiEndpoint returns the Z.ai/ZHIPU base domain, or "" when it is not configured
+func zaiEndpoint(*Config) string {
+	_, baseDomain, _, err := zaiCredentials(context.Background())
+	if err != nil {
+		return ""
+	}
+	return baseDomain
+}
+
+// doctorProviders maps every provider to its configuration check, endpoint, and fix
+var doctorProviders = map[string]doctorProvider{
+	ProviderAntigravity: {
+		configured: func(c *Config) bool {
+			_, err := os.Stat(c.AccountFile)
+			return c.AntigravityToken != nil || err == nil
+		},
+		endpoint: func(c *Config) string { return c.APIURL },
+		fix:      "Create the account file named by ACCOUNT_FILE (see .env.example) and set CLIENT_ID and CLIENT_SECRET, or set ANTIGRAVITY_TOKEN",
+	},
+	ProviderGLM: {
+		configured: func(*Config) bool { _, _, _, err := zaiCredentials(context.Background()); return err == nil },
+		endpoint:   zaiEndpoint,
+		fix:        "Set ZAI_AUTH_TOKEN or ZHIPU_AUTH_TOKEN, and ZAI_ANTHROPIC_BASE_URL for another base URL (a gateway needs ZAI_PLATFORM)",
+	},
+	ProviderZhipuBalance: {
+		configured: func(*Config) bool { _, _, _, err := zaiCredentials(context.Background()); return err == nil },
+		endpoint:   zaiEndpoint,
+		fix:        "Use a pay-as-you-go API key in ZHIPU_AUTH_TOKEN or ZAI_AUTH_TOKEN, or check ZHIPU_BALANCE_PATH",
+	},
+	ProviderClaudeAI: {
+		configured: func(c *Config) bool { _, err := claudeSessionToken(context.Background(), c); return err == nil },
+		endpoint:   func(c *Config) string { return c.ClaudeUsageURL },
+		fix:        "Log in with Claude Code, or set CLAUDE_OAUTH_TOKEN or CLAUDE_CREDENTIALS_FILE",
+	},
+	ProviderCursor: {
+		configured: func(c *Config) bool { return c.CursorSessionToken != nil },
+		endpoint:   func(c *Config) string { return c.CursorUsageURL },
+		fix:        "Copy the WorkosCursorSessionToken cookie from cursor.com into CURSOR_SESSION_TOKEN",
+	},
+	ProviderWindsurf: {
+		configured: func(c *Config) bool { return c.WindsurfAPIKey != nil },
+		endpoint:   func(c *Config) string { return c.WindsurfStatusURL },
+		fix:        "Set WINDSURF_API_KEY to the key from your Windsurf profile",
+	},
+	ProviderXAI: {
+		configured: func(c *Config) bool { return c.XAIAPIKey != nil },
+		endpoint:   func(c *Config) string { return c.XAIBaseURL },
+		fix:        "Set XAI_API_KEY, and XAI_MANAGEMENT_KEY for prepaid credits",
+	},
+	ProviderGroq: {
+		configured: func(c *Config) bool { return c.GroqAPIKey != nil },
+		endpoint:   func(c *Config) string { return c.GroqBaseURL },
+		fix:        "Set GROQ_API_KEY",
+	},
+	ProviderMistral: {
+		configured: func(c *Config) bool { return c.MistralAPIKey != nil },
+		endpoint:   func(c *Config) string { return c.MistralBaseURL },
+		fix:        "Set MISTRAL_API_KEY",
+	},
+	ProviderLocal: {
+		configured: func(*Config) bool { return true },
+		endpoint:   func(c *Config) string { return ollamaBaseURL(c.OllamaHost) },
+		fix:        "Start Ollama or point OLLAMA_HOST at it; ignore this if you do not run local models",
+	},
+}
+
+// checkEnvFile reports whether the .env file exists and parses
+func checkEnvFile(path string) DoctorCheck {
+	check := DoctorCheck{Name: "env file"}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		check.Status, check.Detail = DoctorWarn, path+" not found; using the process environment only"
+		check.Fix = "Copy .env.example to " + path + " and fill in the providers you use"
+		return check
+	}
+	values, err := godotenv.Read(path)
+	if err != nil {
+		check.Status, check.Detail = DoctorFail, fmt.Sprintf("%s does not parse: %v", path, err)
+		check.Fix = "Fix the reported line; quote values that contain spaces or #"
+		return check
+	}
+	check.Status, check.Detail = DoctorOK, fmt.Sprintf("%s defines %d variables", path, len(values))
+	return check
+}
+
+// checkWritable reports whether files can be created in the directory of path
+func checkWritable(name, path string) DoctorCheck {
+	check := DoctorCheck{Name: name}
+	dir := filepath.Dir(path)
+	file, err := os.CreateTemp(dir, ".quota-doctor-*")
+	if err != nil {
+		check.Status, check.Detail = DoctorFail, fmt.Sprintf("cannot write to %s: %v", dir, err)
+		check.Fix = "Make " + dir + " writable by this user or point the setting elsewhere"
+		return check
+	}
+	file.Close()
+	os.Remove(file.Name())
+	check.Status, check.Detail = DoctorOK, dir+" is writable"
+	return check
+}
+
+// checkReachable sends a HEAD request to endpoint and measures the clock skew against the
+// response's Date header. Any HTTP response counts as reachable.
+func checkReachable(ctx context.Context, provider, endpoint string) (DoctorCheck, time.Duration, bool) {
+	check := DoctorCheck{Name: provider + " network"}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		check.Status, check.Detail = DoctorFail, fmt.Sprintf("invalid endpoint %q", endpoint)
+		check.Fix = "Fix the base URL setting for " + provider
+		return check, 0, false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.Scheme+"://"+u.Host, nil)
+	if err != nil {
+		check.Status, check.Detail = DoctorFail, err.Error()
+		return check, 0, false
+	}
+	resp, err := (&http.Client{Transport: httpTransport}).Do(req)
+	if err != nil {
+		check.Status, check.Detail = DoctorFail, fmt.Sprintf("%s unreachable: %v", u.Host, err)
+		check.Fix = "Check DNS, proxy (HTTPS_PROXY), and firewall access to " + u.Host
+		return check, 0, false
+	}
+	resp.Body.Close()
+
+	check.Status, check.Detail = DoctorOK, fmt.Sprintf("%s reachable (HTTP %d)", u.Host, resp.StatusCode)
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return check, 0, false
+	}
+	return check, clockNow().Sub(date), true
+}
+
+// checkClockSkew reports the largest difference between the local clock and server dates
+func checkClockSkew(skews []time.Duration) DoctorCheck {
+	check := DoctorCheck{Name: "clock skew"}
+	if len(skews) == 0 {
+		check.Status, check.Detail = DoctorSkip, "no server reported its time"
+		return check
+	}
+
+	worst := time.Duration(0)
+	for _, skew := range skews {
+		if skew.Abs() > worst.Abs() {
+			worst = skew
+		}
+	}
+	check.Detail = fmt.Sprintf("local clock is %s off", worst.Round(time.Second))
+	if worst.Abs() > maxClockSkew {
+		check.Status = DoctorWarn
+		check.Fix = "Enable NTP time sync; reset times and token expiry depend on the local clock"
+	} else {
+		check.Status = DoctorOK
+	}
+	return check
+}
+
+// runDoctor runs every check: the .env file, each provider's credentials, token, and
+// network reachability, the writability of files the server saves, and clock skew
+func runDoctor(ctx context.Context, envFile string) []DoctorCheck {
+	checks := []DoctorCheck{checkEnvFile(envFile)}
+	config := LoadConfig()
+	service := NewQuotaService(NewCloudCodeClient(config))
+
+	var skews []time.Duration
+	for _, provider := range providerNames() {
+		spec, ok := doctorProviders[provider]
+		if !ok {
+			continue
+		}
+		if !spec.configured(config) {
+			checks = append(checks, DoctorCheck{Name: provider, Status: DoctorSkip, Detail: "not configured"})
+			continue
+		}
+
+		network, skew, hasSkew := checkReachable(ctx, provider, spec.endpoint(config))
+		if hasSkew {
+			skews = append(skews, skew)
+		}
+
+		token := DoctorCheck{Name: provider + " token"}
+		if quota, err := service.fetchQuota(ctx, provider); err != nil {
+			token.Status, token.Detail, token.Fix = DoctorFail, err.Error(), spec.fix
+		} else {
+			token.Status, token.Detail = DoctorOK, fmt.Sprintf("%d models", len(quota.Models))
+		}
+
+		// Local models are optional, so an absent runtime is only a warning
+		if provider == ProviderLocal {
+			for _, check := range []*DoctorCheck{&network, &token} {
+				if check.Status == DoctorFail {
+					check.Status = DoctorWarn
+				}
+			}
+		}
+		checks = append(checks, network, token)
+	}
+
+	// The account file is rewritten when its token is refreshed
+	if _, err := os.Stat(config.AccountFile); err == nil {
+		checks = append(checks, checkWritable("account file directory", config.AccountFile))
+	}
+	if config.HistoryFile != "" {
+		checks = append(checks, checkWritable("history file directory", config.HistoryFile))
+	}
+	checks = append(checks, checkClockSkew(skews))
+	return checks
+}
+
+// printDoctorChecks prints one line per check with its fix, and reports whether any failed
+func printDoctorChecks(w io.Writer, checks []DoctorCheck) bool {
+	failed := false
+	for _, check := range checks {
+		fmt.Fprintf(w, "[%-4s] %s: %s\n", check.Status, check.Name, check.Detail)
+		if check.Fix != "" && (check.Status == DoctorWarn || check.Status == DoctorFail) {
+			fmt.Fprintf(w, "       fix: %s\n", check.Fix)
+		}
+		failed = failed || check.Status == DoctorFail
+	}
+	return failed
+}
+
+// runDoctorCommand runs the checks and fails when any check failed
+func runDoctorCommand(stdout io.Writer) error {
+	if printDoctorChecks(stdout, runDoctor(context.Background(), ".env")) {
+		return fmt.Errorf("some checks failed")
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// quotaGRPCServer implements the Quota gRPC service on top of QuotaService
+type quotaGRPCServer struct {
+	UnimplementedQuotaServer
+	service *QuotaService
+}
+
+// newQuotaSnapshot converts formatted quota into its protobuf message
+func newQuotaSnapshot(provider string, quota *FormattedQuota) *QuotaSnapshot {
+	if provider == "" {
+		provider = ProviderAntigravity
+	}
+
+	snapshot := &QuotaSnapshot{
+		Provider:    provider,
+		LastUpdated: quota.LastUpdated,
+		IsForbidden: quota.IsForbidden,
+	}
+	for _, model := range quota.Models {
+		snapshot.Models = append(snapshot.Models, &ModelQuota{
+			Name:              model.Name,
+			Percentage:        model.Percentage,
+			ResetTime:         model.ResetTime,
+			ResetTimeRelative: model.ResetTimeRelative,
+		})
+	}
+	return snapshot
+}
+
+// grpcError maps quota errors to gRPC status errors
+func grpcError(err error) error {
+	if errors.Is(err, ErrUnknownProvider) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Unavailable, err.Error())
+}
+
+// GetQuota returns the current quota snapshot for a provider
+func (g *quotaGRPCServer) GetQuota(ctx context.Context, req *GetQuotaRequest) (*QuotaSnapshot, error) {
+	g.service.activity.touch()
+	quota, err := g.service.fetchQuota(ctx, req.GetProvider())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return newQuotaSnapshot(req.GetProvider(), quota), nil
+}
+
+// WatchQuota streams a snapshot whenever any model's percentage changes
+func (g *quotaGRPCServer) WatchQuota(req *WatchQuotaRequest, stream Quota_WatchQuotaServer) error {
+	interval := time.Duration(req.GetIntervalSeconds()) * time.Second
+	if interval <= 0 {
+		interval = time.Duration(g.service.client.Config().QueryDebounce) * time.Minute
+	}
+
+	return g.service.watchQuota(stream.Context(), req.GetProvider(), interval,
+		func(quota *FormattedQuota) error {
+			return stream.Send(newQuotaSnapshot(req.GetProvider(), quota))
+		},
+		func(err error) error {
+			if errors.Is(err, ErrUnknownProvider) {
+				return grpcError(err)
+			}
+			log.Printf("WatchQuota refresh failed: %v", err)
+			return nil
+		},
+	)
+}
+
+// newGRPCServer creates a server for the Quota gRPC service, over TLS when tlsConfig is set
+func newGRPCServer(tlsConfig *tls.Config, service *QuotaService) *grpc.Server {
+	options := service.grpcTokenInterceptors()
+	if tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	server := grpc.NewServer(options...)
+	RegisterQuotaServer(server, &quotaGRPCServer{service: service})
+	return server
+}
+
+// startGRPCServer serves a gRPC server on the given address until it is stopped
+func startGRPCServer(address string, server *grpc.Server) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Starting gRPC server on %s", address)
+	return server.Serve(listener)
+}
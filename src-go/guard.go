@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// Commands guarded unless GUARD_COMMANDS is set, as a regular expression matching the
+	// command name
+	DefaultGuardCommands = "claude|aider|codex"
+
+	// Remaining percentage below which guard warns, unless GUARD_THRESHOLD is set
+	DefaultGuardThreshold = 5
+
+	// Longest wait for quota the cache cannot answer; the command runs unchecked after it
+	guardFetchTimeout = 2 * time.Second
+)
+
+// guardCommandName returns the program a shell command line runs, skipping environment
+// assignments such as FOO=1 and the directory of the program
+func guardCommandName(line string) string {
+	for _, word := range strings.Fields(line) {
+		if name, _, ok := strings.Cut(word, "="); ok && name != "" && !strings.ContainsAny(name, "/\\") {
+			continue
+		}
+		return filepath.Base(word)
+	}
+	return ""
+}
+
+// guardQuota returns a provider's quota from the prompt cache while it is within
+// PROMPT_MAX_AGE, so guarding a command rarely waits on the network, else from the server
+// or the provider within guardFetchTimeout
+func guardQuota(ctx context.Context, provider string) (*FormattedQuota, error) {
+	maxAge := time.Duration(getEnvAsInt("PROMPT_MAX_AGE", DefaultPromptMaxAge)) * time.Second
+	if dir := promptCacheDir(); dir != "" {
+		entry, err := readPromptCache(dir, provider)
+		if err == nil && entry.Quota != nil && clockNow().Sub(time.Unix(entry.Fetched, 0)) <= maxAge {
+			return entry.Quota, nil
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, guardFetchTimeout)
+	defer cancel()
+	return fetchQuotaPreferringDaemon(ctx, LoadConfig(), provider, getEnvOrDefault("DAEMON_MODE", daemonAuto))
+}
+
+// runGuardCommand warns on stderr before a command matching GUARD_COMMANDS starts while a
+// model has less than --threshold percent left, for shell preexec hooks. With --block it
+// asks on stdin whether to run the command anyway and fails when the answer is not yes.
+// Quota that cannot be fetched never stops the command.
+func runGuardCommand(args []string, stdin io.Reader, stderr io.Writer) error {
+	flags := flag.NewFlagSet("guard", flag.ContinueOnError)
+	threshold := flags.Float64("threshold", float64(getEnvAsInt("GUARD_THRESHOLD", DefaultGuardThreshold)), "remaining percentage to warn below")
+	provider := flags.String("provider", getEnvOrDefault("GUARD_PROVIDER", ProviderAntigravity), "provider whose quota the commands use")
+	model := flags.String("model", "*", "model name or glob pattern to check")
+	commands := flags.String("commands", getEnvOrDefault("GUARD_COMMANDS", DefaultGuardCommands), "regular expression of the command names to guard")
+	block := flags.Bool("block", false, "ask before running the command instead of only warning")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	pattern, err := regexp.Compile("^(?:" + *commands + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid --commands: %w", err)
+	}
+	// Without a command line every invocation is guarded, for aliases and wrapper scripts
+	commandLine := strings.Join(flags.Args(), " ")
+	name := guardCommandName(commandLine)
+	if commandLine != "" && !pattern.MatchString(name) {
+		return nil
+	}
+
+	config := &Config{Language: resolveLanguage(os.Getenv("OUTPUT_LANGUAGE"))}
+	printer := localizer(config.Language)
+	quota, err := guardQuota(context.Background(), *provider)
+	if err != nil {
+		fmt.Fprintln(stderr, printer.Sprintf(msgGuardUnavailable, *provider, err))
+		return nil
+	}
+	quota = &FormattedQuota{Models: quotaModels(quota.Models)}
+	err = checkRequiredQuota(config, *provider, quota, *model, *threshold)
+	var noModel *noModelError
+	if err == nil || errors.As(err, &noModel) {
+		return nil
+	}
+	fmt.Fprintln(stderr, printer.Sprintf(msgGuardWarning, err))
+	if !*block {
+		return nil
+	}
+
+	if name == "" {
+		name = printer.Sprintf(msgGuardThisCommand)
+	}
+	fmt.Fprint(stderr, printer.Sprintf(msgGuardConfirm, name))
+	answer, _ := bufio.NewReader(stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return errors.New(printer.Sprintf(msgGuardDeclined, name))
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// ProviderHealth describes how fresh and reliable a provider's data is
+type ProviderHealth struct {
+	Provider    string `json:"provider"`
+	LastSuccess int64  `json:"last_success,omitempty"`
+	LastError   string `json:"last_error,omitempty"`
+	LastErrorAt int64  `json:"last_error_at,omitempty"`
+	CacheHit    bool   `json:"cache_hit"`
+	LatencyMs   int64  `json:"latency_ms"`
+}
+
+// healthRegistry tracks ProviderHealth per provider
+type healthRegistry struct {
+	mu        sync.RWMutex
+	providers map[string]ProviderHealth
+}
+
+var providerHealth = &healthRegistry{
+	providers: make(map[string]ProviderHealth),
+}
+
+// recordFetch marks a successful upstream request and its latency
+func (h *healthRegistry) recordFetch(provider string, latency time.Duration) {
+	serverMetrics.recordFetch(provider, latency)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	health := h.providers[provider]
+	health.Provider = provider
+	health.LastSuccess = clockNow().Unix()
+	health.CacheHit = false
+	health.LatencyMs = latency.Milliseconds()
+	h.providers[provider] = health
+}
+
+// recordCacheHit marks that the current data was served from cache
+func (h *healthRegistry) recordCacheHit(provider string) {
+	serverMetrics.recordCacheHit(provider)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	health := h.providers[provider]
+	health.Provider = provider
+	health.CacheHit = true
+	h.providers[provider] = health
+}
+
+// recordError stores the most recent failure for a provider
+func (h *healthRegistry) recordError(provider string, err error) {
+	serverMetrics.recordError(provider, err)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	health := h.providers[provider]
+	health.Provider = provider
+	health.LastError = err.Error()
+	health.LastErrorAt = clockNow().Unix()
+	h.providers[provider] = health
+}
+
+// get returns a copy of a provider's health
+func (h *healthRegistry) get(provider string) ProviderHealth {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	health := h.providers[provider]
+	health.Provider = provider
+	return health
+}
+
+// all returns a copy of every provider's health
+func (h *healthRegistry) all() map[string]ProviderHealth {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	result := make(map[string]ProviderHealth, len(h.providers))
+	for provider, health := range h.providers {
+		result[provider] = health
+	}
+	return result
+}
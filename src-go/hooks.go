@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hook events passed to commands as QUOTA_EVENT
+const (
+	HookEventBelow   = "below"
+	HookEventRecover = "recover"
+	HookEventEmpty   = "empty"
+	HookEventAnomaly = "anomaly"
+)
+
+// Longest a hook command may run before it is killed
+const hookTimeout = 30 * time.Second
+
+// HookEvent describes a threshold crossing, or a reminder that a model is still below
+type HookEvent struct {
+	Event      string
+	Provider   string
+	Model      string
+	Percentage float64
+	Threshold  int
+	ResetTime  string
+	Warning    string
+	Repeat     bool
+}
+
+// env returns the event as QUOTA_* environment variables
+func (e HookEvent) env() []string {
+	return []string{
+		"QUOTA_EVENT=" + e.Event,
+		"QUOTA_PROVIDER=" + e.Provider,
+		"QUOTA_MODEL=" + e.Model,
+		"QUOTA_PERCENTAGE=" + formatPercentage(e.Percentage),
+		fmt.Sprintf("QUOTA_THRESHOLD=%d", e.Threshold),
+		"QUOTA_RESET_TIME=" + e.ResetTime,
+		"QUOTA_WARNING=" + e.Warning,
+		"QUOTA_REPEAT=" + strconv.FormatBool(e.Repeat),
+	}
+}
+
+// HookRunner runs the configured shell commands and notifiers when a model's alert state
+// changes. A model seen below the threshold for the first time fires too, so agents pause
+// on startup.
+type HookRunner struct {
+	mu        sync.Mutex
+	alerts    *alertMachine
+	anomalous map[string]bool
+
+	// run executes a hook command; replaced in tests
+	run func(command string, event HookEvent)
+
+	// mail sends the events listed in EMAIL_ALERTS
+	mail *emailNotifier
+
+	// phone pushes the events listed in NOTIFY_EVENTS to ntfy and Pushover
+	phone *pushNotifier
+
+	// incidents opens and resolves PagerDuty and Opsgenie incidents
+	incidents *incidentManager
+}
+
+// NewHookRunner creates a hook runner that executes commands in the system shell
+func NewHookRunner() *HookRunner {
+	return &HookRunner{
+		alerts:    newAlertMachine(),
+		anomalous: make(map[string]bool),
+		run:       runHookCommand,
+		mail:      newEmailNotifier(),
+		phone:     newPushNotifier(),
+		incidents: newIncidentManager(),
+	}
+}
+
+// hooksEnabled reports whether any hook command, email or push alert, or incident
+// integration is configured
+func hooksEnabled(config *Config) bool {
+	commands := config.HookOnBelow != "" || config.HookOnRecover != "" || config.HookOnEmpty != "" || config.HookOnAnomaly != ""
+	return commands || (emailEnabled(config) && len(config.EmailAlerts) > 0) || pushEnabled(config) || incidentsEnabled(config)
+}
+
+// observe moves each model through the alert state machine and runs hooks and notifiers for
+// the transitions and repeats, and for models whose burn rate has just become anomalous
+func (h *HookRunner) observe(config *Config, provider string, quota *FormattedQuota) {
+	h.incidents.observe(config, provider)
+
+	now := clockNow()
+	var events []HookEvent
+	h.mu.Lock()
+	for _, model := range quota.Models {
+		if len(config.HookModels) > 0 && !matchesAnyGlob(model.Name, config.HookModels) {
+			continue
+		}
+
+		key := burnKey(provider, model.Name)
+		if anomalous := model.Warning != ""; anomalous != h.anomalous[key] {
+			h.anomalous[key] = anomalous
+			if anomalous {
+				log.Printf("Warning: %s/%s is %s", provider, model.Name, model.Warning)
+				events = append(events, HookEvent{
+					Event:      HookEventAnomaly,
+					Provider:   provider,
+					Model:      model.Name,
+					Percentage: model.Percentage,
+					Threshold:  config.HookThreshold,
+					ResetTime:  model.ResetTime,
+					Warning:    model.Warning,
+				})
+			}
+		}
+
+		transition, notify := h.alerts.update(config, key, model.Percentage, now)
+		if !notify {
+			continue
+		}
+		events = append(events, HookEvent{
+			Event:      transition.event(),
+			Provider:   provider,
+			Model:      model.Name,
+			Percentage: model.Percentage,
+			Threshold:  config.HookThreshold,
+			ResetTime:  model.ResetTime,
+			Repeat:     transition.repeat,
+		})
+	}
+	h.mu.Unlock()
+
+	silenced := len(events) > 0 && alertsSilenced(config, now)
+	for _, event := range events {
+		command := map[string]string{
+			HookEventBelow:   config.HookOnBelow,
+			HookEventRecover: config.HookOnRecover,
+			HookEventEmpty:   config.HookOnEmpty,
+			HookEventAnomaly: config.HookOnAnomaly,
+		}[event.Event]
+		// A model that empties without a dedicated hook is still below the threshold
+		if command == "" && event.Event == HookEventEmpty {
+			command = config.HookOnBelow
+		}
+		if command != "" {
+			go h.run(command, event)
+		}
+		if silenced {
+			log.Printf("Silenced %s alert for %s/%s", event.Event, event.Provider, event.Model)
+		} else {
+			if h.mail != nil {
+				go h.mail.alert(config, event)
+			}
+			if h.phone != nil {
+				go h.phone.alert(config, event)
+			}
+		}
+		if h.incidents != nil {
+			h.incidents.alert(config, event)
+		}
+	}
+}
+
+// runHookCommand runs a hook in the system shell with the event in its environment
+func runHookCommand(command string, event HookEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, command)
+	cmd.Env = append(os.Environ(), event.env()...)
+
+	log.Printf("Running %s hook for %s/%s at %s%%", event.Event, event.Provider, event.Model, formatPercentage(event.Percentage))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		log.Printf("%s hook failed: %v: %s", event.Event, err, strings.TrimSpace(string(output)))
+	}
+}
+
+// pollHooks fetches every provider each QUERY_DEBOUNCE interval while any hook is configured,
+// so hooks fire even when no client is polling the server. With IDLE_POLL_INTERVAL set, it
+// polls at that slower interval while clients are idle, and a returning client brings the
+// next refresh forward to the QUERY_DEBOUNCE schedule.
+func (s *QuotaService) pollHooks(ctx context.Context) {
+	var due time.Time
+	for {
+		start := clockNow()
+		config := s.client.Config()
+		if hooksEnabled(config) {
+			if !due.IsZero() {
+				serverMetrics.recordRefresh(start.Sub(due))
+			}
+			for _, provider := range providerNames() {
+				// Providers without credentials fail and are skipped
+				s.fetchQuota(ctx, provider)
+			}
+		}
+
+		interval := pollInterval(config, s.activity.idle())
+		serverMetrics.recordPollInterval(interval)
+		// Slow fetches delay the next refresh, so they count as lag
+		due = start.Add(interval)
+		if !s.waitForPoll(ctx, config, start, interval) {
+			return
+		}
+	}
+}
+
+// waitForPoll waits out a poll interval that started at start, shortening a slow idle
+// interval when a client makes a request. It returns false when ctx is done.
+func (s *QuotaService) waitForPoll(ctx context.Context, config *Config, start time.Time, interval time.Duration) bool {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timer.C:
+			return true
+		case <-s.activity.wake:
+			if active := pollInterval(config, 0); active < interval {
+				interval = active
+				timer.Reset(max(start.Add(interval).Sub(clockNow()), 0))
+			}
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Response bytes logged per exchange unless DEBUG_HTTP_BODY is set
+const defaultTraceBodySize = 1024
+
+// sensitiveJSONField matches a JSON string member, so secrets can be redacted in bodies
+// that are truncated or otherwise not valid JSON
+var sensitiveJSONField = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"(\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+// traceTimings are the connection phases of one request
+type traceTimings struct {
+	mu                          sync.Mutex
+	dnsStart, connectStart      time.Time
+	tlsStart                    time.Time
+	dns, connect, tls, response time.Duration
+	reused                      bool
+	remote                      string
+}
+
+// clientTrace records the phase durations of a request relative to start
+func (t *traceTimings) clientTrace(start time.Time) *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			t.dnsStart = time.Now()
+			t.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			t.dns = time.Since(t.dnsStart)
+			t.mu.Unlock()
+		},
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			t.connectStart = time.Now()
+			t.mu.Unlock()
+		},
+		ConnectDone: func(string, string, error) {
+			t.mu.Lock()
+			t.connect = time.Since(t.connectStart)
+			t.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			t.tlsStart = time.Now()
+			t.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			t.tls = time.Since(t.tlsStart)
+			t.mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.reused = info.Reused
+			t.remote = info.Conn.RemoteAddr().String()
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.response = time.Since(start)
+			t.mu.Unlock()
+		},
+	}
+}
+
+// String lists the address and the phases that took place, e.g. "203.0.113.7:443, dns 12ms,
+// connect 80ms, tls 150ms, first byte 410ms"
+func (t *traceTimings) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var phases []string
+	if t.remote != "" {
+		phases = append(phases, t.remote)
+	}
+	if t.reused {
+		phases = append(phases, "reused connection")
+	}
+	for _, phase := range []struct {
+		name     string
+		duration time.Duration
+	}{{"dns", t.dns}, {"connect", t.connect}, {"tls", t.tls}, {"first byte", t.response}} {
+		if phase.duration > 0 {
+			phases = append(phases, phase.name+" "+phase.duration.Round(time.Millisecond).String())
+		}
+	}
+	return strings.Join(phases, ", ")
+}
+
+// tracingTransport logs each request with redacted headers, its status, connection timings,
+// and the start of the response body, for --debug-http
+type tracingTransport struct {
+	base    http.RoundTripper
+	logger  *log.Logger
+	maxBody int
+}
+
+// RoundTrip performs the request and logs it
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var lines []string
+	lines = append(lines, fmt.Sprintf("--> %s %s", req.Method, redactURL(req.URL)))
+	lines = append(lines, traceHeaders(req.Header)...)
+
+	timings := &traceTimings{}
+	start := time.Now()
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), timings.clientTrace(start)))
+	resp, err := t.base.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		lines = append(lines, fmt.Sprintf("<-- error after %s (%s): %v", elapsed, timings, err))
+		t.logger.Print(strings.Join(lines, "\n"))
+		return resp, err
+	}
+
+	lines = append(lines, fmt.Sprintf("<-- %s in %s (%s)", resp.Status, elapsed, timings))
+	lines = append(lines, traceHeaders(resp.Header)...)
+	if t.maxBody > 0 {
+		// The logged part is read ahead and put back in front of the rest of the body
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, int64(t.maxBody)+1))
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+
+		text := string(body[:min(len(body), t.maxBody)])
+		if len(body) > t.maxBody {
+			text += " ... (truncated)"
+		}
+		if text != "" {
+			lines = append(lines, "    "+redactBodyText(text))
+		}
+	}
+	t.logger.Print(strings.Join(lines, "\n"))
+	return resp, nil
+}
+
+// traceHeaders formats headers one per line in name order, with secrets redacted
+func traceHeaders(header http.Header) []string {
+	redactedHeaders := redactHeaders(header)
+	lines := make([]string, 0, len(redactedHeaders))
+	for _, name := range sortedKeys(redactedHeaders) {
+		lines = append(lines, fmt.Sprintf("    %s: %s", name, strings.Join(redactedHeaders[name], ", ")))
+	}
+	return lines
+}
+
+// redactBodyText redacts the string values of sensitive JSON keys in a possibly truncated body
+func redactBodyText(text string) string {
+	return sensitiveJSONField.ReplaceAllStringFunc(text, func(field string) string {
+		match := sensitiveJSONField.FindStringSubmatch(field)
+		if !sensitiveName.MatchString(match[1]) {
+			return field
+		}
+		return `"` + match[1] + `"` + match[2] + `"` + redacted + `"`
+	})
+}
+
+// debugHTTPFromArgs removes a "--debug-http" or "--debug-http=file" option from the
+// arguments and returns where to write the trace: "-" for stderr, a file path, or "" when
+// tracing is off. DEBUG_HTTP applies when the option is absent.
+func debugHTTPFromArgs(args []string) (string, []string) {
+	target := os.Getenv("DEBUG_HTTP")
+	switch strings.ToLower(target) {
+	case "1", "true", "stderr":
+		target = "-"
+	case "0", "false":
+		target = ""
+	}
+
+	rest := make([]string, 0, len(args))
+	for _, arg := range args {
+		switch {
+		case arg == "--debug-http":
+			target = "-"
+		case strings.HasPrefix(arg, "--debug-http="):
+			target = strings.TrimPrefix(arg, "--debug-http=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return target, rest
+}
+
+// enableHTTPTrace routes every provider HTTP client through a tracingTransport writing to
+// stderr or appending to a file. DEBUG_HTTP_BODY sets how many response bytes are logged,
+// 0 for none.
+func enableHTTPTrace(target string) error {
+	var out io.Writer = os.Stderr
+	if target != "-" {
+		file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return err
+		}
+		out = file
+	}
+
+	maxBody := defaultTraceBodySize
+	if value := os.Getenv("DEBUG_HTTP_BODY"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return fmt.Errorf("DEBUG_HTTP_BODY must be a number of bytes, got %q", value)
+		}
+		maxBody = parsed
+	}
+
+	base := httpTransport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	httpTransport = &tracingTransport{
+		base:    base,
+		logger:  log.New(out, "http: ", log.LstdFlags|log.Lmicroseconds),
+		maxBody: maxBody,
+	}
+	return nil
+}
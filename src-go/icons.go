@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// IconRule gives the models matching a glob, or the models of a provider, an icon
+type IconRule struct {
+	Pattern string
+	Icon    string
+}
+
+// parseIcons parses "pattern=icon,..." pairs in order, so an earlier glob wins
+func parseIcons(value string) []IconRule {
+	var rules []IconRule
+	for pair := range strings.SplitSeq(trimQuotes(value), ",") {
+		pattern, icon, found := strings.Cut(pair, "=")
+		pattern = strings.TrimSpace(pattern)
+		icon = strings.TrimSpace(icon)
+		if found && pattern != "" && icon != "" {
+			rules = append(rules, IconRule{Pattern: pattern, Icon: icon})
+		}
+	}
+	return rules
+}
+
+// isASCII reports whether text needs no emoji or Nerd Font glyphs to display
+func isASCII(text string) bool {
+	for i := 0; i < len(text); i++ {
+		if text[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// modelIcon returns the icon of a model: the first ICONS rule matching its name as a glob
+// or naming its provider, else fallback. With ASCII_OUTPUT, an icon outside ASCII gives
+// way to asciiFallback.
+func modelIcon(config *Config, model FormattedModel, fallback, asciiFallback string) string {
+	icon := fallback
+	for _, rule := range config.Icons {
+		if rule.Pattern == model.Provider || matchesAnyGlob(model.Name, []string{rule.Pattern}) {
+			icon = rule.Icon
+			break
+		}
+	}
+	if config.ASCIIOutput && !isASCII(icon) {
+		return asciiFallback
+	}
+	return icon
+}
+
+// withIcon prefixes text with the model's configured icon, if it has one
+func withIcon(config *Config, model FormattedModel, text string) string {
+	if icon := modelIcon(config, model, "", ""); icon != "" {
+		return icon + " " + text
+	}
+	return text
+}
+
+// asciiFromArgs removes an "--ascii" option from the arguments and sets ASCII_OUTPUT, so
+// every renderer of the command sees it
+func asciiFromArgs(args []string) []string {
+	rest := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "--ascii" {
+			os.Setenv("ASCII_OUTPUT", "true")
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return rest
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// DetectedSetting is a credential found in another tool's configuration, and where
+type DetectedSetting struct {
+	Name   string
+	Value  string
+	Source string
+}
+
+// agentDetectors find the provider credentials each agent CLI is configured with, given the
+// home directory, in order of precedence
+var agentDetectors = []func(home string) []DetectedSetting{
+	detectClaudeCode,
+	detectAider,
+	detectOpenHands,
+	detectCodex,
+}
+
+// credentialHosts maps API hosts to the variable holding their key
+var credentialHosts = map[string]string{
+	"api.z.ai":         "ZAI_AUTH_TOKEN",
+	"open.bigmodel.cn": "ZHIPU_AUTH_TOKEN",
+	"api.groq.com":     "GROQ_API_KEY",
+	"api.mistral.ai":   "MISTRAL_API_KEY",
+	"api.x.ai":         "XAI_API_KEY",
+}
+
+// credentialPrefixes maps LiteLLM model prefixes and aider api-key providers to the variable
+// holding their key
+var credentialPrefixes = map[string]string{
+	"groq":    "GROQ_API_KEY",
+	"mistral": "MISTRAL_API_KEY",
+	"xai":     "XAI_API_KEY",
+}
+
+// credentialName returns the variable a key for the model or base URL belongs in, or "" for
+// providers without quota support
+func credentialName(model, baseURL string) string {
+	if u, err := url.Parse(baseURL); err == nil && u.Hostname() != "" {
+		return credentialHosts[u.Hostname()]
+	}
+	prefix, _, _ := strings.Cut(model, "/")
+	return credentialPrefixes[prefix]
+}
+
+// detectClaudeCode reads the env block of Claude Code's settings, where GLM Coding Plan users
+// point ANTHROPIC_BASE_URL at Z.ai or ZHIPU
+func detectClaudeCode(home string) []DetectedSetting {
+	path := filepath.Join(home, ".claude", "settings.json")
+	var settings struct {
+		Env map[string]string `json:"env"`
+	}
+	if data, err := os.ReadFile(path); err != nil || json.Unmarshal(data, &settings) != nil {
+		return nil
+	}
+	name := credentialName("", settings.Env["ANTHROPIC_BASE_URL"])
+	if name == "" || settings.Env["ANTHROPIC_AUTH_TOKEN"] == "" {
+		return nil
+	}
+	return []DetectedSetting{{name, settings.Env["ANTHROPIC_AUTH_TOKEN"], path}}
+}
+
+// detectAider reads .aider.conf.yml in the home and current directories: the OpenAI-compatible
+// key with its base URL, "provider=key" api-key entries, and "NAME=value" set-env entries
+func detectAider(home string) []DetectedSetting {
+	var found []DetectedSetting
+	for _, path := range []string{filepath.Join(home, ".aider.conf.yml"), ".aider.conf.yml"} {
+		values, err := readSimpleYAML(path)
+		if err != nil {
+			continue
+		}
+		if key := firstValue(values["openai-api-key"]); key != "" {
+			if name := credentialName("", firstValue(values["openai-api-base"])); name != "" {
+				found = append(found, DetectedSetting{name, key, path})
+			}
+		}
+		for _, entry := range values["api-key"] {
+			provider, key, _ := strings.Cut(entry, "=")
+			if name := credentialPrefixes[strings.TrimSpace(provider)]; name != "" && key != "" {
+				found = append(found, DetectedSetting{name, strings.TrimSpace(key), path})
+			}
+		}
+		for _, entry := range values["set-env"] {
+			name, value, _ := strings.Cut(entry, "=")
+			if isCredentialName(name) && value != "" {
+				found = append(found, DetectedSetting{name, value, path})
+			}
+		}
+	}
+	return found
+}
+
+// detectOpenHands reads the LLM settings saved by the OpenHands UI and its config.toml
+func detectOpenHands(home string) []DetectedSetting {
+	var found []DetectedSetting
+	path := filepath.Join(home, ".openhands", "settings.json")
+	var settings struct {
+		Model   string `json:"llm_model"`
+		APIKey  string `json:"llm_api_key"`
+		BaseURL string `json:"llm_base_url"`
+	}
+	if data, err := os.ReadFile(path); err == nil && json.Unmarshal(data, &settings) == nil {
+		if name := credentialName(settings.Model, settings.BaseURL); name != "" && settings.APIKey != "" {
+			found = append(found, DetectedSetting{name, settings.APIKey, path})
+		}
+	}
+	path = filepath.Join(home, ".openhands", "config.toml")
+	if sections, err := readSimpleTOML(path); err == nil {
+		llm := sections["llm"]
+		if name := credentialName(llm["model"], llm["base_url"]); name != "" && llm["api_key"] != "" {
+			found = append(found, DetectedSetting{name, llm["api_key"], path})
+		}
+	}
+	return found
+}
+
+// detectCodex reads the model providers of the Codex CLI. Codex keeps their keys in the
+// variables named by env_key, so a key is only found when that variable is set.
+func detectCodex(home string) []DetectedSetting {
+	path := filepath.Join(home, ".codex", "config.toml")
+	sections, err := readSimpleTOML(path)
+	if err != nil {
+		return nil
+	}
+	var found []DetectedSetting
+	for section, values := range sections {
+		if !strings.HasPrefix(section, "model_providers.") {
+			continue
+		}
+		name := credentialName("", values["base_url"])
+		if key := os.Getenv(values["env_key"]); name != "" && values["env_key"] != "" && key != "" {
+			found = append(found, DetectedSetting{name, key, path + " (" + values["env_key"] + ")"})
+		}
+	}
+	return found
+}
+
+// readSimpleYAML reads the top-level "key: value" pairs and "key:" lists of "- item" lines
+// that agent configs use, with inline [a, b] lists; nested maps are not supported
+func readSimpleYAML(path string) (map[string][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := make(map[string][]string)
+	key := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if item, ok := strings.CutPrefix(line, "- "); ok {
+			if key != "" {
+				values[key] = append(values[key], trimQuotes(strings.TrimSpace(item)))
+			}
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if list, ok := strings.CutPrefix(value, "["); ok {
+			for _, item := range strings.Split(strings.TrimSuffix(list, "]"), ",") {
+				values[key] = append(values[key], trimQuotes(strings.TrimSpace(item)))
+			}
+		} else if value != "" {
+			values[key] = []string{trimQuotes(value)}
+		}
+	}
+	return values, scanner.Err()
+}
+
+// readSimpleTOML reads "key = value" pairs by [section], "" for those before the first
+// section; arrays and inline tables are kept as written
+func readSimpleTOML(path string) (map[string]map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sections := map[string]map[string]string{"": {}}
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.Trim(line, "[] ")
+			if sections[section] == nil {
+				sections[section] = make(map[string]string)
+			}
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			sections[section][strings.TrimSpace(key)] = trimQuotes(strings.TrimSpace(value))
+		}
+	}
+	return sections, scanner.Err()
+}
+
+// firstValue returns the first of a YAML key's values, or ""
+func firstValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// isCredentialName reports whether a variable holds a key of a provider with quota support
+func isCredentialName(name string) bool {
+	for _, known := range credentialHosts {
+		if name == known {
+			return true
+		}
+	}
+	return false
+}
+
+// detectAgentSettings returns the credentials of every detected agent CLI, the first found
+// for each variable
+func detectAgentSettings(home string) []DetectedSetting {
+	seen := make(map[string]bool)
+	var settings []DetectedSetting
+	for _, detect := range agentDetectors {
+		for _, setting := range detect(home) {
+			if !seen[setting.Name] {
+				seen[setting.Name] = true
+				settings = append(settings, setting)
+			}
+		}
+	}
+	return settings
+}
+
+// runInitCommand runs the setup wizard, or with --auto writes the provider credentials found
+// in agent CLI configurations to the env file, leaving variables that are already set alone.
+// Values are never printed.
+func runInitCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("init", flag.ContinueOnError)
+	auto := flags.Bool("auto", false, "detect credentials from Claude Code, aider, OpenHands, and Codex CLI configurations")
+	envFile := flags.String("env-file", ".env", "file to add the variables to")
+	dryRun := flags.Bool("dry-run", false, "with --auto, print what would be added without writing it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if !*auto {
+		return newSetupWizard(stdin, stdout, *envFile).run(context.Background())
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+
+	existing, err := readEnvFile(*envFile)
+	if err != nil {
+		return err
+	}
+	var added []DetectedSetting
+	for _, setting := range detectAgentSettings(home) {
+		if existing[setting.Name] != "" || os.Getenv(setting.Name) != "" {
+			fmt.Fprintf(stdout, "Skipping %s from %s: already set\n", setting.Name, setting.Source)
+			continue
+		}
+		fmt.Fprintf(stdout, "Found %s in %s\n", setting.Name, setting.Source)
+		added = append(added, setting)
+	}
+	if len(added) == 0 {
+		fmt.Fprintln(stdout, "No new provider credentials found in agent CLI configurations")
+		return nil
+	}
+	if *dryRun {
+		fmt.Fprintf(stdout, "Would add %d variables to %s\n", len(added), *envFile)
+		return nil
+	}
+	if err := appendEnvFile(*envFile, "Detected by init --auto", added); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Added %d variables to %s; run doctor to check them\n", len(added), *envFile)
+	return nil
+}
+
+// readEnvFile returns the variables of an env file, none when it does not exist
+func readEnvFile(path string) (map[string]string, error) {
+	values, err := godotenv.Read(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// appendEnvFile adds the settings to an env file under a comment, creating it readable by
+// the owner only
+func appendEnvFile(path, comment string, settings []DetectedSetting) error {
+	var block strings.Builder
+	fmt.Fprintf(&block, "\n# %s\n", comment)
+	for _, setting := range settings {
+		fmt.Fprintf(&block, "%s=%q\n", setting.Name, setting.Value)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(block.String()); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// setEnvFileValue sets a variable in an env file, replacing its lines or adding one at the
+// end, and creates the file readable by the owner only
+func setEnvFileValue(path, name, value string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	setting := fmt.Sprintf("%s=%q", name, value)
+	var lines []string
+	if content := strings.TrimRight(string(data), "\n"); content != "" {
+		lines = strings.Split(content, "\n")
+	}
+	replaced := false
+	for i, line := range lines {
+		key, _, ok := strings.Cut(strings.TrimPrefix(strings.TrimSpace(line), "export "), "=")
+		if ok && strings.TrimSpace(key) == name {
+			lines[i] = setting
+			replaced = true
+		}
+	}
+	if !replaced {
+		lines = append(lines, setting)
+	}
+	return writeFileAtomic(path, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// currencySymbols maps ISO currency codes to their symbol
+var currencySymbols = map[string]string{
+	"CNY": "¥",
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+}
+
+// Languages that write the currency symbol after the amount, e.g. "1.234,50 €"
+var trailingSymbolLanguages = map[string]bool{
+	"de": true, "fr": true, "es": true, "it": true, "pt": true,
+	"ru": true, "pl": true, "cs": true, "sv": true, "fi": true,
+}
+
+// resolveNumberLocale parses a locale such as "de-DE" or "zh_CN.UTF-8", falling back to
+// LC_ALL, LC_NUMERIC, and LANG, then English
+func resolveNumberLocale(value string) language.Tag {
+	candidates := []string{trimQuotes(value), os.Getenv("LC_ALL"), os.Getenv("LC_NUMERIC"), os.Getenv("LANG")}
+	for _, candidate := range candidates {
+		if tag, ok := parseLocale(candidate); ok {
+			return tag
+		}
+	}
+	return language.English
+}
+
+// parseLocale parses a POSIX or BCP 47 locale, ignoring the C and POSIX locales
+func parseLocale(value string) (language.Tag, bool) {
+	// Drop the encoding and modifier, e.g. "zh_CN.UTF-8@pinyin"
+	value, _, _ = strings.Cut(value, ".")
+	value, _, _ = strings.Cut(value, "@")
+	if value == "" || value == "C" || value == "POSIX" {
+		return language.Und, false
+	}
+	tag, err := language.Parse(strings.ReplaceAll(value, "_", "-"))
+	return tag, err == nil
+}
+
+// formatNumber renders a count with the locale's thousands separators
+func formatNumber(locale language.Tag, n int64) string {
+	return message.NewPrinter(locale).Sprintf("%d", n)
+}
+
+// formatCurrency renders an amount with the locale's separators and its currency symbol,
+// or the ISO code when there is no symbol
+func formatCurrency(locale language.Tag, amount float64, code string) string {
+	code = strings.ToUpper(code)
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	digits := message.NewPrinter(locale).Sprintf("%.2f", amount)
+
+	symbol, ok := currencySymbols[code]
+	if !ok {
+		return sign + digits + " " + code
+	}
+	if base, _ := locale.Base(); trailingSymbolLanguages[base.String()] {
+		return sign + digits + " " + symbol
+	}
+	return sign + symbol + digits
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// MCPToolUsage is one tool's share of the monthly MCP call pool
+type MCPToolUsage struct {
+	Tool       string  `json:"tool"`
+	Calls      int     `json:"calls"`
+	Percentage float64 `json:"percentage"`
+}
+
+// MCPUsage is the monthly MCP call pool and its use per tool. The pool is shared, so
+// Remaining applies to all tools together.
+type MCPUsage struct {
+	Used       int            `json:"used"`
+	Total      int            `json:"total"`
+	Remaining  int            `json:"remaining"`
+	Percentage float64        `json:"percentage"`
+	Tools      []MCPToolUsage `json:"tools"`
+}
+
+// mcpUsage breaks down the monthly MCP limit by tool, most used first. Every tool is listed,
+// including ZAI_EXCLUDED_TOOLS and tools added after this release; calls no tool accounts
+// for are listed under an empty name.
+func mcpUsage(limits ProcessedZAILimit, precision int) (MCPUsage, bool) {
+	for _, limit := range limits.Limits {
+		if limit.Type != LimitMCPMonthly {
+			continue
+		}
+
+		usage := MCPUsage{
+			Used:       limit.CurrentUsage,
+			Total:      limit.Total,
+			Remaining:  max(limit.Total-limit.CurrentUsage, 0),
+			Percentage: roundPercentage(limit.Percentage, precision),
+		}
+		share := func(calls int) float64 {
+			if limit.Total <= 0 {
+				return 0
+			}
+			return roundPercentage(float64(calls)/float64(limit.Total)*100, precision)
+		}
+
+		attributed := 0
+		for _, detail := range limit.UsageDetails {
+			usage.Tools = append(usage.Tools, MCPToolUsage{Tool: detail.ModelCode, Calls: detail.Usage, Percentage: share(detail.Usage)})
+			attributed += detail.Usage
+		}
+		sort.SliceStable(usage.Tools, func(i, j int) bool { return usage.Tools[i].Calls > usage.Tools[j].Calls })
+		if other := limit.CurrentUsage - attributed; other > 0 {
+			usage.Tools = append(usage.Tools, MCPToolUsage{Calls: other, Percentage: share(other)})
+		}
+		return usage, true
+	}
+	return MCPUsage{}, false
+}
+
+// writeMCPTable prints the pool summary and a table of tools
+func writeMCPTable(w io.Writer, config *Config, usage MCPUsage) error {
+	printer := localizer(config.Language)
+	count := func(n int) string { return formatNumber(config.NumberLocale, int64(n)) }
+
+	fmt.Fprintln(w, printer.Sprintf(msgMCPPool, count(usage.Used), count(usage.Total), count(usage.Remaining), formatPercentage(usage.Percentage)))
+	fmt.Fprintln(w)
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, printer.Sprintf(msgMCPHeader))
+	for _, tool := range usage.Tools {
+		name := tool.Tool
+		if name == "" {
+			name = printer.Sprintf(msgMCPOther)
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s%%\n", name, count(tool.Calls), formatPercentage(tool.Percentage))
+	}
+	return table.Flush()
+}
+
+// runMCPCommand prints the monthly GLM MCP pool with the calls of every tool
+func runMCPCommand(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("mcp", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the pool and tools as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	_, baseDomain, authToken, err := zaiCredentials(ctx)
+	if err != nil {
+		return err
+	}
+	limits, err := fetchGLMLimits(ctx, baseDomain, "", authToken)
+	if err != nil {
+		return err
+	}
+
+	config := LoadConfig()
+	usage, ok := mcpUsage(limits, config.PercentagePrecision)
+	if !ok {
+		return errors.New(localizer(config.Language).Sprintf(msgMCPNoLimit))
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(usage)
+	}
+	return writeMCPTable(stdout, config, usage)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// mistralRateLimits maps Mistral's per-minute and per-month token limit headers to models
+var mistralRateLimits = []rateLimitSpec{
+	{name: "mistral-tokens-minute", kind: "tokens-minute"},
+	{name: "mistral-tokens-month", kind: "tokens-month"},
+}
+
+// GetMistralUsage gets the token limits of the configured Mistral API key
+func GetMistralUsage(ctx context.Context) (FormattedQuota, error) {
+	config := LoadConfig()
+	apiKey, err := readToken(ctx, config.MistralAPIKey)
+	if err != nil {
+		return FormattedQuota{}, fmt.Errorf("failed to read MISTRAL_API_KEY: %w", err)
+	}
+	if apiKey == "" {
+		return FormattedQuota{}, fmt.Errorf("MISTRAL_API_KEY environment variable is not set")
+	}
+
+	modelsURL := strings.TrimRight(config.MistralBaseURL, "/") + "/v1/models"
+	return getRateLimitQuota(ctx, ProviderMistral, modelsURL, apiKey, mistralRateLimits)
+}
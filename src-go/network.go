@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// dialOptions are the IP family and host overrides provider connections are dialed with
+type dialOptions struct {
+	network   string
+	overrides map[string]string
+}
+
+// currentDialOptions are replaced when the configuration is loaded or reloaded, so a
+// changed IP_FAMILY or HOST_OVERRIDES applies to the next new connection
+var currentDialOptions atomic.Pointer[dialOptions]
+
+// providerDialer is the dialer of http.DefaultTransport
+var providerDialer = &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+
+// parseIPFamily maps IP_FAMILY (4, 6, ipv4, ipv6) to the network to dial, or "" for both
+func parseIPFamily(value string) string {
+	switch strings.ToLower(trimQuotes(value)) {
+	case "4", "ipv4", "tcp4":
+		return "tcp4"
+	case "6", "ipv6", "tcp6":
+		return "tcp6"
+	}
+	return ""
+}
+
+// parseHostOverrides parses "host=ip,host2=ip2" pairs, dropping entries whose value is not
+// an IP address
+func parseHostOverrides(value string) map[string]string {
+	overrides := make(map[string]string)
+	for host, ip := range parseModelAliases(value) {
+		if net.ParseIP(ip) != nil {
+			overrides[strings.ToLower(host)] = ip
+		}
+	}
+	return overrides
+}
+
+// applyNetworkConfig makes new provider connections use the IP family and host overrides
+// of config
+func applyNetworkConfig(config *Config) {
+	currentDialOptions.Store(&dialOptions{network: config.IPFamily, overrides: config.HostOverrides})
+}
+
+// dialProvider dials addr, connecting to the override address of its host when there is
+// one, and over IPv4 or IPv6 only when IP_FAMILY is set. TLS still verifies the original
+// host name, which the transport takes from the request.
+func dialProvider(ctx context.Context, network, addr string) (net.Conn, error) {
+	if options := currentDialOptions.Load(); options != nil {
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			if ip, ok := options.overrides[strings.ToLower(host)]; ok {
+				addr = net.JoinHostPort(ip, port)
+			}
+		}
+		if options.network != "" && network == "tcp" {
+			network = options.network
+		}
+	}
+	return providerDialer.DialContext(ctx, network, addr)
+}
+
+// newProviderTransport returns the default transport dialing through dialProvider
+func newProviderTransport() http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialProvider
+	return transport
+}
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Renderer turns a quota into output in one format. Programs embedding the server can
+// register their own with RegisterRenderer and request them with ?format=<name>; the
+// template format is built per request from a text/template instead.
+type Renderer interface {
+	Render(quota FormattedQuota) ([]byte, error)
+}
+
+// RendererFunc adapts a function to the Renderer interface
+type RendererFunc func(quota FormattedQuota) ([]byte, error)
+
+// Render calls f
+func (f RendererFunc) Render(quota FormattedQuota) ([]byte, error) {
+	return f(quota)
+}
+
+// renderers maps format names to renderers
+var (
+	renderersMu sync.RWMutex
+	renderers   = map[string]Renderer{
+		"json":          RendererFunc(renderJSON),
+		"text":          RendererFunc(renderText),
+		"overview":      RendererFunc(renderOverview),
+		"status":        RendererFunc(renderStatus),
+		"status-zai":    RendererFunc(renderStatusZAI),
+		"status-claude": RendererFunc(renderStatusClaude),
+		"balance":       RendererFunc(renderBalance),
+		"short":         RendererFunc(renderShort),
+		"badge":         RendererFunc(renderBadge),
+		"prompt":        RendererFunc(renderBadge),
+		"nvim":          RendererFunc(renderNvim),
+		"vscode":        RendererFunc(renderVSCode),
+	}
+)
+
+// RegisterRenderer adds a renderer under a format name, replacing any registered before
+func RegisterRenderer(name string, renderer Renderer) {
+	renderersMu.Lock()
+	defer renderersMu.Unlock()
+	renderers[name] = renderer
+}
+
+// RendererNames returns the registered format names, sorted
+func RendererNames() []string {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+
+	names := make([]string, 0, len(renderers))
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// renderQuota renders quota with the renderer registered for format
+func renderQuota(format string, quota FormattedQuota) ([]byte, error) {
+	renderer, err := resolveRenderer(format, "")
+	if err != nil {
+		return nil, err
+	}
+	return renderer.Render(quota)
+}
+
+// aliasedNames maps canonical model names to their configured output names, lowercased
+func aliasedNames(aliases map[string]string, names ...string) []string {
+	result := make([]string, len(names))
+	for i, name := range names {
+		result[i] = strings.ToLower(aliasedName(aliases, name))
+	}
+	return result
+}
+
+// findModel returns the first model whose lowercased name matches
+func findModel(models []FormattedModel, match func(name string) bool) (FormattedModel, bool) {
+	for _, model := range models {
+		if match(strings.ToLower(model.Name)) {
+			return model, true
+		}
+	}
+	return FormattedModel{}, false
+}
+
+// antigravityModels finds the Pro, Flash, and Claude models of an Antigravity quota. Pro and
+// Flash match by substring, Claude exactly, as the non-thinking Sonnet.
+func antigravityModels(models []FormattedModel) (FormattedModel, FormattedModel, FormattedModel) {
+	names := aliasedNames(LoadConfig().ModelAliases, "gemini-3-pro-high", "gemini-3-flash", "claude-sonnet-4-5")
+	pro, _ := findModel(models, func(name string) bool { return strings.Contains(name, names[0]) })
+	flash, _ := findModel(models, func(name string) bool { return strings.Contains(name, names[1]) })
+	claude, _ := findModel(models, func(name string) bool { return name == names[2] })
+	return pro, flash, claude
+}
+
+// formatIconStatus formats a model as its icon, colored when the quota is full or empty,
+// otherwise followed by the colored percentage and the compact time to reset
+func formatIconStatus(icon string, pct float64, resetTime string) string {
+	const (
+		Green = "\033[32m"
+		Red   = "\033[31m"
+		Reset = "\033[0m"
+	)
+
+	if pct == QuotaFull {
+		return Green + icon + Reset
+	} else if pct == 0 {
+		return Red + icon + Reset
+	}
+	status := fmt.Sprintf("%s %s", icon, formatPercentageWithColor(pct))
+	if timeStr := formatTimeCompact(resetTime); timeStr != "" {
+		status += " " + timeStr
+	}
+	return status
+}
+
+// renderJSON renders the quota object as JSON
+func renderJSON(quota FormattedQuota) ([]byte, error) {
+	return json.Marshal(quota)
+}
+
+// renderText renders one "name: 82% (2h15m)" line per model, under a "[group]" header per
+// group when the models are grouped
+func renderText(quota FormattedQuota) ([]byte, error) {
+	var b strings.Builder
+	writeModels := func(models []FormattedModel) {
+		for _, model := range models {
+			fmt.Fprintf(&b, "%s: %s%%", model.Name, formatPercentage(model.Percentage))
+			if timeStr := formatTimeCompact(model.ResetTime); timeStr != "" {
+				fmt.Fprintf(&b, " (%s)", timeStr)
+			}
+			b.WriteByte('\n')
+		}
+	}
+	if len(quota.Groups) == 0 {
+		writeModels(quota.Models)
+	}
+	for _, group := range quota.Groups {
+		fmt.Fprintf(&b, "[%s]\n", group.Name)
+		writeModels(group.Models)
+	}
+	return []byte(b.String()), nil
+}
+
+// renderOverview renders an Antigravity quota as "Pro 95% | Flash 90% | Claude 80%"
+func renderOverview(quota FormattedQuota) ([]byte, error) {
+	pro, flash, claude := antigravityModels(quota.Models)
+	return []byte(fmt.Sprintf("Pro %s%% | Flash %s%% | Claude %s%%",
+		formatPercentage(pro.Percentage), formatPercentage(flash.Percentage), formatPercentage(claude.Percentage))), nil
+}
+
+// renderStatus renders an Antigravity quota as a colored status line with nerdfont icons
+func renderStatus(quota FormattedQuota) ([]byte, error) {
+	const (
+		GeminiIcon = "G"
+		FlashIcon  = "F"
+		ClaudeIcon = "󰛄"
+	)
+
+	config := LoadConfig()
+	pro, flash, claude := antigravityModels(quota.Models)
+	return []byte(fmt.Sprintf("%s | %s | %s",
+		formatIconStatus(modelIcon(config, pro, GeminiIcon, GeminiIcon), pro.Percentage, pro.ResetTime),
+		formatIconStatus(modelIcon(config, flash, FlashIcon, FlashIcon), flash.Percentage, flash.ResetTime),
+		formatIconStatus(modelIcon(config, claude, ClaudeIcon, "C"), claude.Percentage, claude.ResetTime))), nil
+}
+
+// renderStatusZAI renders the GLM token quota as a colored status (e.g., "Z 99%")
+func renderStatusZAI(quota FormattedQuota) ([]byte, error) {
+	const ZAIIcon = "Z"
+
+	config := LoadConfig()
+	glmName := aliasedName(config.ModelAliases, "glm")
+	var glm FormattedModel
+	for _, model := range quota.Models {
+		if model.Name == glmName {
+			glm = model
+			break
+		}
+	}
+	return []byte(formatIconStatus(modelIcon(config, glm, ZAIIcon, ZAIIcon), glm.Percentage, "")), nil
+}
+
+// renderStatusClaude renders the Claude 5-hour session as a colored status with its reset time
+func renderStatusClaude(quota FormattedQuota) ([]byte, error) {
+	const ClaudeIcon = "󰛄"
+
+	config := LoadConfig()
+	sessionName := aliasedName(config.ModelAliases, "claude-ai-session")
+	var session FormattedModel
+	for _, model := range quota.Models {
+		if model.Name == sessionName {
+			session = model
+			break
+		}
+	}
+	return []byte(formatIconStatus(modelIcon(config, session, ClaudeIcon, "C"), session.Percentage, session.ResetTime)), nil
+}
+
+// renderBalance renders the models with a balance as "name ¥42.10 | name $5.00"
+func renderBalance(quota FormattedQuota) ([]byte, error) {
+	var parts []string
+	for _, model := range quota.Models {
+		if model.Balance != nil {
+			parts = append(parts, fmt.Sprintf("%s %s", model.Name, model.Balance.Display))
+		}
+	}
+	return []byte(strings.Join(parts, " | ")), nil
+}
+
+// shortModelName drops the GLM coding plan prefix and the monthly suffix, so
+// glm-coding-plan-mcp-monthly reads as mcp; MODEL_ALIASES renames anything else
+func shortModelName(name string) string {
+	name = strings.TrimPrefix(name, "glm-coding-plan-")
+	return strings.TrimSuffix(name, "-monthly")
+}
+
+// quotaModels returns the models with a quota, leaving out balances and local models
+func quotaModels(models []FormattedModel) []FormattedModel {
+	var result []FormattedModel
+	for _, model := range models {
+		if model.Balance == nil && model.Local == nil {
+			result = append(result, model)
+		}
+	}
+	return result
+}
+
+// renderShort renders the quota models on one line such as "glm 75% · mcp 90%", joined by
+// SHORT_SEPARATOR and limited to the SHORT_LIMIT models with the least quota left, in their
+// output order. Balances and local models have no quota and are left out.
+func renderShort(quota FormattedQuota) ([]byte, error) {
+	config := LoadConfig()
+	models := quotaModels(quota.Models)
+	if config.ShortLimit > 0 && len(models) > config.ShortLimit {
+		order := make([]int, len(models))
+		for i := range order {
+			order[i] = i
+		}
+		slices.SortStableFunc(order, func(a, b int) int {
+			return cmp.Compare(models[a].Percentage, models[b].Percentage)
+		})
+		worst := order[:config.ShortLimit]
+		slices.Sort(worst)
+		kept := make([]FormattedModel, len(worst))
+		for i, index := range worst {
+			kept[i] = models[index]
+		}
+		models = kept
+	}
+
+	parts := make([]string, len(models))
+	for i, model := range models {
+		parts[i] = withIcon(config, model, fmt.Sprintf("%s %s%%", shortModelName(model.Name), formatPercentage(model.Percentage)))
+	}
+	separator := config.ShortSeparator
+	if config.ASCIIOutput && !isASCII(separator) {
+		separator = " | "
+	}
+	return []byte(strings.Join(parts, separator)), nil
+}
+
+// renderBadge renders the model with the least quota left as "🟡 glm 35%", the emoji green
+// from 50%, yellow from 20%, and red below. BADGE_STYLE=color colors the text with ANSI
+// codes instead, and ASCII_OUTPUT marks it [ok], [low], or [out].
+func renderBadge(quota FormattedQuota) ([]byte, error) {
+	return badgeText(LoadConfig(), quota), nil
+}
+
+// badgeText renders the badge with the given icon, style, and ASCII settings
+func badgeText(config *Config, quota FormattedQuota) []byte {
+	models := quotaModels(quota.Models)
+	if len(models) == 0 {
+		return nil
+	}
+	worst := slices.MinFunc(models, func(a, b FormattedModel) int {
+		return cmp.Compare(a.Percentage, b.Percentage)
+	})
+
+	text := withIcon(config, worst, fmt.Sprintf("%s %s%%", shortModelName(worst.Name), formatPercentage(worst.Percentage)))
+	if config.BadgeStyle == "color" {
+		return []byte(templateColor(worst.Percentage, text))
+	}
+	marker, asciiMarker := "🔴", "[out]"
+	switch {
+	case worst.Percentage >= QuotaGood:
+		marker, asciiMarker = "🟢", "[ok]"
+	case worst.Percentage >= QuotaWarning:
+		marker, asciiMarker = "🟡", "[low]"
+	}
+	if config.ASCIIOutput {
+		marker = asciiMarker
+	}
+	return []byte(marker + " " + text)
+}
+
+// Severities of the model with the least quota left, as the badge colors it
+const (
+	severityOK      = "ok"
+	severityWarning = "warning"
+	severityNone    = "none"
+)
+
+// quotaSeverity returns ok from 50%, warning from 20%, and error below
+func quotaSeverity(pct float64) string {
+	switch {
+	case pct >= QuotaGood:
+		return severityOK
+	case pct >= QuotaWarning:
+		return severityWarning
+	default:
+		return severityError
+	}
+}
+
+// NvimStatus is the output of the nvim format, a stable contract for statusline plugins:
+// fields are only ever added. Text is the badge text of the model with the least quota left,
+// HLGroupHint a highlight group to color it with, and Percentage that model's percentage, or
+// null without models.
+type NvimStatus struct {
+	Text        string   `json:"text"`
+	HLGroupHint string   `json:"hl_group_hint"`
+	Percentage  *float64 `json:"percentage"`
+}
+
+// Highlight groups hinted for each severity
+var nvimHighlights = map[string]string{
+	severityOK:      "DiagnosticOk",
+	severityWarning: "DiagnosticWarn",
+	severityError:   "DiagnosticError",
+}
+
+// renderNvim renders the model with the least quota left as a one-line NvimStatus JSON
+// object. The highlight hint is DiagnosticOk from 50%, DiagnosticWarn from 20%,
+// DiagnosticError below, and Comment without models.
+func renderNvim(quota FormattedQuota) ([]byte, error) {
+	status := NvimStatus{HLGroupHint: "Comment"}
+	if worst, ok := worstModel(quotaModels(quota.Models)); ok {
+		status.Text = withIcon(LoadConfig(), worst, fmt.Sprintf("%s %s%%", shortModelName(worst.Name), formatPercentage(worst.Percentage)))
+		status.Percentage = &worst.Percentage
+		status.HLGroupHint = nvimHighlights[quotaSeverity(worst.Percentage)]
+	}
+	return json.Marshal(status)
+}
+
+// VSCodeFormatVersion is the version of the vscode format. It is raised when a field is
+// renamed, removed, or changes meaning; adding a field keeps it.
+const VSCodeFormatVersion = 1
+
+// VSCodeCommand is a command the companion extension can offer, by its command ID
+type VSCodeCommand struct {
+	Command   string   `json:"command"`
+	Title     string   `json:"title"`
+	Arguments []string `json:"arguments,omitempty"`
+}
+
+// VSCodeStatus is the output of the vscode format for the companion extension's status bar
+// item: the item text with a codicon, a Markdown tooltip listing every model, the severity
+// of the model with the least quota left (ok, warning, error, or none), and the commands to
+// offer, the first being the click action
+type VSCodeStatus struct {
+	Version  int             `json:"version"`
+	Text     string          `json:"text"`
+	Tooltip  string          `json:"tooltip"`
+	Severity string          `json:"severity"`
+	Commands []VSCodeCommand `json:"commands"`
+}
+
+// renderVSCode renders quota as a VSCodeStatus JSON object
+func renderVSCode(quota FormattedQuota) ([]byte, error) {
+	status := VSCodeStatus{Version: VSCodeFormatVersion, Text: "$(pulse) -", Severity: severityNone}
+	if worst, ok := worstModel(quotaModels(quota.Models)); ok {
+		status.Text = fmt.Sprintf("$(pulse) %s %s%%", shortModelName(worst.Name), formatPercentage(worst.Percentage))
+		status.Severity = quotaSeverity(worst.Percentage)
+	}
+
+	var tooltip strings.Builder
+	tooltip.WriteString("| Model | Left | Resets |\n|---|---:|---|\n")
+	providers := []string{}
+	for _, model := range quota.Models {
+		left := formatPercentage(model.Percentage) + "%"
+		if model.Balance != nil {
+			left = model.Balance.Display
+		}
+		fmt.Fprintf(&tooltip, "| %s | %s | %s |\n", model.Name, left, formatTimeCompact(model.ResetTime))
+		if model.Provider != "" && !slices.Contains(providers, model.Provider) {
+			providers = append(providers, model.Provider)
+		}
+	}
+	status.Tooltip = tooltip.String()
+
+	status.Commands = []VSCodeCommand{
+		{Command: "codingPlanQuota.refresh", Title: "Refresh quota", Arguments: providers},
+		{Command: "codingPlanQuota.openDashboard", Title: "Open quota dashboard"},
+	}
+	return json.Marshal(status)
+}
+
+// Keys models can be sorted by, and what they can be grouped by
+var (
+	modelSortKeys  = []string{"name", "percentage", "provider"}
+	modelGroupKeys = []string{"provider", "account"}
+)
+
+// ModelGroup holds the models of one provider or account when output is grouped
+type ModelGroup struct {
+	Name   string           `json:"name"`
+	Models []FormattedModel `json:"models"`
+}
+
+// modelGroupName returns the group of a model: its provider, or for account grouping the
+// provider and account such as "glm:team"
+func modelGroupName(model FormattedModel, group string) string {
+	if group == "account" && model.Account != "" {
+		return model.Provider + ":" + model.Account
+	}
+	return model.Provider
+}
+
+// arrangeModels returns quota with its models sorted by key ("name", "percentage" with the
+// least quota first, or "provider"; a "-" prefix reverses the order) and, when group is
+// "provider" or "account", collected in Groups in the order the groups first appear. Empty
+// key and group keep the providers' order.
+func arrangeModels(quota *FormattedQuota, key, group string) (*FormattedQuota, error) {
+	key, descending := strings.CutPrefix(key, "-")
+	if key != "" && !slices.Contains(modelSortKeys, key) {
+		return nil, fmt.Errorf("unknown sort %q (available: %s)", key, strings.Join(modelSortKeys, ", "))
+	}
+	if group != "" && !slices.Contains(modelGroupKeys, group) {
+		return nil, fmt.Errorf("unknown group %q (available: %s)", group, strings.Join(modelGroupKeys, ", "))
+	}
+	if key == "" && group == "" {
+		return quota, nil
+	}
+
+	arranged := *quota
+	arranged.Models = slices.Clone(quota.Models)
+	if key != "" {
+		slices.SortStableFunc(arranged.Models, func(a, b FormattedModel) int {
+			var order int
+			switch key {
+			case "percentage":
+				order = cmp.Compare(a.Percentage, b.Percentage)
+			case "provider":
+				order = cmp.Compare(a.Provider, b.Provider)
+			}
+			if order == 0 && key != "provider" {
+				order = cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+			}
+			if descending {
+				return -order
+			}
+			return order
+		})
+	}
+
+	if group != "" {
+		index := make(map[string]int)
+		for _, model := range arranged.Models {
+			name := modelGroupName(model, group)
+			i, ok := index[name]
+			if !ok {
+				i = len(arranged.Groups)
+				index[name] = i
+				arranged.Groups = append(arranged.Groups, ModelGroup{Name: name})
+			}
+			arranged.Groups[i].Models = append(arranged.Groups[i].Models, model)
+		}
+		arranged.Models = arranged.Models[:0]
+		for _, modelGroup := range arranged.Groups {
+			arranged.Models = append(arranged.Models, modelGroup.Models...)
+		}
+	}
+	return &arranged, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// noModelError is returned when no model matches the required pattern, which waiting
+// does not fix
+type noModelError struct {
+	message string
+}
+
+func (e *noModelError) Error() string {
+	return e.message
+}
+
+// requireProvider returns the provider serving a model name such as "glm", "glm:work", or
+// "claude-ai-session", the longest provider name followed by ":" or "-", or Antigravity for
+// names that do not start with a provider
+func requireProvider(model string) string {
+	provider := ""
+	for name := range quotaProviders {
+		if (model == name || strings.HasPrefix(model, name+":") || strings.HasPrefix(model, name+"-")) && len(name) > len(provider) {
+			provider = name
+		}
+	}
+	if provider == "" {
+		return ProviderAntigravity
+	}
+	return provider
+}
+
+// checkRequiredQuota returns an error naming every model matching pattern that has less
+// than floor percent left, or when no model matches
+func checkRequiredQuota(config *Config, provider string, quota *FormattedQuota, pattern string, floor float64) error {
+	printer := localizer(config.Language)
+	matched := false
+	var failures []string
+	for _, model := range quota.Models {
+		if !matchesAnyGlob(model.Name, []string{pattern}) {
+			continue
+		}
+		matched = true
+		if model.Percentage >= floor {
+			continue
+		}
+		failure := printer.Sprintf(msgRequireBelow, provider, model.Name, formatPercentage(model.Percentage), formatPercentage(floor))
+		if model.ResetTimeRelative != "" {
+			failure = printer.Sprintf(msgRequireResets, failure, model.ResetTimeRelative)
+		}
+		failures = append(failures, failure)
+	}
+	if !matched {
+		return &noModelError{message: printer.Sprintf(msgRequireNoModel, provider, pattern)}
+	}
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "\n"))
+	}
+	return nil
+}
+
+// runRequireCommand fails when a model has less quota left than the floor, so launcher
+// scripts can refuse to start runs that would stop midway. It prints nothing on success.
+func runRequireCommand(args []string) error {
+	flags := flag.NewFlagSet("require", flag.ContinueOnError)
+	model := flags.String("model", "", "model name or glob pattern that must have quota, e.g. glm or gemini-3-*")
+	floor := flags.Float64("min", 1, "lowest acceptable remaining percentage")
+	provider := flags.String("provider", "", "provider of the model (default: from the model name, else antigravity)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *model == "" {
+		return errors.New("usage: require --model name [--min percent] [--provider p]")
+	}
+	if *provider == "" {
+		*provider = requireProvider(*model)
+	}
+
+	config := LoadConfig()
+	service := NewQuotaService(NewCloudCodeClient(config))
+	quota, err := service.fetchQuota(context.Background(), *provider)
+	if err != nil {
+		return err
+	}
+	return checkRequiredQuota(config, *provider, quota, *model, *floor)
+}
+
+// waitForQuota fetches quota every interval until the models matching pattern have at
+// least floor percent left, reporting each check to stdout. Fetch errors are reported and
+// retried; it stops when ctx is done or no model matches.
+func waitForQuota(ctx context.Context, config *Config, fetch func(context.Context) (*FormattedQuota, error), provider, pattern string, floor float64, interval time.Duration, stdout io.Writer) error {
+	printer := localizer(config.Language)
+	for {
+		quota, err := fetch(ctx)
+		if err == nil {
+			err = checkRequiredQuota(config, provider, quota, pattern, floor)
+			if err == nil {
+				fmt.Fprintln(stdout, printer.Sprintf(msgWaitReady, provider, pattern, formatPercentage(floor)))
+				return nil
+			}
+			var noModel *noModelError
+			if errors.As(err, &noModel) {
+				return err
+			}
+		}
+		fmt.Fprintln(stdout, printer.Sprintf(msgWaitRetry, err, interval))
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(interval):
+		}
+	}
+}
+
+// runWaitCommand blocks until a model has at least --min percent left, polling each
+// QUERY_DEBOUNCE interval, so batch pipelines can sleep through an exhausted window
+func runWaitCommand(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("wait", flag.ContinueOnError)
+	model := flags.String("model", "", "model name or glob pattern to wait for, e.g. glm or gemini-3-*")
+	floor := flags.Float64("min", 1, "remaining percentage to wait for")
+	provider := flags.String("provider", "", "provider of the model (default: from the model name, else antigravity)")
+	timeout := flags.Duration("timeout", 0, "give up after this long, e.g. 6h (default: wait indefinitely)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *model == "" {
+		return errors.New("usage: wait --model name [--min percent] [--timeout 6h] [--provider p]")
+	}
+	if *provider == "" {
+		*provider = requireProvider(*model)
+	}
+
+	config := LoadConfig()
+	interval := time.Duration(config.QueryDebounce) * time.Minute
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	service := NewQuotaService(NewCloudCodeClient(config))
+	fetch := func(ctx context.Context) (*FormattedQuota, error) {
+		return service.fetchQuota(ctx, *provider)
+	}
+	if err := waitForQuota(ctx, config, fetch, *provider, *model, *floor, interval, stdout); err != nil {
+		if ctx.Err() != nil {
+			return errors.New(localizer(config.Language).Sprintf(msgWaitTimeout, *timeout, err))
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Daily rollups of monthly pools are kept this long, so a period can be compared with
+	// the one before
+	rollupRetention = 70 * 24 * time.Hour
+
+	// A previous-period rollup further than this from the matching point is not comparable
+	rollupMatchWindow = 36 * time.Hour
+)
+
+// PeriodComparison is how much of a monthly pool was used so far this period, and how much
+// of it had been used at the same point of the previous period
+type PeriodComparison struct {
+	Provider     string  `json:"provider"`
+	Model        string  `json:"model"`
+	Day          int     `json:"day"`
+	Used         float64 `json:"used"`
+	PreviousUsed float64 `json:"previous_used"`
+	PeriodStart  int64   `json:"period_start"`
+}
+
+// isMonthlyModel reports whether a model is a pool that resets monthly, such as
+// glm-coding-plan-mcp-monthly
+func isMonthlyModel(name string) bool {
+	return strings.HasSuffix(name, "-monthly")
+}
+
+// sameLocalDay reports whether two Unix times fall on the same local date
+func sameLocalDay(a, b int64) bool {
+	ay, am, ad := time.Unix(a, 0).Local().Date()
+	by, bm, bd := time.Unix(b, 0).Local().Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// addRollup keeps record as its model's rollup for the day, the last record of each local
+// day, and drops rollups past the retention. The caller holds h.mu.
+func (h *historyStore) addRollup(record HistoryRecord) {
+	key := burnKey(record.Provider, record.Model)
+	rollups := h.rollups[key]
+	if n := len(rollups); n > 0 {
+		last := rollups[n-1]
+		if sameLocalDay(record.Time, last.Time) {
+			if record.Time >= last.Time {
+				rollups[n-1] = record
+			}
+			return
+		}
+		if record.Time < last.Time {
+			return
+		}
+	}
+
+	rollups = append(rollups, record)
+	cutoff := clockNow().Add(-rollupRetention).Unix()
+	start := 0
+	for start < len(rollups) && rollups[start].Time < cutoff {
+		start++
+	}
+	h.rollups[key] = rollups[start:]
+}
+
+// startOfDay returns local midnight of the day of a Unix time
+func startOfDay(unix int64) time.Time {
+	year, month, day := time.Unix(unix, 0).Local().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
+
+// periodStarts returns the local days on which a pool was seen to reset, a rise in its
+// remaining percentage from one day to the next, oldest first
+func periodStarts(rollups []HistoryRecord) []time.Time {
+	var starts []time.Time
+	for i := 1; i < len(rollups); i++ {
+		if rollups[i].Percentage > rollups[i-1].Percentage {
+			starts = append(starts, startOfDay(rollups[i].Time))
+		}
+	}
+	return starts
+}
+
+// periodComparisons compares each monthly pool's use this period with the previous period
+// at the same point. Periods begin on the days resets were seen, else on the first of the
+// calendar month. Pools without a rollup near the matching point of the previous period are
+// left out.
+func (h *historyStore) periodComparisons(now time.Time) []PeriodComparison {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	comparisons := []PeriodComparison{}
+	for _, rollups := range h.rollups {
+		if len(rollups) == 0 {
+			continue
+		}
+		current := rollups[len(rollups)-1]
+		if now.Unix()-current.Time > int64(rollupMatchWindow/time.Second) {
+			continue
+		}
+
+		year, month, _ := now.Local().Date()
+		start := time.Date(year, month, 1, 0, 0, 0, 0, time.Local)
+		previousStart := start.AddDate(0, -1, 0)
+		if starts := periodStarts(rollups); len(starts) > 0 {
+			start = starts[len(starts)-1]
+			previousStart = start.AddDate(0, -1, 0)
+			if len(starts) > 1 {
+				previousStart = starts[len(starts)-2]
+			}
+		}
+
+		elapsed := now.Sub(start)
+		target := previousStart.Add(elapsed).Unix()
+		var previous HistoryRecord
+		found := false
+		for _, rollup := range rollups {
+			if rollup.Time < previousStart.Unix() || rollup.Time >= start.Unix() {
+				continue
+			}
+			if !found || absInt64(rollup.Time-target) < absInt64(previous.Time-target) {
+				previous, found = rollup, true
+			}
+		}
+		if !found || absInt64(previous.Time-target) > int64(rollupMatchWindow/time.Second) {
+			continue
+		}
+
+		comparisons = append(comparisons, PeriodComparison{
+			Provider:     current.Provider,
+			Model:        current.Model,
+			Day:          int(elapsed/(24*time.Hour)) + 1,
+			Used:         100 - current.Percentage,
+			PreviousUsed: 100 - previous.Percentage,
+			PeriodStart:  start.Unix(),
+		})
+	}
+	sort.Slice(comparisons, func(i, j int) bool {
+		return burnKey(comparisons[i].Provider, comparisons[i].Model) < burnKey(comparisons[j].Provider, comparisons[j].Model)
+	})
+	return comparisons
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Quota resetting within this horizon counts as nearly restored, and a burn rate that would
+// exhaust a model sooner than this lowers its score
+const routeHorizon = 5 * time.Hour
+
+// ErrNoRoute is returned when no provider has quota available
+var ErrNoRoute = errors.New("no provider has quota available")
+
+// RouteCandidate is a provider scored by how much headroom it has
+type RouteCandidate struct {
+	Provider   string  `json:"provider"`
+	Model      string  `json:"model,omitempty"`
+	Percentage float64 `json:"percentage"`
+	BurnRate   float64 `json:"burn_rate"`
+	ResetIn    int64   `json:"reset_in,omitempty"`
+	Score      float64 `json:"score"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// RouteResult is the recommendation and every candidate considered, best first
+type RouteResult struct {
+	Recommended string           `json:"recommended"`
+	Candidates  []RouteCandidate `json:"candidates"`
+}
+
+// routeProviders returns the providers considered by default. The local pseudo-provider is
+// always at 100%, so it is only considered when asked for explicitly.
+func routeProviders() []string {
+	var providers []string
+	for _, provider := range providerNames() {
+		if provider != ProviderLocal {
+			providers = append(providers, provider)
+		}
+	}
+	return providers
+}
+
+// timeUntilReset returns how long until an RFC3339 reset time
+func timeUntilReset(resetTime string) (time.Duration, bool) {
+	if resetTime == "" {
+		return 0, false
+	}
+	reset, err := time.Parse(time.RFC3339, resetTime)
+	if err != nil {
+		return 0, false
+	}
+	return reset.Sub(clockNow()), true
+}
+
+// routeScore rates a model from 0 to 100. It starts at the remaining percentage, shrinks
+// when the burn rate would exhaust the model within the horizon before it resets, and
+// grows toward 100 as the reset approaches.
+func routeScore(pct, burnRate float64, untilReset time.Duration, hasReset bool) float64 {
+	score := pct
+	horizon := routeHorizon.Hours()
+
+	if burnRate > 0 {
+		hoursLeft := pct / burnRate
+		resetsFirst := hasReset && untilReset.Hours() < hoursLeft
+		if !resetsFirst && hoursLeft < horizon {
+			score *= hoursLeft / horizon
+		}
+	}
+
+	if hasReset {
+		proximity := math.Max(0, 1-math.Max(0, untilReset.Hours())/horizon)
+		score += (100 - score) * proximity
+	}
+
+	return roundPercentage(score, MaxPercentagePrecision)
+}
+
+// routeCandidate scores a provider by its most constrained model
+func routeCandidate(provider string, quota *FormattedQuota) RouteCandidate {
+	candidate := RouteCandidate{Provider: provider}
+	if quota.IsForbidden || len(quota.Models) == 0 {
+		candidate.Error = "no quota data"
+		return candidate
+	}
+
+	first := true
+	for _, model := range quota.Models {
+		burnRate := burnRates.rate(provider, model.Name)
+		untilReset, hasReset := timeUntilReset(model.ResetTime)
+		score := routeScore(model.Percentage, burnRate, untilReset, hasReset)
+
+		if first || score < candidate.Score {
+			first = false
+			candidate.Model = model.Name
+			candidate.Percentage = model.Percentage
+			candidate.BurnRate = roundPercentage(burnRate, MaxPercentagePrecision)
+			candidate.ResetIn = 0
+			if hasReset && untilReset > 0 {
+				candidate.ResetIn = int64(untilReset.Seconds())
+			}
+			candidate.Score = score
+		}
+	}
+	return candidate
+}
+
+// recommendProvider fetches the given providers (all but local when empty) and ranks them
+// by headroom. Providers that fail to fetch are listed last with their error.
+func (s *QuotaService) recommendProvider(ctx context.Context, providers []string) RouteResult {
+	if len(providers) == 0 {
+		providers = routeProviders()
+	}
+
+	var result RouteResult
+	for _, provider := range providers {
+		quota, err := s.fetchQuota(ctx, provider)
+		if err != nil {
+			result.Candidates = append(result.Candidates, RouteCandidate{Provider: provider, Error: err.Error()})
+			continue
+		}
+		result.Candidates = append(result.Candidates, routeCandidate(provider, quota))
+	}
+
+	sort.SliceStable(result.Candidates, func(i, j int) bool {
+		a, b := result.Candidates[i], result.Candidates[j]
+		if (a.Error == "") != (b.Error == "") {
+			return a.Error == ""
+		}
+		return a.Score > b.Score
+	})
+
+	if len(result.Candidates) > 0 && result.Candidates[0].Error == "" && result.Candidates[0].Score > 0 {
+		result.Recommended = result.Candidates[0].Provider
+	}
+	return result
+}
+
+// routeEnvVar is an environment variable exported for a provider, or unset when Value is empty
+type routeEnvVar struct {
+	Name  string
+	Value string
+}
+
+// routeEnv maps providers usable as an Anthropic-compatible backend to the environment
+// that points Claude Code and similar tools at them
+var routeEnv = map[string]func() ([]routeEnvVar, error){
+	ProviderGLM: func() ([]routeEnvVar, error) {
+		_, _, authToken, err := zaiCredentials(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		return []routeEnvVar{
+			{Name: "ANTHROPIC_BASE_URL", Value: LoadConfig().ZAIBaseURL},
+			{Name: "ANTHROPIC_AUTH_TOKEN", Value: authToken},
+		}, nil
+	},
+	ProviderClaudeAI: func() ([]routeEnvVar, error) {
+		token, err := claudeSessionToken(context.Background(), LoadConfig())
+		if err != nil {
+			return nil, err
+		}
+		return []routeEnvVar{
+			{Name: "CLAUDE_CODE_OAUTH_TOKEN", Value: token},
+			{Name: "ANTHROPIC_BASE_URL"},
+			{Name: "ANTHROPIC_AUTH_TOKEN"},
+		}, nil
+	},
+}
+
+// routeEnvProviders returns the providers --emit-env can switch to
+func routeEnvProviders() []string {
+	providers := make([]string, 0, len(routeEnv))
+	for provider := range routeEnv {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	return providers
+}
+
+// shellQuote quotes a value for POSIX shells
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// formatEnvExports renders variables as one eval-safe line of export and unset statements
+func formatEnvExports(vars []routeEnvVar) string {
+	var exports, unsets []string
+	for _, v := range vars {
+		if v.Value == "" {
+			unsets = append(unsets, v.Name)
+		} else {
+			exports = append(exports, v.Name+"="+shellQuote(v.Value))
+		}
+	}
+
+	var statements []string
+	if len(exports) > 0 {
+		statements = append(statements, "export "+strings.Join(exports, " "))
+	}
+	if len(unsets) > 0 {
+		statements = append(statements, "unset "+strings.Join(unsets, " "))
+	}
+	return strings.Join(statements, "; ")
+}
+
+// runRouteCommand prints the provider with the most headroom, every candidate with --json,
+// or shell exports for it with --emit-env. It fails when no provider has quota, so scripts
+// can branch on the exit status.
+func runRouteCommand(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("route", flag.ContinueOnError)
+	providers := flags.String("providers", "", "comma-separated providers to consider (default: all but local)")
+	asJSON := flags.Bool("json", false, "print every candidate as JSON")
+	emitEnv := flags.Bool("emit-env", false, "print shell exports that switch to the recommended provider")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	service := NewQuotaService(NewCloudCodeClient(LoadConfig()))
+	candidates := parseList(*providers)
+	if *emitEnv && len(candidates) == 0 {
+		candidates = routeEnvProviders()
+	}
+	result := service.recommendProvider(context.Background(), candidates)
+
+	if *emitEnv {
+		if result.Recommended == "" {
+			return ErrNoRoute
+		}
+		envFor, ok := routeEnv[result.Recommended]
+		if !ok {
+			return fmt.Errorf("%s has no environment to emit (supported: %s)", result.Recommended, strings.Join(routeEnvProviders(), ", "))
+		}
+		vars, err := envFor()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, formatEnvExports(vars))
+		return nil
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	} else if result.Recommended != "" {
+		fmt.Fprintln(stdout, result.Recommended)
+	}
+
+	if result.Recommended == "" {
+		return ErrNoRoute
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Prefix of a listen address naming a unix socket, e.g. "unix:/run/quota.sock"
+const unixListenPrefix = "unix:"
+
+// serverOptions are the serve mode settings that only apply at startup
+type serverOptions struct {
+	listen   string
+	certFile string
+	keyFile  string
+	clientCA string
+}
+
+// parseServeArgs reads the serve options from the arguments, falling back to LISTEN_ADDRESS
+// (else all interfaces on PORT) and the TLS_* variables
+func parseServeArgs(args []string) (serverOptions, error) {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	listen := flags.String("listen", trimQuotes(os.Getenv("LISTEN_ADDRESS")), "host:port or unix:/path to listen on (default: all interfaces on PORT)")
+	certFile := flags.String("tls-cert", trimQuotes(os.Getenv("TLS_CERT_FILE")), "PEM certificate to serve HTTPS with")
+	keyFile := flags.String("tls-key", trimQuotes(os.Getenv("TLS_KEY_FILE")), "PEM private key of the certificate")
+	clientCA := flags.String("tls-client-ca", trimQuotes(os.Getenv("TLS_CLIENT_CA_FILE")), "PEM CA bundle that client certificates must be signed by")
+	if err := flags.Parse(args); err != nil {
+		return serverOptions{}, err
+	}
+
+	options := serverOptions{listen: *listen, certFile: *certFile, keyFile: *keyFile, clientCA: *clientCA}
+	if options.listen == "" {
+		port := getEnvOrDefault("PORT", "8000")
+		if _, err := strconv.Atoi(port); err != nil {
+			return serverOptions{}, fmt.Errorf("invalid PORT value: %s", port)
+		}
+		options.listen = ":" + port
+	}
+	return options, nil
+}
+
+// listenServer listens on a TCP address or, with the unix: prefix, on a unix socket that
+// only the current user can connect to. A socket left behind by an earlier run is replaced.
+func listenServer(address string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(address, unixListenPrefix)
+	if !ok {
+		return net.Listen("tcp", address)
+	}
+
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// grpcListenAddress puts the gRPC port on the host of the HTTP listen address, or on
+// loopback when HTTP is served on a unix socket, so --listen 127.0.0.1:8000 keeps gRPC local
+func grpcListenAddress(listen, port string) string {
+	if strings.HasPrefix(listen, unixListenPrefix) {
+		return net.JoinHostPort("127.0.0.1", port)
+	}
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		host = ""
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// serverTLSConfig loads the certificate to serve with, and requires client certificates
+// signed by clientCA when it is set. It returns nil when no certificate is configured.
+func serverTLSConfig(options serverOptions) (*tls.Config, error) {
+	if options.certFile == "" && options.keyFile == "" {
+		if options.clientCA != "" {
+			return nil, errors.New("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		return nil, nil
+	}
+	if options.certFile == "" || options.keyFile == "" {
+		return nil, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	certificate, err := tls.LoadX509KeyPair(options.certFile, options.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12}
+
+	if options.clientCA != "" {
+		bundle, err := os.ReadFile(options.clientCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no PEM certificates in %s", options.clientCA)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// tokenMatches reports whether a request carries token as a bearer token or as ?token=,
+// the only way a browser can send it with a link, an image, or an EventSource
+func tokenMatches(c *gin.Context, token string) bool {
+	bearer := subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte("Bearer "+token)) == 1
+	query := subtle.ConstantTimeCompare([]byte(c.Query("token")), []byte(token)) == 1
+	return bearer || query
+}
+
+// requestToken reads a config secret that requests are checked against. A secret that is
+// configured but cannot be read fails the request with a 500, so its check is never skipped.
+func requestToken(c *gin.Context, name string, source TokenSource) (string, bool) {
+	token, err := readToken(c.Request.Context(), source)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read %s: %v", name, err)})
+		return "", false
+	}
+	return token, true
+}
+
+// requestTokenMap reads a config secret of name=token pairs as requestToken does
+func requestTokenMap(c *gin.Context, name string, source TokenSource) (map[string]string, bool) {
+	tokens, err := readTokenMap(c.Request.Context(), source)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read %s: %v", name, err)})
+		return nil, false
+	}
+	return tokens, true
+}
+
+// requireServerToken rejects every request without SERVER_TOKEN when it is set. The
+// dashboard routes are left to requireDashboardToken, which also takes SERVER_TOKEN
+// besides DASHBOARD_TOKEN and VIEWER_TOKEN, and the daemon identity proof is open, as the
+// CLI asks for it before sending the token. /history also takes a member's HISTORY_TOKENS
+// token, which the handlers bind to that member.
+func (s *QuotaService) requireServerToken(c *gin.Context) {
+	config := s.client.Config()
+	if config.ServerToken == nil || isDashboardRoute(c) || c.FullPath() == daemonIdentityPath {
+		return
+	}
+	token, ok := requestToken(c, "SERVER_TOKEN", config.ServerToken)
+	if !ok || tokenMatches(c, token) {
+		return
+	}
+	if c.FullPath() == "/history" {
+		members, ok := requestTokenMap(c, "HISTORY_TOKENS", config.HistoryTokens)
+		if _, member := historyMemberOf(c, members); !ok || member {
+			return
+		}
+	}
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing or wrong SERVER_TOKEN bearer token"})
+}
+
+// isDashboardRoute reports whether a request is for the dashboard page or its streams
+func isDashboardRoute(c *gin.Context) bool {
+	return c.FullPath() == "/" || c.FullPath() == "/dashboard/stream"
+}
+
+// newRouter returns a gin engine with recovery and an access log that redacts tokens sent
+// as ?token=, so they do not end up in plain text in the log
+func newRouter() *gin.Engine {
+	r := gin.New()
+	r.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		if u, err := url.Parse(param.Path); err == nil && u.RawQuery != "" {
+			param.Path = redactURL(u)
+		}
+		statusColor, methodColor, resetColor := "", "", ""
+		if param.IsOutputColor() {
+			statusColor, methodColor, resetColor = param.StatusCodeColor(), param.MethodColor(), param.ResetColor()
+		}
+		return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			statusColor, param.StatusCode, resetColor,
+			param.Latency,
+			param.ClientIP,
+			methodColor, param.Method, resetColor,
+			param.Path,
+			param.ErrorMessage,
+		)
+	}), gin.Recovery())
+	return r
+}
+
+// grpcAuthorized checks the SERVER_TOKEN bearer token in the "authorization" metadata
+func (s *QuotaService) grpcAuthorized(ctx context.Context) error {
+	token, err := readToken(ctx, s.client.Config().ServerToken)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to read SERVER_TOKEN: %v", err)
+	}
+	if token == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if subtle.ConstantTimeCompare([]byte(value), []byte("Bearer "+token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or wrong SERVER_TOKEN bearer token")
+}
+
+// grpcTokenInterceptors apply requireServerToken to unary and streaming gRPC calls
+func (s *QuotaService) grpcTokenInterceptors() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := s.grpcAuthorized(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := s.grpcAuthorized(stream.Context()); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package main
+
+import "fmt"
+
+// runWindowsServiceCommand is only available on Windows
+func runWindowsServiceCommand(args []string) error {
+	return fmt.Errorf("Windows service management is only supported on Windows")
+}
+
+// runTray is only available on Windows
+func runTray() error {
+	return fmt.Errorf("tray mode is only supported on Windows")
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Session mark kinds
+const (
+	MarkStart = "start"
+	MarkStop  = "stop"
+)
+
+// SessionMark starts or stops a labeled session in the history
+type SessionMark struct {
+	Time    int64  `json:"time"`
+	Mark    string `json:"mark"`
+	Session string `json:"session,omitempty"`
+}
+
+// Session is a labeled time range. An open session has not been stopped and ends now.
+type Session struct {
+	Name  string `json:"name"`
+	Start int64  `json:"start"`
+	End   int64  `json:"end"`
+	Open  bool   `json:"open,omitempty"`
+}
+
+// ModelUsage is the quota a model used over a period, in percentage points
+type ModelUsage struct {
+	Provider string  `json:"provider"`
+	Model    string  `json:"model"`
+	Used     float64 `json:"used"`
+}
+
+// SessionUsage is the quota used per model during a session
+type SessionUsage struct {
+	Session
+	Models []ModelUsage `json:"models"`
+}
+
+// mark records a session mark. Starting a session stops the running one, and stopping
+// returns an error when no session is running. It returns the affected session's name.
+func (h *historyStore) mark(kind, name string) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	running := ""
+	if n := len(h.marks); n > 0 && h.marks[n-1].Mark == MarkStart {
+		running = h.marks[n-1].Session
+	}
+
+	now := clockNow().Unix()
+	var added []interface{}
+	switch kind {
+	case MarkStart:
+		if name == "" {
+			return "", errors.New("a session needs a name")
+		}
+		if running != "" {
+			added = append(added, SessionMark{Time: now, Mark: MarkStop, Session: running})
+		}
+		added = append(added, SessionMark{Time: now, Mark: MarkStart, Session: name})
+	case MarkStop:
+		if running == "" {
+			return "", errors.New("no session is running")
+		}
+		name = running
+		added = append(added, SessionMark{Time: now, Mark: MarkStop, Session: running})
+	default:
+		return "", fmt.Errorf("unknown mark %q (use start or stop)", kind)
+	}
+
+	if h.store != nil {
+		if err := h.store.Append(added...); err != nil {
+			return "", err
+		}
+	}
+	for _, mark := range added {
+		h.marks = append(h.marks, mark.(SessionMark))
+	}
+	return name, nil
+}
+
+// sessions pairs the start and stop marks into sessions, oldest first. A session still
+// running ends at now.
+func (h *historyStore) sessions(now time.Time) []Session {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var sessions []Session
+	for _, mark := range h.marks {
+		switch {
+		case mark.Mark == MarkStart:
+			sessions = append(sessions, Session{Name: mark.Session, Start: mark.Time, End: now.Unix(), Open: true})
+		case mark.Mark == MarkStop && len(sessions) > 0 && sessions[len(sessions)-1].Open:
+			sessions[len(sessions)-1].End = mark.Time
+			sessions[len(sessions)-1].Open = false
+		}
+	}
+	return sessions
+}
+
+// summarizeSessions attributes the quota used during each session that ended after since
+func summarizeSessions(history *historyStore, since, now time.Time) []SessionUsage {
+	summaries := []SessionUsage{}
+	for _, session := range history.sessions(now) {
+		if session.End < since.Unix() {
+			continue
+		}
+		summaries = append(summaries, SessionUsage{
+			Session: session,
+			Models:  history.consumed(time.Unix(session.Start, 0), time.Unix(session.End, 0)),
+		})
+	}
+	return summaries
+}
+
+// formatModelUsage renders the quota a model used as an indented line in the output language
+func formatModelUsage(config *Config, usage ModelUsage) string {
+	used := roundPercentage(usage.Used, config.PercentagePrecision)
+	return localizer(config.Language).Sprintf(msgSummaryModel, usage.Provider, usage.Model, strconv.FormatFloat(used, 'f', -1, 64))
+}
+
+// formatPeriodComparison renders a monthly pool's use against the previous period, e.g.
+// "glm/glm-coding-plan-mcp-monthly: 23% used by day 9; last month you were at 31% by day 9"
+func formatPeriodComparison(config *Config, period PeriodComparison) string {
+	used := roundPercentage(period.Used, config.PercentagePrecision)
+	previous := roundPercentage(period.PreviousUsed, config.PercentagePrecision)
+	return localizer(config.Language).Sprintf(msgSummaryPeriod, period.Provider, period.Model,
+		strconv.FormatFloat(used, 'f', -1, 64), period.Day, strconv.FormatFloat(previous, 'f', -1, 64), period.Day)
+}
+
+// writeSummary prints usage per model, grouped under a heading per session when given
+func writeSummary(w io.Writer, config *Config, heading string, models []ModelUsage) {
+	printer := localizer(config.Language)
+	if heading != "" {
+		fmt.Fprintln(w, heading)
+	}
+	if len(models) == 0 {
+		fmt.Fprintln(w, printer.Sprintf(msgSummaryNoUsage))
+	}
+	for _, usage := range models {
+		fmt.Fprintln(w, formatModelUsage(config, usage))
+	}
+}
+
+// openHistoryFile opens the history store on HISTORY_FILE or HISTORY_URL for the mark and
+// summary commands
+func openHistoryFile(config *Config) error {
+	store := historyStoreOf(config)
+	if store == nil {
+		return errors.New("HISTORY_FILE is not set; sessions and quota usage are kept there")
+	}
+	if err := quotaHistory.open(store); err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+	return nil
+}
+
+// runMarkCommand handles "mark start <name>" and "mark stop"
+func runMarkCommand(args []string, stdout io.Writer) error {
+	if len(args) == 0 || (args[0] == MarkStart && len(args) != 2) || (args[0] == MarkStop && len(args) != 1) {
+		return errors.New("usage: mark start <name> | mark stop")
+	}
+
+	config := LoadConfig()
+	if err := openHistoryFile(config); err != nil {
+		return err
+	}
+	name := ""
+	if args[0] == MarkStart {
+		name = args[1]
+	}
+	name, err := quotaHistory.mark(args[0], name)
+	if err != nil {
+		return err
+	}
+
+	printer := localizer(config.Language)
+	if args[0] == MarkStart {
+		fmt.Fprintln(stdout, printer.Sprintf(msgSessionStarted, name))
+	} else {
+		fmt.Fprintln(stdout, printer.Sprintf(msgSessionStopped, name))
+	}
+	return nil
+}
+
+// runSummaryCommand prints the quota each model used over --since, or per labeled session
+// with --by-session
+func runSummaryCommand(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("summary", flag.ContinueOnError)
+	bySession := flags.Bool("by-session", false, "attribute used quota to each labeled session")
+	since := flags.Duration("since", 24*time.Hour, "period to summarize, or to list sessions from")
+	asJSON := flags.Bool("json", false, "print the summary as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config := LoadConfig()
+	if err := openHistoryFile(config); err != nil {
+		return err
+	}
+	now := clockNow()
+	start := now.Add(-*since)
+
+	if !*bySession {
+		models := quotaHistory.consumed(start, now)
+		periods := quotaHistory.periodComparisons(now)
+		if *asJSON {
+			return writeJSON(stdout, map[string]interface{}{"since": start.Unix(), "until": now.Unix(), "models": models, "periods": periods})
+		}
+		writeSummary(stdout, config, "", models)
+		for _, period := range periods {
+			fmt.Fprintln(stdout, formatPeriodComparison(config, period))
+		}
+		return nil
+	}
+
+	summaries := summarizeSessions(quotaHistory, start, now)
+	if *asJSON {
+		return writeJSON(stdout, map[string]interface{}{"sessions": summaries})
+	}
+	printer := localizer(config.Language)
+	for _, summary := range summaries {
+		from := time.Unix(summary.Start, 0).Local().Format("Jan 2 15:04")
+		heading := printer.Sprintf(msgSummarySession, summary.Name, from, time.Unix(summary.End, 0).Local().Format("15:04"))
+		if summary.Open {
+			heading = printer.Sprintf(msgSummaryOpenSession, summary.Name, from)
+		}
+		writeSummary(stdout, config, heading, summary.Models)
+	}
+	return nil
+}
+
+// writeJSON writes value as indented JSON
+func writeJSON(w io.Writer, value interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
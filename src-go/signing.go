@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RequestSigner adds a signature to an outgoing provider request, after its headers are set
+type RequestSigner interface {
+	Sign(req *http.Request) error
+}
+
+// RequestSignerFunc adapts a function to a RequestSigner
+type RequestSignerFunc func(req *http.Request) error
+
+func (f RequestSignerFunc) Sign(req *http.Request) error {
+	return f(req)
+}
+
+// signerChain applies signers in order, so a later one signs what an earlier one produced,
+// such as an HMAC over a freshly assembled JWT
+type signerChain []RequestSigner
+
+func (c signerChain) Sign(req *http.Request) error {
+	for _, signer := range c {
+		if err := signer.Sign(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// signingTransport signs a copy of each request before passing it to the next transport
+type signingTransport struct {
+	next   http.RoundTripper
+	signer RequestSigner
+}
+
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	signed := req.Clone(req.Context())
+	if err := t.signer.Sign(signed); err != nil {
+		return nil, err
+	}
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return next.RoundTrip(signed)
+}
+
+// requestSignerNames are the signers REQUEST_SIGNING_<PROVIDER> may list
+var requestSignerNames = []string{"zhipu-jwt", "hmac", "sigv4"}
+
+// defaultRequestSigners are the signers of providers whose endpoints need one, such as the
+// Zhipu open platform, which takes a token derived from an id.secret key rather than the key
+var defaultRequestSigners = map[string][]string{
+	ProviderZhipuBalance: {"zhipu-jwt"},
+}
+
+// signingVariable returns the variable listing a provider's request signers, e.g.
+// REQUEST_SIGNING_ZHIPU_BALANCE
+func signingVariable(provider string) string {
+	return "REQUEST_SIGNING_" + strings.ToUpper(strings.ReplaceAll(provider, "-", "_"))
+}
+
+// loadRequestSigners reads the REQUEST_SIGNING_<PROVIDER> signer lists, which replace the
+// defaults of their providers
+func loadRequestSigners() map[string][]string {
+	signers := maps.Clone(defaultRequestSigners)
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		rest, ok := strings.CutPrefix(name, "REQUEST_SIGNING_")
+		if !ok || rest == "" || strings.HasPrefix(rest, "SECRET_") {
+			continue
+		}
+		if names := parseList(trimQuotes(value)); len(names) > 0 {
+			signers[strings.ToLower(strings.ReplaceAll(rest, "_", "-"))] = names
+		}
+	}
+	return signers
+}
+
+// newRequestSigner builds one named signer for provider. It finds its keys' sources when it
+// is built and reads them when it signs, so rotated keys apply on the next request and
+// building a client runs no secret command.
+func newRequestSigner(config *Config, provider, name string) (RequestSigner, error) {
+	suffix := strings.TrimPrefix(signingVariable(provider), "REQUEST_SIGNING_")
+	switch strings.ToLower(name) {
+	case "zhipu-jwt":
+		header, _ := authHeader(config, provider, "")
+		return zhipuJWTSigner(header), nil
+	case "hmac":
+		variable := "REQUEST_SIGNING_SECRET_" + suffix
+		secret := secretToken(variable)
+		if secret == nil {
+			return nil, withFix(fmt.Errorf("hmac request signing of %s has no secret", provider),
+				"Set "+variable+" to the shared signing secret")
+		}
+		return RequestSignerFunc(func(req *http.Request) error {
+			key, err := readToken(req.Context(), secret)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", variable, err)
+			}
+			return hmacSigner([]byte(key)).Sign(req)
+		}), nil
+	case "sigv4":
+		accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), secretToken("AWS_SECRET_ACCESS_KEY")
+		if accessKey == "" || secretKey == nil {
+			return nil, withFix(fmt.Errorf("sigv4 request signing of %s has no AWS credentials", provider),
+				"Set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+		}
+		sessionToken := secretToken("AWS_SESSION_TOKEN")
+		region, service := getEnvOrDefault("AWS_REGION", "us-east-1"), getEnvOrDefault("AWS_SIGV4_SERVICE", "bedrock")
+		return RequestSignerFunc(func(req *http.Request) error {
+			creds := awsCredentials{AccessKey: accessKey, Region: region, Service: service}
+			var err error
+			if creds.SecretKey, err = readToken(req.Context(), secretKey); err != nil {
+				return fmt.Errorf("failed to read AWS_SECRET_ACCESS_KEY: %w", err)
+			}
+			if creds.SessionToken, err = readToken(req.Context(), sessionToken); err != nil {
+				return fmt.Errorf("failed to read AWS_SESSION_TOKEN: %w", err)
+			}
+			return sigV4Signer(creds).Sign(req)
+		}), nil
+	}
+	return nil, withFix(fmt.Errorf("unknown request signer %q for %s", name, provider),
+		"Set "+signingVariable(provider)+" to a list of: "+strings.Join(requestSignerNames, ", "))
+}
+
+// signedClient returns client with its transport wrapped in the request signers configured
+// for provider, or client itself when the provider signs nothing
+func signedClient(client *http.Client, config *Config, provider string) (*http.Client, error) {
+	names := config.RequestSigners[provider]
+	if len(names) == 0 {
+		return client, nil
+	}
+	chain := make(signerChain, 0, len(names))
+	for _, name := range names {
+		signer, err := newRequestSigner(config, provider, name)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, signer)
+	}
+	signed := *client
+	signed.Transport = &signingTransport{next: client.Transport, signer: chain}
+	return &signed, nil
+}
+
+// requestBody returns a request's body without consuming it, restoring it when the request
+// cannot produce a fresh copy
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+	payload, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(payload))
+	return payload, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// zhipuJWTSigner replaces a Zhipu open-platform key of the form id.secret in the named auth
+// header with its short-lived token. Other keys, including JWTs, are sent as they are.
+func zhipuJWTSigner(header string) RequestSigner {
+	return RequestSignerFunc(func(req *http.Request) error {
+		value := req.Header.Get(header)
+		key := value[strings.LastIndex(value, " ")+1:]
+		token, err := zhipuAPIToken(key)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(header, strings.TrimSuffix(value, key)+token)
+		return nil
+	})
+}
+
+// hmacSigner sets X-Timestamp to the Unix time and X-Signature to the hex HMAC-SHA256 of the
+// timestamp, method, request URI, and body hash, one per line
+func hmacSigner(secret []byte) RequestSigner {
+	return RequestSignerFunc(func(req *http.Request) error {
+		body, err := requestBody(req)
+		if err != nil {
+			return fmt.Errorf("failed to read request body for signing: %w", err)
+		}
+		timestamp := strconv.FormatInt(clockNow().Unix(), 10)
+		message := strings.Join([]string{timestamp, req.Method, req.URL.RequestURI(), sha256Hex(body)}, "\n")
+		req.Header.Set("X-Timestamp", timestamp)
+		req.Header.Set("X-Signature", hex.EncodeToString(hmacSHA256(secret, message)))
+		return nil
+	})
+}
+
+// awsCredentials are the keys and scope of AWS Signature Version 4 signing
+type awsCredentials struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	Region       string
+	Service      string
+}
+
+// awsEscape percent-encodes everything but unreserved characters, and "/" unless it is
+// part of a query component
+func awsEscape(value string, keepSlash bool) string {
+	var b strings.Builder
+	for _, c := range []byte(value) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', keepSlash && c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalQuery sorts a query by key and value and encodes it the way SigV4 hashes it
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, awsEscape(key, false)+"="+awsEscape(value, false))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// sigV4Signer signs requests with AWS Signature Version 4, as Bedrock and other AWS APIs
+// require. It signs the host, the content type, and every X-Amz- header.
+func sigV4Signer(creds awsCredentials) RequestSigner {
+	return RequestSignerFunc(func(req *http.Request) error {
+		body, err := requestBody(req)
+		if err != nil {
+			return fmt.Errorf("failed to read request body for signing: %w", err)
+		}
+		if creds.Region == "" || creds.Service == "" {
+			return errors.New("sigv4 signing needs a region and a service")
+		}
+
+		now := clockNow().UTC()
+		amzDate := now.Format("20060102T150405Z")
+		date := now.Format("20060102")
+		req.Header.Set("X-Amz-Date", amzDate)
+		if creds.SessionToken != "" {
+			req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+		}
+
+		host := req.Host
+		if host == "" {
+			host = req.URL.Host
+		}
+		headers := map[string]string{"host": host}
+		for name, values := range req.Header {
+			lower := strings.ToLower(name)
+			if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+				headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+			}
+		}
+		names := make([]string, 0, len(headers))
+		for name := range headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var canonicalHeaders strings.Builder
+		for _, name := range names {
+			canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+		}
+		signedHeaders := strings.Join(names, ";")
+
+		path := req.URL.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+		canonical := strings.Join([]string{
+			req.Method,
+			awsEscape(path, true),
+			canonicalQuery(req.URL.Query()),
+			canonicalHeaders.String(),
+			signedHeaders,
+			sha256Hex(body),
+		}, "\n")
+
+		scope := date + "/" + creds.Region + "/" + creds.Service + "/aws4_request"
+		stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+		key := hmacSHA256([]byte("AWS4"+creds.SecretKey), date)
+		for _, part := range []string{creds.Region, creds.Service, "aws4_request"} {
+			key = hmacSHA256(key, part)
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+			creds.AccessKey, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign))))
+		return nil
+	})
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// File the silence command writes to and the server reads, unless SILENCE_FILE is set
+const DefaultSilenceFile = "silence.json"
+
+// Silence suppresses alerts until a time, written by the silence command
+type Silence struct {
+	Until  int64  `json:"until"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// quietRange is a daily range of local time from QUIET_HOURS, in minutes after midnight. It
+// wraps past midnight when end is before start.
+type quietRange struct {
+	start, end int
+}
+
+// parseQuietHours parses comma-separated HH:MM-HH:MM ranges, skipping invalid ones
+func parseQuietHours(value string) []quietRange {
+	var ranges []quietRange
+	for _, item := range parseList(value) {
+		from, to, ok := strings.Cut(item, "-")
+		if !ok {
+			continue
+		}
+		start, err1 := time.Parse("15:04", strings.TrimSpace(from))
+		end, err2 := time.Parse("15:04", strings.TrimSpace(to))
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		ranges = append(ranges, quietRange{start.Hour()*60 + start.Minute(), end.Hour()*60 + end.Minute()})
+	}
+	return ranges
+}
+
+// contains reports whether the local time of t falls in the range, including its start but
+// not its end
+func (r quietRange) contains(t time.Time) bool {
+	t = t.Local()
+	minute := t.Hour()*60 + t.Minute()
+	if r.start <= r.end {
+		return minute >= r.start && minute < r.end
+	}
+	return minute >= r.start || minute < r.end
+}
+
+// readSilence reads the silence file, returning no silence when it does not exist
+func readSilence(path string) (Silence, error) {
+	var silence Silence
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return silence, nil
+	}
+	if err != nil {
+		return silence, err
+	}
+	err = json.Unmarshal(data, &silence)
+	return silence, err
+}
+
+// alertsSilenced reports whether alerts are silenced at now: during QUIET_HOURS, or until a
+// time set with the silence command. Email and push notifications are dropped, incidents are
+// held and opened when the silence ends, and hook commands still run.
+func alertsSilenced(config *Config, now time.Time) bool {
+	for _, r := range config.QuietHours {
+		if r.contains(now) {
+			return true
+		}
+	}
+	silence, err := readSilence(config.SilenceFile)
+	return err == nil && now.Unix() < silence.Until
+}
+
+// runSilenceCommand silences alerts for a duration with "silence 2h [reason]", lifts the
+// silence with "silence off", or prints the current silence
+func runSilenceCommand(args []string, stdout io.Writer) error {
+	config := LoadConfig()
+	printer := localizer(config.Language)
+	now := clockNow()
+
+	if len(args) == 0 {
+		silence, err := readSilence(config.SilenceFile)
+		if err != nil {
+			return err
+		}
+		if now.Unix() >= silence.Until {
+			fmt.Fprintln(stdout, printer.Sprintf(msgSilenceNone))
+			return nil
+		}
+		printSilence(stdout, config, silence)
+		return nil
+	}
+
+	if args[0] == "off" {
+		if err := os.Remove(config.SilenceFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		fmt.Fprintln(stdout, printer.Sprintf(msgSilenceLifted))
+		return nil
+	}
+
+	duration, err := time.ParseDuration(args[0])
+	if err != nil || duration <= 0 {
+		return errors.New("usage: silence <duration> [reason] | silence off")
+	}
+	silence := Silence{Until: now.Add(duration).Unix(), Reason: strings.Join(args[1:], " ")}
+	data, err := json.Marshal(silence)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(config.SilenceFile, data, 0600); err != nil {
+		return err
+	}
+	printSilence(stdout, config, silence)
+	return nil
+}
+
+// printSilence prints when a silence ends and why it was set
+func printSilence(stdout io.Writer, config *Config, silence Silence) {
+	line := localizer(config.Language).Sprintf(msgSilenceUntil, time.Unix(silence.Until, 0).Local().Format("Jan 2 15:04"))
+	if silence.Reason != "" {
+		line += " (" + silence.Reason + ")"
+	}
+	fmt.Fprintln(stdout, line)
+}
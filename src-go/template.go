@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// templateFormat is the format name that renders a user-supplied text/template
+const templateFormat = "template"
+
+// Width of bars drawn by the template "bar" helper when none is given
+const defaultBarWidth = 10
+
+// templateFuncs are the helpers available to custom templates
+var templateFuncs = template.FuncMap{
+	"color":    templateColor,
+	"bar":      templateBar,
+	"round":    templateRound,
+	"humanize": templateHumanize,
+}
+
+// templateColor wraps text in the ANSI color of a percentage: green from 50, yellow from 20,
+// red below. Without text it colors the percentage itself.
+func templateColor(pct float64, text ...string) string {
+	const (
+		Green  = "\033[32m"
+		Yellow = "\033[33m"
+		Red    = "\033[31m"
+		Reset  = "\033[0m"
+	)
+
+	value := formatPercentage(pct) + "%"
+	if len(text) > 0 {
+		value = strings.Join(text, " ")
+	}
+	switch {
+	case pct >= QuotaGood:
+		return Green + value + Reset
+	case pct >= QuotaWarning:
+		return Yellow + value + Reset
+	default:
+		return Red + value + Reset
+	}
+}
+
+// templateBar draws a percentage as a bar of filled and empty blocks, 10 wide by default
+func templateBar(pct float64, width ...int) string {
+	size := defaultBarWidth
+	if len(width) > 0 && width[0] > 0 {
+		size = width[0]
+	}
+	filled := int(math.Round(clampPercentage(pct) / 100 * float64(size)))
+	return strings.Repeat("█", filled) + strings.Repeat("░", size-filled)
+}
+
+// templateRound rounds a number to the given decimal places, none by default
+func templateRound(value float64, places ...int) float64 {
+	precision := 0
+	if len(places) > 0 {
+		precision = places[0]
+	}
+	return roundPercentage(value, precision)
+}
+
+// templateHumanize formats a reset time as a compact duration ("2h15m") and a count with
+// locale separators ("1,234,567")
+func templateHumanize(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return formatTimeCompact(v)
+	case int:
+		return formatNumber(LoadConfig().NumberLocale, int64(v))
+	case int64:
+		return formatNumber(LoadConfig().NumberLocale, v)
+	case float64:
+		return formatNumber(LoadConfig().NumberLocale, int64(math.Round(v)))
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// templateRenderer renders a quota with a parsed text/template
+type templateRenderer struct {
+	tmpl *template.Template
+}
+
+// Render executes the template with the quota as its data
+func (r templateRenderer) Render(quota FormattedQuota) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := r.tmpl.Execute(&buf, quota); err != nil {
+		return nil, fmt.Errorf("template failed: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// newTemplateRenderer parses text as a text/template with the helper funcs
+func newTemplateRenderer(text string) (Renderer, error) {
+	if text == "" {
+		return nil, errors.New("the template format needs a template")
+	}
+	tmpl, err := template.New(templateFormat).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return templateRenderer{tmpl: tmpl}, nil
+}
+
+// resolveRenderer returns the renderer for format, parsing templateText for the template format
+func resolveRenderer(format, templateText string) (Renderer, error) {
+	if format == templateFormat {
+		return newTemplateRenderer(templateText)
+	}
+
+	renderersMu.RLock()
+	renderer, ok := renderers[format]
+	renderersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q (available: %s, %s)", format, strings.Join(RendererNames(), ", "), templateFormat)
+	}
+	return renderer, nil
+}
+
+// runShowCommand prints one provider's quota in any output format
+func runShowCommand(args []string, stdout io.Writer) error {
+	config := LoadConfig()
+	flags := flag.NewFlagSet("show", flag.ContinueOnError)
+	provider := flags.String("provider", ProviderAntigravity, "provider to show")
+	format := flags.String("format", config.OutputFormat, "output format, or template with --template")
+	templateText := flags.String("template", config.OutputTemplate, "text/template for --format template, e.g. '{{range .Models}}{{.Name}}:{{.Percentage}}% {{end}}'")
+	sortKey := flags.String("sort", config.OutputSort, "sort models by name, percentage, or provider (- prefix to reverse)")
+	group := flags.String("group", config.OutputGroup, "group models by provider or account")
+	viaDaemon := flags.Bool("via-daemon", false, "only get the quota from the running server at DAEMON_ADDRESS")
+	direct := flags.Bool("direct", false, "query the provider even when a server is running")
+	dryRun := flags.Bool("dry-run", false, "print the requests and cache keys a query would use without sending anything")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *dryRun {
+		return writeQueryPlan(stdout, planQuery(context.Background(), config, *provider), *format == "json")
+	}
+	mode := getEnvOrDefault("DAEMON_MODE", daemonAuto)
+	switch {
+	case *viaDaemon:
+		mode = daemonAlways
+	case *direct:
+		mode = daemonNever
+	case !slices.Contains(daemonModes, mode):
+		return fmt.Errorf("unknown DAEMON_MODE %q (available: %s)", mode, strings.Join(daemonModes, ", "))
+	}
+
+	renderer, err := resolveRenderer(*format, *templateText)
+	if err != nil {
+		return err
+	}
+	quota, err := fetchQuotaPreferringDaemon(context.Background(), config, *provider, mode)
+	if err != nil {
+		var problems MultiError
+		problems.Add(*provider, err)
+		if *format == "json" {
+			writeJSON(stdout, map[string][]Problem{"problems": problems.Problems})
+		}
+		return &problems
+	}
+
+	arranged, err := arrangeModels(selectModels(quota, config.ModelOnly, config.ModelExclude), *sortKey, *group)
+	if err != nil {
+		return err
+	}
+	output, err := renderer.Render(*arranged)
+	if err != nil {
+		return err
+	}
+	if len(output) > 0 && output[len(output)-1] != '\n' {
+		output = append(output, '\n')
+	}
+	_, err = stdout.Write(output)
+	return err
+}
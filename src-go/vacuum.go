@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// Interval between compactions of the history file while serving
+const historyVacuumInterval = 24 * time.Hour
+
+// VacuumResult reports a compaction of the history file
+type VacuumResult struct {
+	Lines  int   `json:"lines"`
+	Kept   int   `json:"kept"`
+	Before int64 `json:"bytes_before"`
+	After  int64 `json:"bytes_after"`
+}
+
+// rollupDay identifies the daily rollup a record belongs to
+type rollupDay struct {
+	member string
+	key    string
+	date   string
+}
+
+// compactHistory returns the history lines to keep, in their order: raw records newer
+// than retention, the last record of each member's model per local day before that, and
+// session marks, dropping rollups and marks older than rollupLimit when it is set. Lines
+// that do not parse are dropped.
+func compactHistory(lines [][]byte, now time.Time, retention, rollupLimit time.Duration) [][]byte {
+	rawCutoff := now.Add(-retention).Unix()
+	var rollupCutoff int64
+	if rollupLimit > 0 {
+		rollupCutoff = now.Add(-rollupLimit).Unix()
+	}
+
+	parsed := make([]historyLine, len(lines))
+	valid := make([]bool, len(lines))
+	latest := make(map[rollupDay]int)
+	for i, line := range lines {
+		if json.Unmarshal(line, &parsed[i]) != nil || parsed[i].Time < rollupCutoff {
+			continue
+		}
+		valid[i] = true
+		record := parsed[i]
+		if record.Mark != "" || record.Time >= rawCutoff {
+			continue
+		}
+		day := rollupDay{record.Member, burnKey(record.Provider, record.Model), time.Unix(record.Time, 0).Local().Format(time.DateOnly)}
+		if j, ok := latest[day]; !ok || record.Time >= parsed[j].Time {
+			latest[day] = i
+		}
+	}
+
+	var kept [][]byte
+	for i, line := range lines {
+		if !valid[i] {
+			continue
+		}
+		record := parsed[i]
+		if record.Mark == "" && record.Time < rawCutoff {
+			day := rollupDay{record.Member, burnKey(record.Provider, record.Model), time.Unix(record.Time, 0).Local().Format(time.DateOnly)}
+			if latest[day] != i {
+				continue
+			}
+		}
+		kept = append(kept, line)
+	}
+	return kept
+}
+
+// vacuum rewrites the history file with only the lines compactHistory keeps. It holds the
+// file's lock, so appends from this and other processes wait until the new file is in place.
+func (h *historyStore) vacuum(rollupLimit time.Duration) (VacuumResult, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	store, ok := h.store.(*fileHistoryStore)
+	if !ok {
+		return VacuumResult{}, errors.New("only a history file can be compacted; run history vacuum on the server that keeps it")
+	}
+	release, err := acquireFileLock(historyLockPath(store.path), historyLockWait)
+	if err != nil {
+		return VacuumResult{}, err
+	}
+	defer release()
+	content, err := os.ReadFile(store.path)
+	if os.IsNotExist(err) {
+		return VacuumResult{}, nil
+	}
+	if err != nil {
+		return VacuumResult{}, err
+	}
+	var lines [][]byte
+	for line := range bytes.SplitSeq(content, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	kept := compactHistory(lines, clockNow(), h.retention, rollupLimit)
+
+	var out bytes.Buffer
+	for _, line := range kept {
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+
+	if err := writeFileAtomic(store.path, out.Bytes(), 0600); err != nil {
+		return VacuumResult{}, err
+	}
+	return VacuumResult{Lines: len(lines), Kept: len(kept), Before: int64(len(content)), After: int64(out.Len())}, nil
+}
+
+// rollupRetentionOf returns the HISTORY_ROLLUP_RETENTION period, 0 to keep rollups forever
+func rollupRetentionOf(config *Config) time.Duration {
+	return time.Duration(config.HistoryRollupDays) * 24 * time.Hour
+}
+
+// vacuumHistoryPeriodically compacts the history file now and every historyVacuumInterval,
+// so a server that runs for months keeps a bounded file
+func vacuumHistoryPeriodically(ctx context.Context, client *CloudCodeClient) {
+	ticker := time.NewTicker(historyVacuumInterval)
+	defer ticker.Stop()
+	for {
+		result, err := quotaHistory.vacuum(rollupRetentionOf(client.Config()))
+		if err != nil {
+			log.Printf("Failed to compact history: %v", err)
+		} else if result.Kept < result.Lines {
+			log.Printf("Compacted history: kept %d of %d lines (%d -> %d bytes)", result.Kept, result.Lines, result.Before, result.After)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runHistoryCommand handles "history vacuum", compacting HISTORY_FILE on demand
+func runHistoryCommand(args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] != "vacuum" {
+		return errors.New("usage: history vacuum [--json]")
+	}
+	flags := flag.NewFlagSet("history vacuum", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the result as JSON")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	config := LoadConfig()
+	if config.HistoryFile == "" {
+		return errors.New("HISTORY_FILE is not set; there is no history to compact")
+	}
+	history := newHistoryStore()
+	history.store = &fileHistoryStore{path: config.HistoryFile}
+	history.retention = time.Duration(config.HistoryRawDays) * 24 * time.Hour
+	result, err := history.vacuum(rollupRetentionOf(config))
+	if err != nil {
+		return fmt.Errorf("failed to compact history: %w", err)
+	}
+
+	if *asJSON {
+		return writeJSON(stdout, result)
+	}
+	_, err = fmt.Fprintln(stdout, localizer(config.Language).Sprintf(msgHistoryVacuum, result.Kept, result.Lines,
+		formatNumber(config.NumberLocale, result.Before), formatNumber(config.NumberLocale, result.After)))
+	return err
+}
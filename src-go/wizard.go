@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// wizardVariables maps providers to the variable the wizard asks for; the others sign in
+// another way, shown by their doctor fix
+var wizardVariables = map[string]string{
+	ProviderGLM:          "ZAI_AUTH_TOKEN",
+	ProviderZhipuBalance: "ZHIPU_AUTH_TOKEN",
+	ProviderCursor:       "CURSOR_SESSION_TOKEN",
+	ProviderWindsurf:     "WINDSURF_API_KEY",
+	ProviderXAI:          "XAI_API_KEY",
+	ProviderGroq:         "GROQ_API_KEY",
+	ProviderMistral:      "MISTRAL_API_KEY",
+}
+
+// statusSnippets are the status bar segments the wizard offers, by shell or multiplexer,
+// with %[1]s the executable and %[2]s the provider
+var statusSnippets = map[string]string{
+	"zsh": `# ~/.zshrc
+setopt prompt_subst
+RPROMPT='$(%[1]s show --provider %[2]s --format prompt --refresh 2>/dev/null)'`,
+	"fish": `# ~/.config/fish/functions/fish_right_prompt.fish
+function fish_right_prompt
+    %[1]s show --provider %[2]s --format prompt --refresh 2>/dev/null
+end`,
+	"tmux": `# ~/.tmux.conf
+set -g status-right '#(%[1]s show --provider %[2]s --format prompt --refresh 2>/dev/null)'`,
+}
+
+// setupWizard walks through first-run setup on a terminal: it reports the providers that are
+// already configured, asks for the keys of others and tests them, writes the env file, and
+// offers the service and a status bar segment
+type setupWizard struct {
+	in      *bufio.Reader
+	out     io.Writer
+	envFile string
+
+	// fetch tests a provider with the current environment, and installService installs the
+	// server; both are replaced in tests
+	fetch          func(ctx context.Context, provider string) (*FormattedQuota, error)
+	installService func() error
+}
+
+// newSetupWizard creates a wizard reading answers from in
+func newSetupWizard(in io.Reader, out io.Writer, envFile string) *setupWizard {
+	return &setupWizard{
+		in:      bufio.NewReader(in),
+		out:     out,
+		envFile: envFile,
+		fetch: func(ctx context.Context, provider string) (*FormattedQuota, error) {
+			return NewQuotaService(NewCloudCodeClient(LoadConfig())).fetchQuota(ctx, provider)
+		},
+		installService: func() error { return runServiceCommand([]string{"install"}) },
+	}
+}
+
+// ask prints a question and returns the trimmed answer, or def when it is empty or input ended
+func (w *setupWizard) ask(question, def string) string {
+	fmt.Fprint(w.out, question)
+	answer, _ := w.in.ReadString('\n')
+	if answer = strings.TrimSpace(answer); answer != "" {
+		return answer
+	}
+	return def
+}
+
+// confirm asks a yes or no question
+func (w *setupWizard) confirm(question string, def bool) bool {
+	hint := " [y/N] "
+	if def {
+		hint = " [Y/n] "
+	}
+	switch strings.ToLower(w.ask(question+hint, "")) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	}
+	return def
+}
+
+// test fetches a provider's quota with the new settings in the environment and reports it
+func (w *setupWizard) test(ctx context.Context, provider string) bool {
+	fmt.Fprintf(w.out, "  Testing %s... ", provider)
+	quota, err := w.fetch(ctx, provider)
+	if err != nil {
+		fmt.Fprintf(w.out, "failed: %v\n", err)
+		return false
+	}
+	fmt.Fprintf(w.out, "ok, %d models\n", len(quota.Models))
+	return true
+}
+
+// run goes through every step; declining one skips it without ending the wizard
+func (w *setupWizard) run(ctx context.Context) error {
+	existing, err := readEnvFile(w.envFile)
+	if err != nil {
+		return err
+	}
+	config := LoadConfig()
+	var configured []string
+	fmt.Fprintln(w.out, "Providers:")
+	for _, provider := range providerNames() {
+		status := "not configured"
+		if spec, ok := doctorProviders[provider]; ok && spec.configured(config) {
+			status = "configured"
+			// Local models need no setup and have no quota to show
+			if provider != ProviderLocal {
+				configured = append(configured, provider)
+			}
+		}
+		fmt.Fprintf(w.out, "  %-14s %s\n", provider, status)
+	}
+	fmt.Fprintln(w.out)
+
+	// Keys from agent CLIs first, then the providers still without one
+	var added []DetectedSetting
+	set := func(setting DetectedSetting) {
+		os.Setenv(setting.Name, setting.Value)
+		added = append(added, setting)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		for _, setting := range detectAgentSettings(home) {
+			if existing[setting.Name] == "" && os.Getenv(setting.Name) == "" &&
+				w.confirm(fmt.Sprintf("Found %s in %s. Use it?", setting.Name, setting.Source), true) {
+				set(setting)
+			}
+		}
+	}
+	enabled := slices.Clone(configured)
+	verify := func(provider, variable string) {
+		if w.test(ctx, provider) || w.confirm("  Keep it anyway?", false) {
+			enabled = append(enabled, provider)
+			return
+		}
+		os.Unsetenv(variable)
+		added = slices.DeleteFunc(added, func(s DetectedSetting) bool { return s.Name == variable })
+	}
+	for _, provider := range providerNames() {
+		if provider == ProviderLocal || slices.Contains(configured, provider) {
+			continue
+		}
+		variable, asks := wizardVariables[provider]
+		if asks && os.Getenv(variable) != "" {
+			// Set from an agent CLI above
+			verify(provider, variable)
+			continue
+		}
+		if !w.confirm(fmt.Sprintf("Set up %s?", provider), false) {
+			continue
+		}
+		fmt.Fprintf(w.out, "  %s\n", doctorProviders[provider].fix)
+		if !asks {
+			continue
+		}
+		value := w.ask(fmt.Sprintf("  %s: ", variable), "")
+		if value == "" {
+			continue
+		}
+		set(DetectedSetting{Name: variable, Value: value})
+		verify(provider, variable)
+	}
+
+	if len(added) > 0 {
+		if w.confirm(fmt.Sprintf("Write %d variables to %s?", len(added), w.envFile), true) {
+			if err := appendEnvFile(w.envFile, "Written by init", added); err != nil {
+				return err
+			}
+			fmt.Fprintf(w.out, "Wrote %s\n", w.envFile)
+		}
+	} else {
+		fmt.Fprintln(w.out, "No new variables to write")
+	}
+
+	if w.confirm("Install the server as a background service?", false) {
+		if err := w.installService(); err != nil {
+			fmt.Fprintf(w.out, "Could not install the service: %v\n", err)
+		}
+	}
+
+	if len(enabled) == 0 {
+		return nil
+	}
+	shells := slices.Sorted(maps.Keys(statusSnippets))
+	choice := w.ask(fmt.Sprintf("Add a quota segment to your status bar? (%s, none) [none] ", strings.Join(shells, ", ")), "none")
+	snippet, ok := statusSnippets[strings.ToLower(choice)]
+	if !ok {
+		return nil
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		executable = serviceName
+	}
+	fmt.Fprintf(w.out, "\nAdd this to your configuration:\n\n%s\n", fmt.Sprintf(snippet, executable, enabled[0]))
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// XAIModelLimits holds the per-minute request (RPM) and token (TPM) limits of a model
+type XAIModelLimits struct {
+	Model string
+	RPM   *RateLimit
+	TPM   *RateLimit
+}
+
+// XAIUsage represents the state of an xAI API key
+type XAIUsage struct {
+	TeamID  string
+	Blocked bool
+	Models  []XAIModelLimits
+
+	// Credits is the prepaid balance in USD, nil without a management key
+	Credits *float64
+
+	// FetchedAt is when the data was retrieved from the API
+	FetchedAt time.Time
+}
+
+// xaiAPIKeyInfo is the /v1/api-key response
+type xaiAPIKeyInfo struct {
+	TeamID        string `json:"team_id"`
+	APIKeyBlocked bool   `json:"api_key_blocked"`
+	TeamBlocked   bool   `json:"team_blocked"`
+}
+
+// xaiPrepaidBalance is the management API prepaid balance response, in US cents.
+// A negative total is unspent credit.
+type xaiPrepaidBalance struct {
+	Total struct {
+		Val string `json:"val"`
+	} `json:"total"`
+}
+
+// queryXAIUsage reads key status, per-model rate limits, and optionally prepaid credits
+func queryXAIUsage(ctx context.Context, config *Config, apiKey string) (*XAIUsage, error) {
+	baseURL := strings.TrimRight(config.XAIBaseURL, "/")
+	name, value := authHeader(config, ProviderXAI, apiKey)
+	auth := map[string]string{name: value}
+
+	var keyInfo xaiAPIKeyInfo
+	if err := doJSON(ctx, ProviderXAI, "GET", baseURL+"/v1/api-key", auth, nil, &keyInfo); err != nil {
+		return nil, err
+	}
+
+	usage := &XAIUsage{
+		TeamID:  keyInfo.TeamID,
+		Blocked: keyInfo.APIKeyBlocked || keyInfo.TeamBlocked,
+	}
+
+	for _, model := range config.XAIModels {
+		var info map[string]interface{}
+		header, err := doJSONWithHeaders(ctx, ProviderXAI, "GET", baseURL+"/v1/models/"+url.PathEscape(model), auth, nil, &info)
+		if err != nil {
+			return nil, err
+		}
+		usage.Models = append(usage.Models, XAIModelLimits{
+			Model: model,
+			RPM:   parseRateLimit(header, "requests"),
+			TPM:   parseRateLimit(header, "tokens"),
+		})
+	}
+
+	if config.XAIManagementKey != nil && usage.TeamID != "" {
+		managementKey, err := config.XAIManagementKey.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read XAI_MANAGEMENT_KEY: %w", err)
+		}
+		balanceURL := fmt.Sprintf("%s/v1/billing/teams/%s/prepaid/balance", strings.TrimRight(config.XAIManagementURL, "/"), url.PathEscape(usage.TeamID))
+		var balance xaiPrepaidBalance
+		headers := map[string]string{"Authorization": "Bearer " + managementKey}
+		if err := doJSON(ctx, ProviderXAI, "GET", balanceURL, headers, nil, &balance); err != nil {
+			return nil, err
+		}
+		cents, err := strconv.ParseFloat(balance.Total.Val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid xAI prepaid balance %q", balance.Total.Val)
+		}
+		credits := -cents / 100
+		usage.Credits = &credits
+	}
+
+	return usage, nil
+}
+
+// FormatXAIUsage formats xAI usage to match antigravity quota format.
+// Each model reports "xai-<model>-rpm" and "xai-<model>-tpm"; credits are "xai-credits".
+func FormatXAIUsage(usage *XAIUsage) FormattedQuota {
+	config := LoadConfig()
+
+	var models []FormattedModel
+	if usage.Credits != nil {
+		pct := 0.0
+		if *usage.Credits > 0 {
+			pct = QuotaFull
+		}
+		models = append(models, FormattedModel{
+			Name:       "xai-credits",
+			Percentage: pct,
+			Balance: &Balance{
+				Amount:   *usage.Credits,
+				Currency: "USD",
+				Display:  formatCurrency(config.NumberLocale, *usage.Credits, "USD"),
+			},
+		})
+	}
+
+	for _, model := range usage.Models {
+		limits := []struct {
+			suffix string
+			limit  *RateLimit
+		}{
+			{"rpm", model.RPM},
+			{"tpm", model.TPM},
+		}
+		for _, l := range limits {
+			if l.limit == nil {
+				continue
+			}
+			models = append(models, rateLimitModel(fmt.Sprintf("xai-%s-%s", model.Model, l.suffix), l.limit, config.PercentagePrecision))
+		}
+	}
+
+	models = applyModelAliases(models, config.ModelAliases)
+
+	quota := FormattedQuota{
+		Models:      models,
+		IsForbidden: usage.Blocked,
+	}
+	stampQuotaTime(&quota, usage.FetchedAt)
+	return quota
+}
+
+// GetXAIUsage gets credits and rate limits for the configured xAI API key
+func GetXAIUsage(ctx context.Context) (FormattedQuota, error) {
+	config := LoadConfig()
+	apiKey, err := readToken(ctx, config.XAIAPIKey)
+	if err != nil {
+		return FormattedQuota{}, fmt.Errorf("failed to read XAI_API_KEY: %w", err)
+	}
+	if apiKey == "" {
+		return FormattedQuota{}, fmt.Errorf("XAI_API_KEY environment variable is not set")
+	}
+
+	cacheKey := responseCacheKey(ProviderXAI, "", apiKey, config.XAIBaseURL, strings.Join(config.XAIModels, ","))
+	data, fetchedAt, err := cachedFetch(ctx, providerCache, ProviderXAI, cacheKey, func() (interface{}, error) {
+		return queryXAIUsage(ctx, config, apiKey)
+	})
+	if err != nil {
+		return FormattedQuota{}, err
+	}
+
+	usage := *data.(*XAIUsage)
+	usage.FetchedAt = fetchedAt
+	return FormatXAIUsage(&usage), nil
+}
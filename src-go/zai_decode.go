@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"golang.org/x/text/language"
+)
+
+// jsonIgnored accepts any JSON value without decoding it, so a field is known to the typed
+// decoder but costs nothing
+type jsonIgnored struct{}
+
+// UnmarshalJSON discards the value
+func (*jsonIgnored) UnmarshalJSON([]byte) error {
+	return nil
+}
+
+// zaiQuotaLimitEnvelope is a quota limit response with every field Z.ai currently sends
+type zaiQuotaLimitEnvelope struct {
+	Code    jsonIgnored        `json:"code"`
+	Msg     jsonIgnored        `json:"msg"`
+	Success jsonIgnored        `json:"success"`
+	Data    *zaiQuotaLimitData `json:"data"`
+}
+
+type zaiQuotaLimitData struct {
+	Limits []*zaiLimitPayload `json:"limits"`
+}
+
+// zaiLimitPayload is a limit entry of the current variant. Legacy entries have an unknown
+// field, currentUsage, so they fall back to the generic path that reports the variant.
+type zaiLimitPayload struct {
+	Type          string                   `json:"type"`
+	Percentage    float64                  `json:"percentage"`
+	CurrentValue  *float64                 `json:"currentValue"`
+	Usage         float64                  `json:"usage"`
+	UsageDetails  []*zaiUsageDetailPayload `json:"usageDetails"`
+	Unit          jsonIgnored              `json:"unit"`
+	Number        jsonIgnored              `json:"number"`
+	Remaining     jsonIgnored              `json:"remaining"`
+	NextResetTime jsonIgnored              `json:"nextResetTime"`
+}
+
+type zaiUsageDetailPayload struct {
+	ModelCode string  `json:"modelCode"`
+	Usage     float64 `json:"usage"`
+}
+
+// quotaLimitResult is the cached outcome of a quota limit response: its limits, labeled
+// with their raw identifiers, or the schema error they fail validation with
+type quotaLimitResult struct {
+	limits []ProcessedLimit
+	err    error
+}
+
+// decodeQuotaLimitTyped decodes a quota limit response straight into structs. It only
+// succeeds for an enveloped payload with no unknown fields and at least one known limit, so
+// anything unusual goes through the generic path with its variant handling, warnings, and
+// error messages.
+func decodeQuotaLimitTyped(body []byte) ([]ProcessedLimit, bool) {
+	var envelope zaiQuotaLimitEnvelope
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&envelope); err != nil || envelope.Data == nil {
+		return nil, false
+	}
+
+	limits := make([]ProcessedLimit, 0, len(envelope.Data.Limits))
+	known := false
+	for _, payload := range envelope.Data.Limits {
+		if payload == nil {
+			continue
+		}
+		limit := ProcessedLimit{Type: LimitUnknown, Label: payload.Type, Percentage: payload.Percentage, Total: int(payload.Usage)}
+		if limitType, ok := zaiLimitTypes[payload.Type]; ok {
+			known = true
+			limit.Type = limitType
+		}
+		if payload.CurrentValue != nil {
+			limit.CurrentUsage = int(*payload.CurrentValue)
+		}
+		for _, detail := range payload.UsageDetails {
+			if detail != nil {
+				limit.UsageDetails = append(limit.UsageDetails, ZAIUsageDetail{ModelCode: detail.ModelCode, Usage: int(detail.Usage)})
+			}
+		}
+		limits = append(limits, limit)
+	}
+	return limits, known
+}
+
+// decodeQuotaLimitBody decodes a quota limit response into a quotaLimitResult, through the
+// typed path when the payload allows it
+func decodeQuotaLimitBody(body []byte) (interface{}, error) {
+	if limits, ok := decodeQuotaLimitTyped(body); ok {
+		return quotaLimitResult{limits: limits}, nil
+	}
+
+	data, err := decodeZAIResponse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	processed := ProcessQuotaLimit(data)
+	return quotaLimitResult{limits: processed.Limits, err: validateGLMLimits(data, processed)}, nil
+}
+
+// localizeLimits returns a copy of cached limits with the known ones labeled in lang, so
+// a language change applies to cached data and the cache itself is never written
+func localizeLimits(limits []ProcessedLimit, lang language.Tag) []ProcessedLimit {
+	printer := localizer(lang)
+	localized := make([]ProcessedLimit, len(limits))
+	copy(localized, limits)
+	for i := range localized {
+		if key, ok := limitLabels[localized[i].Type]; ok {
+			localized[i].Label = printer.Sprintf(key)
+		}
+	}
+	return localized
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// domainFallback remembers, per platform and endpoint path, the base domain that last
+// answered, as ZHIPU moves monitor endpoints between its open., dev., and bare hosts
+type domainFallback struct {
+	mu      sync.Mutex
+	working map[string]string
+}
+
+var zaiDomains = &domainFallback{working: make(map[string]string)}
+
+// platformDomains returns the fallback list that holds domain, or none for a domain outside
+// both lists, such as a gateway, which is never swapped for another host
+func platformDomains(config *Config, domain string) []string {
+	for _, domains := range [][]string{config.ZAIDomains, config.ZhipuDomains} {
+		for _, candidate := range domains {
+			if strings.TrimRight(candidate, "/") == domain {
+				return domains
+			}
+		}
+	}
+	return nil
+}
+
+// splitEndpoint splits an endpoint URL into its base domain and the path after it
+func splitEndpoint(endpoint string) (string, string) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", endpoint
+	}
+	domain := u.Scheme + "://" + u.Host
+	return domain, strings.TrimPrefix(endpoint, domain)
+}
+
+// candidates returns the endpoint URLs to try in order: on the domain that last worked for
+// its path, then on the endpoint's own domain, then on the rest of its platform's list
+func (d *domainFallback) candidates(config *Config, endpoint string) []string {
+	domain, path := splitEndpoint(endpoint)
+	domains := platformDomains(config, domain)
+	if len(domains) == 0 {
+		return []string{endpoint}
+	}
+
+	order := []string{domain}
+	d.mu.Lock()
+	if working, ok := d.working[domain+" "+path]; ok {
+		order = []string{working, domain}
+	}
+	d.mu.Unlock()
+	for _, candidate := range domains {
+		order = append(order, strings.TrimRight(candidate, "/"))
+	}
+
+	var urls []string
+	for _, candidate := range order {
+		if !slices.Contains(urls, candidate+path) {
+			urls = append(urls, candidate+path)
+		}
+	}
+	return urls
+}
+
+// remember records that url answered for endpoint, so later queries try its domain first
+func (d *domainFallback) remember(endpoint, answered string) {
+	domain, path := splitEndpoint(endpoint)
+	working, _ := splitEndpoint(answered)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if previous := d.working[domain+" "+path]; previous != working && working != domain {
+		log.Printf("%s answered at %s, using it for later queries", path, working)
+	}
+	d.working[domain+" "+path] = working
+}
+
+// shouldFallBack reports whether a status means the endpoint may live on another domain
+func shouldFallBack(status int) bool {
+	return status == http.StatusNotFound || status >= http.StatusInternalServerError
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Name of the virtual model combining every GLM account
+	effectiveGLMModel = "glm:all"
+
+	// Name of the primary GLM token in ACCOUNT_WEIGHTS
+	primaryAccountName = "primary"
+)
+
+// ZAIAccount is an additional Z.ai/ZHIPU account queried on the same base URL as the
+// primary GLM token
+type ZAIAccount struct {
+	Name  string
+	Token string
+}
+
+// parseZAIAccounts parses "name=token,name2=token2" into accounts, keeping their order
+func parseZAIAccounts(value string) []ZAIAccount {
+	var accounts []ZAIAccount
+	for _, pair := range strings.Split(trimQuotes(value), ",") {
+		name, token, found := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		token = strings.TrimSpace(token)
+		if !found || name == "" || token == "" {
+			continue
+		}
+		accounts = append(accounts, ZAIAccount{Name: name, Token: token})
+	}
+	return accounts
+}
+
+// tokenLimit returns the 5-hour token limit of an account, if it reports one
+func tokenLimit(limits ProcessedZAILimit) (ProcessedLimit, bool) {
+	for _, limit := range limits.Limits {
+		if limit.Type == LimitTokens5h {
+			return limit, true
+		}
+	}
+	return ProcessedLimit{}, false
+}
+
+// effectiveTokenQuota combines the 5-hour token limits of several accounts into one remaining
+// percentage. Accounts are weighted by weights, the configured capacity of each, when given;
+// otherwise by their token cap when every account reports one, which makes the result the
+// share of total capacity left, and else equally.
+func effectiveTokenQuota(accounts []ProcessedZAILimit, weights []float64) (float64, bool) {
+	var limits []ProcessedLimit
+	var limitWeights []float64
+	capped := true
+	for i, account := range accounts {
+		if limit, ok := tokenLimit(account); ok {
+			limits = append(limits, limit)
+			capped = capped && limit.Total > 0
+			if weights != nil {
+				limitWeights = append(limitWeights, weights[i])
+			}
+		}
+	}
+	if len(limits) == 0 {
+		return 0, false
+	}
+
+	var remaining, capacity float64
+	for i, limit := range limits {
+		weight := 1.0
+		switch {
+		case limitWeights != nil:
+			weight = limitWeights[i]
+		case capped:
+			weight = float64(limit.Total)
+		}
+		remaining += weight * (100 - limit.Percentage)
+		capacity += weight
+	}
+	return clampPercentage(remaining / capacity), true
+}
+
+// fetchGLMLimits queries and validates the quota limits of one Z.ai/ZHIPU account, by its
+// label in ZAI_ACCOUNTS or "" for the default one
+func fetchGLMLimits(ctx context.Context, baseDomain, account, authToken string) (ProcessedZAILimit, error) {
+	return fetchGLMLimitsAt(ctx, baseDomain, account, authToken, "/quota/limit")
+}
+
+// fetchGLMLimitsAt queries and validates the limits of a monitor endpoint that reports them
+// in the quota limit format
+func fetchGLMLimitsAt(ctx context.Context, baseDomain, account, authToken, path string) (ProcessedZAILimit, error) {
+	quotaLimitURL := baseDomain + LoadConfig().ZAIMonitorPrefix + path
+	raw, fetchedAt, err := queryZAIEndpoint(ctx, ProviderGLM, account, quotaLimitURL, authToken, "", "limits", decodeQuotaLimitBody)
+	if err != nil {
+		return ProcessedZAILimit{}, err
+	}
+
+	// The decoded limits are cached, so cache hits do not walk the payload again
+	result := raw.(quotaLimitResult)
+	if result.err != nil {
+		return ProcessedZAILimit{}, result.err
+	}
+	return ProcessedZAILimit{Limits: localizeLimits(result.limits, LoadConfig().Language), FetchedAt: fetchedAt}, nil
+}
+
+// accountModels reports the token limit of each additional account as glm:<name>, plus
+// glm:all when EFFECTIVE_QUOTA is set, weighted by ACCOUNT_WEIGHTS when it is. results holds
+// the query outcome of each account in config.ZAIAccounts; accounts that failed are logged
+// and left out so one revoked token does not hide the others.
+func accountModels(config *Config, primary ProcessedZAILimit, results []glmLimitsResult) []FormattedModel {
+	var models []FormattedModel
+	accounts := []ProcessedZAILimit{primary}
+	names := []string{primaryAccountName}
+	for i, account := range config.ZAIAccounts {
+		limits, err := results[i].limits, results[i].err
+		if err != nil {
+			log.Printf("Warning: GLM account %s: %v", account.Name, err)
+			continue
+		}
+		accounts = append(accounts, limits)
+		names = append(names, account.Name)
+		if limit, ok := tokenLimit(limits); ok {
+			models = append(models, FormattedModel{
+				Name:       "glm:" + account.Name,
+				Percentage: roundPercentage(100-limit.Percentage, config.PercentagePrecision),
+				Account:    account.Name,
+			})
+		}
+	}
+
+	if config.EffectiveQuota {
+		var weights []float64
+		if len(config.AccountWeights) > 0 {
+			for _, name := range names {
+				weights = append(weights, weightOf(config.AccountWeights, name))
+			}
+		}
+		if percentage, ok := effectiveTokenQuota(accounts, weights); ok {
+			models = append(models, FormattedModel{
+				Name:       effectiveGLMModel,
+				Percentage: roundPercentage(percentage, config.PercentagePrecision),
+			})
+		}
+	}
+	return applyModelAliases(models, config.ModelAliases)
+}
+
+// parseWeights parses "name=weight,..." into positive weights, skipping malformed pairs
+func parseWeights(value string) map[string]float64 {
+	weights := make(map[string]float64)
+	for name, text := range parseModelAliases(value) {
+		if weight, err := strconv.ParseFloat(text, 64); err == nil && weight > 0 {
+			weights[name] = weight
+		}
+	}
+	return weights
+}
+
+// weightOf returns the configured weight of name, or 1 when it has none
+func weightOf(weights map[string]float64, name string) float64 {
+	if weight, ok := weights[name]; ok {
+		return weight
+	}
+	return 1
+}
+
+// weightedProviderQuota combines providers into one remaining percentage, each counted by
+// its most constrained model and weighted by PROVIDER_WEIGHTS, so a large plan outweighs a
+// small one. It reports false when no provider has models.
+func weightedProviderQuota(quotas map[string]*FormattedQuota, weights map[string]float64) (float64, bool) {
+	var remaining, capacity float64
+	for provider, quota := range quotas {
+		if len(quota.Models) == 0 {
+			continue
+		}
+		lowest := quota.Models[0].Percentage
+		for _, model := range quota.Models[1:] {
+			lowest = min(lowest, model.Percentage)
+		}
+		weight := weightOf(weights, provider)
+		remaining += weight * lowest
+		capacity += weight
+	}
+	if capacity == 0 {
+		return 0, false
+	}
+	return clampPercentage(remaining / capacity), true
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseZAIAccounts(t *testing.T) {
+	accounts := parseZAIAccounts("'work=111.aaa, personal = 222.bbb,broken,empty='")
+	if len(accounts) != 2 {
+		t.Fatalf("Expected 2 accounts, got %v", accounts)
+	}
+	if accounts[0] != (ZAIAccount{Name: "work", Token: "111.aaa"}) || accounts[1] != (ZAIAccount{Name: "personal", Token: "222.bbb"}) {
+		t.Errorf("Unexpected accounts: %v", accounts)
+	}
+}
+
+func TestEffectiveTokenQuota(t *testing.T) {
+	account := func(used float64, total int) ProcessedZAILimit {
+		return ProcessedZAILimit{Limits: []ProcessedLimit{{Type: LimitTokens5h, Percentage: used, Total: total}}}
+	}
+
+	// 20% of 1000 and 80% of 3000 left: 2600 of 4000
+	if got, ok := effectiveTokenQuota([]ProcessedZAILimit{account(80, 1000), account(20, 3000)}, nil); !ok || got != 65 {
+		t.Errorf("Expected capacity-weighted 65, got %v %v", got, ok)
+	}
+
+	// Without caps every account counts equally
+	if got, ok := effectiveTokenQuota([]ProcessedZAILimit{account(80, 0), account(20, 3000)}, nil); !ok || got != 50 {
+		t.Errorf("Expected equal-weighted 50, got %v %v", got, ok)
+	}
+
+	// Configured capacities replace the reported caps: a Max plan worth three Pro plans
+	if got, ok := effectiveTokenQuota([]ProcessedZAILimit{account(80, 1000), account(20, 1000)}, []float64{1, 3}); !ok || got != 65 {
+		t.Errorf("Expected configured-weight 65, got %v %v", got, ok)
+	}
+
+	if _, ok := effectiveTokenQuota([]ProcessedZAILimit{{}}, nil); ok {
+		t.Error("Expected no quota without token limits")
+	}
+}
+
+func TestGetGLMQuotaAccounts(t *testing.T) {
+	used := map[string]int{"primary-token": 30, "work-token": 90}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		percentage, ok := used[r.Header.Get("Authorization")]
+		if !ok || r.URL.Path != "/gw/quota/limit" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `{"code":200,"data":{"limits":[{"type":"TOKENS_LIMIT","percentage":%d,"usage":1000}]}}`, percentage)
+	}))
+	defer server.Close()
+
+	t.Setenv("ZAI_ANTHROPIC_AUTH_TOKEN", "primary-token")
+	t.Setenv("ZAI_ANTHROPIC_BASE_URL", server.URL+"/api/anthropic")
+	t.Setenv("ZAI_PLATFORM", "ZAI")
+	t.Setenv("ZAI_MONITOR_PREFIX", "/gw")
+	t.Setenv("ZAI_ACCOUNTS", "work=work-token,revoked=revoked-token")
+	t.Setenv("EFFECTIVE_QUOTA", "true")
+
+	quota, err := GetGLMQuota(context.Background())
+	if err != nil {
+		t.Fatalf("GetGLMQuota failed: %v", err)
+	}
+
+	want := map[string]float64{"glm": 70, "glm:work": 10, "glm:all": 40}
+	if len(quota.Models) != len(want) {
+		t.Fatalf("Expected %d models, got %+v", len(want), quota.Models)
+	}
+	for _, model := range quota.Models {
+		if percentage, ok := want[model.Name]; !ok || model.Percentage != percentage {
+			t.Errorf("Unexpected model %s at %v", model.Name, model.Percentage)
+		}
+	}
+
+	// 70% of one share and 10% of three: 100 of 400
+	t.Setenv("ACCOUNT_WEIGHTS", "work=3,revoked=5")
+	quota, err = GetGLMQuota(context.Background())
+	if err != nil {
+		t.Fatalf("GetGLMQuota failed: %v", err)
+	}
+	for _, model := range quota.Models {
+		if model.Name == effectiveGLMModel && model.Percentage != 25 {
+			t.Errorf("Expected ACCOUNT_WEIGHTS to give glm:all 25, got %v", model.Percentage)
+		}
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	weights := parseWeights("glm=4, claude-ai = 1.5,zero=0,negative=-1,broken=x,empty=")
+	if len(weights) != 2 || weights["glm"] != 4 || weights["claude-ai"] != 1.5 {
+		t.Errorf("Unexpected weights: %v", weights)
+	}
+	if weightOf(weights, "cursor") != 1 {
+		t.Error("Expected unlisted names to weigh 1")
+	}
+}
+
+func TestWeightedProviderQuota(t *testing.T) {
+	quotas := map[string]*FormattedQuota{
+		"glm":       {Models: []FormattedModel{{Name: "glm", Percentage: 80}, {Name: "glm-mcp", Percentage: 40}}},
+		"claude-ai": {Models: []FormattedModel{{Name: "claude-ai-session", Percentage: 100}}},
+		"cursor":    {},
+	}
+
+	// Each provider counts by its lowest model: (40 + 100) / 2
+	if got, ok := weightedProviderQuota(quotas, nil); !ok || got != 70 {
+		t.Errorf("Expected an equal-weighted 70, got %v %v", got, ok)
+	}
+	if got, ok := weightedProviderQuota(quotas, map[string]float64{"glm": 4}); !ok || got != 52 {
+		t.Errorf("Expected a weighted 52, got %v %v", got, ok)
+	}
+	if _, ok := weightedProviderQuota(map[string]*FormattedQuota{"cursor": {}}, nil); ok {
+		t.Error("Expected no percentage without models")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Minutes without client requests after which adaptive polling slows down
+const DefaultIdleAfter = 30
+
+// clientActivity records when a client last asked for quota data, so background polling
+// can slow down while nobody is looking and speed up again when a client returns
+type clientActivity struct {
+	last atomic.Int64
+
+	// Signaled on each request, so a slow wait can be cut short
+	wake chan struct{}
+}
+
+// newClientActivity creates a tracker that counts as active from now
+func newClientActivity() *clientActivity {
+	a := &clientActivity{wake: make(chan struct{}, 1)}
+	a.last.Store(clockNow().UnixNano())
+	return a
+}
+
+// touch records a client request
+func (a *clientActivity) touch() {
+	a.last.Store(clockNow().UnixNano())
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+// idle returns how long ago the last client request was
+func (a *clientActivity) idle() time.Duration {
+	return clockNow().Sub(time.Unix(0, a.last.Load()))
+}
+
+// middleware records every request it handles as client activity
+func (a *clientActivity) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		a.touch()
+		c.Next()
+	}
+}
+
+// pollInterval returns the background refresh interval: QUERY_DEBOUNCE while clients are
+// active, or IDLE_POLL_INTERVAL once none has made a request for IDLE_AFTER minutes
+func pollInterval(config *Config, idle time.Duration) time.Duration {
+	interval := time.Duration(config.QueryDebounce) * time.Minute
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	if config.IdlePollInterval > 0 && idle >= time.Duration(config.IdleAfter)*time.Minute {
+		interval = max(interval, time.Duration(config.IdlePollInterval)*time.Minute)
+	}
+	return interval
+}
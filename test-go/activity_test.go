@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPollInterval(t *testing.T) {
+	config := &Config{QueryDebounce: 2, IdleAfter: 30}
+	if got := pollInterval(config, time.Hour); got != 2*time.Minute {
+		t.Errorf("Expected QUERY_DEBOUNCE without IDLE_POLL_INTERVAL, got %v", got)
+	}
+
+	config.IdlePollInterval = 20
+	if got := pollInterval(config, 10*time.Minute); got != 2*time.Minute {
+		t.Errorf("Expected QUERY_DEBOUNCE while clients are active, got %v", got)
+	}
+	if got := pollInterval(config, 30*time.Minute); got != 20*time.Minute {
+		t.Errorf("Expected IDLE_POLL_INTERVAL once idle, got %v", got)
+	}
+
+	// The idle interval never polls faster than the active one
+	config.IdlePollInterval = 1
+	if got := pollInterval(config, time.Hour); got != 2*time.Minute {
+		t.Errorf("Expected at least QUERY_DEBOUNCE, got %v", got)
+	}
+}
+
+func TestClientActivityMiddleware(t *testing.T) {
+	clk := &fakeClock{t: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	defer setClock(clk)()
+
+	activity := newClientActivity()
+	clk.Advance(time.Hour)
+	if activity.idle() != time.Hour {
+		t.Fatalf("Expected an hour idle, got %v", activity.idle())
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/quota", activity.middleware(), func(c *gin.Context) {})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/quota", nil))
+	if activity.idle() != 0 {
+		t.Errorf("Expected a request to reset the idle time, got %v", activity.idle())
+	}
+}
+
+func TestWaitForPollWakesOnActivity(t *testing.T) {
+	clk := &fakeClock{t: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	defer setClock(clk)()
+
+	service := NewQuotaService(NewCloudCodeClient(LoadConfig()))
+	config := &Config{QueryDebounce: 1, IdlePollInterval: 60, IdleAfter: 30}
+
+	// The slow wait started two minutes ago, past the active interval
+	start := clk.Now().Add(-2 * time.Minute)
+	done := make(chan bool)
+	go func() { done <- service.waitForPoll(context.Background(), config, start, time.Hour) }()
+	service.activity.touch()
+
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Error("Expected the wait to end with a refresh due")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected client activity to cut the idle wait short")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if service.waitForPoll(ctx, config, clk.Now(), time.Hour) {
+		t.Error("Expected a cancelled wait to stop polling")
+	}
+}
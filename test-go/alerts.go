@@ -0,0 +1,98 @@
+package main
+
+import "time"
+
+// Points a model must climb past a bound before it leaves WARNING or CRITICAL, unless
+// HOOK_HYSTERESIS is set
+const DefaultHookHysteresis = 2
+
+// alertState is a model's alert level: OK, WARNING below HOOK_THRESHOLD, or CRITICAL at 0
+type alertState int
+
+const (
+	alertOK alertState = iota
+	alertWarning
+	alertCritical
+)
+
+// alertEntry is a model's alert state and when it was last notified
+type alertEntry struct {
+	state    alertState
+	notified time.Time
+}
+
+// alertTransition is a change of a model's alert state, or a repeat of an unchanged WARNING
+// or CRITICAL state, that hooks and notifiers are told about
+type alertTransition struct {
+	to     alertState
+	repeat bool
+}
+
+// event returns the hook event of the transition
+func (t alertTransition) event() string {
+	switch t.to {
+	case alertCritical:
+		return HookEventEmpty
+	case alertWarning:
+		return HookEventBelow
+	default:
+		return HookEventRecover
+	}
+}
+
+// alertMachine tracks the alert state of every model, shared by the hook commands, email,
+// push notifications, and incidents so they all see the same transitions. It is not safe
+// for concurrent use; HookRunner guards it.
+type alertMachine struct {
+	entries map[string]alertEntry
+}
+
+// newAlertMachine creates a machine with no models seen
+func newAlertMachine() *alertMachine {
+	return &alertMachine{entries: make(map[string]alertEntry)}
+}
+
+// classify returns the alert state of pct for a model that was in previous. Getting worse
+// takes effect at once, while getting better needs pct to be hysteresis points past the
+// bound, so a model hovering around the threshold does not flap.
+func classify(previous alertState, pct float64, threshold, hysteresis int) alertState {
+	state := alertOK
+	if pct <= 0 {
+		state = alertCritical
+	} else if pct < float64(threshold) {
+		state = alertWarning
+	}
+	if state >= previous {
+		return state
+	}
+	if previous == alertCritical && pct < float64(hysteresis) {
+		return alertCritical
+	}
+	if state == alertOK && pct < float64(threshold+hysteresis) {
+		return alertWarning
+	}
+	return state
+}
+
+// update moves the model under key to the state of pct and returns the transition to notify,
+// if any. A model first seen in OK is not notified, while one first seen in WARNING or
+// CRITICAL is, so agents pause on startup. With HOOK_REPEAT set, an unchanged WARNING or
+// CRITICAL state is notified again every HOOK_REPEAT minutes.
+func (m *alertMachine) update(config *Config, key string, pct float64, now time.Time) (alertTransition, bool) {
+	entry, seen := m.entries[key]
+	state := classify(entry.state, pct, config.HookThreshold, config.HookHysteresis)
+	repeat := time.Duration(config.HookRepeat) * time.Minute
+
+	switch {
+	case !seen || state != entry.state:
+		m.entries[key] = alertEntry{state: state, notified: now}
+		if !seen && state == alertOK {
+			return alertTransition{}, false
+		}
+		return alertTransition{to: state}, true
+	case state != alertOK && repeat > 0 && now.Sub(entry.notified) >= repeat:
+		m.entries[key] = alertEntry{state: state, notified: now}
+		return alertTransition{to: state, repeat: true}, true
+	}
+	return alertTransition{}, false
+}
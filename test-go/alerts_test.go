@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClassifyHysteresis(t *testing.T) {
+	for _, tt := range []struct {
+		previous alertState
+		pct      float64
+		want     alertState
+	}{
+		{alertOK, 50, alertOK},
+		{alertOK, 9, alertWarning},
+		{alertOK, 0, alertCritical},
+		{alertWarning, 10, alertWarning},
+		{alertWarning, 11.5, alertWarning},
+		{alertWarning, 12, alertOK},
+		{alertCritical, 1, alertCritical},
+		{alertCritical, 5, alertWarning},
+		{alertCritical, 50, alertOK},
+	} {
+		if got := classify(tt.previous, tt.pct, 10, 2); got != tt.want {
+			t.Errorf("classify(%v, %v) = %v, expected %v", tt.previous, tt.pct, got, tt.want)
+		}
+	}
+}
+
+func TestAlertMachineRepeat(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	config := &Config{HookThreshold: 10, HookHysteresis: 2, HookRepeat: 30}
+	machine := newAlertMachine()
+	update := func(pct float64, after time.Duration) (alertTransition, bool) {
+		now = now.Add(after)
+		return machine.update(config, "glm/glm", pct, now)
+	}
+
+	if _, notify := update(50, 0); notify {
+		t.Error("Expected no notification for a healthy first observation")
+	}
+	if transition, notify := update(8, time.Minute); !notify || transition.event() != HookEventBelow || transition.repeat {
+		t.Errorf("Expected a below transition, got %+v", transition)
+	}
+	if _, notify := update(7, 20*time.Minute); notify {
+		t.Error("Expected no reminder before HOOK_REPEAT")
+	}
+	if transition, notify := update(7, 10*time.Minute); !notify || transition.event() != HookEventBelow || !transition.repeat {
+		t.Errorf("Expected a below reminder, got %+v", transition)
+	}
+	if _, notify := update(11, time.Minute); notify {
+		t.Error("Expected no recovery within the hysteresis band")
+	}
+	if transition, notify := update(12, time.Minute); !notify || transition.event() != HookEventRecover {
+		t.Errorf("Expected a recovery, got %+v", transition)
+	}
+	if _, notify := update(90, time.Hour); notify {
+		t.Error("Expected no reminders while OK")
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+const (
+	// Previous days whose same hour forms the baseline, and how many must have data
+	anomalyBaselineDays = 7
+	anomalyMinBaseline  = 3
+
+	// A window must span at least this long to yield a burn rate
+	anomalyMinSpan = 15 * time.Minute
+
+	// Burn rates must also exceed the baseline mean by this many percentage points per hour,
+	// so a perfectly steady baseline does not flag every small fluctuation
+	anomalyMinExcess = 5.0
+)
+
+// windowBurnRate returns the percentage used per hour across records, ignoring rises from
+// quota resets, or false when the records span too little time
+func windowBurnRate(records []HistoryRecord) (float64, bool) {
+	if len(records) < 2 {
+		return 0, false
+	}
+	span := time.Duration(records[len(records)-1].Time-records[0].Time) * time.Second
+	if span < anomalyMinSpan {
+		return 0, false
+	}
+
+	return usedPercentage(records) / span.Hours(), true
+}
+
+// meanStdDev returns the mean and population standard deviation of values
+func meanStdDev(values []float64) (float64, float64) {
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
+
+// detectAnomaly compares a model's burn rate over the last hour with the same hour on
+// previous days and returns a warning in lang when it is more than sigma standard
+// deviations above their mean, e.g. a runaway agent loop
+func detectAnomaly(history *historyStore, provider, model string, sigma float64, lang language.Tag) (string, bool) {
+	now := clockNow()
+	current, ok := windowBurnRate(history.query(provider, model, now.Add(-time.Hour), now.Add(time.Second)))
+	if !ok {
+		return "", false
+	}
+
+	var baseline []float64
+	for day := 1; day <= anomalyBaselineDays; day++ {
+		end := now.Add(-time.Duration(day) * 24 * time.Hour)
+		if rate, ok := windowBurnRate(history.query(provider, model, end.Add(-time.Hour), end)); ok {
+			baseline = append(baseline, rate)
+		}
+	}
+	if len(baseline) < anomalyMinBaseline {
+		return "", false
+	}
+
+	mean, stdDev := meanStdDev(baseline)
+	if current <= mean+math.Max(sigma*stdDev, anomalyMinExcess) {
+		return "", false
+	}
+	return localizer(lang).Sprintf(msgAnomalyWarning,
+		formatPercentage(roundPercentage(current, 1)),
+		formatPercentage(roundPercentage(mean, 1)),
+		formatPercentage(roundPercentage(stdDev, 1))), true
+}
+
+// flagAnomalies sets the warning of every model of quota whose burn rate is anomalous
+func flagAnomalies(history *historyStore, provider string, quota *FormattedQuota, config *Config) {
+	for i := range quota.Models {
+		if warning, ok := detectAnomaly(history, provider, quota.Models[i].Name, float64(config.AnomalySigma), config.Language); ok {
+			quota.Models[i].Warning = warning
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+// recordHour records a model dropping by used percentage points over the hour ending at end
+func recordHour(history *historyStore, end time.Time, used float64) {
+	for i := 0; i <= 4; i++ {
+		at := end.Add(-time.Hour + time.Duration(i)*15*time.Minute)
+		history.record("glm", &FormattedQuota{
+			LastUpdated: at.Unix(),
+			Models:      []FormattedModel{{Name: "glm", Percentage: 100 - used*float64(i)/4}},
+		})
+	}
+}
+
+func TestDetectAnomaly(t *testing.T) {
+	now := time.Date(2026, 3, 8, 14, 0, 0, 0, time.UTC)
+	clock := &fakeClock{t: now.Add(-8 * 24 * time.Hour)}
+	restore := setClock(clock)
+	defer restore()
+
+	history := newHistoryStore()
+	for day, used := range []float64{7, 6, 5, 6, 5, 7, 6} {
+		end := now.Add(-time.Duration(7-day) * 24 * time.Hour)
+		clock.t = end
+		recordHour(history, end, used)
+	}
+	clock.t = now
+
+	// Too little data for the current hour
+	if _, ok := detectAnomaly(history, "glm", "glm", 3, language.English); ok {
+		t.Error("Expected no anomaly without a current burn rate")
+	}
+
+	recordHour(history, now, 40)
+	warning, ok := detectAnomaly(history, "glm", "glm", 3, language.English)
+	if !ok {
+		t.Fatal("Expected an anomaly at 40%/h against a 6%/h baseline")
+	}
+	if !strings.HasPrefix(warning, "using 40%/h, usually 6±") {
+		t.Errorf("Unexpected warning: %s", warning)
+	}
+
+	quota := &FormattedQuota{Models: []FormattedModel{{Name: "glm", Percentage: 60}, {Name: "other", Percentage: 90}}}
+	flagAnomalies(history, "glm", quota, &Config{AnomalySigma: 3, Language: language.English})
+	if quota.Models[0].Warning == "" || quota.Models[1].Warning != "" {
+		t.Errorf("Expected only glm flagged, got %+v", quota.Models)
+	}
+}
+
+func TestDetectAnomalyNormalRate(t *testing.T) {
+	now := time.Date(2026, 3, 8, 14, 0, 0, 0, time.UTC)
+	restore := setClock(&fakeClock{t: now})
+	defer restore()
+
+	history := newHistoryStore()
+	for day := 3; day >= 1; day-- {
+		recordHour(history, now.Add(-time.Duration(day)*24*time.Hour), 6)
+	}
+
+	// A steady baseline has no spread, but a small excess is still not an anomaly
+	recordHour(history, now, 10)
+	if warning, ok := detectAnomaly(history, "glm", "glm", 3, language.English); ok {
+		t.Errorf("Expected no anomaly, got %s", warning)
+	}
+}
+
+func TestWindowBurnRateIgnoresReset(t *testing.T) {
+	records := []HistoryRecord{
+		{Time: 0, Percentage: 20},
+		{Time: 900, Percentage: 10},
+		{Time: 1800, Percentage: 100},
+		{Time: 3600, Percentage: 95},
+	}
+	rate, ok := windowBurnRate(records)
+	if !ok || rate != 15 {
+		t.Errorf("Expected 15%%/h, got %v (%v)", rate, ok)
+	}
+	if _, ok := windowBurnRate(records[:1]); ok {
+		t.Error("Expected no rate from a single record")
+	}
+}
+
+func TestHistoryStorePersists(t *testing.T) {
+	restore := setClock(&fakeClock{t: time.Unix(10000, 0)})
+	defer restore()
+
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	history := newHistoryStore()
+	if err := history.open(&fileHistoryStore{path: path}); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	quota := &FormattedQuota{LastUpdated: 9000, Models: []FormattedModel{{Name: "glm", Percentage: 80}}}
+	history.record("glm", quota)
+	// Cached data repeats its fetch time and is not recorded twice
+	history.record("glm", quota)
+
+	reloaded := newHistoryStore()
+	if err := reloaded.open(&fileHistoryStore{path: path}); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	records := reloaded.query("glm", "glm", time.Unix(0, 0), time.Unix(10000, 0))
+	if len(records) != 1 || records[0].Percentage != 80 {
+		t.Errorf("Expected one persisted record, got %+v", records)
+	}
+}
@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"path"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -14,20 +21,34 @@ import (
 // QuotaService handles quota-related operations
 type QuotaService struct {
 	client *CloudCodeClient
+
+	// hooks runs threshold hooks on every fetch; nil outside serve mode
+	hooks *HookRunner
+
+	// activity tracks client requests for adaptive background polling
+	activity *clientActivity
+
+	// draining is closed when the server shuts down, ending streams so they do not hold it open
+	draining  chan struct{}
+	drainOnce sync.Once
 }
 
 // NewQuotaService creates a new quota service
 func NewQuotaService(client *CloudCodeClient) *QuotaService {
-	return &QuotaService{client: client}
+	return &QuotaService{client: client, activity: newClientActivity(), draining: make(chan struct{})}
 }
 
+// ErrUnknownProvider is returned for provider names fetchQuota does not serve
+var ErrUnknownProvider = errors.New("unknown provider")
+
 // setupRoutes configures all API routes
-func setupRoutes(r *gin.Engine) {
+func setupRoutes(r *gin.Engine) *QuotaService {
 	config := LoadConfig()
 	client := NewCloudCodeClient(config)
 	service := NewQuotaService(client)
+	r.Use(service.requireServerToken)
 
-	quota := r.Group("/quota")
+	quota := r.Group("/quota", service.activity.middleware())
 	{
 		quota.GET("", service.GetQuotaEndpoints)
 		quota.GET("/usage", service.GetQuotaEndpoints)
@@ -37,7 +58,33 @@ func setupRoutes(r *gin.Engine) {
 		quota.GET("/pro", service.GetGemini3Pro)
 		quota.GET("/flash", service.GetGemini3Flash)
 		quota.GET("/claude", service.GetClaude45)
+		quota.GET("/glm", service.GetGLMQuota)
+		quota.GET("/status-zai", service.GetQuotaStatusZAI)
+		quota.GET("/balance", service.GetZhipuBalance)
+		quota.GET("/claude-ai", service.GetClaudeAIQuota)
+		quota.GET("/status-claude", service.GetQuotaStatusClaude)
+		quota.GET("/cursor", service.GetCursorQuota)
+		quota.GET("/windsurf", service.GetWindsurfQuota)
+		quota.GET("/xai", service.GetXAIQuota)
+		quota.GET("/groq", service.GetGroqQuota)
+		quota.GET("/mistral", service.GetMistralQuota)
+		quota.GET("/local", service.GetLocalQuota)
+		quota.GET("/combined", service.GetCombinedQuota)
+		quota.GET("/route", service.GetRoute)
+		quota.GET("/cost", service.GetCost)
+		quota.GET("/stream", service.StreamQuota)
 	}
+	r.GET("/badge/:file", service.activity.middleware(), service.GetBadge)
+	r.GET("/", service.requireDashboardToken, service.GetDashboard)
+	r.GET("/dashboard/stream", service.requireDashboardToken, service.activity.middleware(), service.StreamQuota)
+	r.POST("/events/usage", service.PostUsageEvents)
+	r.GET("/history", service.GetHistory)
+	r.POST("/history", service.PostHistory)
+	r.GET("/healthz", service.GetHealth)
+	r.GET(daemonIdentityPath, service.GetDaemonIdentity)
+	r.GET("/metrics", service.GetMetrics)
+
+	return service
 }
 
 // GetQuotaEndpoints returns available endpoints
@@ -45,19 +92,55 @@ func (s *QuotaService) GetQuotaEndpoints(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Welcome to the Antigravity Quota API",
 		"endpoints": gin.H{
-			"/quota":          "This endpoint - lists all available endpoints",
-			"/quota/overview": "Quick summary (e.g., 'Pro 95% | Flash 90% | Claude 80%')",
-			"/quota/status":   "Terminal status with nerdfont icons and colors",
-			"/quota/all":      "All models with percentage and relative reset time",
-			"/quota/pro":      "Gemini 3 Pro models (high, image, low)",
-			"/quota/flash":    "Gemini 3 Flash model",
-			"/quota/claude":   "Claude 4.5 models (opus, sonnet, thinking)",
+			"/quota":               "This endpoint - lists all available endpoints",
+			"/quota/overview":      "Quick summary (e.g., 'Pro 95% | Flash 90% | Claude 80%')",
+			"/quota/status":        "Terminal status with nerdfont icons and colors",
+			"/quota/status-zai":    "GLM quota status with nerdfont icon and colors (e.g., 'Z 99%')",
+			"/quota/all":           "All models with percentage and relative reset time",
+			"/quota/pro":           "Gemini 3 Pro models (high, image, low)",
+			"/quota/flash":         "Gemini 3 Flash model",
+			"/quota/claude":        "Claude 4.5 models (opus, sonnet, thinking)",
+			"/quota/glm":           "GLM (Z.ai/ZHIPU) quota usage and limits",
+			"/quota/balance":       "Zhipu/Z.ai pay-as-you-go account balance and granted credits",
+			"/quota/claude-ai":     "Claude Pro/Max subscription usage (5-hour session and weekly windows)",
+			"/quota/status-claude": "Claude subscription session status with icon and colors (e.g., '󰛄 80% 2h15m')",
+			"/quota/cursor":        "Cursor fast-request usage per model",
+			"/quota/windsurf":      "Windsurf prompt and flow credit usage",
+			"/quota/xai":           "xAI Grok prepaid credits and per-model RPM/TPM",
+			"/quota/groq":          "Groq daily request and per-minute token limits",
+			"/quota/mistral":       "Mistral per-minute and monthly token limits",
+			"/quota/local":         "Local Ollama models (always 100%) with load and VRAM info",
+			"/quota/combined":      "Models from several providers in one view (?providers=glm,cursor)",
+			"/quota/route":         "Provider with the most headroom by percentage, burn rate, and reset time",
+			"/quota/cost":          "Estimated spend or consumed plan value from token usage (?providers=glm)",
+			"/badge/<model>.svg":   "SVG badge of a model's remaining quota (?provider=glm&label=...)",
+			"/":                    "Read-only dashboard of quota gauges for a shared screen (?providers=a,b&token=...)",
+			"/events/usage":        "POST usage events (tokens or points used) from local tooling into burn-rate estimates",
+			"/history":             "GET quota history lines in HISTORY_FILE (?member=name), POST a member's lines to keep them",
+			"/healthz":             "Per-provider last success, last error, cache hit, and latency",
+			"/metrics":             "Prometheus metrics of the server itself (cache, latency, errors, refresh lag)",
+			"/quota/stream":        "Server-Sent Events pushing quota whenever a percentage changes (?provider=glm)",
 		},
 	})
 }
 
-// getQuotaData helper function to load account and fetch quota
-func (s *QuotaService) getQuotaData() (*QuotaResponse, error) {
+// modelNames maps canonical model names to their configured output names
+func (s *QuotaService) modelNames(names ...string) []string {
+	return aliasedNames(s.client.Config().ModelAliases, names...)
+}
+
+// getQuotaData helper function to load account and fetch quota. ANTIGRAVITY_TOKEN takes
+// precedence over the account file and is used as is, without refreshing.
+func (s *QuotaService) getQuotaData(ctx context.Context) (*QuotaResponse, error) {
+	if source := s.client.Config().AntigravityToken; source != nil {
+		token, err := source.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ANTIGRAVITY_TOKEN: %w", err)
+		}
+		projectID, _ := s.client.GetProjectID(ctx, token)
+		return s.client.GetQuota(ctx, token, projectID)
+	}
+
 	account, err := s.client.LoadAccount()
 	if err != nil {
 		return nil, err
@@ -68,12 +151,27 @@ func (s *QuotaService) getQuotaData() (*QuotaResponse, error) {
 		return nil, err
 	}
 
+	quota, err := s.queryAntigravity(ctx, account, accessToken)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+		// The token was revoked or expired early: refresh it and retry once
+		log.Println("Access token rejected, refreshing")
+		if accessToken, err = s.client.RefreshAccount(account, accessToken); err != nil {
+			return nil, err
+		}
+		return s.queryAntigravity(ctx, account, accessToken)
+	}
+	return quota, err
+}
+
+// queryAntigravity fetches the quota of an account with an access token
+func (s *QuotaService) queryAntigravity(ctx context.Context, account *Account, accessToken string) (*QuotaResponse, error) {
 	_, _, _, projectID := s.client.NormalizeAccount(account)
 	if projectID == "" {
-		projectID, _ = s.client.GetProjectID(accessToken)
+		projectID, _ = s.client.GetProjectID(ctx, accessToken)
 	}
 
-	return s.client.GetQuota(accessToken, projectID)
+	return s.client.GetQuota(ctx, accessToken, projectID)
 }
 
 // formatTimeRemaining calculates time remaining until reset
@@ -91,7 +189,7 @@ func formatTimeRemaining(resetTime string) string {
 		}
 	}
 
-	now := time.Now().UTC()
+	now := clockNow().UTC()
 	delta := resetDt.Sub(now)
 
 	if delta <= 0 {
@@ -103,8 +201,150 @@ func formatTimeRemaining(resetTime string) string {
 	return fmt.Sprintf("%dh %dm", hours, minutes)
 }
 
+// fetchQuota returns formatted quota data for a provider with its health metadata attached
+func (s *QuotaService) fetchQuota(ctx context.Context, provider string) (*FormattedQuota, error) {
+	if provider == "" {
+		provider = ProviderAntigravity
+	}
+	fetch := func(ctx context.Context) (FormattedQuota, error) {
+		quotaRaw, err := s.getQuotaData(ctx)
+		if err != nil {
+			return FormattedQuota{}, err
+		}
+		return *formatQuota(quotaRaw, true), nil
+	}
+	if provider != ProviderAntigravity {
+		var ok bool
+		if fetch, ok = quotaProviders[provider]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, provider)
+		}
+	}
+
+	// A rejected credential, just now or remembered for AUTH_FAILURE_TTL, is retried once
+	// when a token from a file, command, or keychain was rotated since
+	quota, err := fetch(ctx)
+	if err != nil && (isUnauthorized(err) || isAuthFailure(err)) && recheckSecrets(ctx) {
+		// A token from a file, command, or keychain was rotated: retry once with it
+		log.Printf("%s rejected the token, retrying with the rotated one", provider)
+		s.client.SetConfig(LoadConfig())
+		quota, err = fetch(ctx)
+	}
+	if err != nil {
+		providerHealth.recordError(provider, err)
+		if s.hooks != nil {
+			s.hooks.incidents.observeError(s.client.Config(), provider, err)
+		}
+		return nil, err
+	}
+	// Fetchers may share their model slices with a cache, so the provider is set on a copy
+	quota.Models = slices.Clone(quota.Models)
+	for i := range quota.Models {
+		quota.Models[i].Provider = provider
+	}
+	quotaFormatted := &quota
+
+	config := s.client.Config()
+	burnRates.record(provider, quotaFormatted)
+	quotaHistory.record(provider, quotaFormatted)
+	quotaHub.push(config, provider, quotaFormatted)
+	if config.AnomalySigma > 0 {
+		flagAnomalies(quotaHistory, provider, quotaFormatted, config)
+	}
+	if s.hooks != nil {
+		s.hooks.observe(config, provider, quotaFormatted)
+	}
+
+	health := providerHealth.get(provider)
+	quotaFormatted.Health = &health
+	return quotaFormatted, nil
+}
+
+// invalidateCache drops cached quota data so the next fetch queries the providers
+func (s *QuotaService) invalidateCache() {
+	s.client.cacheMutex.Lock()
+	s.client.cache = make(map[string]interface{})
+	s.client.cacheMutex.Unlock()
+
+	zaiCache.Clear()
+	providerCache.Clear()
+	authFailures.clear()
+}
+
+// worstModel returns the model with the lowest remaining percentage
+func worstModel(models []FormattedModel) (FormattedModel, bool) {
+	if len(models) == 0 {
+		return FormattedModel{}, false
+	}
+
+	worst := models[0]
+	for _, model := range models[1:] {
+		if model.Percentage < worst.Percentage {
+			worst = model
+		}
+	}
+	return worst, true
+}
+
+// quotaChanged reports whether any model was added, removed, or changed percentage
+func quotaChanged(previous, current *FormattedQuota) bool {
+	if previous == nil || len(previous.Models) != len(current.Models) {
+		return true
+	}
+	for i, model := range current.Models {
+		if previous.Models[i].Name != model.Name || previous.Models[i].Percentage != model.Percentage {
+			return true
+		}
+	}
+	return false
+}
+
+// watchQuota polls a provider every interval and calls onChange whenever any model's
+// percentage changes. Refresh errors go to onError; a callback error stops the watch, as does
+// the server shutting down.
+func (s *QuotaService) watchQuota(ctx context.Context, provider string, interval time.Duration, onChange func(*FormattedQuota) error, onError func(error) error) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last *FormattedQuota
+	for {
+		// A connected stream is an active client
+		s.activity.touch()
+		quota, err := s.fetchQuota(ctx, provider)
+		if err != nil {
+			if err := onError(err); err != nil {
+				return err
+			}
+		} else if quotaChanged(last, quota) {
+			if err := onChange(quota); err != nil {
+				return err
+			}
+			last = quota
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.draining:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// roundPercentage rounds a percentage to the given number of decimal places
+func roundPercentage(pct float64, precision int) float64 {
+	scale := math.Pow(10, float64(precision))
+	return math.Round(pct*scale) / scale
+}
+
+// formatPercentage formats a rounded percentage without trailing zeros
+func formatPercentage(pct float64) string {
+	return strconv.FormatFloat(pct, 'f', -1, 64)
+}
+
 // formatQuota formats quota data to match Python implementation
 func formatQuota(quotaData *QuotaResponse, showRelative bool) *FormattedQuota {
+	config := LoadConfig()
 	var models []FormattedModel
 
 	for name, info := range quotaData.Models {
@@ -115,7 +355,7 @@ func formatQuota(quotaData *QuotaResponse, showRelative bool) *FormattedQuota {
 		if strings.Contains(nameLower, "gemini") || strings.Contains(nameLower, "claude") {
 			model := FormattedModel{
 				Name:       name,
-				Percentage: int(remainingFraction * 100),
+				Percentage: roundPercentage(remainingFraction*100, config.PercentagePrecision),
 				ResetTime:  resetTime,
 			}
 			if showRelative && resetTime != "" {
@@ -125,16 +365,120 @@ func formatQuota(quotaData *QuotaResponse, showRelative bool) *FormattedQuota {
 		}
 	}
 
+	models = applyModelAliases(models, config.ModelAliases)
+
 	// Sort models by name
 	sort.Slice(models, func(i, j int) bool {
 		return models[i].Name < models[j].Name
 	})
 
-	return &FormattedQuota{
+	quota := &FormattedQuota{
 		Models:      models,
-		LastUpdated: time.Now().Unix(),
 		IsForbidden: false,
 	}
+	stampQuotaTime(quota, quotaData.FetchedAt)
+	return quota
+}
+
+// stampQuotaTime sets LastUpdated to when the data was fetched and Age to how old it is now.
+// A zero fetchedAt means the data is fresh.
+func stampQuotaTime(quota *FormattedQuota, fetchedAt time.Time) {
+	now := clockNow()
+	if fetchedAt.IsZero() {
+		fetchedAt = now
+	}
+	quota.LastUpdated = fetchedAt.Unix()
+	quota.Age = int64(now.Sub(fetchedAt).Seconds())
+}
+
+// aliasedName returns the output name of a model after applying the alias table
+func aliasedName(aliases map[string]string, name string) string {
+	if alias, exists := aliases[name]; exists {
+		return alias
+	}
+	return name
+}
+
+// applyModelAliases renames, merges, or hides models according to the alias table.
+// Models renamed to the same alias are merged, keeping the lowest percentage.
+func applyModelAliases(models []FormattedModel, aliases map[string]string) []FormattedModel {
+	if len(aliases) == 0 {
+		return models
+	}
+
+	result := []FormattedModel{}
+	index := make(map[string]int)
+
+	for _, model := range models {
+		name := aliasedName(aliases, model.Name)
+		if name == HiddenModelAlias {
+			continue
+		}
+		model.Name = name
+
+		if i, exists := index[name]; exists {
+			if model.Percentage < result[i].Percentage {
+				result[i] = model
+			}
+			continue
+		}
+
+		index[name] = len(result)
+		result = append(result, model)
+	}
+
+	return result
+}
+
+// matchesAnyGlob reports whether name matches any of the glob patterns
+func matchesAnyGlob(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// selectModels keeps models matching the only globs (if any) and drops those matching exclude globs
+func selectModels(quota *FormattedQuota, only, exclude []string) *FormattedQuota {
+	if len(only) == 0 && len(exclude) == 0 {
+		return quota
+	}
+
+	filtered := []FormattedModel{}
+	for _, model := range quota.Models {
+		if len(only) > 0 && !matchesAnyGlob(model.Name, only) {
+			continue
+		}
+		if matchesAnyGlob(model.Name, exclude) {
+			continue
+		}
+		filtered = append(filtered, model)
+	}
+
+	selected := *quota
+	selected.Models = filtered
+	return &selected
+}
+
+// applyModelSelection applies only/exclude filters from the query string, falling back to config
+func (s *QuotaService) applyModelSelection(c *gin.Context, quota *FormattedQuota) *FormattedQuota {
+	only := s.client.Config().ModelOnly
+	if value, exists := c.GetQuery("only"); exists {
+		only = parseList(value)
+	}
+
+	exclude := s.client.Config().ModelExclude
+	if value, exists := c.GetQuery("exclude"); exists {
+		exclude = parseList(value)
+	}
+
+	selected := selectModels(quota, only, exclude)
+	if isViewer(c) {
+		selected = redactAccounts(selected)
+	}
+	return selected
 }
 
 // filterModels filters models by name patterns
@@ -151,71 +495,92 @@ func filterModels(quota *FormattedQuota, patterns []string) *FormattedQuota {
 		}
 	}
 
-	return &FormattedQuota{
-		Models:      filtered,
-		LastUpdated: quota.LastUpdated,
-		IsForbidden: quota.IsForbidden,
-	}
+	selected := *quota
+	selected.Models = filtered
+	return &selected
 }
 
 // GetQuotaOverview returns quick quota summary
 func (s *QuotaService) GetQuotaOverview(c *gin.Context) {
-	quotaRaw, err := s.getQuotaData()
+	quotaRaw, err := s.getQuotaData(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	respondOverview(c, formatQuota(quotaRaw, false), "overview", nil)
+}
 
-	quotaFormatted := formatQuota(quotaRaw, false)
-
-	// Get Pro average (gemini-3-pro-high)
-	proPct := 0
-	for _, model := range quotaFormatted.Models {
-		if strings.Contains(strings.ToLower(model.Name), "gemini-3-pro-high") {
-			proPct = model.Percentage
-			break
-		}
+// respondProblem sends a provider's error as {"error": ...}, with a "fix" when one is known
+func respondProblem(c *gin.Context, status int, provider string, err error) {
+	body := gin.H{"error": err.Error()}
+	if fix := remediation(provider, err); fix != "" {
+		body["fix"] = fix
 	}
+	c.JSON(status, body)
+}
 
-	// Get Flash (gemini-3-flash)
-	flashPct := 0
-	for _, model := range quotaFormatted.Models {
-		if strings.Contains(strings.ToLower(model.Name), "gemini-3-flash") {
-			flashPct = model.Percentage
-			break
-		}
+// respondOverview sends {"overview": ...} with the quota rendered in format, plus extra fields
+func respondOverview(c *gin.Context, quota *FormattedQuota, format string, extra gin.H) {
+	overview, err := renderQuota(format, *quota)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	body := gin.H{"overview": string(overview)}
+	for key, value := range extra {
+		body[key] = value
 	}
+	c.JSON(http.StatusOK, body)
+}
 
-	// Get Claude (claude-sonnet-4-5, non-thinking)
-	claudePct := 0
-	for _, model := range quotaFormatted.Models {
-		if strings.ToLower(model.Name) == "claude-sonnet-4-5" {
-			claudePct = model.Percentage
-			break
+// respondQuota sends the selected models as {"quota": ...} with extra fields, or, when the
+// request names a ?format other than json, the quota rendered as plain text
+func (s *QuotaService) respondQuota(c *gin.Context, quota *FormattedQuota, extra gin.H) {
+	config := s.client.Config()
+	quota, err := arrangeModels(s.applyModelSelection(c, quota), c.DefaultQuery("sort", config.OutputSort), c.DefaultQuery("group", config.OutputGroup))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if format := c.Query("format"); format != "" && format != "json" {
+		renderer, err := resolveRenderer(format, c.Query("template"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		output, err := renderer.Render(*quota)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", output)
+		return
 	}
 
-	overview := fmt.Sprintf("Pro %d%% | Flash %d%% | Claude %d%%", proPct, flashPct, claudePct)
-	c.JSON(http.StatusOK, gin.H{"overview": overview})
+	body := gin.H{"quota": quota}
+	for key, value := range extra {
+		body[key] = value
+	}
+	c.JSON(http.StatusOK, body)
 }
 
 // formatPercentageWithColor formats percentage with ANSI colors
-func formatPercentageWithColor(pct int) string {
+func formatPercentageWithColor(pct float64) string {
 	const (
-		Green = "\033[32m"
+		Green  = "\033[32m"
 		Yellow = "\033[33m"
-		Red = "\033[31m"
-		Reset = "\033[0m"
+		Red    = "\033[31m"
+		Reset  = "\033[0m"
 	)
 
 	if pct == QuotaFull {
 		return Green + "●" + Reset
 	} else if pct >= QuotaGood {
-		return Green + strconv.Itoa(pct) + "%" + Reset
+		return Green + formatPercentage(pct) + "%" + Reset
 	} else if pct >= QuotaWarning {
-		return Yellow + strconv.Itoa(pct) + "%" + Reset
-	} else if pct >= QuotaCritical {
-		return Red + strconv.Itoa(pct) + "%" + Reset
+		return Yellow + formatPercentage(pct) + "%" + Reset
+	} else if pct > 0 {
+		return Red + formatPercentage(pct) + "%" + Reset
 	} else {
 		return Red + "●" + Reset
 	}
@@ -235,7 +600,7 @@ func formatTimeCompact(resetTime string) string {
 		}
 	}
 
-	now := time.Now().UTC()
+	now := clockNow().UTC()
 	delta := resetDt.Sub(now)
 
 	if delta <= 0 {
@@ -258,123 +623,263 @@ func formatTimeCompact(resetTime string) string {
 
 // GetQuotaStatus returns terminal-friendly status
 func (s *QuotaService) GetQuotaStatus(c *gin.Context) {
-	quotaRaw, err := s.getQuotaData()
+	quotaFormatted, err := s.fetchQuota(c.Request.Context(), ProviderAntigravity)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondProblem(c, http.StatusInternalServerError, ProviderAntigravity, err)
 		return
 	}
+	respondOverview(c, quotaFormatted, "status", nil)
+}
 
-	quotaFormatted := formatQuota(quotaRaw, true)
+// GetAllQuota returns all models with relative reset time
+func (s *QuotaService) GetAllQuota(c *gin.Context) {
+	quotaFormatted, err := s.fetchQuota(c.Request.Context(), ProviderAntigravity)
+	if err != nil {
+		respondProblem(c, http.StatusInternalServerError, ProviderAntigravity, err)
+		return
+	}
+	s.respondQuota(c, quotaFormatted, nil)
+}
 
-	const (
-		Green = "\033[32m"
-		Red = "\033[31m"
-		Reset = "\033[0m"
-		GeminiIcon = "G"
-		FlashIcon = "F"
-		ClaudeIcon = "󰛄"
-	)
+// GetGemini3Pro returns Gemini 3 Pro models
+func (s *QuotaService) GetGemini3Pro(c *gin.Context) {
+	quotaFormatted, err := s.fetchQuota(c.Request.Context(), ProviderAntigravity)
+	if err != nil {
+		respondProblem(c, http.StatusInternalServerError, ProviderAntigravity, err)
+		return
+	}
+	filtered := filterModels(quotaFormatted, s.modelNames("gemini-3-pro-high", "gemini-3-pro-image", "gemini-3-pro-low"))
+	s.respondQuota(c, filtered, nil)
+}
 
-	formatModelStatus := func(icon string, pct int, resetTime string) string {
-		if pct == QuotaFull {
-			return Green + icon + Reset
-		} else if pct == 0 {
-			return Red + icon + Reset
-		} else {
-			pctStr := formatPercentageWithColor(pct)
-			timeStr := formatTimeCompact(resetTime)
-			if timeStr != "" {
-				return fmt.Sprintf("%s %s %s", icon, pctStr, timeStr)
-			}
-			return fmt.Sprintf("%s %s", icon, pctStr)
-		}
+// GetGemini3Flash returns Gemini 3 Flash model
+func (s *QuotaService) GetGemini3Flash(c *gin.Context) {
+	quotaFormatted, err := s.fetchQuota(c.Request.Context(), ProviderAntigravity)
+	if err != nil {
+		respondProblem(c, http.StatusInternalServerError, ProviderAntigravity, err)
+		return
 	}
+	filtered := filterModels(quotaFormatted, s.modelNames("gemini-3-flash"))
+	s.respondQuota(c, filtered, nil)
+}
 
-	// Get Pro (gemini-3-pro-high)
-	proPct, proReset := 0, ""
-	for _, model := range quotaFormatted.Models {
-		if strings.Contains(strings.ToLower(model.Name), "gemini-3-pro-high") {
-			proPct = model.Percentage
-			proReset = model.ResetTime
-			break
-		}
+// GetClaude45 returns Claude 4.5 models
+func (s *QuotaService) GetClaude45(c *gin.Context) {
+	quotaFormatted, err := s.fetchQuota(c.Request.Context(), ProviderAntigravity)
+	if err != nil {
+		respondProblem(c, http.StatusInternalServerError, ProviderAntigravity, err)
+		return
 	}
+	filtered := filterModels(quotaFormatted, s.modelNames("claude-opus-4-5-thinking", "claude-sonnet-4-5", "claude-sonnet-4-5-thinking"))
+	s.respondQuota(c, filtered, nil)
+}
 
-	// Get Flash (gemini-3-flash)
-	flashPct, flashReset := 0, ""
-	for _, model := range quotaFormatted.Models {
-		if strings.Contains(strings.ToLower(model.Name), "gemini-3-flash") {
-			flashPct = model.Percentage
-			flashReset = model.ResetTime
-			break
-		}
+// GetGLMQuota returns GLM (Z.ai/ZHIPU) quota usage and limits
+func (s *QuotaService) GetGLMQuota(c *gin.Context) {
+	quotaFormatted, err := s.fetchQuota(c.Request.Context(), ProviderGLM)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, ProviderGLM, err)
+		return
 	}
 
-	// Get Claude (claude-sonnet-4-5)
-	claudePct, claudeReset := 0, ""
-	for _, model := range quotaFormatted.Models {
-		if strings.ToLower(model.Name) == "claude-sonnet-4-5" {
-			claudePct = model.Percentage
-			claudeReset = model.ResetTime
-			break
-		}
+	s.respondQuota(c, quotaFormatted, nil)
+}
+
+// GetQuotaStatusZAI returns terminal-friendly GLM quota status
+func (s *QuotaService) GetQuotaStatusZAI(c *gin.Context) {
+	quotaFormatted, err := s.fetchQuota(c.Request.Context(), ProviderGLM)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, ProviderGLM, err)
+		return
 	}
+	respondOverview(c, quotaFormatted, "status-zai", nil)
+}
 
-	proStr := formatModelStatus(GeminiIcon, proPct, proReset)
-	flashStr := formatModelStatus(FlashIcon, flashPct, flashReset)
-	claudeStr := formatModelStatus(ClaudeIcon, claudePct, claudeReset)
+// GetClaudeAIQuota returns Claude subscription usage
+func (s *QuotaService) GetClaudeAIQuota(c *gin.Context) {
+	quotaFormatted, err := s.fetchQuota(c.Request.Context(), ProviderClaudeAI)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, ProviderClaudeAI, err)
+		return
+	}
 
-	overview := fmt.Sprintf("%s | %s | %s", proStr, flashStr, claudeStr)
-	c.JSON(http.StatusOK, gin.H{"overview": overview})
+	s.respondQuota(c, quotaFormatted, nil)
 }
 
-// GetAllQuota returns all models with relative reset time
-func (s *QuotaService) GetAllQuota(c *gin.Context) {
-	quotaRaw, err := s.getQuotaData()
+// GetCursorQuota returns Cursor plan usage
+func (s *QuotaService) GetCursorQuota(c *gin.Context) {
+	quotaFormatted, err := s.fetchQuota(c.Request.Context(), ProviderCursor)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondProblem(c, http.StatusBadRequest, ProviderCursor, err)
 		return
 	}
 
-	quotaFormatted := formatQuota(quotaRaw, true)
-	c.JSON(http.StatusOK, gin.H{"quota": quotaFormatted})
+	s.respondQuota(c, quotaFormatted, nil)
 }
 
-// GetGemini3Pro returns Gemini 3 Pro models
-func (s *QuotaService) GetGemini3Pro(c *gin.Context) {
-	quotaRaw, err := s.getQuotaData()
+// GetWindsurfQuota returns Windsurf plan usage
+func (s *QuotaService) GetWindsurfQuota(c *gin.Context) {
+	quotaFormatted, err := s.fetchQuota(c.Request.Context(), ProviderWindsurf)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondProblem(c, http.StatusBadRequest, ProviderWindsurf, err)
 		return
 	}
 
-	quotaFormatted := formatQuota(quotaRaw, true)
-	filtered := filterModels(quotaFormatted, []string{"gemini-3-pro-high", "gemini-3-pro-image", "gemini-3-pro-low"})
-	c.JSON(http.StatusOK, gin.H{"quota": filtered})
+	s.respondQuota(c, quotaFormatted, nil)
 }
 
-// GetGemini3Flash returns Gemini 3 Flash model
-func (s *QuotaService) GetGemini3Flash(c *gin.Context) {
-	quotaRaw, err := s.getQuotaData()
+// GetXAIQuota returns xAI credits and rate limits
+func (s *QuotaService) GetXAIQuota(c *gin.Context) {
+	quotaFormatted, err := s.fetchQuota(c.Request.Context(), ProviderXAI)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondProblem(c, http.StatusBadRequest, ProviderXAI, err)
 		return
 	}
 
-	quotaFormatted := formatQuota(quotaRaw, true)
-	filtered := filterModels(quotaFormatted, []string{"gemini-3-flash"})
-	c.JSON(http.StatusOK, gin.H{"quota": filtered})
+	s.respondQuota(c, quotaFormatted, nil)
 }
 
-// GetClaude45 returns Claude 4.5 models
-func (s *QuotaService) GetClaude45(c *gin.Context) {
-	quotaRaw, err := s.getQuotaData()
+// GetGroqQuota returns Groq rate limits
+func (s *QuotaService) GetGroqQuota(c *gin.Context) {
+	quotaFormatted, err := s.fetchQuota(c.Request.Context(), ProviderGroq)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondProblem(c, http.StatusBadRequest, ProviderGroq, err)
+		return
+	}
+
+	s.respondQuota(c, quotaFormatted, nil)
+}
+
+// GetMistralQuota returns Mistral rate limits
+func (s *QuotaService) GetMistralQuota(c *gin.Context) {
+	quotaFormatted, err := s.fetchQuota(c.Request.Context(), ProviderMistral)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, ProviderMistral, err)
+		return
+	}
+
+	s.respondQuota(c, quotaFormatted, nil)
+}
+
+// GetLocalQuota returns the local runtime's models
+func (s *QuotaService) GetLocalQuota(c *gin.Context) {
+	quotaFormatted, err := s.fetchQuota(c.Request.Context(), ProviderLocal)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, ProviderLocal, err)
 		return
 	}
 
-	quotaFormatted := formatQuota(quotaRaw, true)
-	filtered := filterModels(quotaFormatted, []string{"claude-opus-4-5-thinking", "claude-sonnet-4-5", "claude-sonnet-4-5-thinking"})
-	c.JSON(http.StatusOK, gin.H{"quota": filtered})
+	s.respondQuota(c, quotaFormatted, nil)
+}
+
+// GetCombinedQuota merges the models of several providers into one quota object.
+// Providers that fail (usually for lack of credentials) are listed under "errors", and
+// "weighted_percentage" combines the others by their PROVIDER_WEIGHTS capacity.
+func (s *QuotaService) GetCombinedQuota(c *gin.Context) {
+	providers := parseList(c.Query("providers"))
+	if len(providers) == 0 {
+		providers = providerNames()
+	}
+
+	combined := &FormattedQuota{}
+	errs := make(map[string]string)
+	var problems MultiError
+	quotas := make(map[string]*FormattedQuota)
+	for _, provider := range providers {
+		quotaFormatted, err := s.fetchQuota(c.Request.Context(), provider)
+		if err != nil {
+			errs[provider] = err.Error()
+			problems.Add(provider, err)
+			continue
+		}
+		quotas[provider] = s.applyModelSelection(c, quotaFormatted)
+		combined.Models = append(combined.Models, quotaFormatted.Models...)
+		// Report the oldest data so the combined view never looks fresher than it is
+		if combined.LastUpdated == 0 || quotaFormatted.LastUpdated < combined.LastUpdated {
+			combined.LastUpdated = quotaFormatted.LastUpdated
+			combined.Age = quotaFormatted.Age
+		}
+	}
+
+	// "errors" stays a map of messages for existing clients; "problems" adds the fixes
+	extra := gin.H{"errors": errs, "problems": problems.Problems}
+	if problems.Problems == nil {
+		extra["problems"] = []Problem{}
+	}
+	config := s.client.Config()
+	if percentage, ok := weightedProviderQuota(quotas, config.ProviderWeights); ok {
+		extra["weighted_percentage"] = roundPercentage(percentage, config.PercentagePrecision)
+	}
+	s.respondQuota(c, combined, extra)
+}
+
+// GetCost estimates the money value of each provider's token usage
+func (s *QuotaService) GetCost(c *gin.Context) {
+	estimates, errs := estimateCosts(c.Request.Context(), s.client.Config(), parseList(c.Query("providers")))
+	c.JSON(http.StatusOK, gin.H{
+		"costs":  estimates,
+		"errors": errs,
+	})
+}
+
+// GetRoute recommends the provider with the most headroom
+func (s *QuotaService) GetRoute(c *gin.Context) {
+	c.JSON(http.StatusOK, s.recommendProvider(c.Request.Context(), parseList(c.Query("providers"))))
+}
+
+// GetQuotaStatusClaude returns terminal-friendly Claude subscription session status
+func (s *QuotaService) GetQuotaStatusClaude(c *gin.Context) {
+	quotaFormatted, err := s.fetchQuota(c.Request.Context(), ProviderClaudeAI)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, ProviderClaudeAI, err)
+		return
+	}
+	respondOverview(c, quotaFormatted, "status-claude", nil)
+}
+
+// GetZhipuBalance returns the pay-as-you-go account balance with a currency-aware summary
+func (s *QuotaService) GetZhipuBalance(c *gin.Context) {
+	quotaFormatted, err := s.fetchQuota(c.Request.Context(), ProviderZhipuBalance)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, ProviderZhipuBalance, err)
+		return
+	}
+
+	quotaFormatted = s.applyModelSelection(c, quotaFormatted)
+	respondOverview(c, quotaFormatted, "balance", gin.H{"quota": quotaFormatted})
+}
+
+// StreamQuota pushes a "quota" Server-Sent Event whenever any model's percentage changes
+func (s *QuotaService) StreamQuota(c *gin.Context) {
+	provider := c.DefaultQuery("provider", ProviderAntigravity)
+
+	interval := time.Duration(s.client.Config().QueryDebounce) * time.Minute
+	if seconds, err := strconv.Atoi(c.Query("interval")); err == nil && seconds > 0 {
+		interval = time.Duration(seconds) * time.Second
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+
+	s.watchQuota(c.Request.Context(), provider, interval,
+		func(quota *FormattedQuota) error {
+			c.SSEvent("quota", gin.H{"provider": provider, "quota": s.applyModelSelection(c, quota)})
+			c.Writer.Flush()
+			return nil
+		},
+		func(err error) error {
+			c.SSEvent("error", gin.H{"error": err.Error()})
+			c.Writer.Flush()
+			if errors.Is(err, ErrUnknownProvider) {
+				return err
+			}
+			return nil
+		},
+	)
+}
+
+// GetHealth returns freshness metadata for every provider queried so far
+func (s *QuotaService) GetHealth(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": providerHealth.all()})
 }
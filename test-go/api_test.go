@@ -1,13 +1,17 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,7 +19,15 @@ import (
 func TestMain(m *testing.M) {
 	// Set Gin to test mode
 	gin.SetMode(gin.TestMode)
-	os.Exit(m.Run())
+	// Keep the prompt cache written by show and guard runs out of the user's cache directory
+	dir, err := os.MkdirTemp("", "prompt-cache")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("PROMPT_CACHE_DIR", dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
 }
 
 func setupTestRouter() *gin.Engine {
@@ -27,49 +39,49 @@ func setupTestRouter() *gin.Engine {
 func createTestAccount(t *testing.T) string {
 	tmpDir := t.TempDir()
 	accountFile := filepath.Join(tmpDir, "test-account.json")
-	
+
 	testAccount := Account{
 		AccessToken:  "test-access-token",
 		RefreshToken: "test-refresh-token",
 		ProjectID:    "test-project-id",
 		ExpiresIn:    3600,
 	}
-	
+
 	data, _ := json.MarshalIndent(testAccount, "", "  ")
 	err := os.WriteFile(accountFile, data, 0600)
 	if err != nil {
 		t.Fatalf("Failed to create test account file: %v", err)
 	}
-	
+
 	return accountFile
 }
 
 func TestGetQuotaEndpoints(t *testing.T) {
 	router := setupTestRouter()
-	
+
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/quota", nil)
 	router.ServeHTTP(w, req)
-	
+
 	if w.Code != http.StatusOK {
 		t.Errorf("Expected status 200, got %d", w.Code)
 	}
-	
+
 	var response map[string]interface{}
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	if err != nil {
 		t.Fatalf("Failed to parse response: %v", err)
 	}
-	
+
 	if response["message"] != "Welcome to the Antigravity Quota API" {
 		t.Errorf("Unexpected message in response")
 	}
-	
+
 	endpoints, ok := response["endpoints"].(map[string]interface{})
 	if !ok {
 		t.Errorf("Expected endpoints object in response")
 	}
-	
+
 	if len(endpoints) == 0 {
 		t.Errorf("Expected endpoints to be populated")
 	}
@@ -77,21 +89,21 @@ func TestGetQuotaEndpoints(t *testing.T) {
 
 func TestGetQuotaUsage(t *testing.T) {
 	router := setupTestRouter()
-	
+
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/quota/usage", nil)
 	router.ServeHTTP(w, req)
-	
+
 	if w.Code != http.StatusOK {
 		t.Errorf("Expected status 200, got %d", w.Code)
 	}
-	
+
 	var response map[string]interface{}
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	if err != nil {
 		t.Fatalf("Failed to parse response: %v", err)
 	}
-	
+
 	// Should be same as /quota endpoint
 	if response["message"] != "Welcome to the Antigravity Quota API" {
 		t.Errorf("Unexpected message in response")
@@ -151,10 +163,10 @@ func TestQuotaServiceWithMockServer(t *testing.T) {
 	// Create mock server
 	mockServer := createMockServer(t)
 	defer mockServer.Close()
-	
+
 	// Create test account
 	accountFile := createTestAccount(t)
-	
+
 	// Create config with mock server URLs
 	config := &Config{
 		APIURL:        mockServer.URL + "/v1internal:fetchAvailableModels",
@@ -162,47 +174,85 @@ func TestQuotaServiceWithMockServer(t *testing.T) {
 		TokenURL:      mockServer.URL + "/token",
 		UserAgent:     "test-agent",
 		ClientID:      "test-client-id",
-		ClientSecret:  "test-client-secret",
+		ClientSecret:  staticToken("test-client-secret"),
 		AccountFile:   accountFile,
 		QueryDebounce: 1,
 	}
-	
+
 	client := NewCloudCodeClient(config)
-	
+
 	// Test loading account
 	_, err := client.LoadAccount()
 	if err != nil {
 		t.Fatalf("Failed to load account: %v", err)
 	}
-	
+
 	// Test getting quota (this will use cached token since it's not expired)
-	quotaResp, err := client.GetQuota("test-access-token", "test-project-id")
+	quotaResp, err := client.GetQuota(context.Background(), "test-access-token", "test-project-id")
 	if err != nil {
 		t.Fatalf("Failed to get quota: %v", err)
 	}
-	
+
 	if len(quotaResp.Models) != 3 {
 		t.Errorf("Expected 3 models, got %d", len(quotaResp.Models))
 	}
-	
+
 	// Test formatting
 	formatted := formatQuota(quotaResp, true)
 	if len(formatted.Models) != 3 {
 		t.Errorf("Expected 3 formatted models, got %d", len(formatted.Models))
 	}
-	
+
 	// Test filtering
 	proModels := filterModels(formatted, []string{"gemini-3-pro-high"})
 	if len(proModels.Models) != 1 {
 		t.Errorf("Expected 1 pro model, got %d", len(proModels.Models))
 	}
-	
+
 	if proModels.Models[0].Name != "gemini-3-pro-high" {
 		t.Errorf("Expected gemini-3-pro-high, got %s", proModels.Models[0].Name)
 	}
-	
+
 	if proModels.Models[0].Percentage != 95 {
-		t.Errorf("Expected 95%%, got %d%%", proModels.Models[0].Percentage)
+		t.Errorf("Expected 95%%, got %v%%", proModels.Models[0].Percentage)
+	}
+}
+
+func TestGetQuotaDataRefreshesRejectedToken(t *testing.T) {
+	refreshes := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			refreshes++
+			w.Write([]byte(`{"access_token":"new-access","expires_in":3600,"token_type":"Bearer","refresh_token":"rotated-refresh"}`))
+		case "/quota":
+			if r.Header.Get("Authorization") != "Bearer new-access" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"models":{"gemini-3-flash":{"quotaInfo":{"remainingFraction":0.5}}}}`))
+		}
+	}))
+	defer server.Close()
+
+	// The account file claims the revoked token is valid for another hour
+	expiry := time.Now().Unix() + 3600
+	accountFile := filepath.Join(t.TempDir(), "account.json")
+	data, _ := json.Marshal(Account{Token: &TokenData{AccessToken: "revoked-access", RefreshToken: "old-refresh", ExpiryTimestamp: &expiry, ProjectID: "p"}})
+	os.WriteFile(accountFile, data, 0600)
+
+	service := NewQuotaService(NewCloudCodeClient(&Config{APIURL: server.URL + "/quota", TokenURL: server.URL + "/token", AccountFile: accountFile, QueryDebounce: 1}))
+	quota, err := service.getQuotaData(context.Background())
+	if err != nil || len(quota.Models) != 1 {
+		t.Fatalf("Expected the quota after a refresh, got %v %v", quota, err)
+	}
+	if refreshes != 1 {
+		t.Errorf("Expected one refresh, got %d", refreshes)
+	}
+
+	saved, _ := service.client.LoadAccount()
+	if saved.Token.AccessToken != "new-access" || saved.Token.RefreshToken != "rotated-refresh" {
+		t.Errorf("Expected the new tokens to be saved, got %+v", saved.Token)
 	}
 }
 
@@ -213,10 +263,10 @@ func TestFormatTimeCompact(t *testing.T) {
 		expected string
 	}{
 		{"empty", "", ""},
-		{"2h30m", "2025-12-26T12:30:00Z", ""},  // This will vary based on current time
+		{"2h30m", "2025-12-26T12:30:00Z", ""}, // This will vary based on current time
 		{"invalid", "invalid-time", ""},
 	}
-	
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			result := formatTimeCompact(tt.input)
@@ -232,7 +282,7 @@ func TestFormatTimeCompact(t *testing.T) {
 
 func TestFormatPercentageWithColor(t *testing.T) {
 	tests := []struct {
-		percentage int
+		percentage float64
 		contains   string
 	}{
 		{100, "●"},
@@ -241,7 +291,7 @@ func TestFormatPercentageWithColor(t *testing.T) {
 		{5, "5%"},
 		{0, "●"},
 	}
-	
+
 	for _, tt := range tests {
 		t.Run("", func(t *testing.T) {
 			result := formatPercentageWithColor(tt.percentage)
@@ -251,3 +301,165 @@ func TestFormatPercentageWithColor(t *testing.T) {
 		})
 	}
 }
+
+func TestStreamQuota(t *testing.T) {
+	mockServer := createMockServer(t)
+	defer mockServer.Close()
+
+	config := &Config{
+		APIURL:        mockServer.URL + "/v1internal:fetchAvailableModels",
+		ProjectAPIURL: mockServer.URL + "/v1internal:loadCodeAssist",
+		TokenURL:      mockServer.URL + "/token",
+		UserAgent:     "test-agent",
+		AccountFile:   createTestAccount(t),
+		QueryDebounce: 1,
+	}
+	service := NewQuotaService(NewCloudCodeClient(config))
+
+	router := gin.New()
+	router.GET("/quota/stream", service.StreamQuota)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/quota/stream?only=gemini-*")
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Expected text/event-stream, got %s", resp.Header.Get("Content-Type"))
+	}
+
+	// Read the initial event
+	reader := bufio.NewReader(resp.Body)
+	event, _ := reader.ReadString('\n')
+	data, _ := reader.ReadString('\n')
+
+	if event != "event:quota\n" {
+		t.Fatalf("Expected quota event, got %q", event)
+	}
+
+	var payload struct {
+		Provider string         `json:"provider"`
+		Quota    FormattedQuota `json:"quota"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(data, "data:")), &payload); err != nil {
+		t.Fatalf("Failed to parse event data %q: %v", data, err)
+	}
+	if payload.Provider != ProviderAntigravity || len(payload.Quota.Models) != 2 {
+		t.Errorf("Expected 2 antigravity gemini models, got %s with %d", payload.Provider, len(payload.Quota.Models))
+	}
+}
+
+func TestStreamQuotaUnknownProvider(t *testing.T) {
+	service := NewQuotaService(NewCloudCodeClient(&Config{QueryDebounce: 1}))
+
+	router := gin.New()
+	router.GET("/quota/stream", service.StreamQuota)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/quota/stream?provider=unknown", nil)
+	router.ServeHTTP(w, req)
+
+	if !strings.Contains(w.Body.String(), "event:error") {
+		t.Errorf("Expected error event, got %q", w.Body.String())
+	}
+}
+
+func TestFetchQuotaHealth(t *testing.T) {
+	mockServer := createMockServer(t)
+	defer mockServer.Close()
+
+	config := &Config{
+		APIURL:        mockServer.URL + "/v1internal:fetchAvailableModels",
+		ProjectAPIURL: mockServer.URL + "/v1internal:loadCodeAssist",
+		TokenURL:      mockServer.URL + "/token",
+		UserAgent:     "test-agent",
+		AccountFile:   createTestAccount(t),
+		QueryDebounce: 1,
+	}
+	service := NewQuotaService(NewCloudCodeClient(config))
+
+	// First fetch goes upstream
+	quota, err := service.fetchQuota(context.Background(), ProviderAntigravity)
+	if err != nil {
+		t.Fatalf("fetchQuota failed: %v", err)
+	}
+	if quota.Health == nil || quota.Health.CacheHit || quota.Health.LastSuccess == 0 {
+		t.Errorf("Expected fresh fetch health, got %+v", quota.Health)
+	}
+
+	// Second fetch is served from cache
+	quota, err = service.fetchQuota(context.Background(), ProviderAntigravity)
+	if err != nil {
+		t.Fatalf("fetchQuota failed: %v", err)
+	}
+	if !quota.Health.CacheHit {
+		t.Errorf("Expected cache hit, got %+v", quota.Health)
+	}
+
+	// Failures are recorded without losing the last success
+	config.AccountFile = filepath.Join(t.TempDir(), "missing.json")
+	if _, err := service.fetchQuota(context.Background(), ProviderAntigravity); err == nil {
+		t.Fatal("Expected error for missing account file")
+	}
+	health := providerHealth.get(ProviderAntigravity)
+	if health.LastError == "" || health.LastErrorAt == 0 || health.LastSuccess == 0 {
+		t.Errorf("Expected error and last success in health, got %+v", health)
+	}
+
+	// /healthz exposes the same metadata
+	router := gin.New()
+	router.GET("/healthz", service.GetHealth)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/healthz", nil)
+	router.ServeHTTP(w, req)
+
+	var response struct {
+		Providers map[string]ProviderHealth `json:"providers"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response.Providers[ProviderAntigravity].LastError == "" {
+		t.Errorf("Expected antigravity health in /healthz, got %v", response.Providers)
+	}
+}
+
+func TestGetCombinedQuota(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"userStatus":{"planStatus":{"availablePromptCredits":100,"usedPromptCredits":50}}}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("WINDSURF_API_KEY", "ws-key")
+	t.Setenv("WINDSURF_STATUS_URL", server.URL+"/combined")
+	t.Setenv("CURSOR_SESSION_TOKEN", "")
+
+	service := NewQuotaService(NewCloudCodeClient(&Config{QueryDebounce: 1}))
+	router := gin.New()
+	router.GET("/quota/combined", service.GetCombinedQuota)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/quota/combined?providers=windsurf,cursor", nil)
+	router.ServeHTTP(w, req)
+
+	var response struct {
+		Quota    FormattedQuota    `json:"quota"`
+		Errors   map[string]string `json:"errors"`
+		Problems []Problem         `json:"problems"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(response.Quota.Models) != 2 || response.Quota.Models[0].Name != "windsurf-prompt-credits" {
+		t.Errorf("Expected windsurf models only, got %+v", response.Quota.Models)
+	}
+	if _, ok := response.Errors[ProviderCursor]; !ok {
+		t.Errorf("Expected cursor error without a session token, got %v", response.Errors)
+	}
+	if len(response.Problems) != 1 || response.Problems[0].Provider != ProviderCursor || !strings.Contains(response.Problems[0].Fix, "CURSOR_SESSION_TOKEN") {
+		t.Errorf("Expected a cursor problem with its fix, got %+v", response.Problems)
+	}
+}
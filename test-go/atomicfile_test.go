@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "account.json")
+	if err := os.WriteFile(path, []byte(`{"old": true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFileAtomic(path, []byte(`{"new": true}`), 0600); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != `{"new": true}` {
+		t.Errorf("Expected the new content, got %s", data)
+	}
+	if info, err := os.Stat(path); err != nil || (runtime.GOOS != "windows" && info.Mode().Perm() != 0600) {
+		t.Errorf("Expected mode 0600, got %v, %v", info.Mode(), err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected no temporary files left, got %v", entries)
+	}
+
+	// A failed write leaves the old file alone
+	if err := writeFileAtomic(filepath.Join(dir, "missing", "file"), []byte("x"), 0600); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+}
+
+func TestAppendHistoryAfterTruncatedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	// A crash cut off the last append
+	content := `{"time":100,"provider":"glm","model":"a","percentage":90}` + "\n" + `{"time":200,"provider":"glm","mod`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := appendHistory(path, HistoryRecord{Time: 300, Provider: "glm", Model: "a", Percentage: 80}); err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+	lines, err := readHistoryFile(path, func(historyLine) bool { return true })
+	if err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if len(lines) != 2 || lines[0].Time != 100 || lines[1].Time != 300 {
+		t.Errorf("Expected the records around the truncated line, got %+v", lines)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetchQuotaCachesAuthFailures(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	defer setClock(clock)()
+	defer authFailures.clear()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Authorization") != "fixed-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"code":200,"data":{"limits":[{"type":"TOKENS_LIMIT","percentage":25}]}}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("ZAI_AUTH_TOKEN", "invalid-token")
+	t.Setenv("ZAI_ANTHROPIC_BASE_URL", server.URL+"/api/anthropic")
+	t.Setenv("ZAI_PLATFORM", "ZAI")
+	t.Setenv("AUTH_FAILURE_TTL", "5")
+	service := NewQuotaService(NewCloudCodeClient(LoadConfig()))
+
+	if _, err := service.fetchQuota(context.Background(), ProviderGLM); !isAuthFailure(err) {
+		t.Fatalf("Expected a 403 with the invalid token, got %v", err)
+	}
+	// Within the TTL the rejection is returned without a request
+	clock.Advance(time.Minute)
+	if _, err := service.fetchQuota(context.Background(), ProviderGLM); !isAuthFailure(err) || requests != 1 {
+		t.Errorf("Expected the cached rejection without a request, got %v after %d requests", err, requests)
+	}
+	// After it, the provider is asked again
+	clock.Advance(5 * time.Minute)
+	service.fetchQuota(context.Background(), ProviderGLM)
+	if requests != 2 {
+		t.Errorf("Expected a retry after the TTL, got %d requests", requests)
+	}
+
+	// A fixed token is used at once
+	t.Setenv("ZAI_AUTH_TOKEN", "fixed-token")
+	service.client.SetConfig(LoadConfig())
+	if quota, err := service.fetchQuota(context.Background(), ProviderGLM); err != nil || len(quota.Models) != 1 {
+		t.Errorf("Expected the fixed token to be fetched, got %+v, %v", quota, err)
+	}
+}
+
+func TestAuthFailureCacheDisabled(t *testing.T) {
+	defer authFailures.clear()
+	t.Setenv("AUTH_FAILURE_TTL", "0")
+	key := responseCacheKey(ProviderGroq, "", "key", "models")
+	authFailures.observe(key, &APIError{StatusCode: http.StatusUnauthorized})
+	if err := authFailures.get(key); err != nil {
+		t.Errorf("Expected AUTH_FAILURE_TTL=0 to cache nothing, got %v", err)
+	}
+	if isAuthFailure(&APIError{StatusCode: http.StatusTooManyRequests}) || isAuthFailure(fmt.Errorf("a message with status 401")) {
+		t.Error("Expected only 401 and 403 API errors to be auth failures")
+	}
+	if !isAuthFailure(fmt.Errorf("glm: %w", &APIError{StatusCode: http.StatusForbidden, Message: "Z.ai API error: status 403"})) {
+		t.Error("Expected a wrapped 403 API error to be an auth failure")
+	}
+}
+
+func TestAuthFailuresPerAccount(t *testing.T) {
+	defer authFailures.clear()
+	defer zaiCache.Clear()
+	t.Setenv("AUTH_FAILURE_TTL", "5")
+
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("Authorization")
+		requests[token]++
+		if token == "revoked" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"code":200,"data":{"ok":true}}`)
+	}))
+	defer server.Close()
+
+	query := func(account, token string) error {
+		_, _, err := queryZAIEndpoint(context.Background(), ProviderGLM, account, server.URL+"/api/monitor", token, "", "data", decodeZAIBody)
+		return err
+	}
+	for range 2 {
+		if err := query("work", "revoked"); !isAuthFailure(err) {
+			t.Errorf("Expected the revoked account to fail, got %v", err)
+		}
+	}
+	if requests["revoked"] != 1 {
+		t.Errorf("Expected the rejection to be remembered, got %d requests", requests["revoked"])
+	}
+	if err := query("home", "valid"); err != nil || requests["valid"] != 1 {
+		t.Errorf("Expected another account to be asked, got %v after %d requests", err, requests["valid"])
+	}
+
+	// Unrelated secrets do not matter, and auth set drops the provider's rejections
+	t.Setenv("GROQ_API_KEY", "changed")
+	if err := query("work", "revoked"); !isAuthFailure(err) || requests["revoked"] != 1 {
+		t.Errorf("Expected the rejection to outlive unrelated changes, got %v", err)
+	}
+	authFailures.forget(ProviderGLM)
+	query("work", "revoked")
+	if requests["revoked"] != 2 {
+		t.Errorf("Expected a request after forget, got %d", requests["revoked"])
+	}
+}
+
+func TestAuthSetCommand(t *testing.T) {
+	defer authFailures.clear()
+	t.Setenv("AUTH_FAILURE_TTL", "5")
+	t.Setenv("GROQ_API_KEY", "")
+	envFile := filepath.Join(t.TempDir(), ".env")
+	os.WriteFile(envFile, []byte("PORT=8080\nGROQ_API_KEY=old\n"), 0600)
+	key := responseCacheKey(ProviderGroq, "", "old", "models")
+	authFailures.observe(key, &APIError{StatusCode: http.StatusUnauthorized})
+
+	var out bytes.Buffer
+	if err := runAuthCommand([]string{"set", ProviderGroq, "--env-file", envFile}, strings.NewReader("new-key\n"), &out); err != nil {
+		t.Fatalf("auth set failed: %v", err)
+	}
+	if data, _ := os.ReadFile(envFile); string(data) != "PORT=8080\nGROQ_API_KEY=\"new-key\"\n" {
+		t.Errorf("Expected the key replaced in place, got %q", data)
+	}
+	if err := authFailures.get(key); err != nil {
+		t.Errorf("Expected auth set to drop the rejection, got %v", err)
+	}
+	if err := runAuthCommand([]string{"set", "nope"}, strings.NewReader("x\n"), &out); err == nil {
+		t.Error("Expected an unknown provider to fail")
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetBadge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"code":200,"data":{"limits":[{"type":"TOKENS_LIMIT","percentage":65},{"type":"TIME_LIMIT","percentage":10,"currentValue":90,"usage":100}]}}`)
+	}))
+	defer server.Close()
+	t.Setenv("ZAI_AUTH_TOKEN", "badge-token")
+	t.Setenv("ZAI_ANTHROPIC_BASE_URL", server.URL+"/api/anthropic")
+	t.Setenv("ZAI_PLATFORM", "ZAI")
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	setupRoutes(r)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/badge/glm.svg")
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "image/svg+xml") {
+		t.Fatalf("Expected an SVG badge, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(body, ">glm<") || !strings.Contains(body, ">35%<") || !strings.Contains(body, badgeYellow) {
+		t.Errorf("Expected a yellow glm 35%% badge, got:\n%s", body)
+	}
+	if w.Header().Get("Cache-Control") != "max-age=60" {
+		t.Errorf("Expected the debounce as max-age, got %q", w.Header().Get("Cache-Control"))
+	}
+
+	w = get("/badge/glm-coding-plan-mcp-monthly.svg?label=MCP%20%26%20tools")
+	if body := w.Body.String(); !strings.Contains(body, ">MCP &amp; tools<") || !strings.Contains(body, ">90%<") || !strings.Contains(body, badgeGreen) {
+		t.Errorf("Expected a green escaped MCP badge, got:\n%s", body)
+	}
+
+	w = get("/badge/glm-nothing.svg")
+	if body := w.Body.String(); w.Code != http.StatusOK || !strings.Contains(body, ">not found<") || w.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("Expected a grey not found badge, got %d:\n%s", w.Code, body)
+	}
+
+	if w := get("/badge/glm.png"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected other extensions to be rejected, got %d", w.Code)
+	}
+}
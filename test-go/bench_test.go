@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// benchQuotaLimitBody is a quota limit response as Z.ai sends it
+const benchQuotaLimitBody = `{"code":200,"msg":"Operation successful","data":{"limits":[` +
+	`{"type":"TOKENS_LIMIT","unit":3,"number":5,"usage":40000000,"currentValue":12000000,"remaining":28000000,"percentage":30,"nextResetTime":1767225600000},` +
+	`{"type":"TIME_LIMIT","unit":5,"number":1,"usage":4000,"currentValue":120,"remaining":3880,"percentage":3,"usageDetails":[` +
+	`{"modelCode":"search-prime","usage":80},{"modelCode":"web-reader","usage":30},{"modelCode":"zread","usage":10}]}]},"success":true}`
+
+func BenchmarkDecodeQuotaLimitGeneric(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		data, err := decodeZAIResponse(bytes.NewReader([]byte(benchQuotaLimitBody)))
+		if err != nil {
+			b.Fatal(err)
+		}
+		ProcessQuotaLimit(data)
+	}
+}
+
+func BenchmarkDecodeQuotaLimit(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, ok := decodeQuotaLimitTyped([]byte(benchQuotaLimitBody)); !ok {
+			b.Fatal("typed decoding fell back")
+		}
+	}
+}
+
+func BenchmarkGetGLMQuotaCached(b *testing.B) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, benchQuotaLimitBody)
+	}))
+	defer server.Close()
+
+	b.Setenv("ZAI_AUTH_TOKEN", "bench-token")
+	b.Setenv("ZAI_ANTHROPIC_BASE_URL", server.URL+"/api/anthropic")
+	b.Setenv("ZAI_PLATFORM", "ZAI")
+	b.Setenv("QUERY_DEBOUNCE", "60")
+
+	b.ReportAllocs()
+	for b.Loop() {
+		quota, err := GetGLMQuota(context.Background())
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := renderJSON(quota); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
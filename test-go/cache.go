@@ -0,0 +1,193 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Number of independently locked shards of a ResponseCache
+const cacheShards = 16
+
+// DefaultCacheMaxEntries bounds each response cache unless CACHE_MAX_ENTRIES is set
+const DefaultCacheMaxEntries = 1024
+
+// CacheEntry is a cached provider response
+type CacheEntry struct {
+	Data      interface{}
+	FetchedAt time.Time
+	ExpiresAt time.Time
+}
+
+// cacheItem is an entry with the time it was last read, updated without the write lock
+type cacheItem struct {
+	entry    CacheEntry
+	lastUsed atomic.Int64
+}
+
+// cacheShard is one locked part of a ResponseCache
+type cacheShard struct {
+	mu    sync.RWMutex
+	items map[string]*cacheItem
+}
+
+// ResponseCache is a size-bounded, least-recently-used cache of provider responses. Keys
+// are spread over shards with their own read-write locks, and a read only takes its shard's
+// read lock, so statusline reads of hot entries do not wait for writes to other keys.
+type ResponseCache struct {
+	shards [cacheShards]cacheShard
+	size   atomic.Int64
+
+	hits      atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
+}
+
+// CacheStats are the counters of a ResponseCache
+type CacheStats struct {
+	Entries   int64
+	Hits      int64
+	Misses    int64
+	Evictions int64
+}
+
+// responseCacheKey identifies a cached response by provider, account label, a hash of the
+// credential it was fetched with, and the request. Accounts that share an endpoint never
+// read each other's data, a rotated credential misses, and keys hold no secrets.
+func responseCacheKey(provider, account, credential string, request ...string) string {
+	if account == "" {
+		account = "default"
+	}
+	identity := "anonymous"
+	if credential != "" {
+		sum := sha256.Sum256([]byte(credential))
+		identity = hex.EncodeToString(sum[:8])
+	}
+	return provider + "/" + account + "/" + identity + " " + strings.Join(request, " ")
+}
+
+// credentialIdentity returns the provider, account, and credential hash of a response cache
+// key, without the request, which identify the credential the response was fetched with
+func credentialIdentity(key string) string {
+	identity, _, _ := strings.Cut(key, " ")
+	return identity
+}
+
+// NewResponseCache creates an empty cache
+func NewResponseCache() *ResponseCache {
+	c := &ResponseCache{}
+	for i := range c.shards {
+		c.shards[i].items = make(map[string]*cacheItem)
+	}
+	return c
+}
+
+// shard returns the shard holding key
+func (c *ResponseCache) shard(key string) *cacheShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &c.shards[h.Sum32()%cacheShards]
+}
+
+// Get returns the entry for key while it is fresh
+func (c *ResponseCache) Get(key string) (CacheEntry, bool) {
+	shard := c.shard(key)
+	shard.mu.RLock()
+	item, exists := shard.items[key]
+	shard.mu.RUnlock()
+
+	now := clockNow()
+	if !exists || !now.Before(item.entry.ExpiresAt) {
+		c.misses.Add(1)
+		return CacheEntry{}, false
+	}
+	item.lastUsed.Store(now.UnixNano())
+	c.hits.Add(1)
+	return item.entry, true
+}
+
+// Set stores an entry, dropping expired entries of its shard and then the least recently
+// used entries until at most maxEntries remain
+func (c *ResponseCache) Set(key string, entry CacheEntry, maxEntries int) {
+	now := clockNow()
+	item := &cacheItem{entry: entry}
+	item.lastUsed.Store(now.UnixNano())
+
+	shard := c.shard(key)
+	shard.mu.Lock()
+	if _, exists := shard.items[key]; !exists {
+		c.size.Add(1)
+	}
+	shard.items[key] = item
+	for k, other := range shard.items {
+		if k != key && !now.Before(other.entry.ExpiresAt) {
+			delete(shard.items, k)
+			c.size.Add(-1)
+		}
+	}
+	shard.mu.Unlock()
+
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+	for c.size.Load() > int64(maxEntries) {
+		if !c.evictOldest(key) {
+			break
+		}
+	}
+}
+
+// evictOldest removes the least recently used entry other than keep, returning false when
+// there is none
+func (c *ResponseCache) evictOldest(keep string) bool {
+	var oldestShard *cacheShard
+	var oldestKey string
+	var oldestUsed int64
+	for i := range c.shards {
+		shard := &c.shards[i]
+		shard.mu.RLock()
+		for key, item := range shard.items {
+			if used := item.lastUsed.Load(); key != keep && (oldestShard == nil || used < oldestUsed) {
+				oldestShard, oldestKey, oldestUsed = shard, key, used
+			}
+		}
+		shard.mu.RUnlock()
+	}
+	if oldestShard == nil {
+		return false
+	}
+
+	oldestShard.mu.Lock()
+	defer oldestShard.mu.Unlock()
+	if _, exists := oldestShard.items[oldestKey]; exists {
+		delete(oldestShard.items, oldestKey)
+		c.size.Add(-1)
+		c.evictions.Add(1)
+	}
+	return true
+}
+
+// Clear removes every entry, keeping the counters
+func (c *ResponseCache) Clear() {
+	for i := range c.shards {
+		shard := &c.shards[i]
+		shard.mu.Lock()
+		c.size.Add(-int64(len(shard.items)))
+		shard.items = make(map[string]*cacheItem)
+		shard.mu.Unlock()
+	}
+}
+
+// Stats returns the entry count and the hit, miss, and eviction counters
+func (c *ResponseCache) Stats() CacheStats {
+	return CacheStats{
+		Entries:   c.size.Load(),
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResponseCacheLRU(t *testing.T) {
+	clk := &fakeClock{t: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	defer setClock(clk)()
+
+	cache := NewResponseCache()
+	entry := func(data string) CacheEntry {
+		return CacheEntry{Data: data, FetchedAt: clk.Now(), ExpiresAt: clk.Now().Add(time.Hour)}
+	}
+
+	for _, key := range []string{"a", "b", "c"} {
+		cache.Set(key, entry(key), 3)
+		clk.Advance(time.Second)
+	}
+	// Reading a makes b the least recently used
+	if got, ok := cache.Get("a"); !ok || got.Data != "a" {
+		t.Fatalf("Expected a cached, got %v %v", got, ok)
+	}
+	clk.Advance(time.Second)
+	cache.Set("d", entry("d"), 3)
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("Expected %s to be kept", key)
+		}
+	}
+	stats := cache.Stats()
+	if stats.Entries != 3 || stats.Evictions != 1 || stats.Hits != 4 || stats.Misses != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	// Expired entries miss and are dropped by later writes to their shard
+	clk.Advance(2 * time.Hour)
+	if _, ok := cache.Get("a"); ok {
+		t.Error("Expected a to have expired")
+	}
+	for i := 0; i < 64; i++ {
+		cache.Set(fmt.Sprint("fresh", i), entry("x"), 100)
+	}
+	if stats := cache.Stats(); stats.Entries != 64 {
+		t.Errorf("Expected only the 64 fresh entries, got %d", stats.Entries)
+	}
+
+	cache.Clear()
+	if stats := cache.Stats(); stats.Entries != 0 {
+		t.Errorf("Expected an empty cache, got %d entries", stats.Entries)
+	}
+}
+
+func TestResponseCacheConcurrent(t *testing.T) {
+	cache := NewResponseCache()
+	expires := clockNow().Add(time.Hour)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprintf("%d-%d", worker, i%50)
+				cache.Set(key, CacheEntry{Data: i, ExpiresAt: expires}, 64)
+				cache.Get(key)
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	if entries := cache.Stats().Entries; entries > 64 {
+		t.Errorf("Expected at most 64 entries, got %d", entries)
+	}
+}
+
+func TestWriteCacheMetrics(t *testing.T) {
+	var b strings.Builder
+	writeCacheMetrics(&b, 512)
+	for _, want := range []string{`quota_exporter_cache_entries{cache="zai"}`, `quota_exporter_cache_evictions_total{cache="provider"}`, "quota_exporter_cache_max_entries 512"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("Expected %s in %s", want, b.String())
+		}
+	}
+}
+
+func TestResponseCacheKey(t *testing.T) {
+	key := responseCacheKey(ProviderGLM, "", "secret-token", "limits", "https://api.z.ai/api/monitor/usage/quota/limit")
+	if !strings.HasPrefix(key, "glm/default/") || strings.Contains(key, "secret-token") {
+		t.Errorf("Expected a key by provider and default account without the token, got %s", key)
+	}
+	if key != responseCacheKey(ProviderGLM, "", "secret-token", "limits", "https://api.z.ai/api/monitor/usage/quota/limit") {
+		t.Error("Expected the same request of the same account to share a key")
+	}
+	for _, other := range []string{
+		responseCacheKey(ProviderGLM, "work", "secret-token", "limits", "https://api.z.ai/api/monitor/usage/quota/limit"),
+		responseCacheKey(ProviderGLM, "", "rotated-token", "limits", "https://api.z.ai/api/monitor/usage/quota/limit"),
+		responseCacheKey(ProviderZhipuBalance, "", "secret-token", "limits", "https://api.z.ai/api/monitor/usage/quota/limit"),
+		responseCacheKey(ProviderGLM, "", "secret-token", "plan", "https://api.z.ai/api/monitor/usage/quota/limit"),
+	} {
+		if other == key {
+			t.Errorf("Expected %s to differ from %s", other, key)
+		}
+	}
+	if key := responseCacheKey(ProviderLocal, "", "", "http://localhost:11434/api/tags"); !strings.HasPrefix(key, "local/default/anonymous ") {
+		t.Errorf("Expected an anonymous key without a credential, got %s", key)
+	}
+}
+
+func TestZAICacheIsolatesAccounts(t *testing.T) {
+	zaiCache.Clear()
+	defer zaiCache.Clear()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintf(w, `{"code":200,"data":{"token":%q}}`, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	fetch := func(account, token string) string {
+		data, _, err := queryZAIEndpoint(context.Background(), ProviderGLM, account, server.URL+"/quota", token, "", "data", decodeZAIBody)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		return data.(map[string]interface{})["token"].(string)
+	}
+
+	if got := fetch("", "first-token"); got != "first-token" {
+		t.Errorf("Expected the first account's data, got %s", got)
+	}
+	// Another account on the same endpoint, and a rotated token, are not served its data
+	if got := fetch("work", "first-token"); got != "first-token" || requests != 2 {
+		t.Errorf("Expected the work account to be fetched, got %s after %d requests", got, requests)
+	}
+	if got := fetch("", "rotated-token"); got != "rotated-token" || requests != 3 {
+		t.Errorf("Expected the rotated token to be fetched, got %s after %d requests", got, requests)
+	}
+	if got := fetch("", "rotated-token"); got != "rotated-token" || requests != 3 {
+		t.Errorf("Expected the rotated token's data from the cache, got %s after %d requests", got, requests)
+	}
+}
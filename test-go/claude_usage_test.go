@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClaudeSessionToken(t *testing.T) {
+	if token, err := claudeSessionToken(context.Background(), &Config{ClaudeOAuthToken: staticToken("env-token")}); err != nil || token != "env-token" {
+		t.Errorf("Expected env-token, got %q (%v)", token, err)
+	}
+
+	path := filepath.Join(t.TempDir(), ".credentials.json")
+	if err := os.WriteFile(path, []byte(`{"claudeAiOauth":{"accessToken":"file-token"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if token, err := claudeSessionToken(context.Background(), &Config{ClaudeCredentialsFile: path}); err != nil || token != "file-token" {
+		t.Errorf("Expected file-token, got %q (%v)", token, err)
+	}
+
+	if _, err := claudeSessionToken(context.Background(), &Config{ClaudeCredentialsFile: filepath.Join(t.TempDir(), "missing.json")}); err == nil {
+		t.Error("Expected error for missing credentials file")
+	}
+}
+
+func TestFormatClaudeUsage(t *testing.T) {
+	reset := time.Now().UTC().Add(2 * time.Hour).Format(time.RFC3339)
+	quota := FormatClaudeUsage(&ClaudeUsage{
+		FiveHour: &ClaudeUsageWindow{Utilization: 35, ResetsAt: reset},
+		SevenDay: &ClaudeUsageWindow{Utilization: 10},
+	})
+
+	if len(quota.Models) != 2 {
+		t.Fatalf("Expected 2 models (opus window absent), got %d", len(quota.Models))
+	}
+	session := quota.Models[0]
+	if session.Name != "claude-ai-session" || session.Percentage != 65 || session.ResetTime != reset {
+		t.Errorf("Unexpected session model: %+v", session)
+	}
+	if session.ResetTimeRelative == "" {
+		t.Error("Expected relative reset time for session window")
+	}
+	if quota.Models[1].Name != "claude-ai-weekly" || quota.Models[1].Percentage != 90 {
+		t.Errorf("Unexpected weekly model: %+v", quota.Models[1])
+	}
+}
+
+func TestQueryClaudeUsage(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if got := r.Header.Get("Authorization"); got != "Bearer session" {
+			t.Errorf("Unexpected Authorization header %q", got)
+		}
+		fmt.Fprint(w, `{"five_hour":{"utilization":20.5,"resets_at":"2026-03-01T17:00:00.000000+00:00"},"seven_day":null}`)
+	}))
+	defer server.Close()
+
+	for i := 0; i < 2; i++ {
+		usage, err := queryClaudeUsage(context.Background(), server.URL, "session")
+		if err != nil {
+			t.Fatalf("queryClaudeUsage failed: %v", err)
+		}
+		if usage.FiveHour == nil || usage.FiveHour.Utilization != 20.5 || usage.SevenDay != nil {
+			t.Errorf("Unexpected usage: %+v", usage)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Expected second query to be cached, got %d requests", requests)
+	}
+}
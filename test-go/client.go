@@ -2,13 +2,14 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -34,16 +35,37 @@ type TokenData struct {
 	TokenType       string `json:"token_type,omitempty"`
 }
 
-// TokenResponse represents OAuth token response
+// TokenResponse represents OAuth token response. RefreshToken is only set when the
+// server rotates the refresh token.
 type TokenResponse struct {
-	AccessToken string `json:"access_token"`
-	ExpiresIn   int    `json:"expires_in"`
-	TokenType   string `json:"token_type"`
+	AccessToken  string `json:"access_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// APIError is a googleapis.com response with an unexpected status
+type APIError struct {
+	StatusCode int
+	Body       string
+
+	// Message replaces the default text, for providers that word their failures themselves
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("API request failed: %d - %s", e.StatusCode, e.Body)
 }
 
 // QuotaResponse represents the API response structure
 type QuotaResponse struct {
 	Models map[string]ModelInfo `json:"models"`
+
+	// FetchedAt is when the data was retrieved from the API (not when it was served from cache)
+	FetchedAt time.Time `json:"-"`
 }
 
 // ModelInfo represents model information
@@ -59,10 +81,15 @@ type QuotaInfo struct {
 
 // FormattedModel represents formatted model data
 type FormattedModel struct {
-	Name                string `json:"name"`
-	Percentage          int    `json:"percentage"`
-	ResetTime           string `json:"reset_time"`
-	ResetTimeRelative   string `json:"reset_time_relative,omitempty"`
+	Name              string          `json:"name"`
+	Percentage        float64         `json:"percentage"`
+	ResetTime         string          `json:"reset_time"`
+	ResetTimeRelative string          `json:"reset_time_relative,omitempty"`
+	Balance           *Balance        `json:"balance,omitempty"`
+	Local             *LocalModelInfo `json:"local,omitempty"`
+	Warning           string          `json:"warning,omitempty"`
+	Provider          string          `json:"provider,omitempty"`
+	Account           string          `json:"account,omitempty"`
 }
 
 // FormattedQuota represents formatted quota response
@@ -70,6 +97,11 @@ type FormattedQuota struct {
 	Models      []FormattedModel `json:"models"`
 	LastUpdated int64            `json:"last_updated"`
 	IsForbidden bool             `json:"is_forbidden"`
+	Age         int64            `json:"age"`
+	Health      *ProviderHealth  `json:"health,omitempty"`
+	Usage       *QuotaUsage      `json:"usage,omitempty"`
+	Plan        string           `json:"plan,omitempty"`
+	Groups      []ModelGroup     `json:"groups,omitempty"`
 }
 
 // ProjectResponse represents project API response
@@ -79,27 +111,41 @@ type ProjectResponse struct {
 
 // CloudCodeClient handles API interactions
 type CloudCodeClient struct {
-	config     *Config
+	config     atomic.Pointer[Config]
 	httpClient *http.Client
 	cache      map[string]interface{}
 	cacheMutex sync.RWMutex
 	cacheTime  time.Time
+
+	// Serializes token refreshes so concurrent requests refresh once
+	refreshMutex sync.Mutex
 }
 
 // NewCloudCodeClient creates a new client
 func NewCloudCodeClient(config *Config) *CloudCodeClient {
-	return &CloudCodeClient{
-		config:     config,
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+	client := &CloudCodeClient{
+		httpClient: &http.Client{Timeout: 30 * time.Second, Transport: httpTransport},
 		cache:      make(map[string]interface{}),
 	}
+	client.config.Store(config)
+	return client
+}
+
+// Config returns the current configuration
+func (c *CloudCodeClient) Config() *Config {
+	return c.config.Load()
+}
+
+// SetConfig replaces the configuration without dropping cached data
+func (c *CloudCodeClient) SetConfig(config *Config) {
+	c.config.Store(config)
 }
 
 // LoadAccount loads account from file
 func (c *CloudCodeClient) LoadAccount() (*Account, error) {
-	data, err := os.ReadFile(c.config.AccountFile)
+	data, err := os.ReadFile(c.Config().AccountFile)
 	if err != nil {
-		return nil, fmt.Errorf("account file not found: %s", c.config.AccountFile)
+		return nil, fmt.Errorf("account file not found: %s", c.Config().AccountFile)
 	}
 
 	var account Account
@@ -127,26 +173,34 @@ func (c *CloudCodeClient) NormalizeAccount(account *Account) (string, string, *i
 
 // RefreshAccessToken refreshes the access token
 func (c *CloudCodeClient) RefreshAccessToken(refreshToken string) (*TokenResponse, error) {
+	clientSecret, err := readToken(context.Background(), c.Config().ClientSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CLIENT_SECRET: %w", err)
+	}
 	data := map[string]string{
-		"client_id":     c.config.ClientID,
-		"client_secret": c.config.ClientSecret,
+		"client_id":     c.Config().ClientID,
+		"client_secret": clientSecret,
 		"refresh_token": refreshToken,
 		"grant_type":    "refresh_token",
 	}
 
 	jsonData, _ := json.Marshal(data)
-	resp, err := c.httpClient.Post(c.config.TokenURL, "application/json", bytes.NewBuffer(jsonData))
+	resp, err := c.httpClient.Post(c.Config().TokenURL, "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		body := errorBody(resp.Body)
+		if bytes.Contains(body, []byte("invalid_grant")) {
+			return nil, fmt.Errorf("token refresh failed: %d - the refresh token was revoked or expired; log in again to get a new account file", resp.StatusCode)
+		}
 		return nil, fmt.Errorf("token refresh failed: %d", resp.StatusCode)
 	}
 
 	var tokenResp TokenResponse
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+	if err := decodeResponse(resp, &tokenResp); err != nil {
 		return nil, err
 	}
 
@@ -161,7 +215,7 @@ func (c *CloudCodeClient) EnsureFreshToken(account *Account) (string, error) {
 		return "", fmt.Errorf("missing access_token or refresh_token")
 	}
 
-	now := time.Now().Unix()
+	now := clockNow().Unix()
 	if expiryTimestamp != nil && *expiryTimestamp > now+TokenRefreshBufferSeconds {
 		log.Println("Token is fresh, no need to refresh")
 		return accessToken, nil
@@ -169,11 +223,34 @@ func (c *CloudCodeClient) EnsureFreshToken(account *Account) (string, error) {
 
 	// Token needs refresh
 	log.Println("Token needs refresh")
+	return c.RefreshAccount(account, accessToken)
+}
+
+// RefreshAccount exchanges the account's refresh token for a new access token and saves
+// both, including a rotated refresh token, to the account file. rejected is the access
+// token that expired or was refused; when another request has already replaced it, the
+// account is reloaded instead of refreshing again.
+func (c *CloudCodeClient) RefreshAccount(account *Account, rejected string) (string, error) {
+	c.refreshMutex.Lock()
+	defer c.refreshMutex.Unlock()
+
+	if saved, err := c.LoadAccount(); err == nil {
+		if accessToken, _, _, _ := c.NormalizeAccount(saved); accessToken != "" && accessToken != rejected {
+			*account = *saved
+			return accessToken, nil
+		}
+	}
+
+	_, refreshToken, _, _ := c.NormalizeAccount(account)
+	if refreshToken == "" {
+		return "", fmt.Errorf("missing refresh_token")
+	}
 	newToken, err := c.RefreshAccessToken(refreshToken)
 	if err != nil {
 		return "", err
 	}
 
+	now := clockNow().Unix()
 	newExpiry := now + int64(newToken.ExpiresIn)
 
 	// Update account
@@ -182,12 +259,18 @@ func (c *CloudCodeClient) EnsureFreshToken(account *Account) (string, error) {
 		account.Token.ExpiresIn = newToken.ExpiresIn
 		account.Token.ExpiryTimestamp = &newExpiry
 		account.Token.TokenType = newToken.TokenType
+		if newToken.RefreshToken != "" {
+			account.Token.RefreshToken = newToken.RefreshToken
+		}
 	} else {
 		account.AccessToken = newToken.AccessToken
 		account.ExpiresIn = newToken.ExpiresIn
 		timestamp := now * 1000
 		account.Timestamp = &timestamp
 		account.Type = "antigravity"
+		if newToken.RefreshToken != "" {
+			account.RefreshToken = newToken.RefreshToken
+		}
 	}
 
 	// Update top-level fields
@@ -205,17 +288,17 @@ func (c *CloudCodeClient) EnsureFreshToken(account *Account) (string, error) {
 	return newToken.AccessToken, nil
 }
 
-// saveAccount saves account to file
+// saveAccount saves account to file, atomically so a crash never leaves it unreadable
 func (c *CloudCodeClient) saveAccount(account *Account) error {
 	data, err := json.MarshalIndent(account, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(c.config.AccountFile, data, 0600)
+	return writeFileAtomic(c.Config().AccountFile, data, 0600)
 }
 
 // GetProjectID fetches project ID from API
-func (c *CloudCodeClient) GetProjectID(accessToken string) (string, error) {
+func (c *CloudCodeClient) GetProjectID(ctx context.Context, accessToken string) (string, error) {
 	payload := map[string]interface{}{
 		"metadata": map[string]string{
 			"ideType": "ANTIGRAVITY",
@@ -223,87 +306,116 @@ func (c *CloudCodeClient) GetProjectID(accessToken string) (string, error) {
 	}
 
 	jsonData, _ := json.Marshal(payload)
-	req, err := http.NewRequest("POST", c.config.ProjectAPIURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.Config().ProjectAPIURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("User-Agent", c.config.UserAgent)
+	req.Header.Set(authHeader(c.Config(), ProviderAntigravity, accessToken))
+	req.Header.Set("User-Agent", c.Config().UserAgent)
 	req.Header.Set("Content-Type", "application/json")
+	if err := setRequestHeaders(req, c.Config(), ProviderAntigravity); err != nil {
+		return "", err
+	}
 
-	resp, err := c.httpClient.Do(req)
+	client, err := signedClient(c.httpClient, c.Config(), ProviderAntigravity)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to get project ID: %d", resp.StatusCode)
+		return "", &APIError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("failed to get project ID: %d", resp.StatusCode)}
 	}
 
 	var projectResp ProjectResponse
-	if err := json.NewDecoder(resp.Body).Decode(&projectResp); err != nil {
+	if err := decodeResponse(resp, &projectResp); err != nil {
 		return "", err
 	}
 
 	return projectResp.CloudAICompanionProject, nil
 }
 
-// GetQuota fetches quota information with caching
-func (c *CloudCodeClient) GetQuota(accessToken, projectID string) (*QuotaResponse, error) {
-	cacheKey := "quota"
-
-	// Check cache
-	c.cacheMutex.RLock()
-	if cached, exists := c.cache[cacheKey]; exists {
-		if time.Since(c.cacheTime) < time.Duration(c.config.QueryDebounce)*time.Minute {
-			c.cacheMutex.RUnlock()
-			log.Println("Returning cached quota data")
-			return cached.(*QuotaResponse), nil
+// GetQuota fetches quota information with caching. The cache holds the quota of the last
+// project and token only, so a refreshed token fetches again.
+func (c *CloudCodeClient) GetQuota(ctx context.Context, accessToken, projectID string) (*QuotaResponse, error) {
+	cacheKey := responseCacheKey(ProviderAntigravity, projectID, accessToken, "quota")
+
+	// Check cache; a dry run only records the lookup
+	if plan := dryRunOf(ctx); plan != nil {
+		plan.observeCacheKey(cacheKey)
+	} else {
+		c.cacheMutex.RLock()
+		if cached, exists := c.cache[cacheKey]; exists {
+			if clockNow().Sub(c.cacheTime) < time.Duration(c.Config().QueryDebounce)*time.Minute {
+				c.cacheMutex.RUnlock()
+				log.Println("Returning cached quota data")
+				providerHealth.recordCacheHit(ProviderAntigravity)
+				return cached.(*QuotaResponse), nil
+			}
+		}
+		c.cacheMutex.RUnlock()
+		if err := authFailures.get(cacheKey); err != nil {
+			return nil, err
 		}
 	}
-	c.cacheMutex.RUnlock()
 
 	// Fetch fresh data
 	log.Println("Fetching fresh quota data from googleapis.com")
+	start := time.Now()
 	payload := make(map[string]interface{})
 	if projectID != "" {
 		payload["project"] = projectID
 	}
 
 	jsonData, _ := json.Marshal(payload)
-	req, err := http.NewRequest("POST", c.config.APIURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.Config().APIURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("User-Agent", c.config.UserAgent)
+	req.Header.Set(authHeader(c.Config(), ProviderAntigravity, accessToken))
+	req.Header.Set("User-Agent", c.Config().UserAgent)
 	req.Header.Set("Content-Type", "application/json")
+	if err := setRequestHeaders(req, c.Config(), ProviderAntigravity); err != nil {
+		return nil, err
+	}
 
-	resp, err := c.httpClient.Do(req)
+	client, err := signedClient(c.httpClient, c.Config(), ProviderAntigravity)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed: %d - %s", resp.StatusCode, string(body))
+		body := errorBody(resp.Body)
+		err := &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+		authFailures.observe(cacheKey, err)
+		return nil, err
 	}
 
 	var quotaResp QuotaResponse
-	if err := json.NewDecoder(resp.Body).Decode(&quotaResp); err != nil {
+	if err := decodeResponse(resp, &quotaResp); err != nil {
 		return nil, err
 	}
 
+	providerHealth.recordFetch(ProviderAntigravity, time.Since(start))
+	quotaResp.FetchedAt = clockNow()
+
 	// Update cache
 	c.cacheMutex.Lock()
-	c.cache[cacheKey] = &quotaResp
-	c.cacheTime = time.Now()
+	c.cache = map[string]interface{}{cacheKey: &quotaResp}
+	c.cacheTime = quotaResp.FetchedAt
 	c.cacheMutex.Unlock()
 
-	log.Printf("Cached quota data for %d minute(s)", c.config.QueryDebounce)
+	log.Printf("Cached quota data for %d minute(s)", c.Config().QueryDebounce)
 	return &quotaResp, nil
 }
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time so cache expiry, query windows, and timestamps can be tested
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock reads the system clock
+type realClock struct{}
+
+// Now returns the current system time
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+var (
+	clockMu sync.RWMutex
+	clock   Clock = realClock{}
+)
+
+// clockNow returns the current time from the active clock
+func clockNow() time.Time {
+	clockMu.RLock()
+	defer clockMu.RUnlock()
+	return clock.Now()
+}
+
+// setClock replaces the active clock and returns a function that restores the previous one
+func setClock(c Clock) func() {
+	clockMu.Lock()
+	previous := clock
+	clock = c
+	clockMu.Unlock()
+
+	return func() {
+		clockMu.Lock()
+		clock = previous
+		clockMu.Unlock()
+	}
+}
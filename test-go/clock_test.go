@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock whose time only moves when advanced
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.t = c.t.Add(d)
+	c.mu.Unlock()
+}
+
+func TestBuildTimeQueryParamsFakeClock(t *testing.T) {
+	restore := setClock(&fakeClock{t: time.Date(2026, 3, 1, 14, 25, 0, 0, time.UTC)})
+	defer restore()
+
+	got := BuildTimeQueryParams()
+	want := "?startTime=2026-02-28+14%3A00%3A00&endTime=2026-03-01+14%3A59%3A59"
+	if got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestZAICacheExpiryFakeClock(t *testing.T) {
+	t.Setenv("QUERY_DEBOUNCE", "5")
+	clk := &fakeClock{t: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	defer setClock(clk)()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, `{"code":200,"msg":"ok","data":{"limits":[]},"success":true}`)
+	}))
+	defer server.Close()
+
+	query := func() time.Time {
+		_, fetchedAt, err := QueryZAIEndpoint(context.Background(), ProviderGLM, server.URL, "token", "")
+		if err != nil {
+			t.Fatalf("QueryZAIEndpoint failed: %v", err)
+		}
+		return fetchedAt
+	}
+
+	first := query()
+	if !first.Equal(clk.Now()) {
+		t.Errorf("Expected fetch time %v, got %v", clk.Now(), first)
+	}
+
+	// Within the debounce window the cached entry is served with its original fetch time
+	clk.Advance(4 * time.Minute)
+	if cached := query(); !cached.Equal(first) || requests != 1 {
+		t.Errorf("Expected cached data from %v with 1 request, got %v with %d requests", first, cached, requests)
+	}
+
+	// Once the window passes the endpoint is queried again
+	clk.Advance(2 * time.Minute)
+	if refreshed := query(); !refreshed.Equal(clk.Now()) || requests != 2 {
+		t.Errorf("Expected fresh data at %v with 2 requests, got %v with %d requests", clk.Now(), refreshed, requests)
+	}
+}
+
+func TestStampQuotaTimeFakeClock(t *testing.T) {
+	clk := &fakeClock{t: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	defer setClock(clk)()
+
+	fetchedAt := clk.Now()
+	clk.Advance(150 * time.Second)
+
+	var quota FormattedQuota
+	stampQuotaTime(&quota, fetchedAt)
+	if quota.LastUpdated != fetchedAt.Unix() || quota.Age != 150 {
+		t.Errorf("Expected LastUpdated %d and Age 150, got %d and %d", fetchedAt.Unix(), quota.LastUpdated, quota.Age)
+	}
+}
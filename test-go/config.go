@@ -4,6 +4,9 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+
+	"golang.org/x/text/language"
 )
 
 const (
@@ -18,49 +21,502 @@ const (
 	QuotaGood     = 50
 	QuotaWarning  = 20
 	QuotaCritical = 1
+
+	// Default Z.ai and ZHIPU API base URLs
+	DefaultZAIBaseURL   = "https://api.z.ai/api/anthropic"
+	DefaultZhipuBaseURL = "https://open.bigmodel.cn/api/anthropic"
+
+	// MCP tools excluded from GLM output unless ZAI_EXCLUDED_TOOLS is set
+	DefaultExcludedMCPTools = "zread"
+
+	// Base domains tried in order when one answers 404 or 5xx, unless ZAI_DOMAINS or
+	// ZHIPU_DOMAINS is set
+	DefaultZAIDomains   = "https://api.z.ai"
+	DefaultZhipuDomains = "https://open.bigmodel.cn,https://dev.bigmodel.cn,https://bigmodel.cn"
+
+	// Z.ai monitor endpoints (quota limit, model usage), relative to the base domain
+	DefaultZAIMonitorPrefix = "/api/monitor/usage"
+
+	// Zhipu open-platform account balance endpoint, relative to the base domain
+	DefaultZhipuBalancePath = "/api/biz/account/query-customer-account-report"
+
+	// Separator of the models in the short format
+	DefaultShortSeparator = " · "
+
+	// Z.ai/ZHIPU coding plan subscription endpoint path
+	DefaultZAISubscriptionPath = "/api/biz/subscription/list"
+
+	// Endpoint queries one provider fetch runs at once
+	DefaultFetchConcurrency = 4
+
+	// Claude subscription (claude.ai Pro/Max) usage endpoint
+	DefaultClaudeUsageURL = "https://api.anthropic.com/api/oauth/usage"
+
+	// Cursor and Windsurf plan usage endpoints
+	DefaultCursorUsageURL    = "https://cursor.com/api/usage"
+	DefaultWindsurfStatusURL = "https://server.codeium.com/exa.seat_management_pb.SeatManagementService/GetUserStatus"
+
+	// xAI API and management API base URLs, and models whose rate limits are reported
+	DefaultXAIBaseURL       = "https://api.x.ai"
+	DefaultXAIManagementURL = "https://management-api.x.ai"
+	DefaultXAIModels        = "grok-4"
+
+	// Groq and Mistral API base URLs
+	DefaultGroqBaseURL    = "https://api.groq.com/openai"
+	DefaultMistralBaseURL = "https://api.mistral.ai"
+
+	// Default Ollama API address for the local provider
+	DefaultOllamaHost = "http://localhost:11434"
+
+	// Percentage below which the low-quota hook runs
+	DefaultHookThreshold = 10
+
+	// Standard deviations above the usual burn rate for the hour that count as an anomaly
+	DefaultAnomalySigma = 3
+
+	// Maximum decimal places for percentages
+	MaxPercentagePrecision = 2
+
+	// Provider names accepted by fetchQuota
+	ProviderAntigravity  = "antigravity"
+	ProviderGLM          = "glm"
+	ProviderZhipuBalance = "zhipu-balance"
+	ProviderClaudeAI     = "claude-ai"
+	ProviderCursor       = "cursor"
+	ProviderWindsurf     = "windsurf"
+	ProviderXAI          = "xai"
+	ProviderGroq         = "groq"
+	ProviderMistral      = "mistral"
+	ProviderLocal        = "local"
+
+	// Alias value that hides a model from output
+	HiddenModelAlias = "-"
 )
 
 // Config holds all configuration values
 type Config struct {
-	// Google Cloud Code API URLs
+	// Google Cloud Code API and OAuth URLs
 	APIURL        string
 	ProjectAPIURL string
 	TokenURL      string
+	AuthURL       string
 
 	// User agent
 	UserAgent string
 
 	// Google OAuth credentials
 	ClientID     string
-	ClientSecret string
+	ClientSecret TokenSource
 
-	// Account file path
-	AccountFile string
+	// Account file path, and an access token that is used instead of it when set
+	AccountFile      string
+	AntigravityToken TokenSource
 
 	// Server port
 	Port int
 
 	// Query debounce time in minutes
 	QueryDebounce int
+
+	// Minutes a provider that rejected the credentials is not asked again with them, 0 to
+	// always ask
+	AuthFailureTTL int
+
+	// Model name aliases applied to formatted output (name -> alias, "-" hides the model)
+	ModelAliases map[string]string
+
+	// Glob filters selecting which models are returned
+	ModelOnly    []string
+	ModelExclude []string
+
+	// MCP tools omitted from GLM per-tool usage entries
+	ExcludedMCPTools []string
+
+	// Z.ai/ZHIPU auth token and base URL, and the variable the token was read from
+	ZAIAuthToken   TokenSource
+	ZAIBaseURL     string
+	ZAITokenSource string
+
+	// Platform (ZAI or ZHIPU) of a custom ANTHROPIC_BASE_URL such as a gateway, and the
+	// monitor endpoint path prefix on its base domain
+	ZAIPlatform      string
+	ZAIMonitorPrefix string
+
+	// Base domains of each platform, tried in order when an endpoint answers 404 or 5xx
+	ZAIDomains   []string
+	ZhipuDomains []string
+
+	// Additional Z.ai/ZHIPU accounts reported as glm:<name>, and whether glm:all combines
+	// the remaining token quota of every account
+	ZAIAccounts    []ZAIAccount
+	EffectiveQuota bool
+
+	// Capacities weighting GLM accounts in glm:all and providers in the combined view
+	AccountWeights  map[string]float64
+	ProviderWeights map[string]float64
+
+	// Optional GLM endpoints ("usage", "subscription") queried alongside the quota limits,
+	// the subscription endpoint path, and the most endpoint queries a fetch runs at once
+	GLMEndpoints        []string
+	ZAISubscriptionPath string
+	FetchConcurrency    int
+
+	// Further monitor paths reporting limits in the quota limit format, such as separate
+	// vision or video allowances, merged into the GLM quota
+	ZAILimitPaths []string
+
+	// Default output format and template of the show command
+	OutputFormat   string
+	OutputTemplate string
+
+	// Default model order ("name", "percentage", "provider", "-" prefix to reverse) and
+	// grouping ("provider", "account") of quota output
+	OutputSort  string
+	OutputGroup string
+
+	// Separator of the short format, and the most models it shows, those with the least
+	// quota left (0 for all)
+	ShortSeparator string
+	ShortLimit     int
+
+	// How the badge format marks severity: "emoji" or ANSI "color"
+	BadgeStyle string
+
+	// Icons shown before models in status formats, by model glob or provider, and whether
+	// output is limited to ASCII, replacing emoji and Nerd Font glyphs
+	Icons       []IconRule
+	ASCIIOutput bool
+
+	// Zhipu open-platform balance endpoint path
+	ZhipuBalancePath string
+
+	// Claude subscription usage endpoint and session credential (token or credentials file)
+	ClaudeUsageURL        string
+	ClaudeOAuthToken      TokenSource
+	ClaudeCredentialsFile string
+
+	// Cursor session token (WorkosCursorSessionToken cookie) and usage endpoint
+	CursorSessionToken TokenSource
+	CursorUsageURL     string
+
+	// Windsurf API key and user status endpoint
+	WindsurfAPIKey    TokenSource
+	WindsurfStatusURL string
+
+	// xAI API key, optional management key for prepaid credits, endpoints, and models
+	XAIAPIKey        TokenSource
+	XAIManagementKey TokenSource
+	XAIBaseURL       string
+	XAIManagementURL string
+	XAIModels        []string
+
+	// Groq and Mistral API keys and base URLs
+	GroqAPIKey     TokenSource
+	GroqBaseURL    string
+	MistralAPIKey  TokenSource
+	MistralBaseURL string
+
+	// Ollama API address and the local models to report (all installed when empty)
+	OllamaHost  string
+	LocalModels []string
+
+	// Shell commands run in serve mode when a model drops below HookThreshold, recovers
+	// above it, or reaches 0, limited to models matching HookModels globs
+	HookThreshold int
+	HookOnBelow   string
+	HookOnRecover string
+	HookOnEmpty   string
+	HookModels    []string
+
+	// Points a model must climb past the threshold, or past 0, before it recovers, and the
+	// minutes between reminders while it stays below (0 sends none)
+	HookHysteresis int
+	HookRepeat     int
+
+	// Shell command run when a model's burn rate is AnomalySigma standard deviations above
+	// its usual rate for the hour (0 disables detection)
+	HookOnAnomaly string
+	AnomalySigma  int
+
+	// SMTP server (host:port) and login that email is sent through, the sender and
+	// recipients, the digest schedule (daily, weekly, or off) and its local HH:MM time, and
+	// the hook events mailed as they happen
+	SMTPAddress   string
+	SMTPUsername  string
+	SMTPPassword  TokenSource
+	EmailFrom     string
+	EmailTo       []string
+	EmailDigest   string
+	EmailDigestAt string
+	EmailAlerts   []string
+
+	// Daily local time ranges in which notifiers hold their alerts, and the file the silence
+	// command writes
+	QuietHours  []quietRange
+	SilenceFile string
+
+	// ntfy server, topic (a name or full URL), and access token, Pushover application token
+	// and user key, and the hook events pushed to phones
+	NtfyServer    string
+	NtfyTopic     string
+	NtfyToken     TokenSource
+	PushoverToken TokenSource
+	PushoverUser  TokenSource
+	NotifyEvents  []string
+
+	// PagerDuty Events v2 routing key and Opsgenie API key incidents are opened with, their
+	// API addresses, the minutes a provider may fail before it counts as unreachable, and
+	// the model globs whose empty quota opens an incident (all when empty)
+	PagerDutyKey   TokenSource
+	PagerDutyURL   string
+	OpsgenieKey    TokenSource
+	OpsgenieURL    string
+	IncidentGrace  int
+	IncidentModels []string
+
+	// Cron entries of serve mode tasks, such as "0 3 * * * vacuum; */30 * * * * refresh"
+	Schedule string
+
+	// Background refresh interval in minutes once no client has requested data for
+	// IdleAfter minutes (0 keeps the QUERY_DEBOUNCE interval)
+	IdlePollInterval int
+	IdleAfter        int
+
+	// Bearer token required by every HTTP and gRPC endpoint of the server when set
+	ServerToken TokenSource
+
+	// Bearer token required by POST /events/usage when set
+	EventsToken TokenSource
+
+	// Bearer token required by the dashboard page and its stream when set, and the providers
+	// it shows
+	DashboardToken     TokenSource
+	DashboardProviders []string
+
+	// Bearer token of the viewer role on the hub and dashboard, which sees aggregates only
+	ViewerToken TokenSource
+
+	// Locale for separators and currency symbols in amounts and counts (NUMBER_LOCALE, else LANG)
+	NumberLocale language.Tag
+
+	// Language of output labels and messages (OUTPUT_LANGUAGE, else LANG)
+	Language language.Tag
+
+	// Prices per million tokens by model glob, the defaults overridden by PRICING
+	Pricing map[string]ModelPrice
+
+	// JSON lines file that keeps quota history across restarts (in memory only when empty),
+	// the days raw readings are kept, and the days their daily rollups are kept (0 forever)
+	HistoryFile       string
+	HistoryRawDays    int
+	HistoryRollupDays int
+
+	// Shared quota server whose /history endpoint keeps history instead of HISTORY_FILE, the
+	// bearer token it requires, and the member name this machine's lines are kept under; for
+	// the shared server, each member's token, which may only write and read that member's lines
+	HistoryURL    string
+	HistoryToken  TokenSource
+	HistoryMember string
+	HistoryTokens TokenSource
+
+	// Hub that fetched quota is pushed to, the client's token there, and how many snapshots
+	// are buffered while it is unreachable; for the hub itself, each user's token and team
+	HubURL    string
+	HubToken  TokenSource
+	HubBuffer int
+	HubTokens TokenSource
+	HubTeams  map[string]string
+
+	// Decimal places kept in percentages (0-2)
+	PercentagePrecision int
+
+	// Most entries kept in each provider response cache
+	CacheMaxEntries int
+
+	// Network provider connections are dialed over ("tcp4", "tcp6", or "" for either), and
+	// fixed addresses by host name that bypass DNS
+	IPFamily      string
+	HostOverrides map[string]string
+
+	// Sources of the extra request headers by provider, "" for every provider, such as
+	// gateway credentials or a User-Agent
+	RequestHeaders map[string]TokenSource
+
+	// Auth header templates by provider, from AUTH_HEADER_<PROVIDER>
+	AuthSchemes map[string]string
+
+	// Request signers by provider, applied in order, from REQUEST_SIGNING_<PROVIDER>
+	RequestSigners map[string][]string
 }
 
 // LoadConfig loads configuration from environment variables
 func LoadConfig() *Config {
 	config := &Config{
-		APIURL:        "https://cloudcode-pa.googleapis.com/v1internal:fetchAvailableModels",
-		ProjectAPIURL: "https://cloudcode-pa.googleapis.com/v1internal:loadCodeAssist",
-		TokenURL:      "https://oauth2.googleapis.com/token",
-		UserAgent:     getEnvOrDefault("USER_AGENT", "antigravity/1.13.3 Darwin/arm64"),
-		ClientID:      os.Getenv("CLIENT_ID"),
-		ClientSecret:  os.Getenv("CLIENT_SECRET"),
-		AccountFile:   resolveAccountFile(getEnvOrDefault("ACCOUNT_FILE", "antigravity.json")),
-		Port:          getEnvAsInt("PORT", 8000),
-		QueryDebounce: getEnvAsInt("QUERY_DEBOUNCE", 1),
+		APIURL:                "https://cloudcode-pa.googleapis.com/v1internal:fetchAvailableModels",
+		ProjectAPIURL:         "https://cloudcode-pa.googleapis.com/v1internal:loadCodeAssist",
+		TokenURL:              "https://oauth2.googleapis.com/token",
+		AuthURL:               "https://accounts.google.com/o/oauth2/v2/auth",
+		UserAgent:             getEnvOrDefault("USER_AGENT", "antigravity/1.13.3 Darwin/arm64"),
+		ClientID:              os.Getenv("CLIENT_ID"),
+		ClientSecret:          secretToken("CLIENT_SECRET"),
+		AccountFile:           resolveAccountFile(getEnvOrDefault("ACCOUNT_FILE", "antigravity.json")),
+		AntigravityToken:      secretToken("ANTIGRAVITY_TOKEN"),
+		Port:                  getEnvAsInt("PORT", 8000),
+		QueryDebounce:         getEnvAsInt("QUERY_DEBOUNCE", 1),
+		AuthFailureTTL:        getEnvAsInt("AUTH_FAILURE_TTL", DefaultAuthFailureTTL),
+		ModelAliases:          parseModelAliases(os.Getenv("MODEL_ALIASES")),
+		ModelOnly:             parseList(os.Getenv("MODEL_ONLY")),
+		ModelExclude:          parseList(os.Getenv("MODEL_EXCLUDE")),
+		ExcludedMCPTools:      parseList(getEnvOrDefaultAllowEmpty("ZAI_EXCLUDED_TOOLS", DefaultExcludedMCPTools)),
+		ZAIPlatform:           strings.ToUpper(trimQuotes(os.Getenv("ZAI_PLATFORM"))),
+		ZAIMonitorPrefix:      strings.TrimRight(getEnvOrDefault("ZAI_MONITOR_PREFIX", DefaultZAIMonitorPrefix), "/"),
+		ZAIDomains:            parseList(getEnvOrDefault("ZAI_DOMAINS", DefaultZAIDomains)),
+		ZhipuDomains:          parseList(getEnvOrDefault("ZHIPU_DOMAINS", DefaultZhipuDomains)),
+		ZAIAccounts:           parseZAIAccounts(os.Getenv("ZAI_ACCOUNTS")),
+		EffectiveQuota:        getEnvAsBool("EFFECTIVE_QUOTA", false),
+		AccountWeights:        parseWeights(os.Getenv("ACCOUNT_WEIGHTS")),
+		ProviderWeights:       parseWeights(os.Getenv("PROVIDER_WEIGHTS")),
+		GLMEndpoints:          parseList(os.Getenv("GLM_ENDPOINTS")),
+		ZAISubscriptionPath:   getEnvOrDefault("ZAI_SUBSCRIPTION_PATH", DefaultZAISubscriptionPath),
+		FetchConcurrency:      max(getEnvAsInt("FETCH_CONCURRENCY", DefaultFetchConcurrency), 1),
+		ZAILimitPaths:         parseList(os.Getenv("ZAI_LIMIT_PATHS")),
+		OutputFormat:          getEnvOrDefault("OUTPUT_FORMAT", "text"),
+		OutputTemplate:        os.Getenv("OUTPUT_TEMPLATE"),
+		OutputSort:            strings.ToLower(trimQuotes(os.Getenv("OUTPUT_SORT"))),
+		OutputGroup:           strings.ToLower(trimQuotes(os.Getenv("OUTPUT_GROUP"))),
+		ShortSeparator:        trimQuotes(getEnvOrDefaultAllowEmpty("SHORT_SEPARATOR", DefaultShortSeparator)),
+		ShortLimit:            max(getEnvAsInt("SHORT_LIMIT", 0), 0),
+		BadgeStyle:            strings.ToLower(getEnvOrDefault("BADGE_STYLE", "emoji")),
+		Icons:                 parseIcons(os.Getenv("ICONS")),
+		ASCIIOutput:           getEnvAsBool("ASCII_OUTPUT", false),
+		ZhipuBalancePath:      getEnvOrDefault("ZHIPU_BALANCE_PATH", DefaultZhipuBalancePath),
+		ClaudeUsageURL:        getEnvOrDefault("CLAUDE_USAGE_URL", DefaultClaudeUsageURL),
+		ClaudeOAuthToken:      secretToken("CLAUDE_OAUTH_TOKEN"),
+		ClaudeCredentialsFile: trimQuotes(os.Getenv("CLAUDE_CREDENTIALS_FILE")),
+		CursorSessionToken:    secretToken("CURSOR_SESSION_TOKEN"),
+		CursorUsageURL:        getEnvOrDefault("CURSOR_USAGE_URL", DefaultCursorUsageURL),
+		WindsurfAPIKey:        secretToken("WINDSURF_API_KEY"),
+		WindsurfStatusURL:     getEnvOrDefault("WINDSURF_STATUS_URL", DefaultWindsurfStatusURL),
+		XAIAPIKey:             secretToken("XAI_API_KEY"),
+		XAIManagementKey:      secretToken("XAI_MANAGEMENT_KEY"),
+		XAIBaseURL:            getEnvOrDefault("XAI_BASE_URL", DefaultXAIBaseURL),
+		XAIManagementURL:      getEnvOrDefault("XAI_MANAGEMENT_URL", DefaultXAIManagementURL),
+		XAIModels:             parseList(getEnvOrDefault("XAI_MODELS", DefaultXAIModels)),
+		GroqAPIKey:            secretToken("GROQ_API_KEY"),
+		GroqBaseURL:           getEnvOrDefault("GROQ_BASE_URL", DefaultGroqBaseURL),
+		MistralAPIKey:         secretToken("MISTRAL_API_KEY"),
+		MistralBaseURL:        getEnvOrDefault("MISTRAL_BASE_URL", DefaultMistralBaseURL),
+		OllamaHost:            getEnvOrDefault("OLLAMA_HOST", DefaultOllamaHost),
+		LocalModels:           parseList(os.Getenv("LOCAL_MODELS")),
+		HookThreshold:         clampInt(getEnvAsInt("HOOK_THRESHOLD", DefaultHookThreshold), 0, QuotaFull),
+		HookOnBelow:           trimQuotes(os.Getenv("HOOK_ON_BELOW")),
+		HookOnRecover:         trimQuotes(os.Getenv("HOOK_ON_RECOVER")),
+		HookOnEmpty:           trimQuotes(os.Getenv("HOOK_ON_EMPTY")),
+		HookModels:            parseList(os.Getenv("HOOK_MODELS")),
+		HookHysteresis:        clampInt(getEnvAsInt("HOOK_HYSTERESIS", DefaultHookHysteresis), 0, QuotaFull),
+		HookRepeat:            max(getEnvAsInt("HOOK_REPEAT", 0), 0),
+		HookOnAnomaly:         trimQuotes(os.Getenv("HOOK_ON_ANOMALY")),
+		AnomalySigma:          clampInt(getEnvAsInt("ANOMALY_SIGMA", DefaultAnomalySigma), 0, 10),
+		SMTPAddress:           trimQuotes(os.Getenv("SMTP_ADDRESS")),
+		SMTPUsername:          trimQuotes(os.Getenv("SMTP_USERNAME")),
+		SMTPPassword:          secretToken("SMTP_PASSWORD"),
+		EmailFrom:             trimQuotes(os.Getenv("EMAIL_FROM")),
+		EmailTo:               parseList(os.Getenv("EMAIL_TO")),
+		EmailDigest:           strings.ToLower(trimQuotes(os.Getenv("EMAIL_DIGEST"))),
+		EmailDigestAt:         getEnvOrDefault("EMAIL_DIGEST_AT", DefaultDigestAt),
+		EmailAlerts:           parseList(os.Getenv("EMAIL_ALERTS")),
+		QuietHours:            parseQuietHours(os.Getenv("QUIET_HOURS")),
+		SilenceFile:           getEnvOrDefault("SILENCE_FILE", DefaultSilenceFile),
+		NtfyServer:            getEnvOrDefault("NTFY_SERVER", DefaultNtfyServer),
+		NtfyTopic:             trimQuotes(os.Getenv("NTFY_TOPIC")),
+		NtfyToken:             secretToken("NTFY_TOKEN"),
+		PushoverToken:         secretToken("PUSHOVER_TOKEN"),
+		PushoverUser:          secretToken("PUSHOVER_USER"),
+		NotifyEvents:          parseList(getEnvOrDefault("NOTIFY_EVENTS", DefaultNotifyEvents)),
+		PagerDutyKey:          secretToken("PAGERDUTY_ROUTING_KEY"),
+		PagerDutyURL:          getEnvOrDefault("PAGERDUTY_EVENTS_URL", DefaultPagerDutyURL),
+		OpsgenieKey:           secretToken("OPSGENIE_API_KEY"),
+		OpsgenieURL:           strings.TrimSuffix(getEnvOrDefault("OPSGENIE_API_URL", DefaultOpsgenieURL), "/"),
+		IncidentGrace:         max(getEnvAsInt("INCIDENT_GRACE", DefaultIncidentGrace), 0),
+		IncidentModels:        parseList(os.Getenv("INCIDENT_MODELS")),
+		Schedule:              trimQuotes(os.Getenv("SCHEDULE")),
+		IdlePollInterval:      max(getEnvAsInt("IDLE_POLL_INTERVAL", 0), 0),
+		IdleAfter:             max(getEnvAsInt("IDLE_AFTER", DefaultIdleAfter), 0),
+		ServerToken:           secretToken("SERVER_TOKEN"),
+		EventsToken:           secretToken("EVENTS_TOKEN"),
+		DashboardToken:        secretToken("DASHBOARD_TOKEN"),
+		DashboardProviders:    parseList(getEnvOrDefault("DASHBOARD_PROVIDERS", ProviderAntigravity)),
+		ViewerToken:           secretToken("VIEWER_TOKEN"),
+		HistoryFile:           trimQuotes(os.Getenv("HISTORY_FILE")),
+		HistoryRawDays:        max(getEnvAsInt("HISTORY_RETENTION", DefaultHistoryRetention), 1),
+		HistoryRollupDays:     max(getEnvAsInt("HISTORY_ROLLUP_RETENTION", 0), 0),
+		HistoryURL:            trimQuotes(os.Getenv("HISTORY_URL")),
+		HistoryToken:          secretToken("HISTORY_TOKEN"),
+		HistoryMember:         trimQuotes(os.Getenv("HISTORY_MEMBER")),
+		HistoryTokens:         secretToken("HISTORY_TOKENS"),
+		HubURL:                strings.TrimRight(trimQuotes(os.Getenv("HUB_URL")), "/"),
+		HubToken:              secretToken("HUB_TOKEN"),
+		HubBuffer:             max(getEnvAsInt("HUB_BUFFER", DefaultHubBuffer), 1),
+		HubTokens:             secretToken("HUB_TOKENS"),
+		HubTeams:              parseModelAliases(os.Getenv("HUB_TEAMS")),
+		Pricing:               mergePricing(parsePricing(os.Getenv("PRICING"))),
+		NumberLocale:          resolveNumberLocale(os.Getenv("NUMBER_LOCALE")),
+		Language:              resolveLanguage(os.Getenv("OUTPUT_LANGUAGE")),
+		PercentagePrecision:   clampInt(getEnvAsInt("PERCENTAGE_PRECISION", 0), 0, MaxPercentagePrecision),
+		CacheMaxEntries:       max(getEnvAsInt("CACHE_MAX_ENTRIES", DefaultCacheMaxEntries), 1),
+		IPFamily:              parseIPFamily(os.Getenv("IP_FAMILY")),
+		HostOverrides:         parseHostOverrides(os.Getenv("HOST_OVERRIDES")),
+		RequestHeaders:        loadRequestHeaders(),
+		AuthSchemes:           loadAuthSchemes(),
+		RequestSigners:        loadRequestSigners(),
 	}
 
+	config.ZAIAuthToken, config.ZAIBaseURL, config.ZAITokenSource = resolveZAICredentials()
+
 	return config
 }
 
+// resolveZAICredentials returns the source of the Z.ai/ZHIPU auth token, the base URL, and
+// the variable the token comes from, taking the first that is set of:
+//
+//  1. ZAI_AUTH_TOKEN (base URL default https://api.z.ai/api/anthropic)
+//  2. ZHIPU_AUTH_TOKEN (base URL default https://open.bigmodel.cn/api/anthropic)
+//  3. ZAI_ANTHROPIC_AUTH_TOKEN (base URL default https://api.z.ai/api/anthropic)
+//  4. ANTHROPIC_AUTH_TOKEN, only together with ZAI_ANTHROPIC_BASE_URL or ANTHROPIC_BASE_URL
+//
+// Each token can also come from a _FILE, _CMD, or _KEYCHAIN variable (see TokenSource), read
+// when a request first needs it.
+// ZAI_ANTHROPIC_BASE_URL overrides the base URL in every case. The generic ANTHROPIC_* pair
+// is never written and comes last, so a shell that also talks to Anthropic keeps its own
+// values, and a bare Anthropic key is never sent to Z.ai.
+func resolveZAICredentials() (TokenSource, string, string) {
+	baseURL := trimQuotes(os.Getenv("ZAI_ANTHROPIC_BASE_URL"))
+	sources := []struct {
+		name           string
+		defaultBaseURL string
+	}{
+		{"ZAI_AUTH_TOKEN", DefaultZAIBaseURL},
+		{"ZHIPU_AUTH_TOKEN", DefaultZhipuBaseURL},
+		{"ZAI_ANTHROPIC_AUTH_TOKEN", DefaultZAIBaseURL},
+	}
+	for _, source := range sources {
+		if token := secretToken(source.name); token != nil {
+			if baseURL == "" {
+				baseURL = source.defaultBaseURL
+			}
+			return token, baseURL, source.name
+		}
+	}
+
+	if baseURL == "" {
+		baseURL = trimQuotes(os.Getenv("ANTHROPIC_BASE_URL"))
+	}
+	if token := secretToken("ANTHROPIC_AUTH_TOKEN"); token != nil && baseURL != "" {
+		return token, baseURL, "ANTHROPIC_AUTH_TOKEN"
+	}
+	return nil, baseURL, ""
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -68,6 +524,14 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvOrDefaultAllowEmpty returns the default only when the variable is unset
+func getEnvOrDefaultAllowEmpty(key, defaultValue string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return defaultValue
+}
+
 func getEnvAsInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
 		if intValue, err := strconv.Atoi(value); err == nil {
@@ -77,15 +541,61 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+// clampInt limits value to the [min, max] range
+func clampInt(value, min, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
+
+// parseList parses a comma-separated list, dropping empty entries
+func parseList(value string) []string {
+	var items []string
+	for item := range strings.SplitSeq(trimQuotes(value), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseModelAliases parses "name=alias,name2=alias2" pairs into a lookup table
+func parseModelAliases(value string) map[string]string {
+	aliases := make(map[string]string)
+	for pair := range strings.SplitSeq(trimQuotes(value), ",") {
+		name, alias, found := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		alias = strings.TrimSpace(alias)
+		if !found || name == "" || alias == "" {
+			continue
+		}
+		aliases[name] = alias
+	}
+	return aliases
+}
+
 func resolveAccountFile(accountFile string) string {
 	// Remove quotes if present
 	accountFile = trimQuotes(accountFile)
-	
+
 	// If absolute path, return as is
 	if filepath.IsAbs(accountFile) {
 		return accountFile
 	}
-	
+
 	// Resolve relative to parent directory (project root)
 	return filepath.Join("..", accountFile)
 }
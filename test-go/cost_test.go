@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+func TestParsePricing(t *testing.T) {
+	pricing := parsePricing(`"glm=8 cny, grok-4*=6,bad=x,empty=,=1"`)
+	if len(pricing) != 2 {
+		t.Fatalf("Expected 2 prices, got %v", pricing)
+	}
+	if got := pricing["glm"]; got.PerMillion != 8 || got.Currency != "CNY" {
+		t.Errorf("Unexpected glm price: %+v", got)
+	}
+	if got := pricing["grok-4*"]; got.PerMillion != 6 || got.Currency != "USD" {
+		t.Errorf("Expected USD default, got %+v", got)
+	}
+}
+
+func TestPriceFor(t *testing.T) {
+	pricing := mergePricing(map[string]ModelPrice{
+		"glm-4.6*": {PerMillion: 2, Currency: "USD"},
+		"glm":      {PerMillion: 8, Currency: "CNY"},
+	})
+
+	if price, _ := priceFor(pricing, "glm"); price.PerMillion != 8 {
+		t.Errorf("Expected the exact key to win, got %+v", price)
+	}
+	if price, _ := priceFor(pricing, "glm-4.6-air"); price.PerMillion != 2 {
+		t.Errorf("Expected the longest glob to win, got %+v", price)
+	}
+	if price, _ := priceFor(pricing, "glm-4.5"); price.PerMillion != defaultPricing["glm*"].PerMillion {
+		t.Errorf("Expected the default glm price, got %+v", price)
+	}
+	if _, ok := priceFor(pricing, "unknown"); ok {
+		t.Error("Expected no price for an unknown model")
+	}
+}
+
+func TestEstimateCosts(t *testing.T) {
+	original := tokenUsageProviders
+	defer func() { tokenUsageProviders = original }()
+
+	until := time.Unix(86400, 0)
+	tokenUsageProviders = map[string]tokenUsageSource{
+		"glm": {kind: CostKindValue, fetch: func(ctx context.Context) ([]TokenUsage, error) {
+			return []TokenUsage{{Model: "glm", Tokens: 2500000, Since: until.Add(-24 * time.Hour), Until: until}}, nil
+		}},
+		"metered": {kind: CostKindSpend, fetch: func(ctx context.Context) ([]TokenUsage, error) {
+			return []TokenUsage{{Model: "mystery", Tokens: 10, Since: until.Add(-time.Hour), Until: until}}, nil
+		}},
+		"broken": {kind: CostKindSpend, fetch: func(ctx context.Context) ([]TokenUsage, error) {
+			return nil, errors.New("no token")
+		}},
+	}
+
+	config := &Config{Pricing: mergePricing(nil), NumberLocale: language.English, Language: language.English}
+	estimates, errs := estimateCosts(context.Background(), config, nil)
+	if len(estimates) != 2 || errs["broken"] != "no token" {
+		t.Fatalf("Unexpected result: %+v %v", estimates, errs)
+	}
+
+	if got := formatCostEstimate(config, estimates[0]); got != "glm/glm: 2,500,000 tokens in 24h, ~$2.50 value consumed" {
+		t.Errorf("Unexpected glm line: %s", got)
+	}
+	if got := formatCostEstimate(config, estimates[1]); got != "metered/mystery: 10 tokens in 1h, no price configured" {
+		t.Errorf("Unexpected unpriced line: %s", got)
+	}
+
+	config.Language = language.SimplifiedChinese
+	if got := formatCostEstimate(config, estimates[0]); got != "glm/glm：24 小时内使用 2,500,000 tokens，约消耗价值 $2.50" {
+		t.Errorf("Unexpected Chinese line: %s", got)
+	}
+
+	if _, errs := estimateCosts(context.Background(), config, []string{"cursor"}); errs["cursor"] == "" {
+		t.Error("Expected an error for a provider without token usage")
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCursorUserID(t *testing.T) {
+	tests := []struct {
+		token    string
+		expected string
+		wantErr  bool
+	}{
+		{"user_01ABC::eyJhbGci", "user_01ABC", false},
+		{"user_01ABC%3A%3AeyJhbGci", "user_01ABC", false},
+		{"eyJhbGci", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := cursorUserID(tt.token)
+		if (err != nil) != tt.wantErr || got != tt.expected {
+			t.Errorf("cursorUserID(%q) = %q, %v; expected %q, error %v", tt.token, got, err, tt.expected, tt.wantErr)
+		}
+	}
+}
+
+func TestFormatCursorUsage(t *testing.T) {
+	usage := parseCursorUsage(map[string]interface{}{
+		"gpt-4":        map[string]interface{}{"numRequests": float64(125), "maxRequestUsage": float64(500)},
+		"gpt-4-32k":    map[string]interface{}{"numRequests": float64(3), "maxRequestUsage": nil},
+		"startOfMonth": "2026-03-05T10:00:00.000Z",
+	})
+
+	quota := FormatCursorUsage(usage)
+	if len(quota.Models) != 2 {
+		t.Fatalf("Expected 2 models, got %d", len(quota.Models))
+	}
+	if quota.Models[0].Name != "cursor-gpt-4" || quota.Models[0].Percentage != 75 {
+		t.Errorf("Unexpected limited model: %+v", quota.Models[0])
+	}
+	if quota.Models[1].Percentage != 100 {
+		t.Errorf("Expected unlimited model at 100%%, got %v", quota.Models[1].Percentage)
+	}
+	if quota.Models[0].ResetTime != "2026-04-05T10:00:00Z" {
+		t.Errorf("Expected reset at end of billing month, got %s", quota.Models[0].ResetTime)
+	}
+}
+
+func TestGetCursorUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("user") != "user_01ABC" {
+			t.Errorf("Unexpected user query %q", r.URL.RawQuery)
+		}
+		if cookie, err := r.Cookie("WorkosCursorSessionToken"); err != nil || cookie.Value != "user_01ABC::jwt" {
+			t.Errorf("Unexpected session cookie: %v", cookie)
+		}
+		fmt.Fprint(w, `{"gpt-4":{"numRequests":500,"maxRequestUsage":500},"startOfMonth":"2026-03-05T10:00:00.000Z"}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("CURSOR_SESSION_TOKEN", "user_01ABC::jwt")
+	t.Setenv("CURSOR_USAGE_URL", server.URL)
+
+	quota, err := GetCursorUsage(context.Background())
+	if err != nil {
+		t.Fatalf("GetCursorUsage failed: %v", err)
+	}
+	if len(quota.Models) != 1 || quota.Models[0].Percentage != 0 {
+		t.Errorf("Expected exhausted gpt-4 quota, got %+v", quota.Models)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDaemonAddress(t *testing.T) {
+	t.Setenv("DAEMON_ADDRESS", "")
+	t.Setenv("LISTEN_ADDRESS", "")
+	t.Setenv("PORT", "9100")
+	if got := daemonAddress(); got != "localhost:9100" {
+		t.Errorf("Expected localhost on PORT, got %q", got)
+	}
+	t.Setenv("LISTEN_ADDRESS", "0.0.0.0:8000")
+	if got := daemonAddress(); got != "localhost:8000" {
+		t.Errorf("Expected a wildcard listen address to be reached on localhost, got %q", got)
+	}
+	t.Setenv("LISTEN_ADDRESS", "unix:/run/quota.sock")
+	if got := daemonAddress(); got != "unix:/run/quota.sock" {
+		t.Errorf("Expected the listen socket, got %q", got)
+	}
+	t.Setenv("DAEMON_ADDRESS", "10.0.0.2:8000")
+	if got := daemonAddress(); got != "10.0.0.2:8000" {
+		t.Errorf("Expected DAEMON_ADDRESS to win, got %q", got)
+	}
+}
+
+func TestShowViaDaemon(t *testing.T) {
+	var hits atomic.Int32
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		fmt.Fprint(w, `{"code":200,"data":{"limits":[{"type":"TOKENS_LIMIT","percentage":40}]}}`)
+	}))
+	defer provider.Close()
+	t.Setenv("ZAI_AUTH_TOKEN", "daemon-token")
+	t.Setenv("ZAI_ANTHROPIC_BASE_URL", provider.URL+"/api/anthropic")
+	t.Setenv("ZAI_PLATFORM", "ZAI")
+	t.Setenv("SERVER_TOKEN", "local-secret")
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	setupRoutes(r)
+	socket := filepath.Join(t.TempDir(), "quota.sock")
+	listener, err := listenServer("unix:" + socket)
+	if err != nil {
+		t.Fatalf("Failed to listen on the socket: %v", err)
+	}
+	server := &http.Server{Handler: r}
+	go server.Serve(listener)
+	defer server.Close()
+	t.Setenv("DAEMON_ADDRESS", "unix:"+socket)
+
+	// The second run finds the daemon on its own
+	for _, args := range [][]string{{"--via-daemon"}, nil} {
+		var buf bytes.Buffer
+		if err := runShowCommand(append(args, "--provider", "glm", "--format", "short"), &buf); err != nil {
+			t.Fatalf("show %v failed: %v", args, err)
+		}
+		if !strings.Contains(buf.String(), "60%") {
+			t.Errorf("Expected the daemon's GLM quota, got %q", buf.String())
+		}
+	}
+	if hits.Load() != 1 {
+		t.Errorf("Expected both runs to share the daemon's cache, got %d provider queries", hits.Load())
+	}
+
+	var buf bytes.Buffer
+	err = runShowCommand([]string{"--via-daemon", "--provider", "nothing"}, &buf)
+	if err == nil || !strings.Contains(err.Error(), "unknown provider") {
+		t.Errorf("Expected the daemon's provider error, got %v", err)
+	}
+
+	t.Setenv("SERVER_TOKEN", "wrong")
+	if err := runShowCommand([]string{"--via-daemon", "--provider", "glm"}, &buf); err == nil || !strings.Contains(err.Error(), "SERVER_TOKEN") {
+		t.Errorf("Expected the daemon to refuse a wrong token, got %v", err)
+	}
+}
+
+func TestDaemonTokenOnlySentAfterProof(t *testing.T) {
+	var hits atomic.Int32
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		fmt.Fprint(w, `{"code":200,"data":{"limits":[{"type":"TOKENS_LIMIT","percentage":40}]}}`)
+	}))
+	defer provider.Close()
+	t.Setenv("PROMPT_CACHE_DIR", t.TempDir())
+	t.Setenv("ZAI_AUTH_TOKEN", "impostor-test-token")
+	t.Setenv("ZAI_ANTHROPIC_BASE_URL", provider.URL+"/api/anthropic")
+	t.Setenv("ZAI_PLATFORM", "ZAI")
+	t.Setenv("SERVER_TOKEN", "local-secret")
+
+	// The user's server listens on a socket
+	r := gin.New()
+	setupRoutes(r)
+	socket := filepath.Join(t.TempDir(), "quota.sock")
+	listener, err := listenServer("unix:" + socket)
+	if err != nil {
+		t.Fatalf("Failed to listen on the socket: %v", err)
+	}
+	server := &http.Server{Handler: r}
+	go server.Serve(listener)
+	defer server.Close()
+
+	// Another process on the daemon port relays the identity challenge to it
+	var leaked atomic.Bool
+	impostor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "" {
+			leaked.Store(true)
+		}
+		if req.URL.Path != daemonIdentityPath {
+			fmt.Fprint(w, `{"quota":{"models":[]}}`)
+			return
+		}
+		client, base := daemonClient("unix:" + socket)
+		resp, err := client.Get(base + req.URL.RequestURI())
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		io.Copy(w, resp.Body)
+	}))
+	defer impostor.Close()
+	t.Setenv("DAEMON_ADDRESS", strings.TrimPrefix(impostor.URL, "http://"))
+
+	var buf bytes.Buffer
+	if err := runShowCommand([]string{"--provider", "glm", "--format", "short"}, &buf); err != nil || !strings.Contains(buf.String(), "60%") {
+		t.Errorf("Expected a direct query past the impostor, got %q, %v", buf.String(), err)
+	}
+	if leaked.Load() || hits.Load() != 1 {
+		t.Errorf("Expected SERVER_TOKEN to stay with the CLI, leaked %v, %d provider queries", leaked.Load(), hits.Load())
+	}
+	if info, err := os.Stat(daemonKeyPath()); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected a daemon key only the user can read, got %v", err)
+	}
+
+	// The user's own server passes the check
+	t.Setenv("DAEMON_ADDRESS", "unix:"+socket)
+	if _, err := fetchViaDaemon(context.Background(), LoadConfig(), daemonAddress(), ProviderGLM, true); err != nil {
+		t.Errorf("Expected the user's server to be verified, got %v", err)
+	}
+}
+
+func TestShowFallsBackWithoutDaemon(t *testing.T) {
+	var hits atomic.Int32
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		fmt.Fprint(w, `{"code":200,"data":{"limits":[{"type":"TOKENS_LIMIT","percentage":25}]}}`)
+	}))
+	defer provider.Close()
+	t.Setenv("ZAI_AUTH_TOKEN", "fallback-token")
+	t.Setenv("ZAI_ANTHROPIC_BASE_URL", provider.URL+"/api/anthropic")
+	t.Setenv("ZAI_PLATFORM", "ZAI")
+	t.Setenv("DAEMON_ADDRESS", "unix:"+filepath.Join(t.TempDir(), "missing.sock"))
+
+	var buf bytes.Buffer
+	if err := runShowCommand([]string{"--provider", "glm", "--format", "short"}, &buf); err != nil || !strings.Contains(buf.String(), "75%") {
+		t.Errorf("Expected a direct query without a daemon, got %q, %v", buf.String(), err)
+	}
+	if err := runShowCommand([]string{"--via-daemon", "--provider", "glm"}, &buf); !errors.Is(err, ErrDaemonUnavailable) {
+		t.Errorf("Expected --via-daemon not to fall back, got %v", err)
+	}
+	t.Setenv("DAEMON_MODE", "sometimes")
+	if err := runShowCommand([]string{"--provider", "glm"}, &buf); err == nil || !strings.Contains(err.Error(), "DAEMON_MODE") {
+		t.Errorf("Expected an unknown DAEMON_MODE to be rejected, got %v", err)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("Expected one direct provider query, got %d", hits.Load())
+	}
+}
+
+func TestPromptCacheWrittenByShowOnly(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"code":200,"data":{"limits":[{"type":"TOKENS_LIMIT","percentage":25}]}}`)
+	}))
+	defer provider.Close()
+	dir := t.TempDir()
+	t.Setenv("PROMPT_CACHE_DIR", dir)
+	t.Setenv("ZAI_AUTH_TOKEN", "prompt-cache-token")
+	t.Setenv("ZAI_ANTHROPIC_BASE_URL", provider.URL+"/api/anthropic")
+	t.Setenv("ZAI_PLATFORM", "ZAI")
+	t.Setenv("DAEMON_ADDRESS", "unix:"+filepath.Join(t.TempDir(), "missing.sock"))
+
+	// Requests to the server, cached or not, leave the prompt cache alone
+	service := NewQuotaService(NewCloudCodeClient(LoadConfig()))
+	if _, err := service.fetchQuota(context.Background(), ProviderGLM); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "prompt-glm.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected no prompt cache from a server fetch, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := runShowCommand([]string{"--provider", "glm", "--format", "short"}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if entry, err := readPromptCache(dir, ProviderGLM); err != nil || entry.Quota == nil {
+		t.Errorf("Expected show to write the prompt cache, got %+v, %v", entry, err)
+	}
+}
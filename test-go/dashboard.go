@@ -0,0 +1,144 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// dashboardPage shows a gauge per model and account, updated from one quota stream per
+// provider. The token of the page URL, if any, is passed on to the streams, as EventSource
+// cannot send headers.
+var dashboardPage = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Quota Dashboard</title>
+<style>
+body { margin: 0; padding: 24px; background: #111; color: #eee; font-family: system-ui, sans-serif; }
+h1 { margin: 0 0 4px; font-size: 22px; font-weight: 600; }
+h2 { margin: 28px 0 12px; font-size: 16px; font-weight: 500; color: #aaa; text-transform: uppercase; letter-spacing: .08em; }
+#updated { color: #777; font-size: 13px; }
+.gauges { display: grid; grid-template-columns: repeat(auto-fill, minmax(170px, 1fr)); gap: 16px; }
+.gauge { background: #1c1c1c; border-radius: 10px; padding: 16px; text-align: center; }
+.dial { width: 110px; height: 110px; margin: 0 auto 10px; border-radius: 50%; display: grid; place-items: center; }
+.dial span { width: 84px; height: 84px; border-radius: 50%; background: #1c1c1c; display: grid; place-items: center; font-size: 22px; font-weight: 600; }
+.name { font-size: 14px; word-break: break-word; }
+.reset, .error { font-size: 12px; color: #888; margin-top: 4px; }
+.error { color: #e05d44; }
+</style>
+</head>
+<body>
+<h1>Quota Dashboard</h1>
+<div id="updated">Connecting...</div>
+<div id="providers"></div>
+<script>
+var providers = {{.Providers}};
+var token = new URLSearchParams(location.search).get("token");
+var container = document.getElementById("providers");
+
+function color(pct) {
+	if (pct >= {{.Good}}) return "#4c1";
+	if (pct >= {{.Warning}}) return "#dfb317";
+	return "#e05d44";
+}
+
+function gauge(model) {
+	var pct = Math.max(0, Math.min(100, model.percentage));
+	var el = document.createElement("div");
+	el.className = "gauge";
+	var dial = document.createElement("div");
+	dial.className = "dial";
+	dial.style.background = "conic-gradient(" + color(pct) + " " + pct + "%, #333 0)";
+	var value = document.createElement("span");
+	value.textContent = Math.round(model.percentage) + "%";
+	dial.appendChild(value);
+	var name = document.createElement("div");
+	name.className = "name";
+	name.textContent = model.account ? model.name + " (" + model.account + ")" : model.name;
+	var reset = document.createElement("div");
+	reset.className = "reset";
+	reset.textContent = model.reset_time_relative ? "resets in " + model.reset_time_relative : "";
+	el.append(dial, name, reset);
+	return el;
+}
+
+providers.forEach(function (provider) {
+	var section = document.createElement("section");
+	var title = document.createElement("h2");
+	title.textContent = provider;
+	var gauges = document.createElement("div");
+	gauges.className = "gauges";
+	section.append(title, gauges);
+	container.appendChild(section);
+
+	var url = "dashboard/stream?provider=" + encodeURIComponent(provider);
+	if (token) url += "&token=" + encodeURIComponent(token);
+	var stream = new EventSource(url);
+	stream.addEventListener("quota", function (event) {
+		var models = JSON.parse(event.data).quota.models || [];
+		gauges.replaceChildren.apply(gauges, models.map(gauge));
+		document.getElementById("updated").textContent = "Updated " + new Date().toLocaleTimeString();
+	});
+	stream.addEventListener("error", function (event) {
+		if (!event.data) return;
+		var message = document.createElement("div");
+		message.className = "error";
+		message.textContent = JSON.parse(event.data).error;
+		gauges.replaceChildren(message);
+	});
+});
+</script>
+</body>
+</html>
+`))
+
+// requireDashboardToken rejects requests without DASHBOARD_TOKEN when it is set, unless
+// they carry VIEWER_TOKEN, whose viewers see the dashboard without per-account gauges.
+// SERVER_TOKEN admits to every route, so it is an admin token here as well.
+func (s *QuotaService) requireDashboardToken(c *gin.Context) {
+	config := s.client.Config()
+	admin, ok := requestToken(c, "DASHBOARD_TOKEN", config.DashboardToken)
+	if !ok {
+		return
+	}
+	server, ok := requestToken(c, "SERVER_TOKEN", config.ServerToken)
+	if !ok {
+		return
+	}
+	viewer, ok := requestToken(c, "VIEWER_TOKEN", config.ViewerToken)
+	if !ok {
+		return
+	}
+	if admin == "" {
+		admin = server
+	}
+	role := requestRole(c, admin, viewer)
+	if role != roleAdmin && server != "" && tokenMatches(c, server) {
+		role = roleAdmin
+	}
+	if role == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing or wrong DASHBOARD_TOKEN bearer token"})
+		return
+	}
+	c.Set(roleKey, role)
+}
+
+// GetDashboard serves a read-only page of quota gauges for a shared screen. It shows the
+// providers in ?providers=, else DASHBOARD_PROVIDERS.
+func (s *QuotaService) GetDashboard(c *gin.Context) {
+	providers := parseList(c.Query("providers"))
+	if len(providers) == 0 {
+		providers = s.client.Config().DashboardProviders
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	dashboardPage.Execute(c.Writer, gin.H{
+		"Providers": providers,
+		"Good":      QuotaGood,
+		"Warning":   QuotaWarning,
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetDashboard(t *testing.T) {
+	t.Setenv("DASHBOARD_TOKEN", "office")
+	t.Setenv("DASHBOARD_PROVIDERS", "glm,cursor")
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	setupRoutes(r)
+
+	get := func(path, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, path := range []string{"/", "/?token=wrong", "/dashboard/stream?provider=glm"} {
+		if w := get(path, ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for %s without the token, got %d", path, w.Code)
+		}
+	}
+
+	w := get("/", "Bearer office")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("Expected the page with a bearer token, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if body := w.Body.String(); !strings.Contains(body, `var providers = ["glm","cursor"];`) {
+		t.Errorf("Expected DASHBOARD_PROVIDERS in the page, got:\n%s", body)
+	}
+
+	w = get("/?token=office&providers=claude-ai", "")
+	if body := w.Body.String(); w.Code != http.StatusOK || !strings.Contains(body, `var providers = ["claude-ai"];`) {
+		t.Errorf("Expected ?providers= with a query token, got %d:\n%s", w.Code, body)
+	}
+}
+
+func TestDashboardWithServerAndDashboardTokens(t *testing.T) {
+	t.Setenv("SERVER_TOKEN", "server")
+	t.Setenv("DASHBOARD_TOKEN", "office")
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	setupRoutes(r)
+
+	for path, want := range map[string]int{
+		"/?token=server":          http.StatusOK,
+		"/?token=office":          http.StatusOK,
+		"/?token=wrong":           http.StatusUnauthorized,
+		"/quota/glm?token=office": http.StatusUnauthorized,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != want {
+			t.Errorf("Expected %d for %s, got %d", want, path, w.Code)
+		}
+	}
+}
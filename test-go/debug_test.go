@@ -0,0 +1,120 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedactJSON(t *testing.T) {
+	input := map[string]interface{}{
+		"access_token": "ya29.secret",
+		"user":         "user_123",
+		"data": []interface{}{
+			map[string]interface{}{"apiKey": "k", "email": "a@b.c", "totalTokensUsage": float64(42)},
+		},
+	}
+	output := redactJSON(input).(map[string]interface{})
+	detail := output["data"].([]interface{})[0].(map[string]interface{})
+
+	if output["access_token"] != redacted || output["user"] != redacted || detail["apiKey"] != redacted || detail["email"] != redacted {
+		t.Errorf("Expected secrets redacted, got %v", output)
+	}
+	if detail["totalTokensUsage"] != float64(42) {
+		t.Errorf("Expected token counts kept, got %v", detail)
+	}
+}
+
+func TestRedactURLAndHeaders(t *testing.T) {
+	u, _ := url.Parse("https://cursor.com/api/usage?user=user_123&month=3")
+	if got := redactURL(u); strings.Contains(got, "user_123") || !strings.Contains(got, "month=3") {
+		t.Errorf("Unexpected redacted URL: %s", got)
+	}
+
+	headers := redactHeaders(http.Header{"Set-Cookie": {"session=abc"}, "X-Ratelimit-Limit-Tokens": {"6000"}})
+	if headers["Set-Cookie"][0] != redacted || headers["X-Ratelimit-Limit-Tokens"][0] != "6000" {
+		t.Errorf("Unexpected redacted headers: %v", headers)
+	}
+}
+
+func TestRedactConfig(t *testing.T) {
+	config := redactConfig(&Config{GroqAPIKey: staticToken("gsk-key"), HookOnBelow: "curl https://hooks.example/secret", GroqBaseURL: "https://api.groq.com/openai"})
+	if config["GroqAPIKey"] != redacted || config["HookOnBelow"] != redacted || config["MistralAPIKey"] != nil {
+		t.Errorf("Expected set secrets redacted, got %v %v %v", config["GroqAPIKey"], config["HookOnBelow"], config["MistralAPIKey"])
+	}
+	if config["GroqBaseURL"] != "https://api.groq.com/openai" {
+		t.Errorf("Expected other fields kept, got %v", config["GroqBaseURL"])
+	}
+}
+
+func TestWriteDebugBundle(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-ratelimit-limit-requests", "100")
+		w.Header().Set("x-ratelimit-remaining-requests", "50")
+		fmt.Fprint(w, `{"object":"list","data":[{"id":"llama","owner_email":"a@b.c"}]}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("GROQ_API_KEY", "gsk-key")
+	t.Setenv("GROQ_BASE_URL", server.URL)
+	t.Setenv("OLLAMA_HOST", "127.0.0.1:1")
+	t.Setenv("ACCOUNT_FILE", filepath.Join(t.TempDir(), "missing.json"))
+	t.Setenv("CLAUDE_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "missing.json"))
+	t.Setenv("ANTHROPIC_AUTH_TOKEN", "")
+	t.Setenv("ZAI_ANTHROPIC_AUTH_TOKEN", "")
+	t.Setenv("ZAI_AUTH_TOKEN", "")
+	t.Setenv("ZHIPU_AUTH_TOKEN", "")
+
+	var buf bytes.Buffer
+	if err := writeDebugBundle(context.Background(), &buf); err != nil {
+		t.Fatalf("writeDebugBundle failed: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Invalid zip: %v", err)
+	}
+	contents := make(map[string]string)
+	for _, file := range archive.File {
+		reader, _ := file.Open()
+		data, _ := io.ReadAll(reader)
+		reader.Close()
+		contents[file.Name] = string(data)
+	}
+
+	for _, name := range []string{"version.json", "config.json", "parsed.json"} {
+		if contents[name] == "" {
+			t.Errorf("Expected %s in the bundle, got %v", name, archive.File)
+		}
+	}
+	if strings.Contains(contents["config.json"], "gsk-key") {
+		t.Error("Expected the API key redacted from config.json")
+	}
+
+	var parsed map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(contents["parsed.json"]), &parsed); err != nil || !strings.Contains(string(parsed["groq"]), "groq-requests-daily") {
+		t.Errorf("Expected parsed Groq quota, got %s (%v)", parsed["groq"], err)
+	}
+
+	found := false
+	for name, data := range contents {
+		if strings.HasPrefix(name, "responses/") && strings.Contains(data, "llama") {
+			found = true
+			if strings.Contains(data, "a@b.c") {
+				t.Errorf("Expected the email redacted from %s", name)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected the Groq response recorded, got %v", contents)
+	}
+}
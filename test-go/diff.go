@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// QuotaDelta is the change of a model's remaining quota since a historical snapshot, with the
+// tokens used since then for providers that report them
+type QuotaDelta struct {
+	Provider string  `json:"provider"`
+	Model    string  `json:"model"`
+	Since    int64   `json:"since"`
+	Before   float64 `json:"before"`
+	After    float64 `json:"after"`
+	Change   float64 `json:"change"`
+	Tokens   *int64  `json:"tokens,omitempty"`
+}
+
+// diffQuota compares each model of quota with its history record closest to target.
+// Models without an earlier record are left out.
+func diffQuota(history *historyStore, provider string, quota *FormattedQuota, target time.Time, precision int) []QuotaDelta {
+	var deltas []QuotaDelta
+	for _, model := range quota.Models {
+		baseline, ok := history.closest(provider, model.Name, target, time.Unix(quota.LastUpdated, 0))
+		if !ok {
+			continue
+		}
+		deltas = append(deltas, QuotaDelta{
+			Provider: provider,
+			Model:    model.Name,
+			Since:    baseline.Time,
+			Before:   baseline.Percentage,
+			After:    model.Percentage,
+			Change:   roundPercentage(model.Percentage-baseline.Percentage, precision),
+		})
+	}
+	return deltas
+}
+
+// addTokenDeltas sets the tokens used since each delta's snapshot for providers that report
+// token usage. Providers that fail are added to errs.
+func addTokenDeltas(ctx context.Context, deltas []QuotaDelta, errs map[string]string) {
+	for i, delta := range deltas {
+		source, ok := tokenUsageProviders[delta.Provider]
+		if !ok || source.fetchSince == nil {
+			continue
+		}
+		usages, err := source.fetchSince(ctx, time.Unix(delta.Since, 0))
+		if err != nil {
+			errs[delta.Provider] = err.Error()
+			continue
+		}
+		for _, usage := range usages {
+			if usage.Model == delta.Model {
+				tokens := usage.Tokens
+				deltas[i].Tokens = &tokens
+			}
+		}
+	}
+}
+
+// formatQuotaDelta renders a delta as one line of text in the output language
+func formatQuotaDelta(config *Config, delta QuotaDelta) string {
+	printer := localizer(config.Language)
+	change := strconv.FormatFloat(delta.Change, 'f', -1, 64)
+	if delta.Change > 0 {
+		change = "+" + change
+	}
+	line := printer.Sprintf(msgDiffChange, delta.Provider, delta.Model,
+		strconv.FormatFloat(delta.Before, 'f', -1, 64), strconv.FormatFloat(delta.After, 'f', -1, 64),
+		change, time.Unix(delta.Since, 0).Local().Format("Jan 2 15:04"))
+	if delta.Tokens == nil {
+		return line
+	}
+	return printer.Sprintf(msgDiffTokens, line, formatNumber(config.NumberLocale, *delta.Tokens))
+}
+
+// runDiffCommand prints how much quota each model used since the history snapshot closest
+// to --since ago. The current snapshot is fetched now and added to the history.
+func runDiffCommand(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	since := flags.Duration("since", time.Hour, "how long ago the compared snapshot was taken")
+	providers := flags.String("providers", "", "comma-separated providers to compare (default: all in the history)")
+	asJSON := flags.Bool("json", false, "print deltas as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config := LoadConfig()
+	store := historyStoreOf(config)
+	if store == nil {
+		return errors.New("HISTORY_FILE is not set; diff compares against the history the server records there")
+	}
+	if err := quotaHistory.open(store); err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+
+	names := parseList(*providers)
+	if len(names) == 0 {
+		names = quotaHistory.providers()
+	}
+
+	ctx := context.Background()
+	target := clockNow().Add(-*since)
+	service := NewQuotaService(NewCloudCodeClient(config))
+	deltas := []QuotaDelta{}
+	errs := make(map[string]string)
+	for _, provider := range names {
+		quota, err := service.fetchQuota(ctx, provider)
+		if err != nil {
+			errs[provider] = err.Error()
+			continue
+		}
+		deltas = append(deltas, diffQuota(quotaHistory, provider, quota, target, config.PercentagePrecision)...)
+	}
+	addTokenDeltas(ctx, deltas, errs)
+
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(map[string]interface{}{"deltas": deltas, "errors": errs})
+	}
+
+	if len(deltas) == 0 && len(errs) == 0 {
+		fmt.Fprintln(stdout, localizer(config.Language).Sprintf(msgDiffNoHistory, target.Local().Format("Jan 2 15:04")))
+	}
+	for _, delta := range deltas {
+		fmt.Fprintln(stdout, formatQuotaDelta(config, delta))
+	}
+	failed := make([]string, 0, len(errs))
+	for provider := range errs {
+		failed = append(failed, provider)
+	}
+	sort.Strings(failed)
+	for _, provider := range failed {
+		fmt.Fprintf(stdout, "%s: %s\n", provider, errs[provider])
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+func TestHistoryClosest(t *testing.T) {
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	defer setClock(&fakeClock{t: base.Add(time.Hour)})()
+
+	history := newHistoryStore()
+	for i, pct := range []float64{90, 80, 70} {
+		history.record("glm", &FormattedQuota{LastUpdated: base.Add(time.Duration(i) * 10 * time.Minute).Unix(), Models: []FormattedModel{{Name: "glm", Percentage: pct}}})
+	}
+
+	if record, ok := history.closest("glm", "glm", base.Add(8*time.Minute), base.Add(20*time.Minute)); !ok || record.Percentage != 80 {
+		t.Errorf("Expected the 12:10 record, got %+v %v", record, ok)
+	}
+	// Records at or after until are never the baseline
+	if record, ok := history.closest("glm", "glm", base.Add(time.Hour), base.Add(20*time.Minute)); !ok || record.Percentage != 80 {
+		t.Errorf("Expected the latest record before until, got %+v %v", record, ok)
+	}
+	if _, ok := history.closest("glm", "other", base, base.Add(time.Hour)); ok {
+		t.Error("Expected no record for an unseen model")
+	}
+	if providers := history.providers(); len(providers) != 1 || providers[0] != "glm" {
+		t.Errorf("Unexpected providers: %v", providers)
+	}
+}
+
+func TestDiffQuota(t *testing.T) {
+	original := tokenUsageProviders
+	defer func() { tokenUsageProviders = original }()
+
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	defer setClock(&fakeClock{t: base.Add(time.Hour)})()
+
+	history := newHistoryStore()
+	history.record("glm", &FormattedQuota{LastUpdated: base.Unix(), Models: []FormattedModel{{Name: "glm", Percentage: 82}}})
+
+	var requested time.Time
+	tokenUsageProviders = map[string]tokenUsageSource{
+		"glm": {kind: CostKindValue, fetchSince: func(ctx context.Context, since time.Time) ([]TokenUsage, error) {
+			requested = since
+			return []TokenUsage{{Model: "glm", Tokens: 1234567}}, nil
+		}},
+	}
+
+	current := &FormattedQuota{LastUpdated: base.Add(time.Hour).Unix(), Models: []FormattedModel{{Name: "glm", Percentage: 64}, {Name: "glm-coding-plan-mcp-monthly", Percentage: 90}}}
+	deltas := diffQuota(history, "glm", current, base, 1)
+	errs := make(map[string]string)
+	addTokenDeltas(context.Background(), deltas, errs)
+
+	if len(deltas) != 1 || len(errs) != 0 {
+		t.Fatalf("Expected one delta, got %+v %v", deltas, errs)
+	}
+	delta := deltas[0]
+	if delta.Before != 82 || delta.After != 64 || delta.Change != -18 || delta.Tokens == nil || *delta.Tokens != 1234567 {
+		t.Errorf("Unexpected delta: %+v", delta)
+	}
+	if !requested.Equal(base) {
+		t.Errorf("Expected tokens since the baseline, got %v", requested)
+	}
+
+	config := &Config{NumberLocale: language.English, Language: language.English}
+	if got := formatQuotaDelta(config, delta); !strings.HasPrefix(got, "glm/glm: 82% -> 64% (-18 points since ") || !strings.HasSuffix(got, "), 1,234,567 tokens used") {
+		t.Errorf("Unexpected line: %s", got)
+	}
+}
+
+func TestBuildTimeRangeParams(t *testing.T) {
+	since := time.Date(2026, 3, 1, 13, 40, 0, 0, time.UTC)
+	until := time.Date(2026, 3, 1, 14, 25, 0, 0, time.UTC)
+	want := "?startTime=2026-03-01+13%3A00%3A00&endTime=2026-03-01+14%3A59%3A59"
+	if got := buildTimeRangeParams(since, until); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckEnvFile(t *testing.T) {
+	dir := t.TempDir()
+
+	if check := checkEnvFile(filepath.Join(dir, "missing.env")); check.Status != DoctorWarn || check.Fix == "" {
+		t.Errorf("Expected a warning with a fix for a missing file, got %+v", check)
+	}
+
+	valid := filepath.Join(dir, "valid.env")
+	os.WriteFile(valid, []byte("PORT=8000\nGROQ_API_KEY=gsk\n"), 0600)
+	if check := checkEnvFile(valid); check.Status != DoctorOK || !strings.Contains(check.Detail, "2 variables") {
+		t.Errorf("Expected a valid file, got %+v", check)
+	}
+
+	invalid := filepath.Join(dir, "invalid.env")
+	os.WriteFile(invalid, []byte("PORT=\"8000\nNOT VALID\n"), 0600)
+	if check := checkEnvFile(invalid); check.Status != DoctorFail {
+		t.Errorf("Expected a parse failure, got %+v", check)
+	}
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	if check := checkWritable("history", filepath.Join(dir, "history.jsonl")); check.Status != DoctorOK {
+		t.Errorf("Expected a writable directory, got %+v", check)
+	}
+	if check := checkWritable("history", filepath.Join(dir, "missing", "history.jsonl")); check.Status != DoctorFail || check.Fix == "" {
+		t.Errorf("Expected a failure with a fix, got %+v", check)
+	}
+}
+
+func TestCheckReachableClockSkew(t *testing.T) {
+	serverTime := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", serverTime.Format(http.TimeFormat))
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	restore := setClock(&fakeClock{t: serverTime.Add(3 * time.Minute)})
+	defer restore()
+
+	check, skew, ok := checkReachable(context.Background(), "groq", server.URL+"/openai")
+	if check.Status != DoctorOK || !ok || skew != 3*time.Minute {
+		t.Fatalf("Expected reachable with 3m skew, got %+v %v %v", check, skew, ok)
+	}
+	if check := checkClockSkew([]time.Duration{time.Second, skew}); check.Status != DoctorWarn || !strings.Contains(check.Detail, "3m0s") {
+		t.Errorf("Expected a clock skew warning, got %+v", check)
+	}
+	if check := checkClockSkew([]time.Duration{-2 * time.Second}); check.Status != DoctorOK {
+		t.Errorf("Expected a small skew to pass, got %+v", check)
+	}
+
+	server.Close()
+	if check, _, _ := checkReachable(context.Background(), "groq", server.URL); check.Status != DoctorFail || check.Fix == "" {
+		t.Errorf("Expected an unreachable failure with a fix, got %+v", check)
+	}
+}
+
+func TestPrintDoctorChecks(t *testing.T) {
+	var buf bytes.Buffer
+	failed := printDoctorChecks(&buf, []DoctorCheck{
+		{Name: "env file", Status: DoctorOK, Detail: ".env defines 3 variables"},
+		{Name: "groq token", Status: DoctorFail, Detail: "status 401", Fix: "Set GROQ_API_KEY"},
+	})
+	if !failed {
+		t.Error("Expected a failure to be reported")
+	}
+	want := "[ok  ] env file: .env defines 3 variables\n[fail] groq token: status 401\n       fix: Set GROQ_API_KEY\n"
+	if buf.String() != want {
+		t.Errorf("Unexpected output:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Digest schedules for EMAIL_DIGEST
+const (
+	digestDaily  = "daily"
+	digestWeekly = "weekly"
+)
+
+// Local time digests are sent at unless EMAIL_DIGEST_AT is set; weekly digests go out on Mondays
+const DefaultDigestAt = "08:00"
+
+// emailNotifier sends digests and alerts through the SMTP server in SMTP_ADDRESS
+type emailNotifier struct {
+	// send delivers a message; replaced in tests
+	send func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// newEmailNotifier creates a notifier that sends with net/smtp, upgrading to TLS when the
+// server offers STARTTLS
+func newEmailNotifier() *emailNotifier {
+	return &emailNotifier{send: smtp.SendMail}
+}
+
+// emailEnabled reports whether there is a server to send through and someone to send to
+func emailEnabled(config *Config) bool {
+	return config.SMTPAddress != "" && config.EmailFrom != "" && len(config.EmailTo) > 0
+}
+
+// mail sends a plain text message to EMAIL_TO
+func (n *emailNotifier) mail(config *Config, subject, body string) error {
+	var auth smtp.Auth
+	if config.SMTPUsername != "" {
+		password, err := readToken(context.Background(), config.SMTPPassword)
+		if err != nil {
+			return fmt.Errorf("failed to read SMTP_PASSWORD: %w", err)
+		}
+		host, _, _ := net.SplitHostPort(config.SMTPAddress)
+		auth = smtp.PlainAuth("", config.SMTPUsername, password, host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", config.EmailFrom)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(config.EmailTo, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", clockNow().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return n.send(config.SMTPAddress, auth, config.EmailFrom, config.EmailTo, msg.Bytes())
+}
+
+// alert mails a hook event right away when EMAIL_ALERTS lists it
+func (n *emailNotifier) alert(config *Config, event HookEvent) {
+	if !emailEnabled(config) || !slices.Contains(config.EmailAlerts, event.Event) {
+		return
+	}
+	subject, body := alertText(config, event)
+	if err := n.mail(config, subject, body+"\n"); err != nil {
+		log.Printf("Failed to mail %s alert for %s/%s: %v", event.Event, event.Provider, event.Model, err)
+	}
+}
+
+// alertText returns the title and message of a hook event alert in the configured language
+func alertText(config *Config, event HookEvent) (string, string) {
+	printer := localizer(config.Language)
+	subject := printer.Sprintf(msgAlertSubject, event.Provider, event.Model, formatPercentage(event.Percentage))
+	body := printer.Sprintf(msgAlertBody, event.Provider, event.Model, formatPercentage(event.Percentage), event.Event, event.Threshold)
+	if event.Warning != "" {
+		body += "\n" + event.Warning
+	}
+	if event.ResetTime != "" {
+		body += "\n" + printer.Sprintf(msgAlertReset, event.ResetTime)
+	}
+	return subject, body
+}
+
+// renderDigest writes the quota used per model over the digest period, the sessions that
+// ended in it, and the monthly period comparisons, as summary prints them
+func renderDigest(config *Config, history *historyStore, since, now time.Time) string {
+	printer := localizer(config.Language)
+	var body bytes.Buffer
+	writeSummary(&body, config, printer.Sprintf(msgEmailDigestHeading, since.Local().Format("Jan 2 15:04"), now.Local().Format("Jan 2 15:04")), history.consumed(since, now))
+	for _, summary := range summarizeSessions(history, since, now) {
+		from := time.Unix(summary.Start, 0).Local().Format("Jan 2 15:04")
+		heading := printer.Sprintf(msgSummarySession, summary.Name, from, time.Unix(summary.End, 0).Local().Format("15:04"))
+		if summary.Open {
+			heading = printer.Sprintf(msgSummaryOpenSession, summary.Name, from)
+		}
+		body.WriteString("\n")
+		writeSummary(&body, config, heading, summary.Models)
+	}
+	if periods := history.periodComparisons(now); len(periods) > 0 {
+		body.WriteString("\n")
+		for _, period := range periods {
+			fmt.Fprintln(&body, formatPeriodComparison(config, period))
+		}
+	}
+	return body.String()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmailAlerts(t *testing.T) {
+	sent := make(chan string, 4)
+	runner := NewHookRunner()
+	runner.run = func(string, HookEvent) {}
+	runner.mail.send = func(addr string, _ smtp.Auth, from string, to []string, msg []byte) error {
+		sent <- addr + " " + from + " " + strings.Join(to, ",") + "\n" + string(msg)
+		return nil
+	}
+
+	config := &Config{
+		HookThreshold: 10,
+		SMTPAddress:   "smtp.example.com:587",
+		EmailFrom:     "quota@example.com",
+		EmailTo:       []string{"me@example.com"},
+		EmailAlerts:   []string{HookEventEmpty},
+	}
+	if !hooksEnabled(config) {
+		t.Error("Expected email alerts to enable polling")
+	}
+
+	runner.observe(config, "glm", &FormattedQuota{Models: []FormattedModel{{Name: "glm", Percentage: 5}}})
+	runner.observe(config, "glm", &FormattedQuota{Models: []FormattedModel{{Name: "glm", Percentage: 0, ResetTime: "2026-10-15T14:00:00Z"}}})
+
+	select {
+	case msg := <-sent:
+		for _, want := range []string{
+			"smtp.example.com:587 quota@example.com me@example.com",
+			"Subject: Quota alert: glm/glm at 0%",
+			"glm/glm has 0% left (empty, threshold 10%).",
+			"Resets at 2026-10-15T14:00:00Z.",
+		} {
+			if !strings.Contains(msg, want) {
+				t.Errorf("Expected %q in the alert, got:\n%s", want, msg)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected an alert for the empty model")
+	}
+	select {
+	case msg := <-sent:
+		t.Errorf("Expected only events in EMAIL_ALERTS to be mailed, got:\n%s", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRenderDigest(t *testing.T) {
+	now := time.Date(2026, 10, 15, 8, 0, 0, 0, time.Local)
+	history := newHistoryStore()
+	for i, pct := range []float64{90, 70, 65} {
+		at := now.Add(time.Duration(i-3) * time.Hour).Unix()
+		history.record("glm", &FormattedQuota{Models: []FormattedModel{{Name: "glm", Percentage: pct}}, LastUpdated: at})
+	}
+
+	body := renderDigest(&Config{}, history, now.Add(-24*time.Hour), now)
+	if !strings.HasPrefix(body, "Quota used from Oct 14 08:00 to Oct 15 08:00\n") || !strings.Contains(body, "glm/glm: 25 points used") {
+		t.Errorf("Unexpected digest:\n%s", body)
+	}
+}
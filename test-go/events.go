@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Largest usage event request body accepted
+const maxEventsBody = 1 << 20
+
+// UsageEvent is usage reported by external tooling, such as the tokens a local proxy
+// forwarded. Tokens are converted to quota points once two refreshes have shown what they
+// are worth; Percentage gives the points used directly.
+type UsageEvent struct {
+	Provider   string  `json:"provider"`
+	Model      string  `json:"model"`
+	Tokens     int64   `json:"tokens"`
+	Percentage float64 `json:"percentage"`
+
+	// Unix seconds when the usage happened (default: when it is received)
+	Timestamp int64 `json:"timestamp"`
+}
+
+// validate reports what is missing or out of range in an event
+func (e UsageEvent) validate() error {
+	switch {
+	case e.Provider == "" || e.Model == "":
+		return errors.New("provider and model are required")
+	case e.Tokens < 0 || e.Percentage < 0:
+		return errors.New("tokens and percentage cannot be negative")
+	case e.Tokens == 0 && e.Percentage == 0:
+		return errors.New("tokens or percentage is required")
+	}
+	return nil
+}
+
+// decodeUsageEvents decodes a single event or an array of events
+func decodeUsageEvents(body []byte) ([]UsageEvent, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var events []UsageEvent
+		err := json.Unmarshal(body, &events)
+		return events, err
+	}
+	var event UsageEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+	return []UsageEvent{event}, nil
+}
+
+// PostUsageEvents accepts usage events and adds them to the burn-rate estimates, so the
+// rates move between provider refreshes. With EVENTS_TOKEN set, requests must send it as a
+// bearer token.
+func (s *QuotaService) PostUsageEvents(c *gin.Context) {
+	token, ok := requestToken(c, "EVENTS_TOKEN", s.client.Config().EventsToken)
+	if !ok {
+		return
+	}
+	if token != "" {
+		given := []byte(c.GetHeader("Authorization"))
+		if subtle.ConstantTimeCompare(given, []byte("Bearer "+token)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "missing or wrong EVENTS_TOKEN bearer token"})
+			return
+		}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxEventsBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	events, err := decodeUsageEvents(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid usage event: %v", err)})
+		return
+	}
+	for i, event := range events {
+		if err := event.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("event %d: %v", i, err)})
+			return
+		}
+	}
+
+	now := clockNow()
+	for _, event := range events {
+		at := now
+		if event.Timestamp > 0 {
+			at = time.Unix(event.Timestamp, 0)
+		}
+		burnRates.recordUsage(event.Provider, event.Model, usageEvent{at: at, tokens: event.Tokens, points: event.Percentage})
+	}
+	c.JSON(http.StatusAccepted, gin.H{"accepted": len(events)})
+}
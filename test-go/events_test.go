@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBurnRegistryUsageEvents(t *testing.T) {
+	registry := &burnRegistry{samples: make(map[string][]burnSample)}
+	clk := &fakeClock{t: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	defer setClock(clk)()
+
+	observe := func(pct float64) {
+		registry.record("glm", &FormattedQuota{
+			Models:      []FormattedModel{{Name: "glm", Percentage: pct}},
+			LastUpdated: clk.Now().Unix(),
+		})
+	}
+	use := func(tokens int64, points float64) {
+		registry.recordUsage("glm", "glm", usageEvent{at: clk.Now(), tokens: tokens, points: points})
+	}
+
+	// Points are used directly before any refresh has calibrated tokens
+	observe(90)
+	clk.Advance(30 * time.Minute)
+	use(1000, 0)
+	use(0, 5)
+	if rate := registry.rate("glm", "glm"); rate != 10 {
+		t.Errorf("Expected 10%%/h from the reported points, got %v", rate)
+	}
+
+	// 10 points dropped, 5 reported as points: the 1000 tokens were worth 5
+	observe(80)
+	clk.Advance(30 * time.Minute)
+	use(2000, 0)
+	if rate := registry.rate("glm", "glm"); rate != 20 {
+		t.Errorf("Expected 20%%/h with the calibrated tokens, got %v", rate)
+	}
+
+	// The next refresh includes the events, so they stop counting
+	observe(70)
+	if rate := registry.rate("glm", "glm"); rate != 20 {
+		t.Errorf("Expected 20%%/h from samples alone, got %v", rate)
+	}
+	registry.recordUsage("glm", "glm", usageEvent{at: clk.Now().Add(-time.Minute), points: 50})
+	clk.Advance(time.Minute)
+	use(0, 1)
+	clk.Advance(5 * time.Minute)
+	if rate := registry.rate("glm", "glm"); math.Abs(rate-21.0/66*60) > 1e-9 {
+		t.Errorf("Expected events before the last sample to be ignored, got %v", rate)
+	}
+}
+
+func TestPostUsageEvents(t *testing.T) {
+	t.Setenv("EVENTS_TOKEN", "secret")
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	service := NewQuotaService(NewCloudCodeClient(LoadConfig()))
+	router.POST("/events/usage", service.PostUsageEvents)
+
+	post := func(body, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/events/usage", strings.NewReader(body))
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post(`{"provider":"glm","model":"glm","tokens":100}`, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without the token, got %d", w.Code)
+	}
+	if w := post(`{"provider":"glm","model":"glm","tokens":100}`, "Bearer secret"); w.Code != http.StatusAccepted || !strings.Contains(w.Body.String(), `"accepted":1`) {
+		t.Errorf("Expected a single event to be accepted, got %d %s", w.Code, w.Body.String())
+	}
+	if w := post(`[{"provider":"glm","model":"glm","tokens":100},{"provider":"glm","model":"glm","percentage":1}]`, "Bearer secret"); w.Code != http.StatusAccepted || !strings.Contains(w.Body.String(), `"accepted":2`) {
+		t.Errorf("Expected an array of events to be accepted, got %d %s", w.Code, w.Body.String())
+	}
+	for _, body := range []string{`{"model":"glm","tokens":1}`, `{"provider":"glm","model":"glm"}`, `{"provider":"glm","model":"glm","tokens":-1}`, `not json`} {
+		if w := post(body, "Bearer secret"); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", body, w.Code)
+		}
+	}
+}
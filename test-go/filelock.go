@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// Interval between attempts to take a file lock held by another process
+const fileLockPoll = 10 * time.Millisecond
+
+// errFileLocked is returned when another process holds a file lock past the wait
+var errFileLocked = errors.New("file is locked by another process")
+
+// acquireFileLock takes an advisory lock on path, creating it, and retries for up to wait
+// while another process holds it. Locks are released by the returned function, or by the
+// system when the process exits, so a crash never leaves one stuck.
+func acquireFileLock(path string, wait time.Duration) (func(), error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(wait)
+	for {
+		locked, err := tryLockFile(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if locked {
+			return func() {
+				unlockFile(file)
+				file.Close()
+			}, nil
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, errFileLocked
+		}
+		time.Sleep(fileLockPoll)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquireFileLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.lock")
+	release, err := acquireFileLock(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to lock: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := acquireFileLock(path, 50*time.Millisecond); !errors.Is(err, errFileLocked) {
+		t.Errorf("Expected the held lock to be busy, got %v", err)
+	}
+	if waited := time.Since(start); waited < 50*time.Millisecond {
+		t.Errorf("Expected to wait for the lock, waited %s", waited)
+	}
+
+	// A lock released during the wait is taken
+	time.AfterFunc(20*time.Millisecond, release)
+	again, err := acquireFileLock(path, time.Second)
+	if err != nil {
+		t.Fatalf("Expected the released lock to be taken, got %v", err)
+	}
+	again()
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// groqRateLimits maps Groq's rate-limit headers to models. Groq's request limit is per day
+// and its token limit per minute.
+var groqRateLimits = []rateLimitSpec{
+	{name: "groq-requests-daily", kind: "requests"},
+	{name: "groq-tokens-minute", kind: "tokens"},
+}
+
+// GetGroqUsage gets the daily request and per-minute token limits of the configured Groq API key
+func GetGroqUsage(ctx context.Context) (FormattedQuota, error) {
+	config := LoadConfig()
+	apiKey, err := readToken(ctx, config.GroqAPIKey)
+	if err != nil {
+		return FormattedQuota{}, fmt.Errorf("failed to read GROQ_API_KEY: %w", err)
+	}
+	if apiKey == "" {
+		return FormattedQuota{}, fmt.Errorf("GROQ_API_KEY environment variable is not set")
+	}
+
+	modelsURL := strings.TrimRight(config.GroqBaseURL, "/") + "/v1/models"
+	return getRateLimitQuota(ctx, ProviderGroq, modelsURL, apiKey, groqRateLimits)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCGetQuota(t *testing.T) {
+	mockServer := createMockServer(t)
+	defer mockServer.Close()
+
+	config := &Config{
+		APIURL:        mockServer.URL + "/v1internal:fetchAvailableModels",
+		ProjectAPIURL: mockServer.URL + "/v1internal:loadCodeAssist",
+		TokenURL:      mockServer.URL + "/token",
+		UserAgent:     "test-agent",
+		AccountFile:   createTestAccount(t),
+		QueryDebounce: 1,
+	}
+	server := &quotaGRPCServer{service: NewQuotaService(NewCloudCodeClient(config))}
+
+	snapshot, err := server.GetQuota(context.Background(), &GetQuotaRequest{})
+	if err != nil {
+		t.Fatalf("GetQuota failed: %v", err)
+	}
+
+	if snapshot.GetProvider() != ProviderAntigravity {
+		t.Errorf("Expected provider %s, got %s", ProviderAntigravity, snapshot.GetProvider())
+	}
+	if len(snapshot.GetModels()) != 3 {
+		t.Fatalf("Expected 3 models, got %d", len(snapshot.GetModels()))
+	}
+	if snapshot.GetModels()[0].GetName() != "claude-sonnet-4-5" || snapshot.GetModels()[0].GetPercentage() != 80 {
+		t.Errorf("Unexpected first model: %v", snapshot.GetModels()[0])
+	}
+}
+
+func TestGRPCGetQuotaUnknownProvider(t *testing.T) {
+	server := &quotaGRPCServer{service: NewQuotaService(NewCloudCodeClient(&Config{}))}
+
+	_, err := server.GetQuota(context.Background(), &GetQuotaRequest{Provider: "unknown"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestQuotaChanged(t *testing.T) {
+	previous := &FormattedQuota{Models: []FormattedModel{{Name: "glm", Percentage: 75}}}
+
+	if !quotaChanged(nil, previous) {
+		t.Error("Expected change from nil snapshot")
+	}
+	if quotaChanged(previous, &FormattedQuota{Models: []FormattedModel{{Name: "glm", Percentage: 75}}}) {
+		t.Error("Expected no change for identical percentages")
+	}
+	if !quotaChanged(previous, &FormattedQuota{Models: []FormattedModel{{Name: "glm", Percentage: 74}}}) {
+		t.Error("Expected change for different percentage")
+	}
+	if !quotaChanged(previous, &FormattedQuota{}) {
+		t.Error("Expected change when models are removed")
+	}
+}
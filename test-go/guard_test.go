@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGuardCommandName(t *testing.T) {
+	for line, want := range map[string]string{
+		"claude --resume":                  "claude",
+		"ANTHROPIC_MODEL=x /usr/bin/aider": "aider",
+		"  codex":                          "codex",
+		"":                                 "",
+	} {
+		if got := guardCommandName(line); got != want {
+			t.Errorf("guardCommandName(%q) = %q, expected %q", line, got, want)
+		}
+	}
+}
+
+func TestGuardCommand(t *testing.T) {
+	defer setClock(&fakeClock{t: time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)})()
+	t.Setenv("PROMPT_CACHE_DIR", t.TempDir())
+	t.Setenv("OUTPUT_LANGUAGE", "en")
+	t.Setenv("GUARD_COMMANDS", "")
+	t.Setenv("GUARD_THRESHOLD", "")
+	writePromptCache("glm", &FormattedQuota{Models: []FormattedModel{
+		{Name: "glm", Percentage: 3, ResetTimeRelative: "2h"},
+		{Name: "mcp", Percentage: 60},
+	}})
+	guard := func(stdin string, args ...string) (string, error) {
+		var stderr bytes.Buffer
+		err := runGuardCommand(append([]string{"--provider", "glm"}, args...), strings.NewReader(stdin), &stderr)
+		return stderr.String(), err
+	}
+
+	if out, err := guard("", "--", "ls", "-la"); out != "" || err != nil {
+		t.Errorf("Expected unguarded commands to pass silently, got %q, %v", out, err)
+	}
+	if out, err := guard("", "--threshold", "2", "--", "claude"); out != "" || err != nil {
+		t.Errorf("Expected no warning above the threshold, got %q, %v", out, err)
+	}
+	out, err := guard("", "--", "claude", "-p", "hi")
+	if err != nil || !strings.Contains(out, "quota guard: glm/glm has 3% left, below the required 5%; resets in 2h") {
+		t.Errorf("Expected a warning, got %q, %v", out, err)
+	}
+	if _, err := guard("n\n", "--block", "--", "aider"); err == nil || err.Error() != "not running aider" {
+		t.Errorf("Expected a declined command to be blocked, got %v", err)
+	}
+	if out, err := guard("y\n", "--block", "--", "aider"); err != nil || !strings.Contains(out, "Run aider anyway? [y/N]") {
+		t.Errorf("Expected a confirmed command to run, got %q, %v", out, err)
+	}
+	if _, err := guard("", "--model", "mcp", "--", "codex"); err != nil {
+		t.Errorf("Expected only the selected model to be checked, got %v", err)
+	}
+}
+
+func TestGuardFetchTimeout(t *testing.T) {
+	t.Setenv("PROMPT_CACHE_DIR", t.TempDir())
+	t.Setenv("OUTPUT_LANGUAGE", "en")
+	t.Setenv("GUARD_COMMANDS", "")
+	t.Setenv("DAEMON_MODE", "always")
+	// A daemon that never answers
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	t.Setenv("DAEMON_ADDRESS", strings.TrimPrefix(server.URL, "http://"))
+
+	var stderr bytes.Buffer
+	start := time.Now()
+	err := runGuardCommand([]string{"--provider", "glm", "--block", "--", "claude"}, strings.NewReader(""), &stderr)
+	if elapsed := time.Since(start); elapsed > guardFetchTimeout+time.Second {
+		t.Errorf("Expected guard to give up after %s, took %s", guardFetchTimeout, elapsed)
+	}
+	if err != nil || !strings.Contains(stderr.String(), "quota guard: glm quota unavailable") {
+		t.Errorf("Expected the command to run with a notice, got %q, %v", stderr.String(), err)
+	}
+}
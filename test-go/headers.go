@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// headerVariable returns the variable holding a provider's extra request headers, e.g.
+// HTTP_HEADERS_ZHIPU_BALANCE, or HTTP_HEADERS for every provider
+func headerVariable(provider string) string {
+	if provider == "" {
+		return "HTTP_HEADERS"
+	}
+	return "HTTP_HEADERS_" + strings.ToUpper(strings.ReplaceAll(provider, "-", "_"))
+}
+
+// parseRequestHeaders parses "Name=value,Name2=value2" pairs into headers with canonical names
+func parseRequestHeaders(value string) map[string]string {
+	headers := make(map[string]string)
+	for name, headerValue := range parseModelAliases(value) {
+		headers[http.CanonicalHeaderKey(name)] = headerValue
+	}
+	return headers
+}
+
+// loadRequestHeaders reads HTTP_HEADERS and the HTTP_HEADERS_<PROVIDER> variables, which
+// may hold gateway credentials and so can also come from _FILE, _CMD, or _KEYCHAIN
+// variables, read when a request is built. Providers without headers are left out; "" holds
+// the headers of every provider.
+func loadRequestHeaders() map[string]TokenSource {
+	providers := map[string]bool{"": true}
+	for _, entry := range os.Environ() {
+		name, _, _ := strings.Cut(entry, "=")
+		rest, ok := strings.CutPrefix(name, "HTTP_HEADERS_")
+		if !ok {
+			continue
+		}
+		for _, suffix := range []string{"_FILE", "_CMD", "_KEYCHAIN"} {
+			rest = strings.TrimSuffix(rest, suffix)
+		}
+		if rest != "" {
+			providers[strings.ToLower(strings.ReplaceAll(rest, "_", "-"))] = true
+		}
+	}
+
+	result := make(map[string]TokenSource)
+	for provider := range providers {
+		if source := secretToken(headerVariable(provider)); source != nil {
+			result[provider] = source
+		}
+	}
+	return result
+}
+
+// defaultAuthSchemes are the templates of the header carrying each provider's credential,
+// "Name: value" with {token} for the credential. Windsurf sends its key in the body.
+var defaultAuthSchemes = map[string]string{
+	ProviderAntigravity:  "Authorization: Bearer {token}",
+	ProviderGLM:          "Authorization: {token}",
+	ProviderZhipuBalance: "Authorization: {token}",
+	ProviderClaudeAI:     "Authorization: Bearer {token}",
+	ProviderCursor:       "Cookie: WorkosCursorSessionToken={token}",
+	ProviderXAI:          "Authorization: Bearer {token}",
+	ProviderGroq:         "Authorization: Bearer {token}",
+	ProviderMistral:      "Authorization: Bearer {token}",
+}
+
+// authSchemeVariable returns the variable overriding a provider's auth header template, e.g.
+// AUTH_HEADER_ZHIPU_BALANCE
+func authSchemeVariable(provider string) string {
+	return "AUTH_HEADER_" + strings.ToUpper(strings.ReplaceAll(provider, "-", "_"))
+}
+
+// loadAuthSchemes reads the AUTH_HEADER_<PROVIDER> templates of the providers that have one.
+// Templates without a "Name:" are left out, so the provider keeps its default.
+func loadAuthSchemes() map[string]string {
+	schemes := make(map[string]string)
+	for provider := range defaultAuthSchemes {
+		scheme := trimQuotes(os.Getenv(authSchemeVariable(provider)))
+		if name, _, ok := strings.Cut(scheme, ":"); ok && strings.TrimSpace(name) != "" {
+			schemes[provider] = scheme
+		}
+	}
+	return schemes
+}
+
+// authHeader returns the name and value of the header carrying a provider's credential, by
+// its AUTH_HEADER_<PROVIDER> template or else its default one
+func authHeader(config *Config, provider, token string) (string, string) {
+	scheme, ok := config.AuthSchemes[provider]
+	if !ok {
+		scheme = defaultAuthSchemes[provider]
+	}
+	name, value, _ := strings.Cut(scheme, ":")
+	return http.CanonicalHeaderKey(strings.TrimSpace(name)), strings.ReplaceAll(strings.TrimSpace(value), "{token}", token)
+}
+
+// isAuthHeaderName reports whether an AUTH_HEADER_<PROVIDER> template sends a credential in
+// the named header, which traces and debug bundles then redact like Authorization
+func isAuthHeaderName(name string) bool {
+	for _, scheme := range loadAuthSchemes() {
+		if header, _, _ := strings.Cut(scheme, ":"); http.CanonicalHeaderKey(strings.TrimSpace(header)) == http.CanonicalHeaderKey(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// setRequestHeaders sets the configured extra headers of provider on a request, those of
+// the provider over those of every provider, and both over the headers already set
+func setRequestHeaders(req *http.Request, config *Config, provider string) error {
+	for _, scope := range []string{"", provider} {
+		value, err := readToken(req.Context(), config.RequestHeaders[scope])
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", headerVariable(scope), err)
+		}
+		for name, value := range parseRequestHeaders(value) {
+			req.Header.Set(name, value)
+		}
+	}
+	return nil
+}
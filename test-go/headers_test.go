@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadRequestHeaders(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "gateway")
+	os.WriteFile(keyFile, []byte("x-gateway-key=from-file\n"), 0600)
+	t.Setenv("HTTP_HEADERS", "user-agent=quota-exporter/1.0")
+	t.Setenv("HTTP_HEADERS_ZHIPU_BALANCE", "X-Team=ml")
+	t.Setenv("HTTP_HEADERS_GLM_FILE", keyFile)
+
+	config := &Config{RequestHeaders: loadRequestHeaders()}
+	headers := func(provider string) http.Header {
+		req, _ := http.NewRequest("GET", "https://example.com", nil)
+		if err := setRequestHeaders(req, config, provider); err != nil {
+			t.Fatalf("Expected the %s headers to be read, got %v", provider, err)
+		}
+		return req.Header
+	}
+	if got := headers(ProviderGroq); got.Get("User-Agent") != "quota-exporter/1.0" {
+		t.Errorf("Expected a canonical User-Agent for every provider, got %v", got)
+	}
+	if got := headers(ProviderZhipuBalance); got.Get("X-Team") != "ml" {
+		t.Errorf("Expected the zhipu-balance headers, got %v", got)
+	}
+	if got := headers(ProviderGLM); got.Get("X-Gateway-Key") != "from-file" || got.Get("User-Agent") == "" {
+		t.Errorf("Expected the GLM headers from the file over those of every provider, got %v", got)
+	}
+}
+
+func TestQueryZAIEndpointRequestHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Gateway-Key") != "k3y" || r.Header.Get("User-Agent") != "glm-agent" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"code":200,"data":{"ok":true}}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("HTTP_HEADERS", "User-Agent=generic")
+	t.Setenv("HTTP_HEADERS_GLM", "X-Gateway-Key=k3y,User-Agent=glm-agent")
+	if _, _, err := QueryZAIEndpoint(context.Background(), ProviderGLM, server.URL, "headers-token", ""); err != nil {
+		t.Errorf("Expected the GLM headers over the generic ones, got %v", err)
+	}
+}
+
+func TestAuthHeaderSchemes(t *testing.T) {
+	t.Setenv("AUTH_HEADER_GLM", "X-Api-Key: {token}")
+	t.Setenv("AUTH_HEADER_GROQ", "Authorization: Token {token}")
+	t.Setenv("AUTH_HEADER_MISTRAL", "Bearer {token}")
+	config := LoadConfig()
+
+	for _, tc := range []struct {
+		provider, name, value string
+	}{
+		{ProviderGLM, "X-Api-Key", "secret"},
+		{ProviderGroq, "Authorization", "Token secret"},
+		// A template without a header name keeps the default
+		{ProviderMistral, "Authorization", "Bearer secret"},
+		{ProviderZhipuBalance, "Authorization", "secret"},
+		{ProviderCursor, "Cookie", "WorkosCursorSessionToken=secret"},
+	} {
+		if name, value := authHeader(config, tc.provider, "secret"); name != tc.name || value != tc.value {
+			t.Errorf("Expected %s to send %s: %s, got %s: %s", tc.provider, tc.name, tc.value, name, value)
+		}
+	}
+
+	header := http.Header{"X-Api-Key": {"secret"}, "Accept": {"application/json"}}
+	if redacted := redactHeaders(header); redacted["X-Api-Key"][0] == "secret" || redacted["Accept"][0] != "application/json" {
+		t.Errorf("Expected the custom auth header to be redacted, got %v", redacted)
+	}
+}
+
+func TestQueryZAIEndpointAuthScheme(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer scheme-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"code":200,"data":{"ok":true}}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("AUTH_HEADER_GLM", "Authorization: Bearer {token}")
+	if _, _, err := QueryZAIEndpoint(context.Background(), ProviderGLM, server.URL, "scheme-token", ""); err != nil {
+		t.Errorf("Expected the token with the configured scheme, got %v", err)
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Days raw records are kept in memory and in the history file unless HISTORY_RETENTION is set
+	DefaultHistoryRetention = 8
+
+	// Longest wait for the history file's lock, which a compaction holds while it rewrites the file
+	historyLockWait = 10 * time.Second
+)
+
+// HistoryRecord is one observed percentage of a model at its data fetch time
+type HistoryRecord struct {
+	Time       int64   `json:"time"`
+	Provider   string  `json:"provider"`
+	Model      string  `json:"model"`
+	Percentage float64 `json:"percentage"`
+}
+
+// historyLine is a line of the history file, either a quota record or a session mark
+type historyLine struct {
+	HistoryRecord
+	Mark    string `json:"mark,omitempty"`
+	Session string `json:"session,omitempty"`
+	Member  string `json:"member,omitempty"`
+}
+
+// historyStore keeps recent quota observations and session marks in memory and, when
+// opened with a HistoryStore, appends them to it so they survive restarts
+type historyStore struct {
+	mu        sync.RWMutex
+	store     HistoryStore
+	retention time.Duration
+	records   map[string][]HistoryRecord
+	lastSeen  map[string]int64
+	marks     []SessionMark
+	rollups   map[string][]HistoryRecord
+}
+
+var quotaHistory = newHistoryStore()
+
+// newHistoryStore creates an in-memory history store
+func newHistoryStore() *historyStore {
+	return &historyStore{
+		retention: DefaultHistoryRetention * 24 * time.Hour,
+		records:   make(map[string][]HistoryRecord),
+		lastSeen:  make(map[string]int64),
+		rollups:   make(map[string][]HistoryRecord),
+	}
+}
+
+// open loads the records and session marks of a history store within the retention
+// period, and the daily rollups of monthly pools within theirs, and appends new ones to it
+func (h *historyStore) open(store HistoryStore) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.store = store
+	h.retention = time.Duration(LoadConfig().HistoryRawDays) * 24 * time.Hour
+	lines, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	cutoff := clockNow().Add(-h.retention).Unix()
+	for _, line := range lines {
+		if line.Mark == "" && isMonthlyModel(line.Model) {
+			h.addRollup(line.HistoryRecord)
+		}
+		if line.Time < cutoff {
+			continue
+		}
+		if line.Mark != "" {
+			h.marks = append(h.marks, SessionMark{Time: line.Time, Mark: line.Mark, Session: line.Session})
+			continue
+		}
+		record := line.HistoryRecord
+		key := burnKey(record.Provider, record.Model)
+		h.records[key] = append(h.records[key], record)
+		if record.Time > h.lastSeen[key] {
+			h.lastSeen[key] = record.Time
+		}
+	}
+	return nil
+}
+
+// close detaches the store, so requests still running at shutdown only update the in-memory
+// history
+func (h *historyStore) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.store = nil
+}
+
+// record stores every model of quota. Cached data repeats its fetch time and is skipped.
+// The new records are appended to the store after the lock is released, so a slow store
+// does not hold up readers of the history.
+func (h *historyStore) record(provider string, quota *FormattedQuota) {
+	store, added := h.add(provider, quota)
+	if store != nil && len(added) > 0 {
+		if err := store.Append(added...); err != nil {
+			log.Printf("Failed to write history: %v", err)
+		}
+	}
+}
+
+// add keeps the new records of quota in memory and returns them with the store to append
+// them to
+func (h *historyStore) add(provider string, quota *FormattedQuota) (HistoryStore, []interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	cutoff := clockNow().Add(-h.retention).Unix()
+
+	var added []interface{}
+	for _, model := range quota.Models {
+		key := burnKey(provider, model.Name)
+		if quota.LastUpdated <= h.lastSeen[key] {
+			continue
+		}
+		h.lastSeen[key] = quota.LastUpdated
+
+		record := HistoryRecord{Time: quota.LastUpdated, Provider: provider, Model: model.Name, Percentage: model.Percentage}
+		records := append(h.records[key], record)
+		start := 0
+		for start < len(records) && records[start].Time < cutoff {
+			start++
+		}
+		h.records[key] = records[start:]
+		added = append(added, record)
+		if isMonthlyModel(model.Name) {
+			h.addRollup(record)
+		}
+	}
+	return h.store, added
+}
+
+// consumed returns the quota used per model between since and until in percentage points,
+// starting from each model's last record before since. Models that used nothing are left out.
+func (h *historyStore) consumed(since, until time.Time) []ModelUsage {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	usage := []ModelUsage{}
+	for _, records := range h.records {
+		var window []HistoryRecord
+		for _, record := range records {
+			if record.Time < since.Unix() {
+				window = append(window[:0], record)
+			} else if record.Time <= until.Unix() {
+				window = append(window, record)
+			}
+		}
+		if used := usedPercentage(window); used > 0 {
+			usage = append(usage, ModelUsage{Provider: window[0].Provider, Model: window[0].Model, Used: used})
+		}
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return burnKey(usage[i].Provider, usage[i].Model) < burnKey(usage[j].Provider, usage[j].Model)
+	})
+	return usage
+}
+
+// usedPercentage sums the percentage drops across records, ignoring rises from quota resets
+func usedPercentage(records []HistoryRecord) float64 {
+	used := 0.0
+	for i := 1; i < len(records); i++ {
+		if drop := records[i-1].Percentage - records[i].Percentage; drop > 0 {
+			used += drop
+		}
+	}
+	return used
+}
+
+// query returns a model's records in [since, until), oldest first
+func (h *historyStore) query(provider, model string, since, until time.Time) []HistoryRecord {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var result []HistoryRecord
+	for _, record := range h.records[burnKey(provider, model)] {
+		if record.Time >= since.Unix() && record.Time < until.Unix() {
+			result = append(result, record)
+		}
+	}
+	return result
+}
+
+// closest returns the model's record nearest to target among those before until
+func (h *historyStore) closest(provider, model string, target, until time.Time) (HistoryRecord, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var best HistoryRecord
+	found := false
+	for _, record := range h.records[burnKey(provider, model)] {
+		if record.Time >= until.Unix() {
+			continue
+		}
+		if !found || absInt64(record.Time-target.Unix()) < absInt64(best.Time-target.Unix()) {
+			best, found = record, true
+		}
+	}
+	return best, found
+}
+
+// providers returns the providers that have records, sorted
+func (h *historyStore) providers() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, records := range h.records {
+		for _, record := range records {
+			if !seen[record.Provider] {
+				seen[record.Provider] = true
+				names = append(names, record.Provider)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// absInt64 returns the absolute value of n
+func absInt64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// appendHistory appends records or marks to a JSON lines file, in one durable write so a
+// crash loses at most the last line. It takes the file's lock, so a compaction that is
+// replacing the file does not lose the lines.
+func appendHistory(path string, records ...interface{}) error {
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	release, err := acquireFileLock(historyLockPath(path), historyLockWait)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return appendFileDurable(path, data.Bytes(), 0600)
+}
+
+// historyLockPath returns the lock file that appends and compactions of a history file take
+func historyLockPath(path string) string {
+	return path + ".lock"
+}
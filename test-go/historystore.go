@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// Longest wait for a remote history store to load or append
+	historyStoreTimeout = 10 * time.Second
+
+	// Largest body accepted by POST /history
+	maxHistoryBody = 1 << 20
+
+	// Records kept while a remote history store is unreachable; the oldest are dropped
+	// beyond it
+	historyRetryBuffer = 1000
+
+	// Wait before the first retry of a failed remote append, doubled up to historyRetryMax
+	historyRetryMin = 5 * time.Second
+	historyRetryMax = 5 * time.Minute
+)
+
+// HistoryStore persists the quota records and session marks that the in-memory history is
+// built from. The JSON lines file in HISTORY_FILE is the default; HISTORY_URL points a
+// daemon at a shared server instead, so a team's usage is kept in one place.
+type HistoryStore interface {
+	// Load returns this machine's stored lines, oldest first
+	Load() ([]historyLine, error)
+
+	// Append stores HistoryRecord and SessionMark values
+	Append(records ...interface{}) error
+}
+
+// historyStoreOf returns the store the configuration names, HISTORY_URL before
+// HISTORY_FILE, or nil to keep history in memory only
+func historyStoreOf(config *Config) HistoryStore {
+	switch {
+	case config.HistoryURL != "":
+		return newRemoteHistoryStore(config)
+	case config.HistoryFile != "":
+		return &fileHistoryStore{path: config.HistoryFile}
+	}
+	return nil
+}
+
+// fileHistoryStore keeps history in a JSON lines file. Lines that other members sent to a
+// shared server carry their member name and are not this machine's.
+type fileHistoryStore struct {
+	path string
+}
+
+// Load returns the lines of the file without a member
+func (f *fileHistoryStore) Load() ([]historyLine, error) {
+	return readHistoryFile(f.path, func(line historyLine) bool { return line.Member == "" })
+}
+
+// Append appends records to the file
+func (f *fileHistoryStore) Append(records ...interface{}) error {
+	return appendHistory(f.path, records...)
+}
+
+// readHistoryFile returns the lines of a history file that keep returns true for, or none
+// when the file does not exist
+func readHistoryFile(path string, keep func(historyLine) bool) ([]historyLine, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []historyLine
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var line historyLine
+		if json.Unmarshal(scanner.Bytes(), &line) == nil && keep(line) {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// remoteHistoryStore keeps history on another quota server through its /history endpoint,
+// under this machine's member name. Records the server cannot take are buffered and sent
+// again with backoff, as the hub pusher does.
+type remoteHistoryStore struct {
+	url      string
+	token    TokenSource
+	member   string
+	client   *http.Client
+	retryMin time.Duration
+
+	mu      sync.Mutex
+	pending []interface{}
+	sending bool
+}
+
+// newRemoteHistoryStore creates a store for HISTORY_URL, named HISTORY_MEMBER or else the
+// host name
+func newRemoteHistoryStore(config *Config) *remoteHistoryStore {
+	member := config.HistoryMember
+	if member == "" {
+		member, _ = os.Hostname()
+	}
+	return &remoteHistoryStore{
+		url:      config.HistoryURL,
+		token:    config.HistoryToken,
+		member:   member,
+		client:   &http.Client{Timeout: historyStoreTimeout},
+		retryMin: historyRetryMin,
+	}
+}
+
+// do sends a request for this member's history and decodes the JSON response into result
+func (r *remoteHistoryStore) do(method string, body io.Reader, result interface{}) error {
+	req, err := http.NewRequest(method, r.url+"?"+url.Values{"member": {r.member}}.Encode(), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	token, err := readToken(context.Background(), r.token)
+	if err != nil {
+		return fmt.Errorf("failed to read HISTORY_TOKEN: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		Error string `json:"error"`
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		decodeResponse(resp, &response)
+		if response.Error != "" {
+			return &APIError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("history store at %s: %s", r.url, response.Error)}
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("history store at %s returned status %d", r.url, resp.StatusCode)}
+	}
+	return decodeResponse(resp, result)
+}
+
+// Load returns this member's lines from the shared server
+func (r *remoteHistoryStore) Load() ([]historyLine, error) {
+	var response struct {
+		Lines []historyLine `json:"lines"`
+	}
+	err := r.do(http.MethodGet, nil, &response)
+	return response.Lines, err
+}
+
+// Append sends records to the shared server. When it cannot be reached, or fails with a
+// server error, the records are kept for a retry in the background; while one is pending,
+// new records join it without a request of their own.
+func (r *remoteHistoryStore) Append(records ...interface{}) error {
+	r.mu.Lock()
+	if r.sending {
+		r.buffer(records)
+		r.mu.Unlock()
+		return nil
+	}
+	r.mu.Unlock()
+
+	err := r.send(records)
+	if err == nil || !retryableHistoryError(err) {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.buffer(records)
+	if !r.sending {
+		r.sending = true
+		go r.drain()
+	}
+	return err
+}
+
+// send posts records to the shared server
+func (r *remoteHistoryStore) send(records []interface{}) error {
+	body, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	var response struct {
+		Stored int `json:"stored"`
+	}
+	return r.do(http.MethodPost, bytes.NewReader(body), &response)
+}
+
+// buffer queues records for the next retry, dropping the oldest beyond historyRetryBuffer;
+// the caller holds r.mu
+func (r *remoteHistoryStore) buffer(records []interface{}) {
+	r.pending = append(r.pending, records...)
+	if dropped := len(r.pending) - historyRetryBuffer; dropped > 0 {
+		log.Printf("History buffer full, dropping the %d oldest records", dropped)
+		r.pending = r.pending[dropped:]
+	}
+}
+
+// drain sends the buffered records after a wait that doubles with each failure, until
+// none are left. Records the server refuses as invalid are dropped instead of retried.
+func (r *remoteHistoryStore) drain() {
+	delay := r.retryMin
+	for {
+		time.Sleep(delay)
+		r.mu.Lock()
+		batch := r.pending
+		r.pending = nil
+		r.mu.Unlock()
+
+		err := r.send(batch)
+		r.mu.Lock()
+		if err != nil && retryableHistoryError(err) {
+			delay = min(delay*2, historyRetryMax)
+			log.Printf("Failed to write history, retrying in %s: %v", delay, err)
+			r.pending = append(batch, r.pending...)
+			r.buffer(nil)
+		} else {
+			if err != nil {
+				log.Printf("The history store refused %d records: %v", len(batch), err)
+			}
+			delay = r.retryMin
+			if len(r.pending) == 0 {
+				r.sending = false
+				r.mu.Unlock()
+				return
+			}
+		}
+		r.mu.Unlock()
+	}
+}
+
+// retryableHistoryError reports whether a failed append is worth retrying: network errors
+// and server errors are, a refused token or record is not
+func retryableHistoryError(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= http.StatusInternalServerError || apiErr.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// historyMemberOf returns the member whose HISTORY_TOKENS token the request carries
+func historyMemberOf(c *gin.Context, tokens map[string]string) (string, bool) {
+	given := []byte(c.GetHeader("Authorization"))
+	found := ""
+	for _, member := range sortedKeys(tokens) {
+		if subtle.ConstantTimeCompare(given, []byte("Bearer "+tokens[member])) == 1 {
+			found = member
+		}
+	}
+	return found, found != ""
+}
+
+// requestedMember returns the member a /history request acts as and whether it names one,
+// or responds with an error and returns false for ok. A HISTORY_TOKENS token binds the
+// request to its member, and asking for another is refused. Without one, the request is
+// trusted with the member it names in ?member=, unless HISTORY_TOKENS is set, in which case
+// only SERVER_TOKEN may act for any member.
+func requestedMember(c *gin.Context, config *Config) (member string, named, ok bool) {
+	member, named = c.GetQuery("member")
+	members, ok := requestTokenMap(c, "HISTORY_TOKENS", config.HistoryTokens)
+	if !ok {
+		return "", false, false
+	}
+	server, ok := requestToken(c, "SERVER_TOKEN", config.ServerToken)
+	if !ok {
+		return "", false, false
+	}
+	bound, isMember := historyMemberOf(c, members)
+	if !isMember {
+		if len(members) > 0 && (server == "" || !tokenMatches(c, server)) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "missing or unknown HISTORY_TOKENS bearer token"})
+			return "", false, false
+		}
+		return member, named, true
+	}
+	if named && member != bound {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("this token belongs to member %s", bound)})
+		return "", false, false
+	}
+	return bound, true, true
+}
+
+// GetHistory returns the lines of HISTORY_FILE, those of one member with ?member= or a
+// member's HISTORY_TOKENS token, else every member's including this server's own, which
+// have no member
+func (s *QuotaService) GetHistory(c *gin.Context) {
+	config := s.client.Config()
+	path := config.HistoryFile
+	if path == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "HISTORY_FILE is not set on this server"})
+		return
+	}
+
+	member, one, ok := requestedMember(c, config)
+	if !ok {
+		return
+	}
+	lines, err := readHistoryFile(path, func(line historyLine) bool { return !one || line.Member == member })
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if lines == nil {
+		lines = []historyLine{}
+	}
+	c.JSON(http.StatusOK, gin.H{"lines": lines})
+}
+
+// PostHistory appends a member's records and session marks to HISTORY_FILE, tagged with
+// the member name so members that share the server keep separate histories
+func (s *QuotaService) PostHistory(c *gin.Context) {
+	config := s.client.Config()
+	path := config.HistoryFile
+	if path == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "HISTORY_FILE is not set on this server"})
+		return
+	}
+	member, _, ok := requestedMember(c, config)
+	if !ok {
+		return
+	}
+	if member == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "member is required"})
+		return
+	}
+
+	var raw []map[string]interface{}
+	if err := json.NewDecoder(io.LimitReader(c.Request.Body, maxHistoryBody)).Decode(&raw); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid history lines: %v", err)})
+		return
+	}
+	records := make([]interface{}, len(raw))
+	for i, fields := range raw {
+		if err := validateHistoryLine(fields); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("line %d: %v", i, err)})
+			return
+		}
+		fields["member"] = member
+		records[i] = fields
+	}
+
+	if err := appendHistory(path, records...); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"stored": len(records)})
+}
+
+// validateHistoryLine checks that a posted line is a quota record or a session mark
+func validateHistoryLine(fields map[string]interface{}) error {
+	data, _ := json.Marshal(fields)
+	var line historyLine
+	if err := json.Unmarshal(data, &line); err != nil {
+		return err
+	}
+	switch {
+	case line.Time <= 0:
+		return errors.New("time is required")
+	case line.Mark != "" && line.Mark != MarkStart && line.Mark != MarkStop:
+		return fmt.Errorf("unknown mark %q", line.Mark)
+	case line.Mark == "" && (line.Provider == "" || line.Model == ""):
+		return errors.New("provider and model are required")
+	}
+	return nil
+}
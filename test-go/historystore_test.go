@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRemoteHistoryStore(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.Local)
+	defer setClock(&fakeClock{t: now})()
+
+	path := filepath.Join(t.TempDir(), "shared.jsonl")
+	t.Setenv("HISTORY_FILE", path)
+	t.Setenv("SERVER_TOKEN", "team-secret")
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	setupRoutes(r)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	// The shared server's own reading has no member
+	appendHistory(path, HistoryRecord{Time: now.Add(-time.Hour).Unix(), Provider: "glm", Model: "glm", Percentage: 90})
+
+	members := map[string]*remoteHistoryStore{}
+	for _, name := range []string{"alice", "bob"} {
+		members[name] = newRemoteHistoryStore(&Config{HistoryURL: server.URL + "/history", HistoryToken: staticToken("team-secret"), HistoryMember: name})
+	}
+	if err := members["alice"].Append(HistoryRecord{Time: now.Unix(), Provider: "glm", Model: "glm", Percentage: 70}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if err := members["bob"].Append(SessionMark{Time: now.Unix(), Mark: MarkStart, Session: "review"}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	lines, err := members["alice"].Load()
+	if err != nil || len(lines) != 1 || lines[0].Percentage != 70 || lines[0].Member != "alice" {
+		t.Errorf("Expected only alice's record, got %+v, %v", lines, err)
+	}
+
+	// Each member's history is separate from the others and from the server's own
+	history := newHistoryStore()
+	if err := history.open(members["bob"]); err != nil {
+		t.Fatal(err)
+	}
+	if sessions := history.sessions(now); len(sessions) != 1 || sessions[0].Name != "review" || len(history.providers()) != 0 {
+		t.Errorf("Expected bob's session and no records, got %+v", sessions)
+	}
+	local := newHistoryStore()
+	local.open(&fileHistoryStore{path: path})
+	if record, ok := local.closest("glm", "glm", now, now.Add(time.Hour)); !ok || record.Percentage != 90 {
+		t.Errorf("Expected the server's own reading only, got %+v", record)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/history", nil)
+	req.Header.Set("Authorization", "Bearer team-secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		Lines []historyLine `json:"lines"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if len(body.Lines) != 3 {
+		t.Errorf("Expected every member's lines without ?member=, got %+v", body.Lines)
+	}
+
+	bad := newRemoteHistoryStore(&Config{HistoryURL: server.URL + "/history", HistoryToken: staticToken("team-secret"), HistoryMember: "carol"})
+	if err := bad.Append(HistoryRecord{Time: now.Unix(), Provider: "glm"}); err == nil || !strings.Contains(err.Error(), "provider and model are required") {
+		t.Errorf("Expected an incomplete record to be rejected, got %v", err)
+	}
+	bad.token = staticToken("wrong")
+	if _, err := bad.Load(); err == nil {
+		t.Error("Expected a wrong token to be refused")
+	}
+}
+
+// blockingHistoryStore holds every append until release is closed
+type blockingHistoryStore struct {
+	release chan struct{}
+}
+
+func (b *blockingHistoryStore) Load() ([]historyLine, error) { return nil, nil }
+
+func (b *blockingHistoryStore) Append(...interface{}) error {
+	<-b.release
+	return nil
+}
+
+func TestHistoryReadableDuringSlowAppend(t *testing.T) {
+	store := &blockingHistoryStore{release: make(chan struct{})}
+	history := newHistoryStore()
+	history.open(store)
+	defer close(store.release)
+
+	now := time.Now()
+	go history.record("glm", &FormattedQuota{LastUpdated: now.Unix(), Models: []FormattedModel{{Name: "glm", Percentage: 80}}})
+	read := make(chan []HistoryRecord)
+	go func() {
+		for {
+			if records := history.query("glm", "glm", now.Add(-time.Minute), now.Add(time.Minute)); len(records) > 0 {
+				read <- records
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	select {
+	case <-read:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the history to be readable while the store appends")
+	}
+}
+
+func TestRemoteHistoryStoreRetries(t *testing.T) {
+	var failing atomic.Bool
+	var stored atomic.Int32
+	failing.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var lines []historyLine
+		json.NewDecoder(r.Body).Decode(&lines)
+		stored.Add(int32(len(lines)))
+		fmt.Fprintf(w, `{"stored":%d}`, len(lines))
+	}))
+	defer server.Close()
+
+	store := newRemoteHistoryStore(&Config{HistoryURL: server.URL, HistoryMember: "alice"})
+	store.retryMin = time.Millisecond
+	record := HistoryRecord{Time: 1, Provider: "glm", Model: "glm", Percentage: 50}
+	if err := store.Append(record); err == nil {
+		t.Fatal("Expected the first append to report the failure")
+	}
+	// Records appended while a retry is pending are buffered without a request
+	if err := store.Append(record, record); err != nil {
+		t.Fatalf("Expected a buffered append, got %v", err)
+	}
+
+	failing.Store(false)
+	deadline := time.Now().Add(time.Second)
+	for stored.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if stored.Load() != 3 {
+		t.Errorf("Expected the buffered records to be sent once the store recovers, got %d", stored.Load())
+	}
+}
+
+func TestHistoryMemberTokens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.jsonl")
+	t.Setenv("HISTORY_FILE", path)
+	t.Setenv("SERVER_TOKEN", "admin-secret")
+	t.Setenv("HISTORY_TOKENS", "alice=alice-secret,bob=bob-secret")
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	setupRoutes(r)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	record := HistoryRecord{Time: 1, Provider: "glm", Model: "glm", Percentage: 50}
+	alice := newRemoteHistoryStore(&Config{HistoryURL: server.URL + "/history", HistoryToken: staticToken("alice-secret"), HistoryMember: "alice"})
+	if err := alice.Append(record); err != nil {
+		t.Fatalf("Expected alice to write her own history, got %v", err)
+	}
+	if err := newRemoteHistoryStore(&Config{HistoryURL: server.URL + "/history", HistoryToken: staticToken("bob-secret"), HistoryMember: "bob"}).Append(record); err != nil {
+		t.Fatal(err)
+	}
+	impersonator := newRemoteHistoryStore(&Config{HistoryURL: server.URL + "/history", HistoryToken: staticToken("alice-secret"), HistoryMember: "bob"})
+	if err := impersonator.Append(record); err == nil || !strings.Contains(err.Error(), "member alice") {
+		t.Errorf("Expected alice's token to be refused for bob, got %v", err)
+	}
+	if _, err := impersonator.Load(); err == nil {
+		t.Error("Expected alice's token not to read bob's history")
+	}
+
+	get := func(token, query string) (int, []historyLine) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/history"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var body struct {
+			Lines []historyLine `json:"lines"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body.Lines
+	}
+	if status, lines := get("alice-secret", ""); status != http.StatusOK || len(lines) != 1 || lines[0].Member != "alice" {
+		t.Errorf("Expected a member token to read only its member's lines, got %d %+v", status, lines)
+	}
+	if status, lines := get("admin-secret", ""); status != http.StatusOK || len(lines) != 2 {
+		t.Errorf("Expected SERVER_TOKEN to read every member's lines, got %d %+v", status, lines)
+	}
+	if status, _ := get("alice-secret", "?member=bob"); status != http.StatusForbidden {
+		t.Errorf("Expected a 403 for another member's lines, got %d", status)
+	}
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/quota/glm", nil)
+	req.Header.Set("Authorization", "Bearer alice-secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected member tokens to be refused outside /history, got %d", resp.StatusCode)
+	}
+}
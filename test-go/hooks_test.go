@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestHookRunnerTransitions(t *testing.T) {
+	var mu sync.Mutex
+	var fired []string
+	runner := NewHookRunner()
+	done := make(chan struct{}, 16)
+	runner.run = func(command string, event HookEvent) {
+		mu.Lock()
+		fired = append(fired, command+" "+event.Event+" "+event.Model+" "+formatPercentage(event.Percentage))
+		mu.Unlock()
+		done <- struct{}{}
+	}
+
+	config := &Config{HookThreshold: 10, HookOnBelow: "pause", HookOnRecover: "resume", HookModels: []string{"glm*"}}
+	observe := func(pct float64, wantEvents int) []string {
+		runner.observe(config, "glm", &FormattedQuota{Models: []FormattedModel{
+			{Name: "glm", Percentage: pct},
+			{Name: "other", Percentage: 0},
+		}})
+		for i := 0; i < wantEvents; i++ {
+			<-done
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		result := fired
+		fired = nil
+		return result
+	}
+
+	if got := observe(50, 0); len(got) != 0 {
+		t.Errorf("Expected no hook for a healthy first observation, got %v", got)
+	}
+	if got := observe(8, 1); len(got) != 1 || got[0] != "pause below glm 8" {
+		t.Errorf("Expected below hook, got %v", got)
+	}
+	if got := observe(5, 0); len(got) != 0 {
+		t.Errorf("Expected no repeat while still below, got %v", got)
+	}
+	// Without HOOK_ON_EMPTY, reaching 0 runs the below hook
+	if got := observe(0, 1); len(got) != 1 || got[0] != "pause empty glm 0" {
+		t.Errorf("Expected empty event on the below hook, got %v", got)
+	}
+	if got := observe(100, 1); len(got) != 1 || got[0] != "resume recover glm 100" {
+		t.Errorf("Expected recover hook, got %v", got)
+	}
+}
+
+func TestRunHookCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	out := filepath.Join(t.TempDir(), "event")
+	runHookCommand(`echo "$QUOTA_EVENT $QUOTA_PROVIDER $QUOTA_MODEL $QUOTA_PERCENTAGE $QUOTA_THRESHOLD" > `+out, HookEvent{
+		Event:      HookEventBelow,
+		Provider:   "glm",
+		Model:      "glm",
+		Percentage: 7.5,
+		Threshold:  10,
+	})
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Hook did not run: %v", err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "below glm glm 7.5 10" {
+		t.Errorf("Unexpected hook environment: %q", got)
+	}
+}
+
+func TestHookRunnerAnomaly(t *testing.T) {
+	runner := NewHookRunner()
+	events := make(chan HookEvent, 4)
+	runner.run = func(command string, event HookEvent) {
+		events <- event
+	}
+
+	config := &Config{HookThreshold: 10, HookOnAnomaly: "alert"}
+	observe := func(warning string) {
+		runner.observe(config, "glm", &FormattedQuota{Models: []FormattedModel{{Name: "glm", Percentage: 50, Warning: warning}}})
+	}
+
+	observe("using 40%/h, usually 6±1%/h at this hour")
+	event := <-events
+	if event.Event != HookEventAnomaly || event.Warning == "" {
+		t.Errorf("Expected anomaly event with warning, got %+v", event)
+	}
+
+	// Still anomalous, then back to normal, fires nothing more
+	observe("using 41%/h, usually 6±1%/h at this hour")
+	observe("")
+	select {
+	case event := <-events:
+		t.Errorf("Expected no further events, got %+v", event)
+	default:
+	}
+}
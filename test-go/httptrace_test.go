@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTracingTransport(t *testing.T) {
+	body := `{"access_token":"ya29.secret","expires_in":3599,"scope":"` + strings.Repeat("x", 100) + `"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	client := &http.Client{Transport: &tracingTransport{
+		base:    http.DefaultTransport,
+		logger:  log.New(&out, "http: ", 0),
+		maxBody: 60,
+	}}
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/token?api_key=k3y&region=cn", nil)
+	req.Header.Set("Authorization", "Bearer sk-secret")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	received, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(received) != body {
+		t.Errorf("Expected the whole body to reach the client, got %q", received)
+	}
+
+	trace := out.String()
+	for _, want := range []string{"--> GET " + server.URL + "/token?api_key=%5Bredacted%5D&region=cn", "Authorization: [redacted]",
+		"Set-Cookie: [redacted]", "<-- 200 OK in ", server.Listener.Addr().String() + ", connect ", `"access_token":"[redacted]"`, `"expires_in":3599`, "(truncated)"} {
+		if !strings.Contains(trace, want) {
+			t.Errorf("Expected the trace to contain %q, got:\n%s", want, trace)
+		}
+	}
+	for _, secret := range []string{"sk-secret", "ya29", "k3y", "session=abc"} {
+		if strings.Contains(trace, secret) {
+			t.Errorf("Expected %q to be redacted, got:\n%s", secret, trace)
+		}
+	}
+}
+
+func TestDebugHTTPFromArgs(t *testing.T) {
+	t.Setenv("DEBUG_HTTP", "")
+	target, rest := debugHTTPFromArgs([]string{"show", "--debug-http", "--provider", "glm"})
+	if target != "-" || strings.Join(rest, " ") != "show --provider glm" {
+		t.Errorf("Expected stderr and the other arguments, got %q %v", target, rest)
+	}
+	if target, _ := debugHTTPFromArgs([]string{"--debug-http=trace.log"}); target != "trace.log" {
+		t.Errorf("Expected the file target, got %q", target)
+	}
+
+	t.Setenv("DEBUG_HTTP", "true")
+	if target, _ := debugHTTPFromArgs(nil); target != "-" {
+		t.Errorf("Expected DEBUG_HTTP=true to trace to stderr, got %q", target)
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Largest snapshot body the hub accepts
+const maxHubBody = 4 << 20
+
+// HubSnapshot is the quota a client pushed to the hub for one provider, labeled with the
+// host and login it was fetched on
+type HubSnapshot struct {
+	Provider string         `json:"provider"`
+	Quota    FormattedQuota `json:"quota"`
+	Host     string         `json:"host,omitempty"`
+	Login    string         `json:"login,omitempty"`
+	Received int64          `json:"received"`
+}
+
+// HubUser is the quota state of one user of the hub
+type HubUser struct {
+	User      string        `json:"user"`
+	Team      string        `json:"team,omitempty"`
+	Snapshots []HubSnapshot `json:"snapshots"`
+}
+
+// HubTeamModel aggregates a model across the members of a team that reported it
+type HubTeamModel struct {
+	Provider string  `json:"provider"`
+	Model    string  `json:"model"`
+	Lowest   float64 `json:"lowest"`
+	Average  float64 `json:"average"`
+	Members  int     `json:"members"`
+}
+
+// HubTeam is the quota state of a team, from its members' latest snapshots
+type HubTeam struct {
+	Team    string         `json:"team"`
+	Members []string       `json:"members"`
+	Models  []HubTeamModel `json:"models"`
+}
+
+// hubState keeps the latest snapshot of every user and provider pushed to the hub
+type hubState struct {
+	mu        sync.RWMutex
+	snapshots map[string]map[string]HubSnapshot
+}
+
+// newHubState creates an empty hub
+func newHubState() *hubState {
+	return &hubState{snapshots: make(map[string]map[string]HubSnapshot)}
+}
+
+// store keeps a user's snapshot unless they already pushed a newer one of the provider,
+// as a client flushing its offline buffer sends old snapshots late
+func (h *hubState) store(user string, snapshot HubSnapshot) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.snapshots[user] == nil {
+		h.snapshots[user] = make(map[string]HubSnapshot)
+	}
+	if current, ok := h.snapshots[user][snapshot.Provider]; ok && current.Quota.LastUpdated > snapshot.Quota.LastUpdated {
+		return
+	}
+	h.snapshots[user][snapshot.Provider] = snapshot
+}
+
+// decodeHubSnapshots decodes a single snapshot or an array of snapshots
+func decodeHubSnapshots(body []byte) ([]HubSnapshot, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var snapshots []HubSnapshot
+		err := json.Unmarshal(body, &snapshots)
+		return snapshots, err
+	}
+	var snapshot HubSnapshot
+	if err := json.Unmarshal(body, &snapshot); err != nil {
+		return nil, err
+	}
+	return []HubSnapshot{snapshot}, nil
+}
+
+// users returns every user's snapshots, sorted by user and provider
+func (h *hubState) users(teams map[string]string) []HubUser {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	users := []HubUser{}
+	for _, user := range sortedKeys(h.snapshots) {
+		entry := HubUser{User: user, Team: teams[user]}
+		for _, provider := range sortedKeys(h.snapshots[user]) {
+			entry.Snapshots = append(entry.Snapshots, h.snapshots[user][provider])
+		}
+		users = append(users, entry)
+	}
+	return users
+}
+
+// aggregateTeams combines the snapshots of each team's members into the lowest and
+// average percentage per model, rounded to precision. Users without a team are left out.
+func aggregateTeams(users []HubUser, precision int) []HubTeam {
+	type modelSum struct {
+		model HubTeamModel
+		sum   float64
+	}
+	members := make(map[string][]string)
+	sums := make(map[string]map[string]*modelSum)
+	for _, user := range users {
+		if user.Team == "" {
+			continue
+		}
+		members[user.Team] = append(members[user.Team], user.User)
+		if sums[user.Team] == nil {
+			sums[user.Team] = make(map[string]*modelSum)
+		}
+		for _, snapshot := range user.Snapshots {
+			for _, model := range snapshot.Quota.Models {
+				key := burnKey(snapshot.Provider, model.Name)
+				sum, ok := sums[user.Team][key]
+				if !ok {
+					sum = &modelSum{model: HubTeamModel{Provider: snapshot.Provider, Model: model.Name, Lowest: model.Percentage}}
+					sums[user.Team][key] = sum
+				}
+				sum.model.Lowest = min(sum.model.Lowest, model.Percentage)
+				sum.model.Members++
+				sum.sum += model.Percentage
+			}
+		}
+	}
+
+	teams := []HubTeam{}
+	for _, team := range sortedKeys(members) {
+		entry := HubTeam{Team: team, Members: members[team], Models: []HubTeamModel{}}
+		for _, key := range sortedKeys(sums[team]) {
+			sum := sums[team][key]
+			sum.model.Average = roundPercentage(sum.sum/float64(sum.model.Members), precision)
+			entry.Models = append(entry.Models, sum.model)
+		}
+		teams = append(teams, entry)
+	}
+	return teams
+}
+
+// hubServer serves the hub: clients push snapshots with their HUB_TOKENS token, and the
+// aggregated state is read from /quota and /metrics
+type hubServer struct {
+	config *Config
+	state  *hubState
+}
+
+// userOf returns the user whose HUB_TOKENS token the request carries
+func (s *hubServer) userOf(c *gin.Context, tokens map[string]string) (string, bool) {
+	given := []byte(c.GetHeader("Authorization"))
+	found := ""
+	for _, user := range sortedKeys(tokens) {
+		if subtle.ConstantTimeCompare(given, []byte("Bearer "+tokens[user])) == 1 {
+			found = user
+		}
+	}
+	return found, found != ""
+}
+
+// requireReadToken rejects reads without SERVER_TOKEN, for admins, or VIEWER_TOKEN, for
+// viewers, when SERVER_TOKEN is set
+func (s *hubServer) requireReadToken(c *gin.Context) {
+	admin, ok := requestToken(c, "SERVER_TOKEN", s.config.ServerToken)
+	if !ok {
+		return
+	}
+	viewer, ok := requestToken(c, "VIEWER_TOKEN", s.config.ViewerToken)
+	if !ok {
+		return
+	}
+	role := requestRole(c, admin, viewer)
+	if role == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing or wrong SERVER_TOKEN bearer token"})
+		return
+	}
+	c.Set(roleKey, role)
+}
+
+// PostPush stores a snapshot, or an array of them, pushed by a client
+func (s *hubServer) PostPush(c *gin.Context) {
+	tokens, ok := requestTokenMap(c, "HUB_TOKENS", s.config.HubTokens)
+	if !ok {
+		return
+	}
+	user, ok := s.userOf(c, tokens)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing or unknown HUB_TOKENS bearer token"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxHubBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	snapshots, err := decodeHubSnapshots(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid snapshot: %v", err)})
+		return
+	}
+	for i, snapshot := range snapshots {
+		if snapshot.Provider == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("snapshot %d: provider is required", i)})
+			return
+		}
+	}
+
+	now := clockNow().Unix()
+	for _, snapshot := range snapshots {
+		snapshot.Received = now
+		s.state.store(user, snapshot)
+	}
+	c.JSON(http.StatusAccepted, gin.H{"user": user, "accepted": len(snapshots)})
+}
+
+// GetQuota returns every user's latest snapshots and the per-team aggregates, only the
+// aggregates for viewers
+func (s *hubServer) GetQuota(c *gin.Context) {
+	users := s.state.users(s.config.HubTeams)
+	teams := aggregateTeams(users, s.config.PercentagePrecision)
+	if isViewer(c) {
+		c.JSON(http.StatusOK, gin.H{"teams": teams})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"users": users, "teams": teams})
+}
+
+// GetMetrics exposes the hub's quota state in the Prometheus text format, only the team
+// aggregates for viewers
+func (s *hubServer) GetMetrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	users := s.state.users(s.config.HubTeams)
+	now := clockNow().Unix()
+
+	w := c.Writer
+	if isViewer(c) {
+		writeTeamMetrics(w, aggregateTeams(users, s.config.PercentagePrecision))
+		return
+	}
+	fmt.Fprintln(w, "# HELP quota_hub_percentage Remaining quota of a user's model, from their latest snapshot.")
+	fmt.Fprintln(w, "# TYPE quota_hub_percentage gauge")
+	for _, user := range users {
+		for _, snapshot := range user.Snapshots {
+			for _, model := range snapshot.Quota.Models {
+				fmt.Fprintf(w, "quota_hub_percentage{user=%q,team=%q,provider=%q,model=%q} %s\n", user.User, user.Team, snapshot.Provider, model.Name, formatFloat(model.Percentage))
+			}
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP quota_hub_snapshot_age_seconds Time since a user last pushed a provider's quota.")
+	fmt.Fprintln(w, "# TYPE quota_hub_snapshot_age_seconds gauge")
+	for _, user := range users {
+		for _, snapshot := range user.Snapshots {
+			fmt.Fprintf(w, "quota_hub_snapshot_age_seconds{user=%q,provider=%q} %d\n", user.User, snapshot.Provider, now-snapshot.Received)
+		}
+	}
+
+	writeTeamMetrics(w, aggregateTeams(users, s.config.PercentagePrecision))
+}
+
+// writeTeamMetrics writes the per-team aggregates of the hub metrics
+func writeTeamMetrics(w io.Writer, teams []HubTeam) {
+	fmt.Fprintln(w, "# HELP quota_hub_team_lowest_percentage Lowest remaining quota of a model across a team's members.")
+	fmt.Fprintln(w, "# TYPE quota_hub_team_lowest_percentage gauge")
+	for _, team := range teams {
+		for _, model := range team.Models {
+			fmt.Fprintf(w, "quota_hub_team_lowest_percentage{team=%q,provider=%q,model=%q} %s\n", team.Team, model.Provider, model.Model, formatFloat(model.Lowest))
+		}
+	}
+}
+
+// setupHubRoutes registers the hub endpoints
+func setupHubRoutes(r *gin.Engine, hub *hubServer) {
+	r.POST("/push", hub.PostPush)
+	r.GET("/quota", hub.requireReadToken, hub.GetQuota)
+	r.GET("/metrics", hub.requireReadToken, hub.GetMetrics)
+	r.GET("/healthz", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "ok"}) })
+}
+
+// runHubCommand runs the team hub, taking the same listen and TLS options as serve
+func runHubCommand(args []string) error {
+	options, err := parseServeArgs(args)
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := serverTLSConfig(options)
+	if err != nil {
+		return err
+	}
+	config := LoadConfig()
+	users, err := readTokenMap(context.Background(), config.HubTokens)
+	if err != nil {
+		return fmt.Errorf("failed to read HUB_TOKENS: %w", err)
+	}
+	if len(users) == 0 {
+		return errors.New("HUB_TOKENS is not set; give each client a token as user=token,...")
+	}
+	listener, err := listenServer(options.listen)
+	if err != nil {
+		return err
+	}
+
+	r := newRouter()
+	setupHubRoutes(r, &hubServer{config: config, state: newHubState()})
+	server := &http.Server{Handler: r}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	if activeProfile != "" {
+		log.Printf("Using profile %s", activeProfile)
+	}
+	log.Printf("Starting quota hub on %s for %d users", options.listen, len(users))
+	return server.Serve(listener)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHubAggregatesPushedQuota(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.Local)
+	defer setClock(&fakeClock{t: now})()
+
+	config := &Config{
+		ServerToken: staticToken("read-secret"),
+		ViewerToken: staticToken("view-secret"),
+		HubTokens:   staticToken("alice=alice-token,bob=bob-token,carol=carol-token"),
+		HubTeams:    map[string]string{"alice": "platform", "bob": "platform"},
+	}
+	hub := &hubServer{config: config, state: newHubState()}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	setupHubRoutes(r, hub)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	pushes := map[string]float64{"alice": 80, "bob": 30, "carol": 10}
+	for user, percentage := range pushes {
+		pusher := newHubPusher()
+		quota := &FormattedQuota{Models: []FormattedModel{{Name: "glm", Percentage: percentage}}, LastUpdated: now.Unix()}
+		pusher.push(&Config{HubURL: server.URL, HubToken: staticToken(user + "-token")}, "glm", quota)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(hub.state.users(nil)) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/quota", nil)
+	req.Header.Set("Authorization", "Bearer read-secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		Users []HubUser `json:"users"`
+		Teams []HubTeam `json:"teams"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+
+	if len(body.Users) != 3 || body.Users[2].User != "carol" || body.Users[2].Team != "" {
+		t.Fatalf("Expected three users sorted by name, got %+v", body.Users)
+	}
+	if len(body.Teams) != 1 || len(body.Teams[0].Models) != 1 {
+		t.Fatalf("Expected one team with one model, got %+v", body.Teams)
+	}
+	model := body.Teams[0].Models[0]
+	if model.Lowest != 30 || model.Average != 55 || model.Members != 2 {
+		t.Errorf("Expected the platform team's lowest 30 and average 55, got %+v", model)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/metrics?token=read-secret", nil)
+	resp, _ = http.DefaultClient.Do(req)
+	var metrics bytes.Buffer
+	metrics.ReadFrom(resp.Body)
+	resp.Body.Close()
+	for _, want := range []string{
+		`quota_hub_percentage{user="bob",team="platform",provider="glm",model="glm"} 30`,
+		`quota_hub_team_lowest_percentage{team="platform",provider="glm",model="glm"} 30`,
+	} {
+		if !strings.Contains(metrics.String(), want) {
+			t.Errorf("Expected %q in metrics, got:\n%s", want, metrics.String())
+		}
+	}
+
+	resp, _ = http.Get(server.URL + "/quota?token=view-secret")
+	var view map[string]json.RawMessage
+	json.NewDecoder(resp.Body).Decode(&view)
+	resp.Body.Close()
+	if _, ok := view["users"]; ok || view["teams"] == nil {
+		t.Errorf("Expected viewers to see only the team aggregates, got %v", view)
+	}
+	resp, _ = http.Get(server.URL + "/metrics?token=view-secret")
+	metrics.Reset()
+	metrics.ReadFrom(resp.Body)
+	resp.Body.Close()
+	if strings.Contains(metrics.String(), `user="`) || !strings.Contains(metrics.String(), "quota_hub_team_lowest_percentage") {
+		t.Errorf("Expected viewers to get only team metrics, got:\n%s", metrics.String())
+	}
+
+	resp, _ = http.Get(server.URL + "/quota")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected reads without SERVER_TOKEN to be refused, got %d", resp.StatusCode)
+	}
+	req, _ = http.NewRequest(http.MethodPost, server.URL+"/push", strings.NewReader(`{"provider":"glm"}`))
+	req.Header.Set("Authorization", "Bearer stranger")
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected an unknown token to be refused, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"os"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+)
+
+// Message keys are the English text; other languages translate them in outputTranslations
+const (
+	msgTokenUsage5h       = "Token usage(5 Hour)"
+	msgMCPUsageMonthly    = "MCP usage(1 Month)"
+	msgVisionUsage        = "Vision usage"
+	msgVideoUsage         = "Video usage"
+	msgAnomalyWarning     = "using %s%%/h, usually %s±%s%%/h at this hour"
+	msgCostTokens         = "%s/%s: %s tokens in %sh"
+	msgCostNoPrice        = "%s, no price configured"
+	msgCostValueConsumed  = "%s, ~%s value consumed"
+	msgCostSpent          = "%s, ~%s spent"
+	msgNoTokenUsageSource = "%s does not report token usage"
+	msgDiffChange         = "%s/%s: %s%% -> %s%% (%s points since %s)"
+	msgDiffTokens         = "%s, %s tokens used"
+	msgDiffNoHistory      = "no quota history before %s; run the server with HISTORY_FILE set"
+	msgSessionStarted     = "Started session %s"
+	msgSessionStopped     = "Stopped session %s"
+	msgSummarySession     = "%s (%s - %s)"
+	msgSummaryOpenSession = "%s (%s - now, running)"
+	msgSummaryModel       = "  %s/%s: %s points used"
+	msgSummaryNoUsage     = "  no quota used"
+	msgSummaryPeriod      = "%s/%s: %s%% used by day %d; last month you were at %s%% by day %d"
+	msgAlertSubject       = "Quota alert: %s/%s at %s%%"
+	msgAlertBody          = "%s/%s has %s%% left (%s, threshold %d%%)."
+	msgAlertReset         = "Resets at %s."
+	msgEmailDigestSubject = "Quota digest, %s - %s"
+	msgEmailDigestHeading = "Quota used from %s to %s"
+	msgSilenceUntil       = "Alerts silenced until %s"
+	msgSilenceNone        = "Alerts are not silenced"
+	msgSilenceLifted      = "Alerts are no longer silenced"
+	msgHistoryVacuum      = "Kept %d of %d history lines (%s -> %s bytes)"
+	msgRequireBelow       = "%s/%s has %s%% left, below the required %s%%"
+	msgRequireResets      = "%s; resets in %s"
+	msgRequireNoModel     = "%s has no model matching %q"
+	msgWaitRetry          = "%s; checking again in %s"
+	msgWaitReady          = "%s/%s has at least %s%% left"
+	msgWaitTimeout        = "gave up after %s: %s"
+	msgGuardWarning       = "quota guard: %s"
+	msgGuardUnavailable   = "quota guard: %s quota unavailable: %s"
+	msgGuardConfirm       = "Run %s anyway? [y/N] "
+	msgGuardDeclined      = "not running %s"
+	msgGuardThisCommand   = "the command"
+	msgMCPPool            = "Monthly MCP pool: %s of %s calls used, %s remaining (%s%% used)"
+	msgMCPHeader          = "TOOL\tCALLS\tSHARE OF POOL"
+	msgMCPOther           = "(unattributed)"
+	msgMCPNoLimit         = "GLM reports no monthly MCP limit"
+)
+
+// outputLanguages are the languages with a catalog, the first being the fallback
+var outputLanguages = []language.Tag{language.English, language.SimplifiedChinese}
+
+// outputTranslations holds the non-English catalogs
+var outputTranslations = map[language.Tag]map[string]string{
+	language.SimplifiedChinese: {
+		msgTokenUsage5h:       "Token 用量（5 小时）",
+		msgMCPUsageMonthly:    "MCP 用量（1 个月）",
+		msgVisionUsage:        "视觉用量",
+		msgVideoUsage:         "视频用量",
+		msgAnomalyWarning:     "每小时消耗 %s%%，该时段通常为每小时 %s±%s%%",
+		msgCostTokens:         "%[1]s/%[2]s：%[4]s 小时内使用 %[3]s tokens",
+		msgCostNoPrice:        "%s，未配置价格",
+		msgCostValueConsumed:  "%s，约消耗价值 %s",
+		msgCostSpent:          "%s，约花费 %s",
+		msgNoTokenUsageSource: "%s 不提供 token 用量",
+		msgDiffChange:         "%[1]s/%[2]s：%[3]s%% -> %[4]s%%（自 %[6]s 起 %[5]s 个百分点）",
+		msgDiffTokens:         "%s，使用 %s tokens",
+		msgDiffNoHistory:      "%s 之前没有配额历史；请设置 HISTORY_FILE 运行服务",
+		msgSessionStarted:     "已开始会话 %s",
+		msgSessionStopped:     "已结束会话 %s",
+		msgSummarySession:     "%s（%s - %s）",
+		msgSummaryOpenSession: "%s（%s - 现在，进行中）",
+		msgSummaryModel:       "  %s/%s：使用 %s 个百分点",
+		msgSummaryNoUsage:     "  未使用配额",
+		msgSummaryPeriod:      "%[1]s/%[2]s：第 %[4]d 天已用 %[3]s%%；上月第 %[6]d 天为 %[5]s%%",
+		msgAlertSubject:       "配额提醒：%s/%s 剩余 %s%%",
+		msgAlertBody:          "%s/%s 剩余 %s%%（%s，阈值 %d%%）。",
+		msgAlertReset:         "将于 %s 重置。",
+		msgEmailDigestSubject: "配额摘要，%s - %s",
+		msgEmailDigestHeading: "%s 至 %s 的配额使用",
+		msgSilenceUntil:       "提醒已静音至 %s",
+		msgSilenceNone:        "提醒未静音",
+		msgSilenceLifted:      "已取消提醒静音",
+		msgHistoryVacuum:      "保留了 %d / %d 行历史记录（%s -> %s 字节）",
+		msgRequireBelow:       "%s/%s 剩余 %s%%，低于要求的 %s%%",
+		msgRequireResets:      "%s；%s 后重置",
+		msgRequireNoModel:     "%s 没有匹配 %q 的模型",
+		msgWaitRetry:          "%s；%s 后再次检查",
+		msgWaitReady:          "%s/%s 至少剩余 %s%%",
+		msgWaitTimeout:        "%s 后放弃：%s",
+		msgGuardWarning:       "配额保护：%s",
+		msgGuardUnavailable:   "配额保护：无法获取 %s 配额：%s",
+		msgGuardConfirm:       "仍要运行 %s 吗？[y/N] ",
+		msgGuardDeclined:      "未运行 %s",
+		msgGuardThisCommand:   "该命令",
+		msgMCPPool:            "每月 MCP 额度：已用 %s / %s 次，剩余 %s 次（已用 %s%%）",
+		msgMCPHeader:          "工具\t调用次数\t占额度比例",
+		msgMCPOther:           "（未归属）",
+		msgMCPNoLimit:         "GLM 未提供每月 MCP 限额",
+	},
+}
+
+var outputCatalog = buildOutputCatalog()
+
+// buildOutputCatalog registers the translations; English falls back to the keys themselves
+func buildOutputCatalog() catalog.Catalog {
+	builder := catalog.NewBuilder(catalog.Fallback(language.English))
+	for tag, messages := range outputTranslations {
+		for key, msg := range messages {
+			builder.SetString(tag, key, msg)
+		}
+	}
+	return builder
+}
+
+// resolveLanguage picks the catalog language for a locale such as "zh_CN.UTF-8", falling back
+// to LC_ALL, LC_MESSAGES, and LANG, then English
+func resolveLanguage(value string) language.Tag {
+	candidates := []string{trimQuotes(value), os.Getenv("LC_ALL"), os.Getenv("LC_MESSAGES"), os.Getenv("LANG")}
+	for _, candidate := range candidates {
+		if tag, ok := parseLocale(candidate); ok {
+			_, index, confidence := language.NewMatcher(outputLanguages).Match(tag)
+			if confidence == language.No {
+				return outputLanguages[0]
+			}
+			return outputLanguages[index]
+		}
+	}
+	return outputLanguages[0]
+}
+
+// localizer returns a printer that translates message keys into the output language
+func localizer(lang language.Tag) *message.Printer {
+	return message.NewPrinter(lang, message.Catalog(outputCatalog))
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestModelIcons(t *testing.T) {
+	t.Setenv("ICONS", "glm-coding-plan-*=🔌,glm=⚡,claude-ai=🤖")
+	quota := FormattedQuota{Models: []FormattedModel{
+		{Name: "glm", Percentage: 75, Provider: ProviderGLM},
+		{Name: "glm-coding-plan-mcp-monthly", Percentage: 90, Provider: ProviderGLM},
+		{Name: "glm:team", Percentage: 40, Provider: ProviderGLM, Account: "team"},
+	}}
+
+	output, _ := renderShort(quota)
+	if string(output) != "⚡ glm 75% · 🔌 mcp 90% · ⚡ glm:team 40%" {
+		t.Errorf("Expected icons by glob, then by provider, got %q", output)
+	}
+	if output, _ := renderStatusZAI(quota); string(output) != "⚡ \033[32m75%\033[0m" {
+		t.Errorf("Expected the GLM icon in the status, got %q", output)
+	}
+
+	// ASCII output drops glyphs and emoji for their fallbacks
+	t.Setenv("ASCII_OUTPUT", "true")
+	output, _ = renderShort(quota)
+	if string(output) != "glm 75% | mcp 90% | glm:team 40%" {
+		t.Errorf("Unexpected ASCII short output %q", output)
+	}
+	if output, _ := renderBadge(quota); string(output) != "[low] glm:team 40%" {
+		t.Errorf("Unexpected ASCII badge %q", output)
+	}
+	if output, _ := renderStatusZAI(quota); string(output) != "Z \033[32m75%\033[0m" {
+		t.Errorf("Expected the ASCII status icon, got %q", output)
+	}
+	claude := FormattedQuota{Models: []FormattedModel{{Name: "claude-ai-session", Percentage: 100, Provider: ProviderClaudeAI}}}
+	if output, _ := renderStatusClaude(claude); string(output) != "\033[32mC\033[0m" {
+		t.Errorf("Expected the ASCII Claude icon, got %q", output)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// Default API endpoints, overridable for EU accounts and proxies
+	DefaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+	DefaultOpsgenieURL  = "https://api.opsgenie.com"
+
+	// Minutes a provider that worked before may fail before an incident is opened, unless
+	// INCIDENT_GRACE is set
+	DefaultIncidentGrace = 15
+
+	// Longest wait for PagerDuty or Opsgenie to accept an event
+	incidentTimeout = 10 * time.Second
+)
+
+// Incident severities, mapped to PagerDuty severities and Opsgenie priorities
+const (
+	severityCritical = "critical"
+	severityError    = "error"
+)
+
+// Incident is an open problem reported to the escalation services, deduplicated by Key
+type Incident struct {
+	Key      string
+	Summary  string
+	Severity string
+	Details  map[string]string
+}
+
+// incidentManager opens incidents in serve mode when a model's quota reaches zero or a
+// provider that worked before stays unreachable past INCIDENT_GRACE, and resolves them when
+// that ends
+type incidentManager struct {
+	mu           sync.Mutex
+	open         map[string]bool
+	failingSince map[string]time.Time
+	client       *http.Client
+
+	// held are the incidents that became active during a silence, opened once it ends
+	held map[string]Incident
+}
+
+// newIncidentManager creates a manager with no open incidents
+func newIncidentManager() *incidentManager {
+	return &incidentManager{
+		open:         make(map[string]bool),
+		failingSince: make(map[string]time.Time),
+		client:       &http.Client{Timeout: incidentTimeout},
+		held:         make(map[string]Incident),
+	}
+}
+
+// incidentsEnabled reports whether PagerDuty or Opsgenie is configured
+func incidentsEnabled(config *Config) bool {
+	return config.PagerDutyKey != nil || config.OpsgenieKey != nil
+}
+
+// observe resolves the provider's outage after a successful fetch
+func (m *incidentManager) observe(config *Config, provider string) {
+	if !incidentsEnabled(config) {
+		return
+	}
+	m.mu.Lock()
+	delete(m.failingSince, provider)
+	m.mu.Unlock()
+	m.set(config, false, Incident{Key: "provider-down:" + provider})
+	m.release(config)
+}
+
+// alert opens an incident when a model matching INCIDENT_MODELS enters CRITICAL, and
+// resolves it when the model leaves it
+func (m *incidentManager) alert(config *Config, event HookEvent) {
+	if !incidentsEnabled(config) || event.Event == HookEventAnomaly {
+		return
+	}
+	if len(config.IncidentModels) > 0 && !matchesAnyGlob(event.Model, config.IncidentModels) {
+		return
+	}
+	m.set(config, event.Event == HookEventEmpty, Incident{
+		Key:      "quota-empty:" + burnKey(event.Provider, event.Model),
+		Summary:  fmt.Sprintf("%s/%s quota is exhausted", event.Provider, event.Model),
+		Severity: severityCritical,
+		Details:  map[string]string{"provider": event.Provider, "model": event.Model, "reset_time": event.ResetTime},
+	})
+}
+
+// observeError opens an incident when a provider has been failing for longer than
+// INCIDENT_GRACE. Providers that never succeeded, such as ones without credentials, are
+// left alone.
+func (m *incidentManager) observeError(config *Config, provider string, err error) {
+	if !incidentsEnabled(config) || providerHealth.get(provider).LastSuccess == 0 {
+		return
+	}
+	m.release(config)
+	now := clockNow()
+	m.mu.Lock()
+	since, failing := m.failingSince[provider]
+	if !failing {
+		since = now
+		m.failingSince[provider] = now
+	}
+	m.mu.Unlock()
+
+	if now.Sub(since) < time.Duration(config.IncidentGrace)*time.Minute {
+		return
+	}
+	m.set(config, true, Incident{
+		Key:      "provider-down:" + provider,
+		Summary:  fmt.Sprintf("%s quota is unavailable since %s", provider, since.Format(time.RFC3339)),
+		Severity: severityError,
+		Details:  map[string]string{"provider": provider, "error": err.Error()},
+	})
+}
+
+// set opens the incident when active and not yet open, and resolves it when inactive and open
+func (m *incidentManager) set(config *Config, active bool, incident Incident) {
+	m.mu.Lock()
+	if !active {
+		delete(m.held, incident.Key)
+	}
+	if m.open[incident.Key] == active {
+		m.mu.Unlock()
+		return
+	}
+	// Incidents are held while alerts are silenced, but are always resolved
+	if active && alertsSilenced(config, clockNow()) {
+		_, already := m.held[incident.Key]
+		m.held[incident.Key] = incident
+		m.mu.Unlock()
+		if !already {
+			log.Printf("Holding incident %s until the silence ends: %s", incident.Key, incident.Summary)
+		}
+		return
+	}
+	m.open[incident.Key] = active
+	m.mu.Unlock()
+
+	if active {
+		log.Printf("Opening incident %s: %s", incident.Key, incident.Summary)
+	} else {
+		log.Printf("Resolving incident %s", incident.Key)
+	}
+	if config.PagerDutyKey != nil {
+		go m.sendPagerDuty(config, active, incident)
+	}
+	if config.OpsgenieKey != nil {
+		go m.sendOpsgenie(config, active, incident)
+	}
+}
+
+// release opens the incidents held during a silence once it has ended. The alert state
+// machine does not report their transitions again, so without this a quota that ran out
+// overnight would never open one.
+func (m *incidentManager) release(config *Config) {
+	m.mu.Lock()
+	if len(m.held) == 0 || alertsSilenced(config, clockNow()) {
+		m.mu.Unlock()
+		return
+	}
+	held := m.held
+	m.held = make(map[string]Incident)
+	m.mu.Unlock()
+
+	for _, incident := range held {
+		m.set(config, true, incident)
+	}
+}
+
+// sendPagerDuty triggers or resolves an incident through the PagerDuty Events API v2
+func (m *incidentManager) sendPagerDuty(config *Config, active bool, incident Incident) {
+	key, err := readToken(context.Background(), config.PagerDutyKey)
+	if err != nil {
+		log.Printf("Failed to send incident %s to PagerDuty: failed to read PAGERDUTY_ROUTING_KEY: %v", incident.Key, err)
+		return
+	}
+	event := map[string]interface{}{
+		"routing_key":  key,
+		"event_action": "resolve",
+		"dedup_key":    incident.Key,
+	}
+	if active {
+		source, _ := os.Hostname()
+		event["event_action"] = "trigger"
+		event["payload"] = map[string]interface{}{
+			"summary":        incident.Summary,
+			"source":         source,
+			"severity":       incident.Severity,
+			"component":      "coding-plan-quota-query",
+			"custom_details": incident.Details,
+		}
+	}
+	if err := m.post(config.PagerDutyURL, "", event); err != nil {
+		log.Printf("Failed to send incident %s to PagerDuty: %v", incident.Key, err)
+	}
+}
+
+// sendOpsgenie creates or closes an alert through the Opsgenie Alert API, aliased by the
+// incident key
+func (m *incidentManager) sendOpsgenie(config *Config, active bool, incident Incident) {
+	key, err := readToken(context.Background(), config.OpsgenieKey)
+	if err != nil {
+		log.Printf("Failed to send incident %s to Opsgenie: failed to read OPSGENIE_API_KEY: %v", incident.Key, err)
+		return
+	}
+	endpoint := config.OpsgenieURL + "/v2/alerts/" + url.PathEscape(incident.Key) + "/close?identifierType=alias"
+	body := map[string]interface{}{"source": "coding-plan-quota-query"}
+	if active {
+		priority := "P2"
+		if incident.Severity == severityCritical {
+			priority = "P1"
+		}
+		endpoint = config.OpsgenieURL + "/v2/alerts"
+		body = map[string]interface{}{
+			"message":  incident.Summary,
+			"alias":    incident.Key,
+			"priority": priority,
+			"source":   "coding-plan-quota-query",
+			"details":  incident.Details,
+		}
+	}
+	if err := m.post(endpoint, "GenieKey "+key, body); err != nil {
+		log.Printf("Failed to send incident %s to Opsgenie: %v", incident.Key, err)
+	}
+}
+
+// post sends a JSON body, with an Authorization header when given
+func (m *incidentManager) post(endpoint, authorization string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, errorBody(resp.Body))
+	}
+	return nil
+}
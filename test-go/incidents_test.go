@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type incidentRequest struct {
+	path          string
+	authorization string
+	body          map[string]interface{}
+}
+
+func TestIncidents(t *testing.T) {
+	clk := &fakeClock{t: time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)}
+	defer setClock(clk)()
+
+	requests := make(chan incidentRequest, 8)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		requests <- incidentRequest{r.URL.RequestURI(), r.Header.Get("Authorization"), body}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+	next := func() incidentRequest {
+		select {
+		case req := <-requests:
+			return req
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected an incident request")
+			return incidentRequest{}
+		}
+	}
+
+	config := &Config{PagerDutyKey: staticToken("routing"), PagerDutyURL: srv.URL + "/v2/enqueue", IncidentGrace: 15}
+	if !hooksEnabled(config) {
+		t.Error("Expected incidents to enable polling")
+	}
+	runner := NewHookRunner()
+	runner.run = func(string, HookEvent) {}
+	manager := runner.incidents
+
+	runner.observe(config, "glm", &FormattedQuota{Models: []FormattedModel{{Name: "glm-5", Percentage: 0}}})
+	req := next()
+	if req.body["event_action"] != "trigger" || req.body["dedup_key"] != "quota-empty:glm/glm-5" || req.body["routing_key"] != "routing" {
+		t.Errorf("Unexpected trigger: %+v", req)
+	}
+	if payload, _ := req.body["payload"].(map[string]interface{}); payload["severity"] != severityCritical {
+		t.Errorf("Expected a critical incident, got %v", req.body["payload"])
+	}
+
+	// Still empty: no duplicate, then the quota resets
+	runner.observe(config, "glm", &FormattedQuota{Models: []FormattedModel{{Name: "glm-5", Percentage: 0}}})
+	runner.observe(config, "glm", &FormattedQuota{Models: []FormattedModel{{Name: "glm-5", Percentage: 100}}})
+	if req := next(); req.body["event_action"] != "resolve" || req.body["dedup_key"] != "quota-empty:glm/glm-5" {
+		t.Errorf("Unexpected resolve: %+v", req)
+	}
+
+	// Opsgenie alerts for a provider unreachable past the grace window
+	config = &Config{OpsgenieKey: staticToken("genie"), OpsgenieURL: srv.URL, IncidentGrace: 15}
+	failure := errors.New("connection refused")
+	manager.observeError(config, "incident-never-worked", failure)
+	clk.Advance(time.Hour)
+	manager.observeError(config, "incident-never-worked", failure)
+
+	providerHealth.recordFetch("incident-provider", time.Millisecond)
+	manager.observeError(config, "incident-provider", failure)
+	clk.Advance(10 * time.Minute)
+	manager.observeError(config, "incident-provider", failure)
+	select {
+	case req := <-requests:
+		t.Fatalf("Expected no incident within the grace window, got %+v", req)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	clk.Advance(5 * time.Minute)
+	manager.observeError(config, "incident-provider", failure)
+	req = next()
+	if req.path != "/v2/alerts" || req.authorization != "GenieKey genie" || req.body["alias"] != "provider-down:incident-provider" || req.body["priority"] != "P2" {
+		t.Errorf("Unexpected Opsgenie alert: %+v", req)
+	}
+
+	manager.observe(config, "incident-provider")
+	if req := next(); req.path != "/v2/alerts/provider-down:incident-provider/close?identifierType=alias" {
+		t.Errorf("Unexpected Opsgenie close: %+v", req)
+	}
+}
+
+func TestIncidentsHeldDuringSilence(t *testing.T) {
+	clk := &fakeClock{t: time.Date(2026, 10, 15, 2, 0, 0, 0, time.UTC)}
+	defer setClock(clk)()
+
+	requests := make(chan map[string]interface{}, 8)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		requests <- body
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	silenceFile := filepath.Join(t.TempDir(), "silence.json")
+	data, _ := json.Marshal(Silence{Until: clk.Now().Add(time.Hour).Unix()})
+	os.WriteFile(silenceFile, data, 0600)
+	config := &Config{PagerDutyKey: staticToken("routing"), PagerDutyURL: srv.URL, SilenceFile: silenceFile}
+	runner := NewHookRunner()
+	runner.run = func(string, HookEvent) {}
+
+	empty := &FormattedQuota{Models: []FormattedModel{{Name: "glm-5", Percentage: 0}}}
+	runner.observe(config, "glm", empty)
+	runner.observe(config, "glm", empty)
+	select {
+	case body := <-requests:
+		t.Fatalf("Expected no incident during the silence, got %+v", body)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// The model stays empty, so no transition fires, but the held incident opens
+	clk.Advance(2 * time.Hour)
+	runner.observe(config, "glm", empty)
+	select {
+	case body := <-requests:
+		if body["event_action"] != "trigger" || body["dedup_key"] != "quota-empty:glm/glm-5" {
+			t.Errorf("Unexpected event after the silence: %+v", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the held incident to open after the silence")
+	}
+
+	// A held incident whose cause cleared during the silence is dropped
+	os.WriteFile(silenceFile, []byte(`{"until":`+strconv.FormatInt(clk.Now().Add(time.Hour).Unix(), 10)+`}`), 0600)
+	runner.observe(config, "glm", &FormattedQuota{Models: []FormattedModel{{Name: "glm-4", Percentage: 0}}})
+	runner.observe(config, "glm", &FormattedQuota{Models: []FormattedModel{{Name: "glm-4", Percentage: 100}}})
+	clk.Advance(2 * time.Hour)
+	runner.observe(config, "glm", &FormattedQuota{Models: []FormattedModel{{Name: "glm-4", Percentage: 100}}})
+	select {
+	case body := <-requests:
+		t.Errorf("Expected the cleared incident to be dropped, got %+v", body)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joho/godotenv"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInitAuto(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	for _, name := range []string{"ZAI_AUTH_TOKEN", "ZHIPU_AUTH_TOKEN", "GROQ_API_KEY", "MISTRAL_API_KEY", "XAI_API_KEY"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("CODEX_MISTRAL_KEY", "mistral-from-codex")
+
+	writeTestFile(t, filepath.Join(home, ".claude", "settings.json"),
+		`{"env": {"ANTHROPIC_BASE_URL": "https://api.z.ai/api/anthropic", "ANTHROPIC_AUTH_TOKEN": "zai-key"}}`)
+	writeTestFile(t, filepath.Join(home, ".aider.conf.yml"), `# aider settings
+model: groq/llama-3.3-70b-versatile
+api-key:
+  - groq=groq-key
+  - gemini=ignored
+set-env: [XAI_API_KEY=xai-key]
+openai-api-key: "zhipu-key"
+openai-api-base: https://open.bigmodel.cn/api/paas/v4
+`)
+	writeTestFile(t, filepath.Join(home, ".openhands", "settings.json"),
+		`{"llm_model": "groq/other", "llm_api_key": "groq-from-openhands"}`)
+	writeTestFile(t, filepath.Join(home, ".codex", "config.toml"), `model = "mistral-large"
+
+[model_providers.mistral]
+name = "Mistral"
+base_url = "https://api.mistral.ai/v1"
+env_key = "CODEX_MISTRAL_KEY"
+`)
+
+	envFile := filepath.Join(t.TempDir(), ".env")
+	writeTestFile(t, envFile, "XAI_API_KEY=existing\n")
+
+	var out bytes.Buffer
+	if err := runInitCommand([]string{"--auto", "--env-file", envFile, "--dry-run"}, strings.NewReader(""), &out); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(envFile); string(data) != "XAI_API_KEY=existing\n" {
+		t.Errorf("Expected --dry-run to leave the env file alone, got %q", data)
+	}
+	if strings.Contains(out.String(), "-key") {
+		t.Errorf("Expected values never to be printed, got %q", out.String())
+	}
+
+	out.Reset()
+	if err := runInitCommand([]string{"--auto", "--env-file", envFile}, strings.NewReader(""), &out); err != nil {
+		t.Fatal(err)
+	}
+	values, err := godotenv.Read(envFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"ZAI_AUTH_TOKEN":   "zai-key",
+		"ZHIPU_AUTH_TOKEN": "zhipu-key",
+		"GROQ_API_KEY":     "groq-key",
+		"MISTRAL_API_KEY":  "mistral-from-codex",
+		"XAI_API_KEY":      "existing",
+	}
+	if len(values) != len(want) {
+		t.Errorf("Unexpected env file %v", values)
+	}
+	for name, value := range want {
+		if values[name] != value {
+			t.Errorf("Expected %s=%q, got %q", name, value, values[name])
+		}
+	}
+	if !strings.Contains(out.String(), "Skipping XAI_API_KEY") || !strings.Contains(out.String(), "Added 4 variables") {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+}
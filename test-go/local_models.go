@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LocalModelInfo describes a model served by a local runtime
+type LocalModelInfo struct {
+	Loaded    bool  `json:"loaded"`
+	SizeBytes int64 `json:"size_bytes"`
+	VRAMBytes int64 `json:"vram_bytes,omitempty"`
+}
+
+// ollamaModel is an entry of the Ollama /api/tags and /api/ps responses
+type ollamaModel struct {
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	SizeVRAM int64  `json:"size_vram"`
+}
+
+// ollamaModelList is the Ollama /api/tags and /api/ps response
+type ollamaModelList struct {
+	Models []ollamaModel `json:"models"`
+}
+
+// ollamaBaseURL normalizes OLLAMA_HOST, which Ollama also accepts without a scheme
+func ollamaBaseURL(host string) string {
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	return strings.TrimRight(host, "/")
+}
+
+// queryLocalModels returns the installed models with load state and VRAM use
+func queryLocalModels(ctx context.Context, baseURL string) (map[string]LocalModelInfo, error) {
+	var installed, running ollamaModelList
+	if err := doJSON(ctx, ProviderLocal, "GET", baseURL+"/api/tags", nil, nil, &installed); err != nil {
+		return nil, err
+	}
+	if err := doJSON(ctx, ProviderLocal, "GET", baseURL+"/api/ps", nil, nil, &running); err != nil {
+		return nil, err
+	}
+
+	models := make(map[string]LocalModelInfo)
+	for _, model := range installed.Models {
+		models[model.Name] = LocalModelInfo{SizeBytes: model.Size}
+	}
+	for _, model := range running.Models {
+		info := models[model.Name]
+		info.Loaded = true
+		info.VRAMBytes = model.SizeVRAM
+		if info.SizeBytes == 0 {
+			info.SizeBytes = model.Size
+		}
+		models[model.Name] = info
+	}
+	return models, nil
+}
+
+// FormatLocalModels formats local models to match antigravity quota format.
+// Local models have no quota, so every entry is at 100%.
+func FormatLocalModels(models map[string]LocalModelInfo, only []string) FormattedQuota {
+	config := LoadConfig()
+
+	var formatted []FormattedModel
+	for name, info := range models {
+		if len(only) > 0 && !containsString(only, name) {
+			continue
+		}
+		info := info
+		formatted = append(formatted, FormattedModel{
+			Name:       "local-" + name,
+			Percentage: QuotaFull,
+			Local:      &info,
+		})
+	}
+
+	formatted = applyModelAliases(formatted, config.ModelAliases)
+
+	sort.Slice(formatted, func(i, j int) bool {
+		return formatted[i].Name < formatted[j].Name
+	})
+
+	return FormattedQuota{
+		Models:      formatted,
+		IsForbidden: false,
+	}
+}
+
+// GetLocalModels reports the models of the local Ollama runtime as always available
+func GetLocalModels(ctx context.Context) (FormattedQuota, error) {
+	config := LoadConfig()
+	baseURL := ollamaBaseURL(config.OllamaHost)
+
+	data, fetchedAt, err := cachedFetch(ctx, providerCache, ProviderLocal, responseCacheKey(ProviderLocal, "", "", baseURL+"/api/tags"), func() (interface{}, error) {
+		return queryLocalModels(ctx, baseURL)
+	})
+	if err != nil {
+		return FormattedQuota{}, fmt.Errorf("local runtime unavailable: %w", err)
+	}
+
+	quota := FormatLocalModels(data.(map[string]LocalModelInfo), config.LocalModels)
+	stampQuotaTime(&quota, fetchedAt)
+	return quota, nil
+}
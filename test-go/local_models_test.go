@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOllamaBaseURL(t *testing.T) {
+	tests := map[string]string{
+		"http://localhost:11434": "http://localhost:11434",
+		"127.0.0.1:11434":        "http://127.0.0.1:11434",
+		"https://gpu-box:11434/": "https://gpu-box:11434",
+	}
+	for host, expected := range tests {
+		if got := ollamaBaseURL(host); got != expected {
+			t.Errorf("ollamaBaseURL(%q) = %q, expected %q", host, got, expected)
+		}
+	}
+}
+
+func TestGetLocalModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			fmt.Fprint(w, `{"models":[{"name":"qwen3:8b","size":5200000000},{"name":"llama3.2:3b","size":2000000000}]}`)
+		case "/api/ps":
+			fmt.Fprint(w, `{"models":[{"name":"qwen3:8b","size":6000000000,"size_vram":6000000000}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("OLLAMA_HOST", strings.TrimPrefix(server.URL, "http://"))
+
+	quota, err := GetLocalModels(context.Background())
+	if err != nil {
+		t.Fatalf("GetLocalModels failed: %v", err)
+	}
+	if len(quota.Models) != 2 {
+		t.Fatalf("Expected 2 models, got %+v", quota.Models)
+	}
+
+	llama, qwen := quota.Models[0], quota.Models[1]
+	if llama.Name != "local-llama3.2:3b" || llama.Percentage != 100 || llama.Local.Loaded {
+		t.Errorf("Unexpected unloaded model: %+v %+v", llama, llama.Local)
+	}
+	if !qwen.Local.Loaded || qwen.Local.VRAMBytes != 6000000000 || qwen.Local.SizeBytes != 5200000000 {
+		t.Errorf("Unexpected loaded model info: %+v", qwen.Local)
+	}
+}
+
+func TestFormatLocalModelsOnly(t *testing.T) {
+	quota := FormatLocalModels(map[string]LocalModelInfo{"a": {}, "b": {}}, []string{"b"})
+	if len(quota.Models) != 1 || quota.Models[0].Name != "local-b" {
+		t.Errorf("Expected only local-b, got %+v", quota.Models)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+func TestFormatCurrency(t *testing.T) {
+	tests := []struct {
+		locale   language.Tag
+		amount   float64
+		currency string
+		expected string
+	}{
+		{language.English, 12.5, "CNY", "¥12.50"},
+		{language.English, 3, "usd", "$3.00"},
+		{language.English, -1.25, "USD", "-$1.25"},
+		{language.English, 7, "CHF", "7.00 CHF"},
+		{language.English, 1234567.5, "USD", "$1,234,567.50"},
+		{language.SimplifiedChinese, 1234.5, "CNY", "¥1,234.50"},
+		{language.German, 1234.5, "EUR", "1.234,50 €"},
+		{language.German, -3, "USD", "-3,00 $"},
+	}
+
+	for _, tt := range tests {
+		if got := formatCurrency(tt.locale, tt.amount, tt.currency); got != tt.expected {
+			t.Errorf("formatCurrency(%s, %v, %q) = %q, expected %q", tt.locale, tt.amount, tt.currency, got, tt.expected)
+		}
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	if got := formatNumber(language.English, 2500000); got != "2,500,000" {
+		t.Errorf("Expected 2,500,000, got %s", got)
+	}
+	if got := formatNumber(language.German, 2500000); got != "2.500.000" {
+		t.Errorf("Expected 2.500.000, got %s", got)
+	}
+}
+
+func TestResolveNumberLocale(t *testing.T) {
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_NUMERIC", "")
+	t.Setenv("LANG", "de_DE.UTF-8")
+
+	if got := resolveNumberLocale("zh_CN.UTF-8"); got != language.MustParse("zh-CN") {
+		t.Errorf("Expected zh-CN from the override, got %s", got)
+	}
+	if got := resolveNumberLocale(""); got != language.MustParse("de-DE") {
+		t.Errorf("Expected de-DE from LANG, got %s", got)
+	}
+
+	t.Setenv("LANG", "C.UTF-8")
+	if got := resolveNumberLocale(""); got != language.English {
+		t.Errorf("Expected English for the C locale, got %s", got)
+	}
+}
+
+func TestResolveLanguage(t *testing.T) {
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+	t.Setenv("LANG", "zh_TW.UTF-8")
+
+	if got := resolveLanguage("en_GB"); got != language.English {
+		t.Errorf("Expected English from the override, got %s", got)
+	}
+	if got := resolveLanguage(""); got != language.SimplifiedChinese {
+		t.Errorf("Expected Chinese from LANG, got %s", got)
+	}
+
+	t.Setenv("LANG", "ja_JP.UTF-8")
+	if got := resolveLanguage(""); got != language.English {
+		t.Errorf("Expected English for an unsupported language, got %s", got)
+	}
+}
+
+func TestLocalizer(t *testing.T) {
+	if got := localizer(language.SimplifiedChinese).Sprintf(msgTokenUsage5h); got != "Token 用量（5 小时）" {
+		t.Errorf("Unexpected Chinese label: %s", got)
+	}
+	if got := localizer(language.English).Sprintf(msgMCPUsageMonthly); got != msgMCPUsageMonthly {
+		t.Errorf("Unexpected English label: %s", got)
+	}
+}
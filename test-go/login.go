@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// How long login waits for the browser to return to the callback
+const loginTimeout = 5 * time.Minute
+
+// Google OAuth scopes requested by the Antigravity IDE
+var antigravityScopes = []string{
+	"https://www.googleapis.com/auth/cloud-platform",
+	"https://www.googleapis.com/auth/userinfo.email",
+	"https://www.googleapis.com/auth/userinfo.profile",
+	"https://www.googleapis.com/auth/cclog",
+	"https://www.googleapis.com/auth/experimentsandconfigs",
+}
+
+// randomURLString returns n random bytes, base64url-encoded
+func randomURLString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// authorizationURL builds the Google consent URL for an authorization code with PKCE
+func authorizationURL(config *Config, redirectURI, state, verifier string) string {
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"client_id":             {config.ClientID},
+		"redirect_uri":          {redirectURI},
+		"response_type":         {"code"},
+		"scope":                 {strings.Join(antigravityScopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+		"access_type":           {"offline"},
+		"prompt":                {"consent"},
+	}
+	return config.AuthURL + "?" + query.Encode()
+}
+
+// receiveAuthCode serves the OAuth redirect on listener until it brings a code for state
+func receiveAuthCode(ctx context.Context, listener net.Listener, state string) (string, error) {
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch {
+		case query.Get("state") != state:
+			http.Error(w, "Login failed: state mismatch", http.StatusBadRequest)
+			return
+		case query.Get("error") != "":
+			http.Error(w, "Login failed: "+query.Get("error"), http.StatusBadRequest)
+			results <- result{err: fmt.Errorf("authorization denied: %s", query.Get("error"))}
+		case query.Get("code") == "":
+			http.Error(w, "Login failed: no code", http.StatusBadRequest)
+			return
+		default:
+			fmt.Fprintln(w, "Login complete. You can close this window.")
+			results <- result{code: query.Get("code")}
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	select {
+	case res := <-results:
+		return res.code, res.err
+	case <-ctx.Done():
+		return "", fmt.Errorf("no response from the browser: %w", ctx.Err())
+	}
+}
+
+// exchangeCode trades an authorization code for access and refresh tokens
+func exchangeCode(ctx context.Context, config *Config, code, redirectURI, verifier string) (*TokenResponse, error) {
+	clientSecret, err := readToken(ctx, config.ClientSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CLIENT_SECRET: %w", err)
+	}
+	form := url.Values{
+		"client_id":     {config.ClientID},
+		"client_secret": {clientSecret},
+		"code":          {code},
+		"code_verifier": {verifier},
+		"redirect_uri":  {redirectURI},
+		"grant_type":    {"authorization_code"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body := errorBody(resp.Body)
+		return nil, fmt.Errorf("code exchange failed: %d - %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var token TokenResponse
+	if err := decodeResponse(resp, &token); err != nil {
+		return nil, err
+	}
+	if token.RefreshToken == "" {
+		return nil, errors.New("code exchange returned no refresh token")
+	}
+	return &token, nil
+}
+
+// loginAntigravity runs the browser OAuth flow on listener and returns the new account.
+// open is given the consent URL.
+func loginAntigravity(ctx context.Context, client *CloudCodeClient, listener net.Listener, open func(string) error) (*Account, error) {
+	config := client.Config()
+	if config.ClientID == "" || config.ClientSecret == nil {
+		return nil, errors.New("CLIENT_ID and CLIENT_SECRET are not set (see .env.example)")
+	}
+
+	state, err := randomURLString(16)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomURLString(32)
+	if err != nil {
+		return nil, err
+	}
+	redirectURI := fmt.Sprintf("http://localhost:%d/oauth-callback", listener.Addr().(*net.TCPAddr).Port)
+
+	if err := open(authorizationURL(config, redirectURI, state, verifier)); err != nil {
+		return nil, err
+	}
+	code, err := receiveAuthCode(ctx, listener, state)
+	if err != nil {
+		return nil, err
+	}
+	token, err := exchangeCode(ctx, config, code, redirectURI, verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	expiry := clockNow().Unix() + int64(token.ExpiresIn)
+	projectID, _ := client.GetProjectID(ctx, token.AccessToken)
+	return &Account{
+		Token: &TokenData{
+			AccessToken:     token.AccessToken,
+			RefreshToken:    token.RefreshToken,
+			ExpiryTimestamp: &expiry,
+			ProjectID:       projectID,
+			ExpiresIn:       token.ExpiresIn,
+			TokenType:       token.TokenType,
+		},
+		AccessToken: token.AccessToken,
+		Type:        "antigravity",
+		Expired:     time.Unix(expiry, 0).Format(time.RFC3339),
+	}, nil
+}
+
+// openBrowser opens url in the default browser
+func openBrowser(url string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url).Start()
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	default:
+		return exec.Command("xdg-open", url).Start()
+	}
+}
+
+// runLoginCommand signs in to a provider in the browser and saves its credentials
+func runLoginCommand(args []string, stdout io.Writer) error {
+	provider := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		provider, args = args[0], args[1:]
+	}
+	flags := flag.NewFlagSet("login", flag.ContinueOnError)
+	noBrowser := flags.Bool("no-browser", false, "print the sign-in URL instead of opening a browser")
+	port := flags.Int("port", 0, "local port for the OAuth redirect (default: any free port)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if provider == "" {
+		provider = flags.Arg(0)
+	}
+
+	if provider != ProviderAntigravity {
+		if check, ok := doctorProviders[provider]; ok {
+			return fmt.Errorf("%s has no sign-in flow: %s", provider, check.fix)
+		}
+		return fmt.Errorf("usage: login %s [--no-browser] [--port n]", ProviderAntigravity)
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", *port))
+	if err != nil {
+		return fmt.Errorf("cannot listen for the OAuth redirect: %w", err)
+	}
+	defer listener.Close()
+
+	open := func(url string) error {
+		fmt.Fprintf(stdout, "Sign in at:\n\n  %s\n\n", url)
+		if !*noBrowser {
+			if err := openBrowser(url); err != nil {
+				fmt.Fprintf(stdout, "Could not open a browser (%v); open the URL above.\n", err)
+			}
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), loginTimeout)
+	defer cancel()
+	client := NewCloudCodeClient(LoadConfig())
+	account, err := loginAntigravity(ctx, client, listener, open)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(client.Config().AccountFile), 0700); err != nil {
+		return err
+	}
+	if err := client.saveAccount(account); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Saved Antigravity credentials to %s\n", client.Config().AccountFile)
+	return nil
+}
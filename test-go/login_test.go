@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoginAntigravity(t *testing.T) {
+	var challenge string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			r.ParseForm()
+			sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+			if r.Form.Get("code") != "auth-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"access_token":"access","expires_in":3600,"token_type":"Bearer","refresh_token":"refresh"}`))
+		case "/project":
+			w.Write([]byte(`{"cloudaicompanionproject":"project-1"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewCloudCodeClient(&Config{
+		AuthURL:       server.URL + "/auth",
+		TokenURL:      server.URL + "/token",
+		ProjectAPIURL: server.URL + "/project",
+		ClientID:      "id",
+		ClientSecret:  staticToken("secret"),
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer listener.Close()
+
+	// The browser consents and follows the redirect
+	browser := func(consentURL string) error {
+		consent, _ := url.Parse(consentURL)
+		query := consent.Query()
+		challenge = query.Get("code_challenge")
+		if query.Get("access_type") != "offline" || !strings.Contains(query.Get("scope"), "cloud-platform") {
+			t.Errorf("Unexpected consent URL %s", consentURL)
+		}
+		go http.Get(query.Get("redirect_uri") + "?code=auth-code&state=" + url.QueryEscape(query.Get("state")))
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	account, err := loginAntigravity(ctx, client, listener, browser)
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if account.Token.AccessToken != "access" || account.Token.RefreshToken != "refresh" || account.Token.ProjectID != "project-1" {
+		t.Errorf("Unexpected account %+v", account.Token)
+	}
+}
+
+func TestReceiveAuthCodeStateMismatch(t *testing.T) {
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer listener.Close()
+	redirect := "http://" + listener.Addr().String() + "/oauth-callback"
+
+	go func() {
+		http.Get(redirect + "?code=forged&state=other")
+		http.Get(redirect + "?error=access_denied&state=expected")
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := receiveAuthCode(ctx, listener, "expected"); err == nil || !strings.Contains(err.Error(), "access_denied") {
+		t.Errorf("Expected the forged code to be ignored and the denial reported, got %v", err)
+	}
+}
+
+func TestRunLoginCommandUnsupported(t *testing.T) {
+	if err := runLoginCommand([]string{ProviderCursor}, nil); err == nil || !strings.Contains(err.Error(), "CURSOR_SESSION_TOKEN") {
+		t.Errorf("Expected the cursor setup hint, got %v", err)
+	}
+}
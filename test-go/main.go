@@ -1,40 +1,292 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"sync"
 
-	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"google.golang.org/grpc"
 )
 
+const usage = `Usage: coding-plan-quota-query [--profile name] [--debug-http[=file]] [--ascii] [command]
+
+Commands:
+  serve [--listen host:port|unix:/path] [--tls-cert f --tls-key f [--tls-client-ca f]]
+                                      Run the HTTP (and optional gRPC) server (default)
+  service install [serve options]|uninstall|status
+                                      Manage the server as a systemd user unit, launchd agent, or Windows service
+  init [--auto [--dry-run]] [--env-file .env]
+                                      Set up providers, the service, and a status bar segment step by step, or with
+                                      --auto add keys found in Claude Code, aider, OpenHands, and Codex CLI configs
+  login antigravity [--no-browser] [--port n]
+                                      Sign in with Google in the browser and save the account file
+  auth set <provider> [--env-file .env]
+                                      Replace a provider's key with the first line of stdin and retry it at once
+  show [--provider p] [--format text|short|badge|status|...|template] [--template '{{...}}']
+       [--sort name|percentage|provider] [--group provider|account] [--via-daemon|--direct] [--dry-run]
+                                      Print a provider's quota in an output format or text/template,
+                                      from the running server when there is one; --format prompt [--refresh]
+                                      reads only the disk cache, refreshing it in the background; --dry-run
+                                      prints the requests and cache keys it would use without sending them
+  route [--providers p1,p2] [--json|--emit-env]
+                                      Print the provider with the most quota headroom, or shell exports for it
+  require --model m [--min 15] [--provider p]
+                                      Exit non-zero when a model has less quota left than --min percent
+  wait --model m [--min 30] [--timeout 6h] [--provider p]
+                                      Block until a model has at least --min percent left
+  guard [--threshold 5] [--provider p] [--model m] [--commands 'claude|aider|codex'] [--block] [-- command line]
+                                      Warn, or with --block ask, before a guarded command starts while a model
+                                      has less than --threshold percent left, for shell preexec hooks
+  mcp [--json]                        Print the monthly GLM MCP call pool used by each tool
+  cost [--providers p1,p2] [--json]   Estimate spend or consumed plan value from token usage
+  diff [--since 1h] [--providers p1,p2] [--json]
+                                      Print quota used per model since the closest history snapshot
+  mark start <name> | mark stop       Label a usage session in the history
+  summary [--by-session] [--since 24h] [--json]
+                                      Print quota used per model, or per labeled session
+  --stdio                             Answer JSON-RPC (getQuota, subscribe) on stdin and stdout for editor plugins
+  silence [<duration> [reason] | off]
+                                      Hold alert notifications for a while, e.g. silence 2h, or show the silence
+  history vacuum [--json]             Compact HISTORY_FILE to daily rollups past HISTORY_RETENTION days
+  hub [--listen addr] [--tls-cert f --tls-key f]
+                                      Run a team hub that aggregates quota pushed by servers with HUB_URL
+  debug dump [--output file.zip]      Write redacted raw responses, parsed quota, and config to a zip
+  doctor                              Check configuration, credentials, network, and clock, with fixes
+  tray                                Show the worst-model percentage in the system tray (Windows)
+  help                                Show this help
+
+Profiles bundle settings as PROFILE_<NAME>_<VARIABLE>=value lines in .env and are chosen
+with --profile <name> before the command or QUOTA_PROFILE.
+
+--ascii keeps output to ASCII, replacing emoji and Nerd Font icons (as ASCII_OUTPUT=true).
+--debug-http logs every provider request with redacted headers, status, DNS/connect/TLS
+timings, and the first DEBUG_HTTP_BODY bytes (default 1024) of the response to stderr, or
+appends to a file with --debug-http=file.
+`
+
 func main() {
 	// Load .env file
 	if err := godotenv.Load("../.env"); err != nil {
 		log.Printf("Warning: .env file not found: %v", err)
 	}
 
-	// Get port from environment
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8000"
+	profile, args := profileFromArgs(os.Args[1:])
+	if profile != "" {
+		if err := applyProfile(profile); err != nil {
+			log.Fatalf("profile: %v", err)
+		}
+	}
+	args = asciiFromArgs(args)
+	// Prompt segments only read the disk cache, so they skip loading secrets and the network,
+	// and --debug-http has nothing to trace for them
+	if _, segment := debugHTTPFromArgs(args); isPromptSegment(segment) {
+		if err := runPromptSegment(segment[1:], os.Stdout); err != nil {
+			log.Fatalf("show: %v", err)
+		}
+		return
+	}
+	applyNetworkConfig(LoadConfig())
+	httpTransport = newProviderTransport()
+	trace, args := debugHTTPFromArgs(args)
+	if trace != "" {
+		if err := enableHTTPTrace(trace); err != nil {
+			log.Fatalf("debug-http: %v", err)
+		}
 	}
 
-	// Validate port
-	if _, err := strconv.Atoi(port); err != nil {
-		log.Fatalf("Invalid PORT value: %s", port)
+	command := "serve"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		if err := runServer(args); err != nil {
+			log.Fatalf("serve: %v", err)
+		}
+	case "service":
+		if err := runServiceCommand(args); err != nil {
+			log.Fatalf("service: %v", err)
+		}
+	case "init":
+		if err := runInitCommand(args, os.Stdin, os.Stdout); err != nil {
+			log.Fatalf("init: %v", err)
+		}
+	case "login":
+		if err := runLoginCommand(args, os.Stdout); err != nil {
+			log.Fatalf("login: %v", err)
+		}
+	case "auth":
+		if err := runAuthCommand(args, os.Stdin, os.Stdout); err != nil {
+			log.Fatalf("auth: %v", err)
+		}
+	case "show":
+		if err := runShowCommand(args, os.Stdout); err != nil {
+			log.Fatalf("show: %v", err)
+		}
+	case "route":
+		if err := runRouteCommand(args, os.Stdout); err != nil {
+			log.Fatalf("route: %v", err)
+		}
+	case "require":
+		if err := runRequireCommand(args); err != nil {
+			log.Fatalf("require: %v", err)
+		}
+	case "guard":
+		if err := runGuardCommand(args, os.Stdin, os.Stderr); err != nil {
+			log.Fatalf("guard: %v", err)
+		}
+	case "wait":
+		if err := runWaitCommand(args, os.Stdout); err != nil {
+			log.Fatalf("wait: %v", err)
+		}
+	case "mcp":
+		if err := runMCPCommand(args, os.Stdout); err != nil {
+			log.Fatalf("mcp: %v", err)
+		}
+	case "cost":
+		if err := runCostCommand(args, os.Stdout); err != nil {
+			log.Fatalf("cost: %v", err)
+		}
+	case "diff":
+		if err := runDiffCommand(args, os.Stdout); err != nil {
+			log.Fatalf("diff: %v", err)
+		}
+	case "mark":
+		if err := runMarkCommand(args, os.Stdout); err != nil {
+			log.Fatalf("mark: %v", err)
+		}
+	case "silence":
+		if err := runSilenceCommand(args, os.Stdout); err != nil {
+			log.Fatalf("silence: %v", err)
+		}
+	case "stdio", "--stdio":
+		if err := runStdioCommand(os.Stdin, os.Stdout); err != nil {
+			log.Fatalf("stdio: %v", err)
+		}
+	case "summary":
+		if err := runSummaryCommand(args, os.Stdout); err != nil {
+			log.Fatalf("summary: %v", err)
+		}
+	case "hub":
+		if err := runHubCommand(args); err != nil {
+			log.Fatalf("hub: %v", err)
+		}
+	case "history":
+		if err := runHistoryCommand(args, os.Stdout); err != nil {
+			log.Fatalf("history: %v", err)
+		}
+	case "debug":
+		if err := runDebugCommand(args, os.Stdout); err != nil {
+			log.Fatalf("debug: %v", err)
+		}
+	case "doctor":
+		if err := runDoctorCommand(os.Stdout); err != nil {
+			log.Fatalf("doctor: %v", err)
+		}
+	case "tray":
+		if err := runTray(); err != nil {
+			log.Fatalf("tray: %v", err)
+		}
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n%s", command, usage)
+		os.Exit(2)
+	}
+}
+
+// runServer starts the HTTP server and, if configured, the gRPC server, until SIGINT or SIGTERM
+func runServer(args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer stop()
+	return serveUntil(ctx, args)
+}
+
+// serveUntil runs the servers and their background tasks until ctx is done, then drains them
+func serveUntil(ctx context.Context, args []string) error {
+	options, err := parseServeArgs(args)
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := serverTLSConfig(options)
+	if err != nil {
+		return err
+	}
+	listener, err := listenServer(options.listen)
+	if err != nil {
+		return err
 	}
 
 	// Create Gin router
-	r := gin.Default()
+	r := newRouter()
 
 	// Setup routes
-	setupRoutes(r)
+	service := setupRoutes(r)
+
+	// Background tasks stop with ctx, and shutdown waits for them
+	var background sync.WaitGroup
+
+	// Keep quota history across restarts for anomaly detection
+	if store := historyStoreOf(service.client.Config()); store != nil {
+		if err := quotaHistory.open(store); err != nil {
+			log.Printf("Failed to load history: %v", err)
+		}
+		if _, local := store.(*fileHistoryStore); local {
+			background.Go(func() { vacuumHistoryPeriodically(ctx, service.client) })
+		}
+	}
+
+	// Run threshold and anomaly hooks on every fetch, polling when no client does
+	service.hooks = NewHookRunner()
+	background.Go(func() { service.pollHooks(ctx) })
+	background.Go(func() { service.runScheduler(ctx, service.hooks.mail) })
+
+	// Reload .env on change or SIGHUP without dropping cached data
+	background.Go(func() {
+		if err := NewConfigReloader(".env", service.client).Watch(ctx); err != nil {
+			log.Printf("Config hot-reload disabled: %v", err)
+		}
+	})
+
+	// Start gRPC server if configured
+	var grpcServer *grpc.Server
+	if grpcPort := os.Getenv("GRPC_PORT"); grpcPort != "" {
+		if _, err := strconv.Atoi(grpcPort); err != nil {
+			return fmt.Errorf("invalid GRPC_PORT value: %s", grpcPort)
+		}
+		grpcServer = newGRPCServer(tlsConfig, service)
+		go func() {
+			if err := startGRPCServer(grpcListenAddress(options.listen, grpcPort), grpcServer); err != nil {
+				log.Fatalf("Failed to start gRPC server: %v", err)
+			}
+		}()
+	}
 
 	// Start server
-	log.Printf("Starting server on port %s", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	server := &http.Server{Handler: r}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	if activeProfile != "" {
+		log.Printf("Using profile %s", activeProfile)
+	}
+	log.Printf("Starting server on %s", options.listen)
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener) }()
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
 	}
+	drainServer(shutdownTimeout(), server, grpcServer, service, &background)
+	return nil
 }
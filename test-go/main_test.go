@@ -121,10 +121,10 @@ func TestFormatQuota(t *testing.T) {
 	// Check percentages
 	for _, model := range formatted.Models {
 		if model.Name == "gemini-3-pro-high" && model.Percentage != 95 {
-			t.Errorf("Expected 95%% for gemini-3-pro-high, got %d%%", model.Percentage)
+			t.Errorf("Expected 95%% for gemini-3-pro-high, got %v%%", model.Percentage)
 		}
 		if model.Name == "claude-sonnet-4-5" && model.Percentage != 80 {
-			t.Errorf("Expected 80%% for claude-sonnet-4-5, got %d%%", model.Percentage)
+			t.Errorf("Expected 80%% for claude-sonnet-4-5, got %v%%", model.Percentage)
 		}
 	}
 }
@@ -192,3 +192,150 @@ func TestLoadAccount(t *testing.T) {
 		t.Errorf("Expected project ID 'test-project', got %s", account.ProjectID)
 	}
 }
+
+func TestParseModelAliases(t *testing.T) {
+	aliases := parseModelAliases(`"glm=Z, glm-coding-plan-mcp-monthly = mcp,glm-coding-plan-zread=-,invalid,=empty"`)
+
+	expected := map[string]string{
+		"glm":                         "Z",
+		"glm-coding-plan-mcp-monthly": "mcp",
+		"glm-coding-plan-zread":       "-",
+	}
+
+	if len(aliases) != len(expected) {
+		t.Errorf("Expected %d aliases, got %d: %v", len(expected), len(aliases), aliases)
+	}
+	for name, alias := range expected {
+		if aliases[name] != alias {
+			t.Errorf("Expected alias %s for %s, got %s", alias, name, aliases[name])
+		}
+	}
+}
+
+func TestApplyModelAliases(t *testing.T) {
+	models := []FormattedModel{
+		{Name: "glm", Percentage: 75},
+		{Name: "glm-coding-plan-search-prime", Percentage: 90},
+		{Name: "glm-coding-plan-web-reader", Percentage: 60},
+		{Name: "glm-coding-plan-mcp-monthly", Percentage: 80},
+	}
+	aliases := map[string]string{
+		"glm":                          "Z",
+		"glm-coding-plan-search-prime": "tools",
+		"glm-coding-plan-web-reader":   "tools",
+		"glm-coding-plan-mcp-monthly":  HiddenModelAlias,
+	}
+
+	result := applyModelAliases(models, aliases)
+
+	if len(result) != 2 {
+		t.Fatalf("Expected 2 models after rename/merge/hide, got %d: %v", len(result), result)
+	}
+	if result[0].Name != "Z" || result[0].Percentage != 75 {
+		t.Errorf("Expected Z with 75%%, got %s with %v%%", result[0].Name, result[0].Percentage)
+	}
+	// Merged models keep the lowest percentage
+	if result[1].Name != "tools" || result[1].Percentage != 60 {
+		t.Errorf("Expected tools with 60%%, got %s with %v%%", result[1].Name, result[1].Percentage)
+	}
+
+	// No aliases leaves models untouched
+	if unchanged := applyModelAliases(models, nil); len(unchanged) != len(models) {
+		t.Errorf("Expected %d models without aliases, got %d", len(models), len(unchanged))
+	}
+}
+
+func TestSelectModels(t *testing.T) {
+	quota := &FormattedQuota{
+		Models: []FormattedModel{
+			{Name: "glm", Percentage: 75},
+			{Name: "glm-coding-plan-mcp-monthly", Percentage: 90},
+			{Name: "glm-coding-plan-search-prime", Percentage: 97},
+			{Name: "glm-coding-plan-web-reader", Percentage: 98},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		only     []string
+		exclude  []string
+		expected []string
+	}{
+		{"no filters", nil, nil, []string{"glm", "glm-coding-plan-mcp-monthly", "glm-coding-plan-search-prime", "glm-coding-plan-web-reader"}},
+		{"only", []string{"glm", "glm-coding-plan-mcp-monthly"}, nil, []string{"glm", "glm-coding-plan-mcp-monthly"}},
+		{"exclude glob", nil, []string{"glm-coding-plan-*"}, []string{"glm"}},
+		{"only and exclude", []string{"glm-coding-plan-*"}, []string{"*-monthly"}, []string{"glm-coding-plan-search-prime", "glm-coding-plan-web-reader"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := selectModels(quota, tt.only, tt.exclude)
+			if len(result.Models) != len(tt.expected) {
+				t.Fatalf("Expected %d models, got %d", len(tt.expected), len(result.Models))
+			}
+			for i, model := range result.Models {
+				if model.Name != tt.expected[i] {
+					t.Errorf("Expected %s at %d, got %s", tt.expected[i], i, model.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundPercentage(t *testing.T) {
+	tests := []struct {
+		pct       float64
+		precision int
+		expected  float64
+	}{
+		{95.4, 0, 95},
+		{95.5, 0, 96},
+		{0.4, 0, 0},
+		{99.667, 1, 99.7},
+		{99.667, 2, 99.67},
+		{0.005, 2, 0.01},
+	}
+
+	for _, tt := range tests {
+		if result := roundPercentage(tt.pct, tt.precision); result != tt.expected {
+			t.Errorf("roundPercentage(%v, %d) = %v, expected %v", tt.pct, tt.precision, result, tt.expected)
+		}
+	}
+}
+
+func TestWorstModel(t *testing.T) {
+	if _, ok := worstModel(nil); ok {
+		t.Error("Expected no worst model for empty input")
+	}
+
+	worst, ok := worstModel([]FormattedModel{
+		{Name: "gemini-3-pro-high", Percentage: 95},
+		{Name: "glm", Percentage: 12.5},
+		{Name: "claude-sonnet-4-5", Percentage: 80},
+	})
+	if !ok || worst.Name != "glm" || worst.Percentage != 12.5 {
+		t.Errorf("Expected glm at 12.5%%, got %s at %v%%", worst.Name, worst.Percentage)
+	}
+}
+
+func TestFormatQuotaUsesFetchTime(t *testing.T) {
+	fetchedAt := time.Now().Add(-90 * time.Second)
+	quota := formatQuota(&QuotaResponse{
+		Models: map[string]ModelInfo{
+			"gemini-3-pro-high": {QuotaInfo: QuotaInfo{RemainingFraction: 0.5}},
+		},
+		FetchedAt: fetchedAt,
+	}, true)
+
+	if quota.LastUpdated != fetchedAt.Unix() {
+		t.Errorf("Expected LastUpdated %d, got %d", fetchedAt.Unix(), quota.LastUpdated)
+	}
+	if quota.Age < 90 || quota.Age > 91 {
+		t.Errorf("Expected Age around 90, got %d", quota.Age)
+	}
+
+	fresh := formatQuota(&QuotaResponse{}, true)
+	if fresh.Age != 0 {
+		t.Errorf("Expected Age 0 for fresh data, got %d", fresh.Age)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+func TestMCPUsage(t *testing.T) {
+	limits := ProcessedZAILimit{Limits: []ProcessedLimit{
+		{Type: LimitTokens5h, Percentage: 30},
+		{Type: LimitMCPMonthly, Percentage: 3.5, CurrentUsage: 140, Total: 4000, UsageDetails: []ZAIUsageDetail{
+			{ModelCode: "web-reader", Usage: 30},
+			{ModelCode: "search-prime", Usage: 80},
+			{ModelCode: "new-tool", Usage: 10},
+		}},
+	}}
+
+	usage, ok := mcpUsage(limits, 1)
+	if !ok {
+		t.Fatal("Expected the MCP limit to be found")
+	}
+	if usage.Used != 140 || usage.Total != 4000 || usage.Remaining != 3860 || usage.Percentage != 3.5 {
+		t.Errorf("Unexpected pool: %+v", usage)
+	}
+
+	want := []MCPToolUsage{
+		{Tool: "search-prime", Calls: 80, Percentage: 2},
+		{Tool: "web-reader", Calls: 30, Percentage: 0.8},
+		{Tool: "new-tool", Calls: 10, Percentage: 0.3},
+		{Calls: 20, Percentage: 0.5},
+	}
+	if len(usage.Tools) != len(want) {
+		t.Fatalf("Expected %d tools, got %+v", len(want), usage.Tools)
+	}
+	for i, tool := range want {
+		if usage.Tools[i] != tool {
+			t.Errorf("Tool %d: expected %+v, got %+v", i, tool, usage.Tools[i])
+		}
+	}
+
+	if _, ok := mcpUsage(ProcessedZAILimit{Limits: limits.Limits[:1]}, 1); ok {
+		t.Error("Expected no MCP usage without a monthly limit")
+	}
+}
+
+func TestWriteMCPTable(t *testing.T) {
+	config := &Config{Language: language.English, NumberLocale: language.English}
+	usage := MCPUsage{Used: 1200, Total: 4000, Remaining: 2800, Percentage: 30, Tools: []MCPToolUsage{
+		{Tool: "search-prime", Calls: 1100, Percentage: 27.5},
+		{Calls: 100, Percentage: 2.5},
+	}}
+
+	var out strings.Builder
+	if err := writeMCPTable(&out, config, usage); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Monthly MCP pool: 1,200 of 4,000 calls used, 2,800 remaining (30% used)",
+		"TOOL            CALLS  SHARE OF POOL",
+		"search-prime    1,100  27.5%",
+		"(unattributed)  100    2.5%",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Upper bounds in seconds of the upstream request latency histogram buckets
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// statusCode finds an HTTP status in provider error messages such as "status 429"
+var statusCode = regexp.MustCompile(`status (\d{3})`)
+
+// latencyHistogram counts request durations per bucket
+type latencyHistogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// observe adds a duration to the histogram
+func (h *latencyHistogram) observe(latency time.Duration) {
+	seconds := latency.Seconds()
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// daemonMetrics tracks the server's own behavior, as opposed to the quota it reports
+type daemonMetrics struct {
+	mu                  sync.Mutex
+	cacheHits           map[string]uint64
+	cacheMisses         map[string]uint64
+	latency             map[string]*latencyHistogram
+	errors              map[string]map[string]uint64
+	consecutiveFailures map[string]int
+	refreshLag          time.Duration
+	refreshLastRun      time.Time
+	pollInterval        time.Duration
+}
+
+var serverMetrics = newDaemonMetrics()
+
+// newDaemonMetrics creates empty metrics
+func newDaemonMetrics() *daemonMetrics {
+	return &daemonMetrics{
+		cacheHits:           make(map[string]uint64),
+		cacheMisses:         make(map[string]uint64),
+		latency:             make(map[string]*latencyHistogram),
+		errors:              make(map[string]map[string]uint64),
+		consecutiveFailures: make(map[string]int),
+	}
+}
+
+// recordFetch counts a cache miss and its upstream latency, and resets the failure streak
+func (m *daemonMetrics) recordFetch(provider string, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cacheMisses[provider]++
+	histogram, ok := m.latency[provider]
+	if !ok {
+		histogram = &latencyHistogram{counts: make([]uint64, len(latencyBuckets))}
+		m.latency[provider] = histogram
+	}
+	histogram.observe(latency)
+	m.consecutiveFailures[provider] = 0
+}
+
+// recordCacheHit counts a response served from cache
+func (m *daemonMetrics) recordCacheHit(provider string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cacheHits[provider]++
+}
+
+// recordError counts a failure by class and extends the provider's failure streak
+func (m *daemonMetrics) recordError(provider string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.errors[provider] == nil {
+		m.errors[provider] = make(map[string]uint64)
+	}
+	m.errors[provider][errorClass(err)]++
+	m.consecutiveFailures[provider]++
+}
+
+// recordRefresh stores how late a background refresh started compared to its schedule
+func (m *daemonMetrics) recordRefresh(lag time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refreshLag = lag
+	m.refreshLastRun = clockNow()
+}
+
+// recordPollInterval stores the interval until the next background refresh
+func (m *daemonMetrics) recordPollInterval(interval time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pollInterval = interval
+}
+
+// errorClass groups provider errors for alerting: timeout, network, auth, rate_limit,
+// server, client, gateway, schema, config, or other
+func errorClass(err error) string {
+	var schemaErr *ZAISchemaError
+	var pageErr *GatewayPageError
+	var netErr net.Error
+	var urlErr *url.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &pageErr) && pageErr.SignIn:
+		return "auth"
+	case errors.As(err, &pageErr):
+		return "gateway"
+	case errors.As(err, &schemaErr):
+		return "schema"
+	case errors.As(err, &urlErr), errors.As(err, &netErr):
+		return "network"
+	}
+
+	message := err.Error()
+	if match := statusCode.FindStringSubmatch(message); match != nil {
+		code, _ := strconv.Atoi(match[1])
+		switch {
+		case code == http.StatusUnauthorized || code == http.StatusForbidden:
+			return "auth"
+		case code == http.StatusTooManyRequests:
+			return "rate_limit"
+		case code >= 500:
+			return "server"
+		case code >= 400:
+			return "client"
+		}
+	}
+	switch {
+	case strings.Contains(message, "timeout"):
+		return "timeout"
+	case strings.Contains(message, "request to"), strings.Contains(message, "failed to query"):
+		return "network"
+	case strings.Contains(message, "decode"), strings.Contains(message, "invalid"):
+		return "schema"
+	case strings.Contains(message, "not set"), strings.Contains(message, "not configured"):
+		return "config"
+	}
+	return "other"
+}
+
+// sortedKeys returns the keys of a provider map in order
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatFloat formats a metric value
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// writePrometheus writes the metrics in the Prometheus text exposition format
+func (m *daemonMetrics) writePrometheus(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP quota_exporter_cache_requests_total Provider data requests by cache result.")
+	fmt.Fprintln(w, "# TYPE quota_exporter_cache_requests_total counter")
+	providers := make(map[string]bool)
+	for provider := range m.cacheHits {
+		providers[provider] = true
+	}
+	for provider := range m.cacheMisses {
+		providers[provider] = true
+	}
+	for _, provider := range sortedKeys(providers) {
+		fmt.Fprintf(w, "quota_exporter_cache_requests_total{provider=%q,result=\"hit\"} %d\n", provider, m.cacheHits[provider])
+		fmt.Fprintf(w, "quota_exporter_cache_requests_total{provider=%q,result=\"miss\"} %d\n", provider, m.cacheMisses[provider])
+	}
+
+	fmt.Fprintln(w, "# HELP quota_exporter_upstream_request_duration_seconds Latency of successful upstream provider requests.")
+	fmt.Fprintln(w, "# TYPE quota_exporter_upstream_request_duration_seconds histogram")
+	for _, provider := range sortedKeys(m.latency) {
+		histogram := m.latency[provider]
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(w, "quota_exporter_upstream_request_duration_seconds_bucket{provider=%q,le=%q} %d\n", provider, formatFloat(bound), histogram.counts[i])
+		}
+		fmt.Fprintf(w, "quota_exporter_upstream_request_duration_seconds_bucket{provider=%q,le=\"+Inf\"} %d\n", provider, histogram.count)
+		fmt.Fprintf(w, "quota_exporter_upstream_request_duration_seconds_sum{provider=%q} %s\n", provider, formatFloat(histogram.sum))
+		fmt.Fprintf(w, "quota_exporter_upstream_request_duration_seconds_count{provider=%q} %d\n", provider, histogram.count)
+	}
+
+	fmt.Fprintln(w, "# HELP quota_exporter_errors_total Provider fetch failures by error class.")
+	fmt.Fprintln(w, "# TYPE quota_exporter_errors_total counter")
+	for _, provider := range sortedKeys(m.errors) {
+		for _, class := range sortedKeys(m.errors[provider]) {
+			fmt.Fprintf(w, "quota_exporter_errors_total{provider=%q,class=%q} %d\n", provider, class, m.errors[provider][class])
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP quota_exporter_consecutive_failures Failed fetches since the provider's last success.")
+	fmt.Fprintln(w, "# TYPE quota_exporter_consecutive_failures gauge")
+	for _, provider := range sortedKeys(m.consecutiveFailures) {
+		fmt.Fprintf(w, "quota_exporter_consecutive_failures{provider=%q} %d\n", provider, m.consecutiveFailures[provider])
+	}
+
+	if !m.refreshLastRun.IsZero() {
+		fmt.Fprintln(w, "# HELP quota_exporter_refresh_lag_seconds How late the last background refresh started.")
+		fmt.Fprintln(w, "# TYPE quota_exporter_refresh_lag_seconds gauge")
+		fmt.Fprintf(w, "quota_exporter_refresh_lag_seconds %s\n", formatFloat(m.refreshLag.Seconds()))
+		fmt.Fprintln(w, "# HELP quota_exporter_refresh_last_run_timestamp_seconds When the last background refresh started.")
+		fmt.Fprintln(w, "# TYPE quota_exporter_refresh_last_run_timestamp_seconds gauge")
+		fmt.Fprintf(w, "quota_exporter_refresh_last_run_timestamp_seconds %d\n", m.refreshLastRun.Unix())
+	}
+	if m.pollInterval > 0 {
+		fmt.Fprintln(w, "# HELP quota_exporter_poll_interval_seconds Interval until the next background refresh, longer while clients are idle.")
+		fmt.Fprintln(w, "# TYPE quota_exporter_poll_interval_seconds gauge")
+		fmt.Fprintf(w, "quota_exporter_poll_interval_seconds %s\n", formatFloat(m.pollInterval.Seconds()))
+	}
+}
+
+// responseCaches names the response caches reported in the metrics
+var responseCaches = map[string]*ResponseCache{
+	"zai":      zaiCache,
+	"provider": providerCache,
+}
+
+// writeCacheMetrics writes the size and evictions of the response caches
+func writeCacheMetrics(w io.Writer, maxEntries int) {
+	names := sortedKeys(responseCaches)
+	fmt.Fprintln(w, "# HELP quota_exporter_cache_entries Entries held by each response cache.")
+	fmt.Fprintln(w, "# TYPE quota_exporter_cache_entries gauge")
+	for _, name := range names {
+		fmt.Fprintf(w, "quota_exporter_cache_entries{cache=%q} %d\n", name, responseCaches[name].Stats().Entries)
+	}
+	fmt.Fprintln(w, "# HELP quota_exporter_cache_evictions_total Least recently used entries dropped to stay within CACHE_MAX_ENTRIES.")
+	fmt.Fprintln(w, "# TYPE quota_exporter_cache_evictions_total counter")
+	for _, name := range names {
+		fmt.Fprintf(w, "quota_exporter_cache_evictions_total{cache=%q} %d\n", name, responseCaches[name].Stats().Evictions)
+	}
+	fmt.Fprintln(w, "# HELP quota_exporter_cache_max_entries Most entries kept in each response cache.")
+	fmt.Fprintln(w, "# TYPE quota_exporter_cache_max_entries gauge")
+	fmt.Fprintf(w, "quota_exporter_cache_max_entries %d\n", maxEntries)
+}
+
+// GetMetrics serves the server's own metrics in the Prometheus text format
+func (s *QuotaService) GetMetrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	serverMetrics.writePrometheus(c.Writer)
+	writeCacheMetrics(c.Writer, s.client.Config().CacheMaxEntries)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		err   error
+		class string
+	}{
+		{context.DeadlineExceeded, "timeout"},
+		{&url.Error{Op: "Get", URL: "https://api.z.ai", Err: errors.New("connection refused")}, "network"},
+		{fmt.Errorf("wrapped: %w", &ZAISchemaError{Field: "limits"}), "schema"},
+		{errors.New("Z.ai API error: status 401"), "auth"},
+		{errors.New("api.groq.com API error: status 429 - slow down"), "rate_limit"},
+		{errors.New("api.x.ai API error: status 503 - unavailable"), "server"},
+		{errors.New("api.x.ai API error: status 404 - missing"), "client"},
+		{errors.New("ANTHROPIC_AUTH_TOKEN environment variable is not set"), "config"},
+		{errors.New("something else"), "other"},
+	}
+	for _, test := range tests {
+		if got := errorClass(test.err); got != test.class {
+			t.Errorf("errorClass(%v) = %s, expected %s", test.err, got, test.class)
+		}
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	defer setClock(&fakeClock{t: time.Unix(1772366400, 0)})()
+
+	metrics := newDaemonMetrics()
+	metrics.recordFetch("glm", 300*time.Millisecond)
+	metrics.recordCacheHit("glm")
+	metrics.recordCacheHit("glm")
+	metrics.recordError("groq", errors.New("status 429"))
+	metrics.recordError("groq", errors.New("status 429"))
+	metrics.recordRefresh(2 * time.Second)
+
+	var buf bytes.Buffer
+	metrics.writePrometheus(&buf)
+	output := buf.String()
+
+	for _, line := range []string{
+		`quota_exporter_cache_requests_total{provider="glm",result="hit"} 2`,
+		`quota_exporter_cache_requests_total{provider="glm",result="miss"} 1`,
+		`quota_exporter_upstream_request_duration_seconds_bucket{provider="glm",le="0.25"} 0`,
+		`quota_exporter_upstream_request_duration_seconds_bucket{provider="glm",le="0.5"} 1`,
+		`quota_exporter_upstream_request_duration_seconds_bucket{provider="glm",le="+Inf"} 1`,
+		`quota_exporter_upstream_request_duration_seconds_sum{provider="glm"} 0.3`,
+		`quota_exporter_errors_total{provider="groq",class="rate_limit"} 2`,
+		`quota_exporter_consecutive_failures{provider="glm"} 0`,
+		`quota_exporter_consecutive_failures{provider="groq"} 2`,
+		`quota_exporter_refresh_lag_seconds 2`,
+		`quota_exporter_refresh_last_run_timestamp_seconds 1772366400`,
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Expected %q in:\n%s", line, output)
+		}
+	}
+
+	// A success ends the failure streak
+	metrics.recordFetch("groq", time.Second)
+	buf.Reset()
+	metrics.writePrometheus(&buf)
+	if !strings.Contains(buf.String(), `quota_exporter_consecutive_failures{provider="groq"} 0`) {
+		t.Errorf("Expected the groq streak reset:\n%s", buf.String())
+	}
+}
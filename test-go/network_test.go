@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseNetworkConfig(t *testing.T) {
+	for value, want := range map[string]string{"4": "tcp4", "IPv6": "tcp6", "auto": "", "": ""} {
+		if got := parseIPFamily(value); got != want {
+			t.Errorf("parseIPFamily(%q) = %q, want %q", value, got, want)
+		}
+	}
+
+	overrides := parseHostOverrides("API.z.ai=203.0.113.7, open.bigmodel.cn=2001:db8::1,bad=not-an-ip")
+	if len(overrides) != 2 || overrides["api.z.ai"] != "203.0.113.7" || overrides["open.bigmodel.cn"] != "2001:db8::1" {
+		t.Errorf("Unexpected overrides: %v", overrides)
+	}
+}
+
+func TestDialProviderOverrides(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	defer currentDialOptions.Store(nil)
+
+	// The override connects to the test server while the request keeps its host name
+	applyNetworkConfig(&Config{HostOverrides: map[string]string{"api.quota.test": "127.0.0.1"}})
+	client := &http.Client{Transport: newProviderTransport()}
+	resp, err := client.Get("http://api.quota.test:" + port + "/")
+	if err != nil {
+		t.Fatalf("Expected the override to be dialed, got %v", err)
+	}
+	host, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(host) != "api.quota.test:"+port {
+		t.Errorf("Expected the original host name, got %q", host)
+	}
+
+	// Forcing IPv6 cannot reach an IPv4 address
+	applyNetworkConfig(&Config{IPFamily: "tcp6"})
+	if conn, err := dialProvider(context.Background(), "tcp", "127.0.0.1:"+port); err == nil {
+		conn.Close()
+		t.Error("Expected an IPv6-only dial of an IPv4 address to fail")
+	}
+	applyNetworkConfig(&Config{IPFamily: "tcp4"})
+	conn, err := dialProvider(context.Background(), "tcp", "127.0.0.1:"+port)
+	if err != nil {
+		t.Fatalf("Expected an IPv4 dial to succeed, got %v", err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// ntfy server topics are published to unless NTFY_SERVER is set
+	DefaultNtfyServer = "https://ntfy.sh"
+
+	// Pushover message endpoint
+	DefaultPushoverURL = "https://api.pushover.net/1/messages.json"
+
+	// Hook events pushed unless NOTIFY_EVENTS is set
+	DefaultNotifyEvents = HookEventBelow + "," + HookEventEmpty
+
+	// Longest wait for ntfy or Pushover to accept a notification
+	notifyTimeout = 10 * time.Second
+)
+
+// pushNotifier sends hook events to phones through an ntfy topic or the Pushover API
+type pushNotifier struct {
+	client *http.Client
+
+	// pushoverURL is the Pushover message endpoint; replaced in tests
+	pushoverURL string
+}
+
+// newPushNotifier creates a notifier for the public Pushover API
+func newPushNotifier() *pushNotifier {
+	return &pushNotifier{
+		client:      &http.Client{Timeout: notifyTimeout},
+		pushoverURL: DefaultPushoverURL,
+	}
+}
+
+// pushEnabled reports whether an ntfy topic or a Pushover application and user are configured
+func pushEnabled(config *Config) bool {
+	return config.NtfyTopic != "" || (config.PushoverToken != nil && config.PushoverUser != nil)
+}
+
+// alert pushes a hook event to every configured service when NOTIFY_EVENTS lists it. Empty
+// quota is sent with high priority so it rings through quiet hours.
+func (n *pushNotifier) alert(config *Config, event HookEvent) {
+	if !pushEnabled(config) || !slices.Contains(config.NotifyEvents, event.Event) {
+		return
+	}
+	title, message := alertText(config, event)
+	urgent := event.Event == HookEventEmpty
+	if config.NtfyTopic != "" {
+		if err := n.ntfy(config, title, message, urgent); err != nil {
+			log.Printf("Failed to push %s alert for %s/%s to ntfy: %v", event.Event, event.Provider, event.Model, err)
+		}
+	}
+	if config.PushoverToken != nil && config.PushoverUser != nil {
+		if err := n.pushover(config, title, message, urgent); err != nil {
+			log.Printf("Failed to push %s alert for %s/%s to Pushover: %v", event.Event, event.Provider, event.Model, err)
+		}
+	}
+}
+
+// ntfy publishes to NTFY_TOPIC, a topic name on NTFY_SERVER or a full topic URL, with
+// NTFY_TOKEN as the access token of protected topics
+func (n *pushNotifier) ntfy(config *Config, title, message string, urgent bool) error {
+	endpoint := config.NtfyTopic
+	if !strings.Contains(endpoint, "://") {
+		endpoint = strings.TrimSuffix(config.NtfyServer, "/") + "/" + url.PathEscape(endpoint)
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(message))
+	if err != nil {
+		return err
+	}
+	// Header values must be ASCII, so translated titles are MIME encoded, which ntfy decodes
+	req.Header.Set("Title", mime.QEncoding.Encode("utf-8", title))
+	req.Header.Set("Tags", "battery")
+	if urgent {
+		req.Header.Set("Priority", "high")
+	}
+	token, err := readToken(req.Context(), config.NtfyToken)
+	if err != nil {
+		return fmt.Errorf("failed to read NTFY_TOKEN: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return n.do(req)
+}
+
+// pushover sends a message through the Pushover API to PUSHOVER_USER
+func (n *pushNotifier) pushover(config *Config, title, message string, urgent bool) error {
+	token, err := readToken(context.Background(), config.PushoverToken)
+	if err != nil {
+		return fmt.Errorf("failed to read PUSHOVER_TOKEN: %w", err)
+	}
+	user, err := readToken(context.Background(), config.PushoverUser)
+	if err != nil {
+		return fmt.Errorf("failed to read PUSHOVER_USER: %w", err)
+	}
+	form := url.Values{
+		"token":   {token},
+		"user":    {user},
+		"title":   {title},
+		"message": {message},
+	}
+	if urgent {
+		form.Set("priority", "1")
+	}
+	req, err := http.NewRequest(http.MethodPost, n.pushoverURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return n.do(req)
+}
+
+// do sends a request and turns error statuses into errors
+func (n *pushNotifier) do(req *http.Request) error {
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, errorBody(resp.Body))
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPushAlerts(t *testing.T) {
+	type pushed struct {
+		path   string
+		header http.Header
+		body   string
+	}
+	requests := make(chan pushed, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- pushed{r.URL.Path, r.Header, string(body)}
+	}))
+	defer srv.Close()
+
+	runner := NewHookRunner()
+	runner.run = func(string, HookEvent) {}
+	runner.phone.pushoverURL = srv.URL + "/1/messages.json"
+	config := &Config{
+		HookThreshold: 10,
+		NtfyServer:    srv.URL,
+		NtfyTopic:     "my quota",
+		NtfyToken:     staticToken("tk_secret"),
+		PushoverToken: staticToken("app"),
+		PushoverUser:  staticToken("user"),
+		NotifyEvents:  []string{HookEventEmpty},
+	}
+	if !hooksEnabled(config) {
+		t.Error("Expected push notifications to enable polling")
+	}
+
+	runner.observe(config, "glm", &FormattedQuota{Models: []FormattedModel{{Name: "glm", Percentage: 5}}})
+	runner.observe(config, "glm", &FormattedQuota{Models: []FormattedModel{{Name: "glm", Percentage: 0}}})
+
+	for range 2 {
+		select {
+		case req := <-requests:
+			switch req.path {
+			case "/my quota":
+				if req.header.Get("Title") != "Quota alert: glm/glm at 0%" || req.header.Get("Priority") != "high" || req.header.Get("Authorization") != "Bearer tk_secret" {
+					t.Errorf("Unexpected ntfy headers: %v", req.header)
+				}
+				if !strings.HasPrefix(req.body, "glm/glm has 0% left (empty, threshold 10%).") {
+					t.Errorf("Unexpected ntfy message: %q", req.body)
+				}
+			case "/1/messages.json":
+				form, _ := url.ParseQuery(req.body)
+				if form.Get("token") != "app" || form.Get("user") != "user" || form.Get("priority") != "1" || form.Get("title") != "Quota alert: glm/glm at 0%" {
+					t.Errorf("Unexpected Pushover form: %v", form)
+				}
+			default:
+				t.Errorf("Unexpected request to %s", req.path)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected an ntfy and a Pushover notification")
+		}
+	}
+	select {
+	case req := <-requests:
+		t.Errorf("Expected only events in NOTIFY_EVENTS to be pushed, got %+v", req)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
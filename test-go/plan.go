@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// errDryRun is returned for every request of a dry run instead of sending it
+var errDryRun = errors.New("dry run, request not sent")
+
+// PlannedRequest is a request a dry run would have sent, with secrets in the query redacted
+type PlannedRequest struct {
+	Method string              `json:"method"`
+	URL    string              `json:"url"`
+	Query  map[string][]string `json:"query,omitempty"`
+}
+
+// QueryPlan is what fetching a provider would query: its requests, the cache keys looked up
+// first, and the error it stopped at when that was not a request of the dry run
+type QueryPlan struct {
+	Provider  string           `json:"provider"`
+	Requests  []PlannedRequest `json:"requests"`
+	CacheKeys []string         `json:"cache_keys"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// dryRunPlan records the requests and cache lookups of a fetch without sending anything.
+// Providers that chain requests stop at the first, as the next needs its answer.
+type dryRunPlan struct {
+	config *Config
+
+	mu        sync.Mutex
+	requests  []PlannedRequest
+	cacheKeys []string
+}
+
+// dryRunKey is the context key of the dry run a fetch belongs to
+type dryRunKey struct{}
+
+// dryRunOf returns the dry run ctx belongs to, or nil for a real fetch
+func dryRunOf(ctx context.Context) *dryRunPlan {
+	plan, _ := ctx.Value(dryRunKey{}).(*dryRunPlan)
+	return plan
+}
+
+// transportFor returns the transport of provider requests made for ctx: the dry run's while
+// one is in progress, otherwise httpTransport
+func transportFor(ctx context.Context) http.RoundTripper {
+	if plan := dryRunOf(ctx); plan != nil {
+		return plan
+	}
+	return httpTransport
+}
+
+// observeCacheKey records a cache lookup the fetch would have made
+func (p *dryRunPlan) observeCacheKey(key string) {
+	p.mu.Lock()
+	p.cacheKeys = append(p.cacheKeys, p.redact(key))
+	p.mu.Unlock()
+}
+
+// RoundTrip records the request and fails it with errDryRun
+func (p *dryRunPlan) RoundTrip(req *http.Request) (*http.Response, error) {
+	u, _ := url.Parse(p.redact(redactURL(req.URL)))
+	planned := PlannedRequest{Method: req.Method, URL: u.Scheme + "://" + u.Host + u.Path}
+	if query := u.Query(); len(query) > 0 {
+		planned.Query = query
+	}
+	p.mu.Lock()
+	p.requests = append(p.requests, planned)
+	p.mu.Unlock()
+	return nil, errDryRun
+}
+
+// redact replaces the configured secrets in text, such as tokens in cache keys or paths.
+// Secrets read from a source are known once the request that needs them has been built.
+func (p *dryRunPlan) redact(text string) string {
+	for _, secret := range configSecrets(p.config) {
+		text = strings.ReplaceAll(text, secret, redacted)
+	}
+	return text
+}
+
+// configSecrets returns the secret string values of the config, the tokens of its secret
+// sources that are known without reading them, and the tokens of the GLM accounts
+func configSecrets(config *Config) []string {
+	var secrets []string
+	value := reflect.ValueOf(config).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		if !secretConfigFields[value.Type().Field(i).Name] {
+			continue
+		}
+		if field.Kind() == reflect.String && field.String() != "" {
+			secrets = append(secrets, field.String())
+		}
+		if source, ok := field.Interface().(TokenSource); ok {
+			if token := knownToken(source); token != "" {
+				secrets = append(secrets, token)
+			}
+		}
+	}
+	for _, account := range config.ZAIAccounts {
+		if account.Token != "" {
+			secrets = append(secrets, account.Token)
+		}
+	}
+	return secrets
+}
+
+// planQuery runs a provider's fetcher with a dry run in its context, so its requests go to
+// the dry-run transport and its cache lookups miss without touching the caches. It skips
+// what fetchQuota does after a fetch, such as history, hooks, and retries with rotated tokens.
+func planQuery(ctx context.Context, config *Config, provider string) QueryPlan {
+	if provider == "" {
+		provider = ProviderAntigravity
+	}
+	plan := &dryRunPlan{config: config}
+	ctx = context.WithValue(ctx, dryRunKey{}, plan)
+
+	var err error
+	if fetch, ok := quotaProviders[provider]; ok {
+		_, err = fetch(ctx)
+	} else if provider == ProviderAntigravity {
+		// A client of its own sends the token refresh to the plan as well
+		client := NewCloudCodeClient(config)
+		client.httpClient = &http.Client{Transport: plan}
+		_, err = NewQuotaService(client).getQuotaData(ctx)
+	} else {
+		err = fmt.Errorf("%w: %s", ErrUnknownProvider, provider)
+	}
+
+	plan.mu.Lock()
+	defer plan.mu.Unlock()
+	// Concurrent requests are recorded in any order
+	slices.SortFunc(plan.requests, func(a, b PlannedRequest) int { return strings.Compare(a.URL, b.URL) })
+	result := QueryPlan{Provider: provider, Requests: plan.requests, CacheKeys: plan.cacheKeys}
+	if err != nil && !strings.Contains(err.Error(), errDryRun.Error()) {
+		result.Error = plan.redact(err.Error())
+	}
+	return result
+}
+
+// writeQueryPlan prints a plan as text, or as JSON when asJSON is set
+func writeQueryPlan(w io.Writer, plan QueryPlan, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(plan)
+	}
+	fmt.Fprintf(w, "Dry run of %s, nothing was sent\n", plan.Provider)
+	fmt.Fprintln(w, "Requests:")
+	if len(plan.Requests) == 0 {
+		fmt.Fprintln(w, "  none")
+	}
+	for _, req := range plan.Requests {
+		fmt.Fprintf(w, "  %s %s\n", req.Method, req.URL)
+		for _, name := range slices.Sorted(maps.Keys(req.Query)) {
+			for _, value := range req.Query[name] {
+				fmt.Fprintf(w, "      %s=%s\n", name, value)
+			}
+		}
+	}
+	fmt.Fprintln(w, "Cache keys:")
+	if len(plan.CacheKeys) == 0 {
+		fmt.Fprintln(w, "  none")
+	}
+	for _, key := range plan.CacheKeys {
+		fmt.Fprintf(w, "  %s\n", key)
+	}
+	if plan.Error != "" {
+		fmt.Fprintf(w, "Stopped: %s\n", plan.Error)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDryRunPlan(t *testing.T) {
+	var sent atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent.Add(1)
+		fmt.Fprint(w, `{"code":200,"data":{"limits":[{"type":"TOKENS_LIMIT","percentage":25}]}}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("ZAI_AUTH_TOKEN", "secret-token")
+	t.Setenv("ZAI_ANTHROPIC_BASE_URL", server.URL+"/api/anthropic")
+	t.Setenv("ZAI_PLATFORM", "ZAI")
+	t.Setenv("ZAI_ACCOUNTS", "")
+	t.Setenv("CURSOR_SESSION_TOKEN", "")
+
+	// A cached quota is neither used nor dropped by the dry run
+	if _, err := GetGLMQuota(context.Background()); err != nil {
+		t.Fatalf("GetGLMQuota failed: %v", err)
+	}
+	fetched := sent.Load()
+	plan := planQuery(context.Background(), LoadConfig(), ProviderGLM)
+	if sent.Load() != fetched {
+		t.Error("Expected a dry run to send nothing")
+	}
+	if _, err := GetGLMQuota(context.Background()); err != nil || sent.Load() != fetched {
+		t.Errorf("Expected the quota to stay cached after a dry run, got %v", err)
+	}
+	if plan.Error != "" || len(plan.Requests) == 0 || len(plan.CacheKeys) == 0 {
+		t.Fatalf("Expected requests and cache keys, got %+v", plan)
+	}
+	for _, req := range plan.Requests {
+		if !strings.HasPrefix(req.URL, server.URL+"/api/monitor/usage/") {
+			t.Errorf("Expected requests to the custom base URL, got %s", req.URL)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := writeQueryPlan(&out, plan, true); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "secret-token") || !strings.HasPrefix(plan.CacheKeys[0], "glm/default/") {
+		t.Errorf("Expected cache keys by account without the token, got %s", out.String())
+	}
+	var decoded QueryPlan
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || decoded.Provider != ProviderGLM {
+		t.Errorf("Unexpected JSON plan %s, %v", out.String(), err)
+	}
+
+	// A provider without credentials stops before any request
+	plan = planQuery(context.Background(), LoadConfig(), ProviderCursor)
+	out.Reset()
+	writeQueryPlan(&out, plan, false)
+	if len(plan.Requests) != 0 || plan.Error == "" || !strings.Contains(out.String(), "Stopped: ") {
+		t.Errorf("Expected a configuration error, got %s", out.String())
+	}
+}
+
+func TestDryRunPlanAntigravity(t *testing.T) {
+	t.Setenv("ANTIGRAVITY_TOKEN", "antigravity-secret")
+	config := LoadConfig()
+	config.ProjectAPIURL = "https://cloudcode.example/v1internal:loadCodeAssist"
+	config.APIURL = "https://cloudcode.example/v1internal:fetchAvailableModels"
+
+	plan := planQuery(context.Background(), config, ProviderAntigravity)
+	if plan.Error != "" || len(plan.Requests) != 2 || len(plan.CacheKeys) != 1 {
+		t.Fatalf("Expected the project and quota requests and one cache key, got %+v", plan)
+	}
+	if strings.Contains(plan.CacheKeys[0], "antigravity-secret") || !strings.HasPrefix(plan.CacheKeys[0], "antigravity/") {
+		t.Errorf("Expected a cache key without the token, got %s", plan.CacheKeys[0])
+	}
+}
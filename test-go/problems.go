@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+// Problem is one thing that went wrong, with how to fix it when that is known
+type Problem struct {
+	Provider string `json:"provider,omitempty"`
+	Error    string `json:"error"`
+	Fix      string `json:"fix,omitempty"`
+}
+
+// fixedError is an error that carries its remediation, such as the variable to set
+type fixedError struct {
+	err error
+	fix string
+}
+
+// withFix attaches a remediation to an error
+func withFix(err error, fix string) error {
+	return &fixedError{err: err, fix: fix}
+}
+
+func (e *fixedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *fixedError) Unwrap() error {
+	return e.err
+}
+
+// remediation returns how to fix a provider's error: the fix it carries, else one by kind of
+// failure, else the provider's doctor fix when it is not configured, else ""
+func remediation(provider string, err error) string {
+	var fixed *fixedError
+	if errors.As(err, &fixed) {
+		return fixed.fix
+	}
+	if errors.Is(err, ErrUnknownProvider) {
+		return "Use one of: " + strings.Join(providerNames(), ", ")
+	}
+	if errors.Is(err, ErrDaemonUnavailable) {
+		return "Start the server with serve, or query the provider directly with --direct or DAEMON_MODE=auto"
+	}
+	spec, known := doctorProviders[provider]
+	if isUnauthorized(err) && known {
+		return "The provider rejected the token. " + spec.fix
+	}
+	var gatewayErr *GatewayPageError
+	if errors.As(err, &gatewayErr) {
+		return "A proxy or gateway answered instead of the provider; check the base URL and HTTPS_PROXY"
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return "Check the network connection, HTTPS_PROXY, and HOST_OVERRIDES, or run doctor"
+	}
+	if known && !spec.configured(LoadConfig()) {
+		return spec.fix
+	}
+	return ""
+}
+
+// newProblem describes a provider's error with its remediation
+func newProblem(provider string, err error) Problem {
+	return Problem{Provider: provider, Error: err.Error(), Fix: remediation(provider, err)}
+}
+
+// MultiError collects every problem of an operation, so they are reported at once instead of
+// one per run
+type MultiError struct {
+	Problems []Problem
+	errs     []error
+}
+
+// Add records a provider's error with its remediation
+func (m *MultiError) Add(provider string, err error) {
+	m.Problems = append(m.Problems, newProblem(provider, err))
+	m.errs = append(m.errs, err)
+}
+
+// Unwrap returns the collected errors, for errors.Is and errors.As
+func (m *MultiError) Unwrap() []error {
+	return m.errs
+}
+
+// Err returns the collected problems as an error, or nil when there are none
+func (m *MultiError) Err() error {
+	if len(m.Problems) == 0 {
+		return nil
+	}
+	return m
+}
+
+// Error lists one problem per line, each followed by its fix
+func (m *MultiError) Error() string {
+	var b strings.Builder
+	for i, problem := range m.Problems {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if problem.Provider != "" {
+			b.WriteString(problem.Provider + ": ")
+		}
+		b.WriteString(problem.Error)
+		if problem.Fix != "" {
+			b.WriteString("\n  fix: " + problem.Fix)
+		}
+	}
+	return b.String()
+}
+
+// problemError turns a problem reported by a server back into an error carrying its fix
+func problemError(problem Problem) error {
+	if problem.Fix == "" {
+		return errors.New(problem.Error)
+	}
+	return withFix(errors.New(problem.Error), problem.Fix)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMultiError(t *testing.T) {
+	t.Setenv("ZAI_AUTH_TOKEN", "token")
+	t.Setenv("ZAI_ANTHROPIC_BASE_URL", "https://llm.example.com/api/anthropic")
+	t.Setenv("ZAI_PLATFORM", "")
+
+	var problems MultiError
+	if problems.Err() != nil {
+		t.Error("Expected no error without problems")
+	}
+	_, _, _, err := zaiCredentials(context.Background())
+	problems.Add(ProviderGLM, err)
+	problems.Add("nope", ErrUnknownProvider)
+	problems.Add(ProviderGroq, errors.New("something odd"))
+
+	want := "glm: unrecognized Z.ai base URL: https://llm.example.com/api/anthropic\n" +
+		"  fix: Set ZAI_ANTHROPIC_BASE_URL to https://api.z.ai/api/anthropic or https://open.bigmodel.cn/api/anthropic, or set ZAI_PLATFORM to ZAI or ZHIPU for a gateway\n" +
+		"nope: unknown provider\n  fix: Use one of: "
+	if got := problems.Err().Error(); !strings.HasPrefix(got, want) {
+		t.Errorf("Unexpected human output:\n%s", got)
+	}
+	if !errors.Is(problems.Err(), ErrUnknownProvider) {
+		t.Error("Expected the collected errors to unwrap")
+	}
+
+	var out bytes.Buffer
+	writeJSON(&out, map[string][]Problem{"problems": problems.Problems})
+	var decoded struct {
+		Problems []map[string]string `json:"problems"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || len(decoded.Problems) != 3 {
+		t.Fatalf("Unexpected JSON output %s, %v", out.String(), err)
+	}
+	if groq := decoded.Problems[2]; groq["provider"] != ProviderGroq || groq["error"] != "something odd" {
+		t.Errorf("Unexpected problem %v", groq)
+	}
+}
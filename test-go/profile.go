@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// profilePrefix starts the variables of a named profile: PROFILE_<NAME>_<VARIABLE>=value
+// sets VARIABLE while the profile is active
+const profilePrefix = "PROFILE_"
+
+// activeProfile is the profile chosen at startup; the config reloader re-applies it
+var activeProfile string
+
+// environMap returns the process environment as a map
+func environMap() map[string]string {
+	vars := make(map[string]string)
+	for _, entry := range os.Environ() {
+		if key, value, found := strings.Cut(entry, "="); found {
+			vars[key] = value
+		}
+	}
+	return vars
+}
+
+// profileOverrides returns the variables a profile sets, read from its PROFILE_<NAME>_ entries.
+// Profile names are matched case-insensitively and cannot contain underscores.
+func profileOverrides(vars map[string]string, name string) map[string]string {
+	prefix := profilePrefix + strings.ToUpper(name) + "_"
+	overrides := make(map[string]string)
+	for key, value := range vars {
+		if variable, ok := strings.CutPrefix(key, prefix); ok && variable != "" {
+			overrides[variable] = value
+		}
+	}
+	return overrides
+}
+
+// profileNames returns the profiles defined in vars, lowercased and sorted
+func profileNames(vars map[string]string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for key := range vars {
+		rest, ok := strings.CutPrefix(key, profilePrefix)
+		if !ok {
+			continue
+		}
+		if name, _, found := strings.Cut(rest, "_"); found && name != "" && !seen[strings.ToLower(name)] {
+			seen[strings.ToLower(name)] = true
+			names = append(names, strings.ToLower(name))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyProfile sets the variables of a profile in the process environment, over .env and
+// the variables already set, and makes it the active profile. It logs nothing, as its
+// output would land in shell prompts; the servers log the active profile when they start.
+func applyProfile(name string) error {
+	vars := environMap()
+	overrides := profileOverrides(vars, name)
+	if len(overrides) == 0 {
+		defined := strings.Join(profileNames(vars), ", ")
+		if defined == "" {
+			defined = "none"
+		}
+		return fmt.Errorf("profile %q is not defined; add PROFILE_%s_<VARIABLE>=value lines to .env (defined: %s)",
+			name, strings.ToUpper(name), defined)
+	}
+
+	for key, value := range overrides {
+		os.Setenv(key, value)
+	}
+	activeProfile = name
+	return nil
+}
+
+// profileFromArgs removes a "--profile name" or "--profile=name" option that comes before
+// the command from the arguments and returns the profile name, or QUOTA_PROFILE when the
+// option is absent. Arguments from the command on, or after "--", are left as they are, as
+// they may belong to a command that guard runs.
+func profileFromArgs(args []string) (string, []string) {
+	profile := os.Getenv("QUOTA_PROFILE")
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--" || !strings.HasPrefix(args[i], "-"):
+			return profile, append(rest, args[i:]...)
+		case args[i] == "--profile" && i+1 < len(args):
+			profile = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], "--profile="):
+			profile = strings.TrimPrefix(args[i], "--profile=")
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return profile, rest
+}
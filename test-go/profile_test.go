@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joho/godotenv"
+)
+
+func TestProfileOverrides(t *testing.T) {
+	vars := map[string]string{
+		"PROFILE_WORK_MODEL_ONLY":    "glm*",
+		"PROFILE_WORK_ZAI_ACCOUNTS":  "team=1.a",
+		"PROFILE_DEMO_OUTPUT_FORMAT": "overview",
+		"PROFILE_WORK_":              "ignored",
+		"MODEL_ONLY":                 "gemini*",
+	}
+
+	want := map[string]string{"MODEL_ONLY": "glm*", "ZAI_ACCOUNTS": "team=1.a"}
+	if got := profileOverrides(vars, "work"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if names := profileNames(vars); !reflect.DeepEqual(names, []string{"demo", "work"}) {
+		t.Errorf("Expected demo and work, got %v", names)
+	}
+}
+
+func TestProfileFromArgs(t *testing.T) {
+	t.Setenv("QUOTA_PROFILE", "personal")
+
+	tests := []struct {
+		args        []string
+		wantProfile string
+		wantRest    []string
+	}{
+		{[]string{"show", "--format", "text"}, "personal", []string{"show", "--format", "text"}},
+		{[]string{"--profile", "work", "show"}, "work", []string{"show"}},
+		{[]string{"--ascii", "--profile=demo", "cost", "--json"}, "demo", []string{"--ascii", "cost", "--json"}},
+		// Options of the command, and the command guard runs, are not the CLI's
+		{[]string{"guard", "--", "claude", "--profile", "x"}, "personal", []string{"guard", "--", "claude", "--profile", "x"}},
+		{[]string{"--profile", "work", "guard", "claude", "--profile=x"}, "work", []string{"guard", "claude", "--profile=x"}},
+	}
+	for _, test := range tests {
+		profile, rest := profileFromArgs(test.args)
+		if profile != test.wantProfile || !reflect.DeepEqual(rest, test.wantRest) {
+			t.Errorf("%v: expected %s %v, got %s %v", test.args, test.wantProfile, test.wantRest, profile, rest)
+		}
+	}
+}
+
+func TestApplyProfileReload(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), ".env")
+	os.WriteFile(envFile, []byte("QUERY_DEBOUNCE=1\nPROFILE_DEMO_QUERY_DEBOUNCE=7\nPROFILE_DEMO_OUTPUT_FORMAT=overview\n"), 0600)
+	t.Cleanup(func() {
+		activeProfile = ""
+		for _, key := range []string{"QUERY_DEBOUNCE", "OUTPUT_FORMAT", "PROFILE_DEMO_QUERY_DEBOUNCE", "PROFILE_DEMO_OUTPUT_FORMAT"} {
+			os.Unsetenv(key)
+		}
+	})
+	godotenv.Load(envFile)
+
+	if err := applyProfile("missing"); err == nil || !strings.Contains(err.Error(), "defined: demo") {
+		t.Errorf("Expected an undefined profile error listing demo, got %v", err)
+	}
+	if err := applyProfile("Demo"); err != nil {
+		t.Fatalf("applyProfile failed: %v", err)
+	}
+	config := LoadConfig()
+	if config.QueryDebounce != 7 || config.OutputFormat != "overview" {
+		t.Errorf("Expected the profile's debounce 7 and overview format, got %d and %s", config.QueryDebounce, config.OutputFormat)
+	}
+
+	// The profile is applied again over a reloaded file
+	client := NewCloudCodeClient(config)
+	reloader := NewConfigReloader(envFile, client)
+	os.WriteFile(envFile, []byte("QUERY_DEBOUNCE=2\nPROFILE_DEMO_QUERY_DEBOUNCE=9\nPROFILE_DEMO_OUTPUT_FORMAT=overview\n"), 0600)
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if client.Config().QueryDebounce != 9 {
+		t.Errorf("Expected the reloaded profile debounce 9, got %d", client.Config().QueryDebounce)
+	}
+}
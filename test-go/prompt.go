@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// Seconds cached quota is trusted before --refresh updates it, unless PROMPT_MAX_AGE is set
+	DefaultPromptMaxAge = 60
+
+	// A refresh started this recently is not started again, so fast prompts do not pile up
+	// background processes
+	promptRefreshCooldown = 30 * time.Second
+
+	// Longest wait for another run writing the same prompt cache; the write is skipped after it
+	promptLockWait = 200 * time.Millisecond
+)
+
+// promptCacheEntry is the quota of one provider as last fetched, for prompt segments
+type promptCacheEntry struct {
+	Fetched int64           `json:"fetched"`
+	Quota   *FormattedQuota `json:"quota"`
+}
+
+// startPromptRefresh starts a background fetch of a provider that updates the prompt cache;
+// replaced in tests
+var startPromptRefresh = func(provider string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	args := []string{"show", "--provider", provider, "--format", "json"}
+	if activeProfile != "" {
+		args = append([]string{"--profile", activeProfile}, args...)
+	}
+	cmd := exec.Command(executable, args...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// promptCacheDir returns PROMPT_CACHE_DIR, else a directory in the user cache directory,
+// or "" when there is none. It reads the environment directly, as prompt segments skip
+// loading the config.
+func promptCacheDir() string {
+	if dir := trimQuotes(os.Getenv("PROMPT_CACHE_DIR")); dir != "" {
+		return dir
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "coding-plan-quota-query")
+}
+
+// promptCachePath returns the cache file of a provider, of the active profile when there is
+// one, as profiles may query different accounts of the same provider
+func promptCachePath(dir, provider string) string {
+	if provider == "" {
+		provider = ProviderAntigravity
+	}
+	if activeProfile != "" {
+		provider = strings.ToLower(activeProfile) + "-" + provider
+	}
+	return filepath.Join(dir, "prompt-"+provider+".json")
+}
+
+// writePromptCache saves a provider's quota for prompt segments. The file is replaced
+// atomically, so a prompt never reads half of it and needs no lock. Writers take the cache's
+// lock file, so concurrent runs do not replace newer data with older; failures only cost
+// prompt freshness.
+func writePromptCache(provider string, quota *FormattedQuota) {
+	dir := promptCacheDir()
+	if dir == "" || os.MkdirAll(dir, 0700) != nil {
+		return
+	}
+	release, err := acquireFileLock(promptCachePath(dir, provider)+".lock", promptLockWait)
+	if err != nil {
+		return
+	}
+	defer release()
+	if entry, err := readPromptCache(dir, provider); err == nil && entry.Quota != nil && entry.Quota.LastUpdated > quota.LastUpdated {
+		return
+	}
+	data, err := json.Marshal(promptCacheEntry{Fetched: clockNow().Unix(), Quota: quota})
+	if err != nil {
+		return
+	}
+	writeFileAtomic(promptCachePath(dir, provider), data, 0600)
+}
+
+// readPromptCache reads the cached quota of a provider
+func readPromptCache(dir, provider string) (promptCacheEntry, error) {
+	var entry promptCacheEntry
+	data, err := os.ReadFile(promptCachePath(dir, provider))
+	if err != nil {
+		return entry, err
+	}
+	err = json.Unmarshal(data, &entry)
+	return entry, err
+}
+
+// isPromptSegment reports whether the arguments ask show for the prompt format, by flag or
+// OUTPUT_FORMAT
+func isPromptSegment(args []string) bool {
+	if len(args) == 0 || args[0] != "show" {
+		return false
+	}
+	format := os.Getenv("OUTPUT_FORMAT")
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name != "format" || !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			value = args[i+1]
+		}
+		format = value
+	}
+	return format == "prompt"
+}
+
+// runPromptSegment prints the badge of a provider from the prompt cache only, never touching
+// the network or loading secrets, so shell prompts can call it on every command. With
+// --refresh, a missing cache or one older than PROMPT_MAX_AGE seconds is updated by a
+// background process for the next prompt.
+func runPromptSegment(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("show", flag.ContinueOnError)
+	provider := flags.String("provider", ProviderAntigravity, "provider to show")
+	flags.String("format", "prompt", "output format")
+	refresh := flags.Bool("refresh", false, "update a stale cache in the background")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	dir := promptCacheDir()
+	entry, err := readPromptCache(dir, *provider)
+	maxAge := time.Duration(getEnvAsInt("PROMPT_MAX_AGE", DefaultPromptMaxAge)) * time.Second
+	if *refresh && dir != "" && (err != nil || clockNow().Sub(time.Unix(entry.Fetched, 0)) > maxAge) {
+		refreshPromptCache(dir, *provider)
+	}
+	if err != nil || entry.Quota == nil {
+		return nil
+	}
+
+	config := &Config{
+		BadgeStyle:   strings.ToLower(getEnvOrDefault("BADGE_STYLE", "emoji")),
+		Icons:        parseIcons(os.Getenv("ICONS")),
+		ASCIIOutput:  getEnvAsBool("ASCII_OUTPUT", false),
+		ModelOnly:    parseList(os.Getenv("MODEL_ONLY")),
+		ModelExclude: parseList(os.Getenv("MODEL_EXCLUDE")),
+	}
+	output := badgeText(config, *selectModels(entry.Quota, config.ModelOnly, config.ModelExclude))
+	if len(output) == 0 {
+		return nil
+	}
+	_, err = stdout.Write(append(output, '\n'))
+	return err
+}
+
+// refreshPromptCache starts a background refresh unless one started within the cooldown,
+// marking the start with a file's modification time. The check and mark happen under the
+// cache's lock file without waiting, so of prompts drawn at once only one starts a refresh,
+// and none while a run is writing the cache.
+func refreshPromptCache(dir, provider string) {
+	if os.MkdirAll(dir, 0700) != nil {
+		return
+	}
+	release, err := acquireFileLock(promptCachePath(dir, provider)+".lock", 0)
+	if err != nil {
+		return
+	}
+	defer release()
+
+	marker := promptCachePath(dir, provider) + ".refresh"
+	if info, err := os.Stat(marker); err == nil && clockNow().Sub(info.ModTime()) < promptRefreshCooldown {
+		return
+	}
+	if os.WriteFile(marker, nil, 0600) != nil {
+		return
+	}
+	now := clockNow()
+	os.Chtimes(marker, now, now)
+	startPromptRefresh(provider)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPromptSegment(t *testing.T) {
+	clk := &fakeClock{t: time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)}
+	defer setClock(clk)()
+	dir := t.TempDir()
+	t.Setenv("PROMPT_CACHE_DIR", dir)
+	t.Setenv("BADGE_STYLE", "emoji")
+	t.Setenv("ASCII_OUTPUT", "true")
+	t.Setenv("ICONS", "")
+	t.Setenv("MODEL_ONLY", "")
+	t.Setenv("MODEL_EXCLUDE", "")
+
+	refreshed := 0
+	original := startPromptRefresh
+	startPromptRefresh = func(provider string) error {
+		if provider != "glm" {
+			t.Errorf("Unexpected refresh of %q", provider)
+		}
+		refreshed++
+		return nil
+	}
+	defer func() { startPromptRefresh = original }()
+
+	// Without a cache the segment is empty, and --refresh fills it in the background
+	var out bytes.Buffer
+	if err := runPromptSegment([]string{"--provider", "glm", "--refresh"}, &out); err != nil || out.Len() != 0 || refreshed != 1 {
+		t.Fatalf("Expected an empty segment and one refresh, got %q, %d, %v", out.String(), refreshed, err)
+	}
+	// A refresh in flight is not started again
+	runPromptSegment([]string{"--provider", "glm", "--refresh"}, &out)
+	if refreshed != 1 {
+		t.Errorf("Expected the refresh cooldown to hold, got %d refreshes", refreshed)
+	}
+
+	writePromptCache("glm", &FormattedQuota{Models: []FormattedModel{{Name: "glm-4.6", Percentage: 15}, {Name: "glm-4.5-air", Percentage: 80}}})
+	out.Reset()
+	if err := runPromptSegment([]string{"--provider", "glm", "--format", "prompt", "--refresh"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "[out] glm-4.6 15%" {
+		t.Errorf("Unexpected segment %q", got)
+	}
+	if refreshed != 1 {
+		t.Errorf("Expected a fresh cache not to be refreshed, got %d refreshes", refreshed)
+	}
+
+	// Past PROMPT_MAX_AGE and the cooldown, the cache is refreshed again
+	t.Setenv("PROMPT_MAX_AGE", "60")
+	clk.Advance(2 * time.Minute)
+	os.Chtimes(filepath.Join(dir, "prompt-glm.json.refresh"), clockNow().Add(-time.Minute), clockNow().Add(-time.Minute))
+	runPromptSegment([]string{"--provider", "glm", "--refresh"}, &out)
+	if refreshed != 2 {
+		t.Errorf("Expected a stale cache to be refreshed, got %d refreshes", refreshed)
+	}
+}
+
+func TestIsPromptSegment(t *testing.T) {
+	t.Setenv("OUTPUT_FORMAT", "")
+	for _, tt := range []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"show", "--format", "prompt"}, true},
+		{[]string{"show", "-format=prompt", "--provider", "glm"}, true},
+		{[]string{"show", "--format", "badge"}, false},
+		{[]string{"serve", "--format", "prompt"}, false},
+	} {
+		if got := isPromptSegment(tt.args); got != tt.want {
+			t.Errorf("isPromptSegment(%v) = %v, expected %v", tt.args, got, tt.want)
+		}
+	}
+	t.Setenv("OUTPUT_FORMAT", "prompt")
+	if !isPromptSegment([]string{"show"}) {
+		t.Error("Expected OUTPUT_FORMAT=prompt to select the prompt segment")
+	}
+}
+
+func TestPromptCacheConcurrentRuns(t *testing.T) {
+	defer setClock(&fakeClock{t: time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)})()
+	dir := t.TempDir()
+	t.Setenv("PROMPT_CACHE_DIR", dir)
+	refreshed := 0
+	defer func(saved func(string) error) { startPromptRefresh = saved }(startPromptRefresh)
+	startPromptRefresh = func(string) error {
+		refreshed++
+		return nil
+	}
+
+	// A run that fetched earlier does not replace the data of one that fetched later
+	writePromptCache("glm", &FormattedQuota{LastUpdated: 200, Models: []FormattedModel{{Name: "newer"}}})
+	writePromptCache("glm", &FormattedQuota{LastUpdated: 100, Models: []FormattedModel{{Name: "older"}}})
+	if entry, err := readPromptCache(dir, "glm"); err != nil || entry.Quota.Models[0].Name != "newer" {
+		t.Errorf("Expected the newer quota to be kept, got %+v, %v", entry.Quota, err)
+	}
+
+	// While another run holds the lock, prompts still read the cache but leave the refresh to it
+	release, err := acquireFileLock(promptCachePath(dir, "glm")+".lock", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshPromptCache(dir, "glm")
+	if _, err := readPromptCache(dir, "glm"); err != nil || refreshed != 0 {
+		t.Errorf("Expected a lock-free read and no refresh, got %d refreshes, %v", refreshed, err)
+	}
+	release()
+	refreshPromptCache(dir, "glm")
+	if refreshed != 1 {
+		t.Errorf("Expected a refresh once the lock is free, got %d", refreshed)
+	}
+}
+
+func TestPromptCachePerProfile(t *testing.T) {
+	defer setClock(&fakeClock{t: time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)})()
+	dir := t.TempDir()
+	t.Setenv("PROMPT_CACHE_DIR", dir)
+	defer func(saved string) { activeProfile = saved }(activeProfile)
+
+	// Two profiles querying different accounts of a provider keep separate caches
+	for profile, percentage := range map[string]float64{"work": 20, "personal": 90} {
+		activeProfile = profile
+		writePromptCache("glm", &FormattedQuota{LastUpdated: 100, Models: []FormattedModel{{Name: "glm", Percentage: percentage}}})
+	}
+	for profile, want := range map[string]float64{"work": 20, "personal": 90} {
+		activeProfile = profile
+		entry, err := readPromptCache(dir, "glm")
+		if err != nil || entry.Quota.Models[0].Percentage != want {
+			t.Errorf("Expected %s's cache to hold %v%%, got %+v, %v", profile, want, entry.Quota, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "prompt-work-glm.json")); err != nil {
+		t.Errorf("Expected the profile in the cache file name: %v", err)
+	}
+
+	// Without a profile the cache is the provider's alone
+	activeProfile = ""
+	if _, err := readPromptCache(dir, "glm"); err == nil {
+		t.Error("Expected no cache without a profile")
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// quotaProviders maps provider names to their fetchers. Antigravity is served by
+// QuotaService itself because it needs the Cloud Code client.
+var quotaProviders = map[string]func(context.Context) (FormattedQuota, error){
+	ProviderGLM:          GetGLMQuota,
+	ProviderZhipuBalance: GetZhipuBalance,
+	ProviderClaudeAI:     GetClaudeUsage,
+	ProviderCursor:       GetCursorUsage,
+	ProviderWindsurf:     GetWindsurfUsage,
+	ProviderXAI:          GetXAIUsage,
+	ProviderGroq:         GetGroqUsage,
+	ProviderMistral:      GetMistralUsage,
+	ProviderLocal:        GetLocalModels,
+}
+
+// providerCache holds responses of providers fetched through cachedFetch, keyed by
+// responseCacheKey
+var providerCache = NewResponseCache()
+
+// providerNames returns every provider accepted by fetchQuota, antigravity first
+func providerNames() []string {
+	names := make([]string, 0, len(quotaProviders))
+	for name := range quotaProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append([]string{ProviderAntigravity}, names...)
+}
+
+// cachedFetch returns the cached value for key while it is fresh, otherwise calls fetch,
+// records health for provider, and caches the result for QUERY_DEBOUNCE minutes.
+// It also returns when the data was fetched.
+func cachedFetch(ctx context.Context, cache *ResponseCache, provider, key string, fetch func() (interface{}, error)) (interface{}, time.Time, error) {
+	if plan := dryRunOf(ctx); plan != nil {
+		plan.observeCacheKey(key)
+		data, err := fetch()
+		return data, clockNow(), err
+	}
+	if entry, exists := cache.Get(key); exists {
+		log.Printf("Returning cached %s data", provider)
+		providerHealth.recordCacheHit(provider)
+		return entry.Data, entry.FetchedAt, nil
+	}
+
+	if err := authFailures.get(key); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	start := time.Now()
+	data, err := fetch()
+	if err != nil {
+		authFailures.observe(key, err)
+		return nil, time.Time{}, err
+	}
+	providerHealth.recordFetch(provider, time.Since(start))
+	fetchedAt := clockNow()
+
+	config := LoadConfig()
+	cache.Set(key, CacheEntry{
+		Data:      data,
+		FetchedAt: fetchedAt,
+		ExpiresAt: fetchedAt.Add(time.Duration(config.QueryDebounce) * time.Minute),
+	}, config.CacheMaxEntries)
+
+	return data, fetchedAt, nil
+}
+
+// runConcurrently runs jobs with at most limit of them at once and waits for all of them
+func runConcurrently(limit int, jobs []func()) {
+	slots := make(chan struct{}, max(limit, 1))
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		slots <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-slots }()
+			job()
+		})
+	}
+	wg.Wait()
+}
+
+// doJSON sends a request for provider with an optional JSON body and decodes a JSON response
+// into out. The provider's configured extra headers are added to headers.
+func doJSON(ctx context.Context, provider, method, url string, headers map[string]string, body, out interface{}) error {
+	_, err := doJSONWithHeaders(ctx, provider, method, url, headers, body, out)
+	return err
+}
+
+// doJSONWithHeaders is doJSON that also returns the response headers, for providers
+// that report rate limits there
+func doJSONWithHeaders(ctx context.Context, provider, method, url string, headers map[string]string, body, out interface{}) (http.Header, error) {
+	var reqBody io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		reqBody = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	config := LoadConfig()
+	if err := setRequestHeaders(req, config, provider); err != nil {
+		return nil, err
+	}
+
+	client, err := signedClient(&http.Client{Timeout: 10 * time.Second, Transport: transportFor(ctx)}, config, provider)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to %s failed: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		snippet := errorBody(io.LimitReader(resp.Body, 512))
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(snippet),
+			Message: fmt.Sprintf("%s API error: status %d - %s", req.URL.Host, resp.StatusCode, string(snippet))}
+	}
+
+	if err := decodeResponse(resp, out); err != nil {
+		return nil, err
+	}
+	return resp.Header, nil
+}
+
+// clampPercentage limits a percentage to the [0, 100] range
+func clampPercentage(pct float64) float64 {
+	return math.Max(0, math.Min(100, pct))
+}
+
+// RateLimit is a limit reported in x-ratelimit-* response headers
+type RateLimit struct {
+	Limit     int
+	Remaining int
+
+	// ResetTime is when the limit is restored (RFC3339), empty when not reported
+	ResetTime string
+}
+
+// parseRateLimit reads x-ratelimit-limit-<kind> and x-ratelimit-remaining-<kind> headers.
+// A Go-style duration in x-ratelimit-reset-<kind> (e.g. "2m59.56s") sets ResetTime.
+func parseRateLimit(header http.Header, kind string) *RateLimit {
+	limit, err := strconv.Atoi(header.Get("x-ratelimit-limit-" + kind))
+	if err != nil {
+		return nil
+	}
+	remaining, err := strconv.Atoi(header.Get("x-ratelimit-remaining-" + kind))
+	if err != nil {
+		return nil
+	}
+
+	rateLimit := &RateLimit{Limit: limit, Remaining: remaining}
+	if reset, err := time.ParseDuration(header.Get("x-ratelimit-reset-" + kind)); err == nil {
+		rateLimit.ResetTime = clockNow().Add(reset).UTC().Format(time.RFC3339)
+	}
+	return rateLimit
+}
+
+// queryRateLimitHeaders lists models with an API key and returns the response headers,
+// which carry the key's rate limits
+func queryRateLimitHeaders(ctx context.Context, provider, modelsURL, apiKey string) (http.Header, error) {
+	var models interface{}
+	name, value := authHeader(LoadConfig(), provider, apiKey)
+	headers := map[string]string{name: value}
+	return doJSONWithHeaders(ctx, provider, "GET", modelsURL, headers, nil, &models)
+}
+
+// rateLimitPercentage returns the remaining share of a rate limit
+func rateLimitPercentage(limit *RateLimit, precision int) float64 {
+	if limit.Limit <= 0 {
+		return QuotaFull
+	}
+	return roundPercentage(clampPercentage(float64(limit.Remaining)/float64(limit.Limit)*100), precision)
+}
+
+// rateLimitModel formats a rate limit as a model entry
+func rateLimitModel(name string, limit *RateLimit, precision int) FormattedModel {
+	model := FormattedModel{
+		Name:       name,
+		Percentage: rateLimitPercentage(limit, precision),
+		ResetTime:  limit.ResetTime,
+	}
+	if limit.ResetTime != "" {
+		model.ResetTimeRelative = formatTimeRemaining(limit.ResetTime)
+	}
+	return model
+}
+
+// rateLimitSpec names the model reported for one x-ratelimit-* header kind
+type rateLimitSpec struct {
+	name string
+	kind string
+}
+
+// rateLimitQuota is the cached result of a header-based provider
+type rateLimitQuota struct {
+	limits map[string]*RateLimit
+}
+
+// getRateLimitQuota formats the rate limits a models endpoint reports for an API key,
+// one model per spec whose headers are present
+func getRateLimitQuota(ctx context.Context, provider, modelsURL, apiKey string, specs []rateLimitSpec) (FormattedQuota, error) {
+	data, fetchedAt, err := cachedFetch(ctx, providerCache, provider, responseCacheKey(provider, "", apiKey, modelsURL), func() (interface{}, error) {
+		header, err := queryRateLimitHeaders(ctx, provider, modelsURL, apiKey)
+		if err != nil {
+			return nil, err
+		}
+		result := rateLimitQuota{limits: make(map[string]*RateLimit)}
+		for _, spec := range specs {
+			if limit := parseRateLimit(header, spec.kind); limit != nil {
+				result.limits[spec.kind] = limit
+			}
+		}
+		return result, nil
+	})
+	if err != nil {
+		return FormattedQuota{}, err
+	}
+
+	config := LoadConfig()
+	limits := data.(rateLimitQuota).limits
+
+	var models []FormattedModel
+	for _, spec := range specs {
+		if limit, ok := limits[spec.kind]; ok {
+			models = append(models, rateLimitModel(spec.name, limit, config.PercentagePrecision))
+		}
+	}
+	if len(models) == 0 {
+		return FormattedQuota{}, fmt.Errorf("%s returned no rate-limit headers", provider)
+	}
+
+	models = applyModelAliases(models, config.ModelAliases)
+
+	quota := FormattedQuota{
+		Models:      models,
+		IsForbidden: false,
+	}
+	stampQuotaTime(&quota, fetchedAt)
+	return quota, nil
+}
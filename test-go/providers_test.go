@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	defer setClock(&fakeClock{t: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)})()
+
+	header := http.Header{}
+	header.Set("x-ratelimit-limit-requests", "480")
+	header.Set("x-ratelimit-remaining-requests", "360")
+	header.Set("x-ratelimit-reset-requests", "2m30s")
+
+	rpm := parseRateLimit(header, "requests")
+	if rpm == nil || rpm.Limit != 480 || rpm.Remaining != 360 {
+		t.Fatalf("Unexpected request limit: %+v", rpm)
+	}
+	if rpm.ResetTime != "2026-03-01T12:02:30Z" {
+		t.Errorf("Expected reset at 12:02:30, got %s", rpm.ResetTime)
+	}
+	if tpm := parseRateLimit(header, "tokens"); tpm != nil {
+		t.Errorf("Expected nil token limit without headers, got %+v", tpm)
+	}
+}
+
+func TestGetGroqUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openai/v1/models" || r.Header.Get("Authorization") != "Bearer gsk-key" {
+			t.Errorf("Unexpected request %s with %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		w.Header().Set("x-ratelimit-limit-requests", "14400")
+		w.Header().Set("x-ratelimit-remaining-requests", "1440")
+		w.Header().Set("x-ratelimit-reset-requests", "21h36m")
+		w.Header().Set("x-ratelimit-limit-tokens", "6000")
+		w.Header().Set("x-ratelimit-remaining-tokens", "6000")
+		fmt.Fprint(w, `{"object":"list","data":[]}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("GROQ_API_KEY", "gsk-key")
+	t.Setenv("GROQ_BASE_URL", server.URL+"/openai")
+
+	quota, err := GetGroqUsage(context.Background())
+	if err != nil {
+		t.Fatalf("GetGroqUsage failed: %v", err)
+	}
+	if len(quota.Models) != 2 || quota.Models[0].Name != "groq-requests-daily" || quota.Models[0].Percentage != 10 {
+		t.Errorf("Unexpected Groq models: %+v", quota.Models)
+	}
+	if quota.Models[0].ResetTimeRelative == "" || quota.Models[1].Percentage != 100 {
+		t.Errorf("Unexpected Groq reset or token limit: %+v", quota.Models)
+	}
+}
+
+func TestGetMistralUsageWithoutHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"object":"list","data":[]}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("MISTRAL_API_KEY", "mistral-key")
+	t.Setenv("MISTRAL_BASE_URL", server.URL)
+
+	if _, err := GetMistralUsage(context.Background()); err == nil {
+		t.Error("Expected error when no rate-limit headers are returned")
+	}
+}
+
+func TestGetMistralUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-ratelimit-limit-tokens-minute", "500000")
+		w.Header().Set("x-ratelimit-remaining-tokens-minute", "250000")
+		w.Header().Set("x-ratelimit-limit-tokens-month", "1000000000")
+		w.Header().Set("x-ratelimit-remaining-tokens-month", "900000000")
+		fmt.Fprint(w, `{"object":"list","data":[]}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("MISTRAL_API_KEY", "mistral-key")
+	t.Setenv("MISTRAL_BASE_URL", server.URL+"/")
+
+	quota, err := GetMistralUsage(context.Background())
+	if err != nil {
+		t.Fatalf("GetMistralUsage failed: %v", err)
+	}
+	if len(quota.Models) != 2 || quota.Models[0].Percentage != 50 || quota.Models[1].Percentage != 90 {
+		t.Errorf("Unexpected Mistral models: %+v", quota.Models)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/user"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// Longest wait for the hub to accept a batch of snapshots
+	hubPushTimeout = 10 * time.Second
+
+	// Most snapshots sent to the hub in one request
+	hubPushBatch = 100
+
+	// Snapshots kept while the hub is unreachable unless HUB_BUFFER is set; the oldest are
+	// dropped beyond it
+	DefaultHubBuffer = 1000
+
+	// Wait before the first retry of a failed push, doubled up to hubRetryMax
+	hubRetryMin = 5 * time.Second
+	hubRetryMax = 5 * time.Minute
+)
+
+// hubPusher ships every freshly fetched snapshot to the hub in HUB_URL. Snapshots are
+// buffered in order while the hub is unreachable and sent again with backoff, so a laptop
+// that drops off the network keeps what it fetched meanwhile.
+type hubPusher struct {
+	mu       sync.Mutex
+	pushed   map[string]int64
+	pending  []HubSnapshot
+	inFlight int
+	config   *Config
+	sending  bool
+	client   *http.Client
+	retryMin time.Duration
+	host     string
+	login    string
+}
+
+var quotaHub = newHubPusher()
+
+// newHubPusher creates a pusher labeling snapshots with this machine's host name and login
+func newHubPusher() *hubPusher {
+	host, _ := os.Hostname()
+	login := ""
+	if current, err := user.Current(); err == nil {
+		login = current.Username
+	}
+	return &hubPusher{
+		pushed:   make(map[string]int64),
+		client:   &http.Client{Timeout: hubPushTimeout},
+		retryMin: hubRetryMin,
+		host:     host,
+		login:    login,
+	}
+}
+
+// push queues a provider's quota for the hub and starts sending when not already. Cached
+// data repeats its fetch time and is not queued again.
+func (p *hubPusher) push(config *Config, provider string, quota *FormattedQuota) {
+	if config.HubURL == "" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if quota.LastUpdated <= p.pushed[provider] {
+		return
+	}
+	p.pushed[provider] = quota.LastUpdated
+	p.config = config
+
+	limit := max(config.HubBuffer, 1)
+	p.pending = append(p.pending, HubSnapshot{Provider: provider, Quota: *quota, Host: p.host, Login: p.login})
+	if dropped := len(p.pending) - limit; dropped > 0 {
+		log.Printf("Hub buffer full, dropping the %d oldest snapshots", dropped)
+		p.pending = p.pending[dropped:]
+		p.inFlight = max(p.inFlight-dropped, 0)
+	}
+	if !p.sending {
+		p.sending = true
+		go p.drain()
+	}
+}
+
+// drain sends the buffered snapshots in batches until none are left, waiting longer after
+// each failure. A hub that refuses a batch as invalid gets it dropped instead of retried.
+func (p *hubPusher) drain() {
+	delay := p.retryMin
+	for {
+		p.mu.Lock()
+		if len(p.pending) == 0 {
+			p.sending = false
+			p.mu.Unlock()
+			return
+		}
+		batch := slices.Clone(p.pending[:min(len(p.pending), hubPushBatch)])
+		p.inFlight = len(batch)
+		config := p.config
+		p.mu.Unlock()
+
+		retry, err := p.send(config, batch)
+		if err != nil && retry {
+			log.Printf("Failed to push quota to the hub, retrying in %s: %v", delay, err)
+			time.Sleep(delay)
+			delay = min(delay*2, hubRetryMax)
+			continue
+		}
+		if err != nil {
+			log.Printf("The hub refused %d snapshots: %v", len(batch), err)
+		}
+		delay = p.retryMin
+
+		// Snapshots dropped from a full buffer meanwhile were taken from the batch
+		p.mu.Lock()
+		p.pending = p.pending[p.inFlight:]
+		p.inFlight = 0
+		p.mu.Unlock()
+	}
+}
+
+// send posts a batch to the hub. It reports whether a failure is worth retrying: network
+// errors and server errors are, a refused token or snapshot is not.
+func (p *hubPusher) send(config *Config, batch []HubSnapshot) (bool, error) {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequest(http.MethodPost, config.HubURL+"/push", bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	token, err := readToken(context.Background(), config.HubToken)
+	if err != nil {
+		return true, fmt.Errorf("failed to read HUB_TOKEN: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("status %d", resp.StatusCode)
+	case resp.StatusCode >= http.StatusBadRequest:
+		return false, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return false, nil
+}
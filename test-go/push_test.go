@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHubPusherBuffersWhileOffline(t *testing.T) {
+	config := &Config{HubTokens: staticToken("alice=alice-token")}
+	hub := &hubServer{config: config, state: newHubState()}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	var online atomic.Bool
+	var refused atomic.Int32
+	r.Use(func(c *gin.Context) {
+		if !online.Load() {
+			refused.Add(1)
+			c.AbortWithStatus(http.StatusServiceUnavailable)
+		}
+	})
+	setupHubRoutes(r, hub)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	pusher := newHubPusher()
+	pusher.retryMin = 10 * time.Millisecond
+	client := &Config{HubURL: server.URL, HubToken: staticToken("alice-token"), HubBuffer: 2}
+	for i, percentage := range []float64{90, 80, 70} {
+		quota := &FormattedQuota{Models: []FormattedModel{{Name: "glm", Percentage: percentage}}, LastUpdated: int64(1000 + i)}
+		pusher.push(client, "glm", quota)
+	}
+	// A repeated fetch time is cached data and is not queued
+	pusher.push(client, "glm", &FormattedQuota{LastUpdated: 1002})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for refused.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	pusher.mu.Lock()
+	buffered := len(pusher.pending)
+	pusher.mu.Unlock()
+	if buffered != 2 {
+		t.Errorf("Expected the buffer to keep the 2 newest snapshots while offline, got %d", buffered)
+	}
+
+	online.Store(true)
+	for time.Now().Before(deadline) {
+		pusher.mu.Lock()
+		sending := pusher.sending
+		pusher.mu.Unlock()
+		if !sending {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	users := hub.state.users(nil)
+	if len(users) != 1 || len(users[0].Snapshots) != 1 {
+		t.Fatalf("Expected alice's glm snapshot, got %+v", users)
+	}
+	snapshot := users[0].Snapshots[0]
+	if snapshot.Quota.Models[0].Percentage != 70 || snapshot.Host != pusher.host || snapshot.Host == "" {
+		t.Errorf("Expected the newest snapshot labeled with the host, got %+v", snapshot)
+	}
+
+	// A snapshot older than the hub's is ignored when it arrives late
+	hub.state.store("alice", HubSnapshot{Provider: "glm", Quota: FormattedQuota{LastUpdated: 999}})
+	if got := hub.state.users(nil)[0].Snapshots[0].Quota.LastUpdated; got != 1002 {
+		t.Errorf("Expected the newer snapshot to be kept, got %d", got)
+	}
+}
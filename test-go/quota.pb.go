@@ -0,0 +1,342 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: proto/quota.proto
+
+package main
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetQuotaRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Provider name, as for /quota/stream?provider=; defaults to "antigravity".
+	Provider      string `protobuf:"bytes,1,opt,name=provider,proto3" json:"provider,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetQuotaRequest) Reset() {
+	*x = GetQuotaRequest{}
+	mi := &file_proto_quota_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetQuotaRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetQuotaRequest) ProtoMessage() {}
+
+func (x *GetQuotaRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_quota_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetQuotaRequest.ProtoReflect.Descriptor instead.
+func (*GetQuotaRequest) Descriptor() ([]byte, []int) {
+	return file_proto_quota_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetQuotaRequest) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+type WatchQuotaRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Provider name, as for /quota/stream?provider=; defaults to "antigravity".
+	Provider string `protobuf:"bytes,1,opt,name=provider,proto3" json:"provider,omitempty"`
+	// Poll interval in seconds; defaults to the QUERY_DEBOUNCE interval.
+	IntervalSeconds int32 `protobuf:"varint,2,opt,name=interval_seconds,json=intervalSeconds,proto3" json:"interval_seconds,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *WatchQuotaRequest) Reset() {
+	*x = WatchQuotaRequest{}
+	mi := &file_proto_quota_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchQuotaRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchQuotaRequest) ProtoMessage() {}
+
+func (x *WatchQuotaRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_quota_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchQuotaRequest.ProtoReflect.Descriptor instead.
+func (*WatchQuotaRequest) Descriptor() ([]byte, []int) {
+	return file_proto_quota_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *WatchQuotaRequest) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *WatchQuotaRequest) GetIntervalSeconds() int32 {
+	if x != nil {
+		return x.IntervalSeconds
+	}
+	return 0
+}
+
+type ModelQuota struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Name              string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Percentage        float64                `protobuf:"fixed64,2,opt,name=percentage,proto3" json:"percentage,omitempty"`
+	ResetTime         string                 `protobuf:"bytes,3,opt,name=reset_time,json=resetTime,proto3" json:"reset_time,omitempty"`
+	ResetTimeRelative string                 `protobuf:"bytes,4,opt,name=reset_time_relative,json=resetTimeRelative,proto3" json:"reset_time_relative,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *ModelQuota) Reset() {
+	*x = ModelQuota{}
+	mi := &file_proto_quota_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ModelQuota) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ModelQuota) ProtoMessage() {}
+
+func (x *ModelQuota) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_quota_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ModelQuota.ProtoReflect.Descriptor instead.
+func (*ModelQuota) Descriptor() ([]byte, []int) {
+	return file_proto_quota_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ModelQuota) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ModelQuota) GetPercentage() float64 {
+	if x != nil {
+		return x.Percentage
+	}
+	return 0
+}
+
+func (x *ModelQuota) GetResetTime() string {
+	if x != nil {
+		return x.ResetTime
+	}
+	return ""
+}
+
+func (x *ModelQuota) GetResetTimeRelative() string {
+	if x != nil {
+		return x.ResetTimeRelative
+	}
+	return ""
+}
+
+type QuotaSnapshot struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Provider      string                 `protobuf:"bytes,1,opt,name=provider,proto3" json:"provider,omitempty"`
+	Models        []*ModelQuota          `protobuf:"bytes,2,rep,name=models,proto3" json:"models,omitempty"`
+	LastUpdated   int64                  `protobuf:"varint,3,opt,name=last_updated,json=lastUpdated,proto3" json:"last_updated,omitempty"`
+	IsForbidden   bool                   `protobuf:"varint,4,opt,name=is_forbidden,json=isForbidden,proto3" json:"is_forbidden,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QuotaSnapshot) Reset() {
+	*x = QuotaSnapshot{}
+	mi := &file_proto_quota_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QuotaSnapshot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QuotaSnapshot) ProtoMessage() {}
+
+func (x *QuotaSnapshot) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_quota_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QuotaSnapshot.ProtoReflect.Descriptor instead.
+func (*QuotaSnapshot) Descriptor() ([]byte, []int) {
+	return file_proto_quota_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *QuotaSnapshot) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *QuotaSnapshot) GetModels() []*ModelQuota {
+	if x != nil {
+		return x.Models
+	}
+	return nil
+}
+
+func (x *QuotaSnapshot) GetLastUpdated() int64 {
+	if x != nil {
+		return x.LastUpdated
+	}
+	return 0
+}
+
+func (x *QuotaSnapshot) GetIsForbidden() bool {
+	if x != nil {
+		return x.IsForbidden
+	}
+	return false
+}
+
+var File_proto_quota_proto protoreflect.FileDescriptor
+
+const file_proto_quota_proto_rawDesc = "" +
+	"\n" +
+	"\x11proto/quota.proto\x12\bquota.v1\"-\n" +
+	"\x0fGetQuotaRequest\x12\x1a\n" +
+	"\bprovider\x18\x01 \x01(\tR\bprovider\"Z\n" +
+	"\x11WatchQuotaRequest\x12\x1a\n" +
+	"\bprovider\x18\x01 \x01(\tR\bprovider\x12)\n" +
+	"\x10interval_seconds\x18\x02 \x01(\x05R\x0fintervalSeconds\"\x8f\x01\n" +
+	"\n" +
+	"ModelQuota\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1e\n" +
+	"\n" +
+	"percentage\x18\x02 \x01(\x01R\n" +
+	"percentage\x12\x1d\n" +
+	"\n" +
+	"reset_time\x18\x03 \x01(\tR\tresetTime\x12.\n" +
+	"\x13reset_time_relative\x18\x04 \x01(\tR\x11resetTimeRelative\"\x9f\x01\n" +
+	"\rQuotaSnapshot\x12\x1a\n" +
+	"\bprovider\x18\x01 \x01(\tR\bprovider\x12,\n" +
+	"\x06models\x18\x02 \x03(\v2\x14.quota.v1.ModelQuotaR\x06models\x12!\n" +
+	"\flast_updated\x18\x03 \x01(\x03R\vlastUpdated\x12!\n" +
+	"\fis_forbidden\x18\x04 \x01(\bR\visForbidden2\x8d\x01\n" +
+	"\x05Quota\x12>\n" +
+	"\bGetQuota\x12\x19.quota.v1.GetQuotaRequest\x1a\x17.quota.v1.QuotaSnapshot\x12D\n" +
+	"\n" +
+	"WatchQuota\x12\x1b.quota.v1.WatchQuotaRequest\x1a\x17.quota.v1.QuotaSnapshot0\x01B\tZ\a./;mainb\x06proto3"
+
+var (
+	file_proto_quota_proto_rawDescOnce sync.Once
+	file_proto_quota_proto_rawDescData []byte
+)
+
+func file_proto_quota_proto_rawDescGZIP() []byte {
+	file_proto_quota_proto_rawDescOnce.Do(func() {
+		file_proto_quota_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto_quota_proto_rawDesc), len(file_proto_quota_proto_rawDesc)))
+	})
+	return file_proto_quota_proto_rawDescData
+}
+
+var file_proto_quota_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_proto_quota_proto_goTypes = []any{
+	(*GetQuotaRequest)(nil),   // 0: quota.v1.GetQuotaRequest
+	(*WatchQuotaRequest)(nil), // 1: quota.v1.WatchQuotaRequest
+	(*ModelQuota)(nil),        // 2: quota.v1.ModelQuota
+	(*QuotaSnapshot)(nil),     // 3: quota.v1.QuotaSnapshot
+}
+var file_proto_quota_proto_depIdxs = []int32{
+	2, // 0: quota.v1.QuotaSnapshot.models:type_name -> quota.v1.ModelQuota
+	0, // 1: quota.v1.Quota.GetQuota:input_type -> quota.v1.GetQuotaRequest
+	1, // 2: quota.v1.Quota.WatchQuota:input_type -> quota.v1.WatchQuotaRequest
+	3, // 3: quota.v1.Quota.GetQuota:output_type -> quota.v1.QuotaSnapshot
+	3, // 4: quota.v1.Quota.WatchQuota:output_type -> quota.v1.QuotaSnapshot
+	3, // [3:5] is the sub-list for method output_type
+	1, // [1:3] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_proto_quota_proto_init() }
+func file_proto_quota_proto_init() {
+	if File_proto_quota_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_quota_proto_rawDesc), len(file_proto_quota_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_proto_quota_proto_goTypes,
+		DependencyIndexes: file_proto_quota_proto_depIdxs,
+		MessageInfos:      file_proto_quota_proto_msgTypes,
+	}.Build()
+	File_proto_quota_proto = out.File
+	file_proto_quota_proto_goTypes = nil
+	file_proto_quota_proto_depIdxs = nil
+}
@@ -0,0 +1,173 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: proto/quota.proto
+
+package main
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Quota_GetQuota_FullMethodName   = "/quota.v1.Quota/GetQuota"
+	Quota_WatchQuota_FullMethodName = "/quota.v1.Quota/WatchQuota"
+)
+
+// QuotaClient is the client API for Quota service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Quota serves the same data as the /quota HTTP endpoints.
+type QuotaClient interface {
+	// GetQuota returns the current quota snapshot for a provider.
+	GetQuota(ctx context.Context, in *GetQuotaRequest, opts ...grpc.CallOption) (*QuotaSnapshot, error)
+	// WatchQuota sends the current snapshot, then a new one whenever any
+	// model's percentage changes.
+	WatchQuota(ctx context.Context, in *WatchQuotaRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[QuotaSnapshot], error)
+}
+
+type quotaClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewQuotaClient(cc grpc.ClientConnInterface) QuotaClient {
+	return &quotaClient{cc}
+}
+
+func (c *quotaClient) GetQuota(ctx context.Context, in *GetQuotaRequest, opts ...grpc.CallOption) (*QuotaSnapshot, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(QuotaSnapshot)
+	err := c.cc.Invoke(ctx, Quota_GetQuota_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *quotaClient) WatchQuota(ctx context.Context, in *WatchQuotaRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[QuotaSnapshot], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Quota_ServiceDesc.Streams[0], Quota_WatchQuota_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchQuotaRequest, QuotaSnapshot]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Quota_WatchQuotaClient = grpc.ServerStreamingClient[QuotaSnapshot]
+
+// QuotaServer is the server API for Quota service.
+// All implementations must embed UnimplementedQuotaServer
+// for forward compatibility.
+//
+// Quota serves the same data as the /quota HTTP endpoints.
+type QuotaServer interface {
+	// GetQuota returns the current quota snapshot for a provider.
+	GetQuota(context.Context, *GetQuotaRequest) (*QuotaSnapshot, error)
+	// WatchQuota sends the current snapshot, then a new one whenever any
+	// model's percentage changes.
+	WatchQuota(*WatchQuotaRequest, grpc.ServerStreamingServer[QuotaSnapshot]) error
+	mustEmbedUnimplementedQuotaServer()
+}
+
+// UnimplementedQuotaServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedQuotaServer struct{}
+
+func (UnimplementedQuotaServer) GetQuota(context.Context, *GetQuotaRequest) (*QuotaSnapshot, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetQuota not implemented")
+}
+func (UnimplementedQuotaServer) WatchQuota(*WatchQuotaRequest, grpc.ServerStreamingServer[QuotaSnapshot]) error {
+	return status.Error(codes.Unimplemented, "method WatchQuota not implemented")
+}
+func (UnimplementedQuotaServer) mustEmbedUnimplementedQuotaServer() {}
+func (UnimplementedQuotaServer) testEmbeddedByValue()               {}
+
+// UnsafeQuotaServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to QuotaServer will
+// result in compilation errors.
+type UnsafeQuotaServer interface {
+	mustEmbedUnimplementedQuotaServer()
+}
+
+func RegisterQuotaServer(s grpc.ServiceRegistrar, srv QuotaServer) {
+	// If the following call panics, it indicates UnimplementedQuotaServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Quota_ServiceDesc, srv)
+}
+
+func _Quota_GetQuota_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetQuotaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(QuotaServer).GetQuota(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Quota_GetQuota_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(QuotaServer).GetQuota(ctx, req.(*GetQuotaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Quota_WatchQuota_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchQuotaRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(QuotaServer).WatchQuota(m, &grpc.GenericServerStream[WatchQuotaRequest, QuotaSnapshot]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Quota_WatchQuotaServer = grpc.ServerStreamingServer[QuotaSnapshot]
+
+// Quota_ServiceDesc is the grpc.ServiceDesc for Quota service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Quota_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "quota.v1.Quota",
+	HandlerType: (*QuotaServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetQuota",
+			Handler:    _Quota_GetQuota_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchQuota",
+			Handler:       _Quota_WatchQuota_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "proto/quota.proto",
+}
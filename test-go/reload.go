@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/joho/godotenv"
+)
+
+// Config fields whose values are never written to the log
+var secretConfigFields = map[string]bool{
+	"ClientSecret":       true,
+	"ClaudeOAuthToken":   true,
+	"CursorSessionToken": true,
+	"WindsurfAPIKey":     true,
+	"XAIAPIKey":          true,
+	"XAIManagementKey":   true,
+	"GroqAPIKey":         true,
+	"MistralAPIKey":      true,
+	"ZAIAccounts":        true,
+	"ZAIAuthToken":       true,
+	"AntigravityToken":   true,
+	"ServerToken":        true,
+	"EventsToken":        true,
+	"DashboardToken":     true,
+	"ViewerToken":        true,
+	"SMTPPassword":       true,
+	"NtfyToken":          true,
+	"PushoverToken":      true,
+	"PushoverUser":       true,
+	"PagerDutyKey":       true,
+	"OpsgenieKey":        true,
+	"HistoryToken":       true,
+	"HistoryTokens":      true,
+	"HubToken":           true,
+	"HubTokens":          true,
+	"RequestHeaders":     true,
+}
+
+// ConfigReloader re-reads the .env file and swaps the client configuration in place,
+// keeping cached quota data
+type ConfigReloader struct {
+	path   string
+	client *CloudCodeClient
+
+	mu       sync.Mutex
+	values   map[string]string
+	external map[string]bool
+}
+
+// NewConfigReloader creates a reloader for the given .env file. Variables already set
+// in the process environment to a different value win over the file, as with godotenv.Load,
+// and the active profile is applied over both after every reload.
+func NewConfigReloader(path string, client *CloudCodeClient) *ConfigReloader {
+	values, _ := godotenv.Read(path)
+
+	// Variables set by the active profile differ from the file but are not external
+	profiled := profileOverrides(environMap(), activeProfile)
+	external := make(map[string]bool)
+	for key, value := range values {
+		if _, ok := profiled[key]; ok && activeProfile != "" {
+			continue
+		}
+		if current, exists := os.LookupEnv(key); exists && current != value {
+			external[key] = true
+		}
+	}
+
+	return &ConfigReloader{
+		path:     path,
+		client:   client,
+		values:   values,
+		external: external,
+	}
+}
+
+// Reload applies the current .env contents and logs what changed
+func (r *ConfigReloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	values, err := godotenv.Read(r.path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", r.path, err)
+	}
+
+	for key := range r.values {
+		if _, exists := values[key]; !exists && !r.external[key] {
+			os.Unsetenv(key)
+		}
+	}
+	for key, value := range values {
+		if !r.external[key] {
+			os.Setenv(key, value)
+		}
+	}
+	r.values = values
+	if activeProfile != "" {
+		if err := applyProfile(activeProfile); err != nil {
+			log.Printf("Warning: %v; keeping the .env values", err)
+		}
+	}
+
+	previous := r.client.Config()
+	current := LoadConfig()
+	r.client.SetConfig(current)
+	applyNetworkConfig(current)
+
+	changes := diffConfig(previous, current)
+	if len(changes) == 0 {
+		log.Printf("Config reloaded from %s: no changes", r.path)
+	}
+	for _, change := range changes {
+		log.Printf("Config reloaded from %s: %s", r.path, change)
+	}
+	return nil
+}
+
+// Watch reloads the configuration when the .env file changes or on SIGHUP.
+// It blocks until ctx is done or the watcher fails.
+func (r *ConfigReloader) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	// Watch the directory so editors that replace the file are still noticed
+	if err := watcher.Add(filepath.Dir(r.path)); err != nil {
+		return err
+	}
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	target := filepath.Clean(r.path)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(event.Name) != target || !event.Has(fsnotify.Write|fsnotify.Create) {
+				continue
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("Config watcher error: %v", err)
+			continue
+		case <-hangup:
+			log.Println("Received SIGHUP, reloading config")
+		}
+
+		if err := r.Reload(); err != nil {
+			log.Printf("Config reload failed: %v", err)
+		}
+	}
+}
+
+// diffConfig describes the fields that differ between two configurations
+func diffConfig(previous, current *Config) []string {
+	var changes []string
+
+	prevValue := reflect.ValueOf(previous).Elem()
+	currValue := reflect.ValueOf(current).Elem()
+	for i := 0; i < prevValue.NumField(); i++ {
+		field := prevValue.Type().Field(i)
+		before := prevValue.Field(i).Interface()
+		after := currValue.Field(i).Interface()
+		if reflect.DeepEqual(before, after) {
+			continue
+		}
+
+		if secretConfigFields[field.Name] {
+			changes = append(changes, fmt.Sprintf("%s changed", field.Name))
+		} else {
+			changes = append(changes, fmt.Sprintf("%s: %v -> %v", field.Name, before, after))
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigReloader(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(envFile, []byte("QUERY_DEBOUNCE=1\n"), 0600); err != nil {
+		t.Fatalf("Failed to write env file: %v", err)
+	}
+	os.Setenv("QUERY_DEBOUNCE", "1")
+	t.Cleanup(func() {
+		os.Unsetenv("QUERY_DEBOUNCE")
+		os.Unsetenv("MODEL_ALIASES")
+	})
+
+	client := NewCloudCodeClient(LoadConfig())
+	client.cache["quota"] = &QuotaResponse{}
+	reloader := NewConfigReloader(envFile, client)
+
+	// Changed and added values are applied
+	os.WriteFile(envFile, []byte("QUERY_DEBOUNCE=5\nMODEL_ALIASES=glm=Z\n"), 0600)
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if client.Config().QueryDebounce != 5 || client.Config().ModelAliases["glm"] != "Z" {
+		t.Errorf("Expected reloaded debounce 5 and glm alias, got %d and %v", client.Config().QueryDebounce, client.Config().ModelAliases)
+	}
+
+	// Removed values fall back to defaults
+	os.WriteFile(envFile, []byte("MODEL_ALIASES=glm=Z\n"), 0600)
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if client.Config().QueryDebounce != 1 {
+		t.Errorf("Expected default debounce 1 after removal, got %d", client.Config().QueryDebounce)
+	}
+
+	// Cached data survives reloads
+	if _, exists := client.cache["quota"]; !exists {
+		t.Error("Expected cache to be kept across reloads")
+	}
+}
+
+func TestConfigReloaderWatchStopsWithContext(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), ".env")
+	os.WriteFile(envFile, []byte("QUERY_DEBOUNCE=1\n"), 0600)
+	os.Setenv("QUERY_DEBOUNCE", "1")
+	t.Cleanup(func() { os.Unsetenv("QUERY_DEBOUNCE") })
+
+	client := NewCloudCodeClient(LoadConfig())
+	reloader := NewConfigReloader(envFile, client)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- reloader.Watch(ctx) }()
+
+	// Writes are reloaded while the watcher runs
+	deadline := time.Now().Add(5 * time.Second)
+	for client.Config().QueryDebounce != 7 && time.Now().Before(deadline) {
+		os.WriteFile(envFile, []byte("QUERY_DEBOUNCE=7\n"), 0600)
+		time.Sleep(20 * time.Millisecond)
+	}
+	if client.Config().QueryDebounce != 7 {
+		t.Fatalf("Expected the watcher to reload the changed file, got debounce %d", client.Config().QueryDebounce)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected the watcher to stop cleanly, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the watcher to stop when its context is done")
+	}
+}
+
+func TestDiffConfig(t *testing.T) {
+	previous := &Config{QueryDebounce: 1, ClientSecret: staticToken("old-secret")}
+	current := &Config{QueryDebounce: 5, ClientSecret: staticToken("new-secret")}
+
+	changes := strings.Join(diffConfig(previous, current), "\n")
+
+	if !strings.Contains(changes, "QueryDebounce: 1 -> 5") {
+		t.Errorf("Expected debounce change, got %q", changes)
+	}
+	if strings.Contains(changes, "secret") {
+		t.Errorf("Expected secrets to be redacted, got %q", changes)
+	}
+	if !strings.Contains(changes, "ClientSecret changed") {
+		t.Errorf("Expected ClientSecret change to be reported, got %q", changes)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBuiltinRenderers(t *testing.T) {
+	antigravity := FormattedQuota{Models: []FormattedModel{
+		{Name: "gemini-3-pro-high", Percentage: 95},
+		{Name: "gemini-3-flash", Percentage: 100},
+		{Name: "claude-sonnet-4-5-thinking", Percentage: 10},
+		{Name: "claude-sonnet-4-5", Percentage: 80},
+	}}
+
+	tests := []struct {
+		format string
+		quota  FormattedQuota
+		want   string
+	}{
+		{"overview", antigravity, "Pro 95% | Flash 100% | Claude 80%"},
+		{"status", antigravity, "G \033[32m95%\033[0m | \033[32mF\033[0m | 󰛄 \033[32m80%\033[0m"},
+		{"status-zai", FormattedQuota{Models: []FormattedModel{{Name: "glm", Percentage: 0}}}, "\033[31mZ\033[0m"},
+		{"text", FormattedQuota{Models: []FormattedModel{{Name: "glm", Percentage: 64}}}, "glm: 64%\n"},
+		{"balance", FormattedQuota{Models: []FormattedModel{{Name: "zhipu-balance", Balance: &Balance{Display: "¥42.10"}}, {Name: "other"}}}, "zhipu-balance ¥42.10"},
+	}
+	for _, test := range tests {
+		output, err := renderQuota(test.format, test.quota)
+		if err != nil || string(output) != test.want {
+			t.Errorf("%s: expected %q, got %q (%v)", test.format, test.want, output, err)
+		}
+	}
+
+	if _, err := renderQuota("yaml", antigravity); err == nil || !strings.Contains(err.Error(), "overview") {
+		t.Errorf("Expected an unknown format error listing the formats, got %v", err)
+	}
+}
+
+func TestRegisterRenderer(t *testing.T) {
+	RegisterRenderer("count", RendererFunc(func(quota FormattedQuota) ([]byte, error) {
+		return []byte(strings.Repeat("*", len(quota.Models))), nil
+	}))
+	defer func() {
+		renderersMu.Lock()
+		delete(renderers, "count")
+		renderersMu.Unlock()
+	}()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	service := &QuotaService{client: NewCloudCodeClient(LoadConfig())}
+	router.GET("/quota", func(c *gin.Context) {
+		service.respondQuota(c, &FormattedQuota{Models: []FormattedModel{{Name: "a"}, {Name: "b"}}}, nil)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/quota?format=count", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "**" || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("Expected the custom renderer output, got %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/quota?format=missing", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown format, got %d", w.Code)
+	}
+}
+
+func TestArrangeModels(t *testing.T) {
+	quota := &FormattedQuota{Models: []FormattedModel{
+		{Name: "glm", Percentage: 60, Provider: ProviderGLM},
+		{Name: "gemini-3-flash", Percentage: 90, Provider: ProviderAntigravity},
+		{Name: "glm:team", Percentage: 20, Provider: ProviderGLM, Account: "team"},
+		{Name: "Claude-sonnet-4-5", Percentage: 60, Provider: ProviderAntigravity},
+	}}
+	names := func(quota *FormattedQuota) string {
+		var result []string
+		for _, model := range quota.Models {
+			result = append(result, model.Name)
+		}
+		return strings.Join(result, ",")
+	}
+
+	cases := []struct {
+		sort, group, want string
+	}{
+		{"name", "", "Claude-sonnet-4-5,gemini-3-flash,glm,glm:team"},
+		{"percentage", "", "glm:team,Claude-sonnet-4-5,glm,gemini-3-flash"},
+		{"-percentage", "", "gemini-3-flash,glm,Claude-sonnet-4-5,glm:team"},
+		{"provider", "", "gemini-3-flash,Claude-sonnet-4-5,glm,glm:team"},
+		{"", "provider", "glm,glm:team,gemini-3-flash,Claude-sonnet-4-5"},
+		{"percentage", "account", "glm:team,Claude-sonnet-4-5,gemini-3-flash,glm"},
+	}
+	for _, tc := range cases {
+		arranged, err := arrangeModels(quota, tc.sort, tc.group)
+		if err != nil {
+			t.Fatalf("arrangeModels(%q, %q) failed: %v", tc.sort, tc.group, err)
+		}
+		if got := names(arranged); got != tc.want {
+			t.Errorf("arrangeModels(%q, %q) = %s, want %s", tc.sort, tc.group, got, tc.want)
+		}
+	}
+	if names(quota) != "glm,gemini-3-flash,glm:team,Claude-sonnet-4-5" {
+		t.Errorf("Expected the input to be left alone, got %s", names(quota))
+	}
+
+	grouped, _ := arrangeModels(quota, "name", "account")
+	output, _ := renderText(*grouped)
+	want := "[antigravity]\nClaude-sonnet-4-5: 60%\ngemini-3-flash: 90%\n[glm]\nglm: 60%\n[glm:team]\nglm:team: 20%\n"
+	if string(output) != want {
+		t.Errorf("Unexpected grouped text:\n%s", output)
+	}
+
+	if _, err := arrangeModels(quota, "size", ""); err == nil {
+		t.Error("Expected an unknown sort to fail")
+	}
+	if _, err := arrangeModels(quota, "", "model"); err == nil {
+		t.Error("Expected an unknown group to fail")
+	}
+}
+
+func TestRenderShort(t *testing.T) {
+	quota := FormattedQuota{Models: []FormattedModel{
+		{Name: "glm", Percentage: 75},
+		{Name: "glm-coding-plan-mcp-monthly", Percentage: 90},
+		{Name: "glm-coding-plan-vision", Percentage: 40},
+		{Name: "zhipu-balance", Percentage: 100, Balance: &Balance{}},
+	}}
+
+	output, _ := renderShort(quota)
+	if string(output) != "glm 75% · mcp 90% · vision 40%" {
+		t.Errorf("Unexpected short output %q", output)
+	}
+
+	t.Setenv("SHORT_SEPARATOR", " | ")
+	t.Setenv("SHORT_LIMIT", "2")
+	output, _ = renderShort(quota)
+	if string(output) != "glm 75% | vision 40%" {
+		t.Errorf("Expected the two lowest models in output order, got %q", output)
+	}
+}
+
+func TestRenderBadge(t *testing.T) {
+	quota := FormattedQuota{Models: []FormattedModel{
+		{Name: "glm", Percentage: 75},
+		{Name: "glm-coding-plan-mcp-monthly", Percentage: 35},
+		{Name: "zhipu-balance", Percentage: 0, Balance: &Balance{}},
+	}}
+	if output, _ := renderBadge(quota); string(output) != "🟡 mcp 35%" {
+		t.Errorf("Unexpected badge %q", output)
+	}
+
+	quota.Models[0].Percentage = 5
+	t.Setenv("BADGE_STYLE", "color")
+	if output, _ := renderBadge(quota); string(output) != "\033[31mglm 5%\033[0m" {
+		t.Errorf("Unexpected colored badge %q", output)
+	}
+
+	if output, _ := renderBadge(FormattedQuota{}); len(output) != 0 {
+		t.Errorf("Expected no badge without models, got %q", output)
+	}
+}
+
+func TestRenderNvim(t *testing.T) {
+	quota := FormattedQuota{Models: []FormattedModel{
+		{Name: "glm", Percentage: 75},
+		{Name: "glm-coding-plan-mcp-monthly", Percentage: 35},
+		{Name: "zhipu-balance", Percentage: 0, Balance: &Balance{}},
+	}}
+	output, err := renderQuota("nvim", quota)
+	if err != nil || string(output) != `{"text":"mcp 35%","hl_group_hint":"DiagnosticWarn","percentage":35}` {
+		t.Errorf("Unexpected nvim status %s, %v", output, err)
+	}
+
+	quota.Models[0].Percentage = 5
+	if output, _ := renderNvim(quota); !strings.Contains(string(output), `"hl_group_hint":"DiagnosticError"`) {
+		t.Errorf("Expected an error highlight below 20%%, got %s", output)
+	}
+	if output, _ := renderNvim(FormattedQuota{}); string(output) != `{"text":"","hl_group_hint":"Comment","percentage":null}` {
+		t.Errorf("Unexpected nvim status without models %s", output)
+	}
+}
+
+func TestRenderVSCode(t *testing.T) {
+	quota := FormattedQuota{Models: []FormattedModel{
+		{Name: "glm", Provider: "glm", Percentage: 75},
+		{Name: "glm-coding-plan-mcp-monthly", Provider: "glm", Percentage: 10},
+		{Name: "zhipu-balance", Provider: "zhipu-balance", Balance: &Balance{Display: "¥42.10"}},
+	}}
+	output, err := renderQuota("vscode", quota)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status VSCodeStatus
+	if err := json.Unmarshal(output, &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Version != VSCodeFormatVersion || status.Text != "$(pulse) mcp 10%" || status.Severity != "error" {
+		t.Errorf("Unexpected status %+v", status)
+	}
+	if !strings.Contains(status.Tooltip, "| glm | 75% |  |\n") || !strings.Contains(status.Tooltip, "| zhipu-balance | ¥42.10 |  |\n") {
+		t.Errorf("Unexpected tooltip:\n%s", status.Tooltip)
+	}
+	if len(status.Commands) == 0 || status.Commands[0].Command != "codingPlanQuota.refresh" || strings.Join(status.Commands[0].Arguments, ",") != "glm,zhipu-balance" {
+		t.Errorf("Unexpected commands %+v", status.Commands)
+	}
+
+	if output, _ := renderVSCode(FormattedQuota{}); !strings.Contains(string(output), `"severity":"none"`) {
+		t.Errorf("Expected no severity without models, got %s", output)
+	}
+}
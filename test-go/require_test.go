@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequireProvider(t *testing.T) {
+	for model, want := range map[string]string{
+		"glm":               ProviderGLM,
+		"glm:work":          ProviderGLM,
+		"gemini-3-pro":      ProviderAntigravity,
+		"claude-sonnet-4*":  ProviderAntigravity,
+		"claude-ai-session": ProviderClaudeAI,
+		"glm-coding-plan-*": ProviderGLM,
+	} {
+		if got := requireProvider(model); got != want {
+			t.Errorf("requireProvider(%q) = %q, want %q", model, got, want)
+		}
+	}
+}
+
+func TestCheckRequiredQuota(t *testing.T) {
+	config := LoadConfig()
+	quota := &FormattedQuota{Models: []FormattedModel{
+		{Name: "gemini-3-pro", Percentage: 40},
+		{Name: "gemini-3-flash", Percentage: 8, ResetTimeRelative: "2h 15m"},
+	}}
+
+	if err := checkRequiredQuota(config, ProviderAntigravity, quota, "gemini-3-pro", 15); err != nil {
+		t.Errorf("Expected 40%% to meet a 15%% floor, got %v", err)
+	}
+
+	err := checkRequiredQuota(config, ProviderAntigravity, quota, "gemini-3-*", 15)
+	if err == nil || !strings.Contains(err.Error(), "gemini-3-flash has 8% left, below the required 15%") || !strings.Contains(err.Error(), "resets in 2h 15m") {
+		t.Errorf("Expected the model below the floor with its reset, got %v", err)
+	}
+	if strings.Contains(err.Error(), "gemini-3-pro") {
+		t.Errorf("Expected only failing models in the message, got %v", err)
+	}
+
+	if err := checkRequiredQuota(config, ProviderAntigravity, quota, "glm", 15); err == nil || !strings.Contains(err.Error(), "no model matching") {
+		t.Errorf("Expected an error for a model that does not exist, got %v", err)
+	}
+}
+
+func TestRunRequireCommandUsage(t *testing.T) {
+	if err := runRequireCommand(nil); err == nil || !strings.Contains(err.Error(), "usage") {
+		t.Errorf("Expected a usage error without --model, got %v", err)
+	}
+}
+
+func TestWaitForQuota(t *testing.T) {
+	config := LoadConfig()
+	percentages := []float64{5, 5, 100}
+	calls := 0
+	fetch := func(context.Context) (*FormattedQuota, error) {
+		if calls == 1 {
+			calls++
+			return nil, errors.New("status 503")
+		}
+		quota := &FormattedQuota{Models: []FormattedModel{{Name: "glm", Percentage: percentages[calls]}}}
+		calls++
+		return quota, nil
+	}
+
+	var out strings.Builder
+	if err := waitForQuota(context.Background(), config, fetch, ProviderGLM, "glm", 30, time.Millisecond, &out); err != nil {
+		t.Fatalf("Expected the wait to end after the reset, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 checks, got %d", calls)
+	}
+	if !strings.Contains(out.String(), "glm/glm has 5% left") || !strings.Contains(out.String(), "status 503") || !strings.Contains(out.String(), "glm/glm has at least 30% left") {
+		t.Errorf("Unexpected progress output: %q", out.String())
+	}
+}
+
+func TestWaitForQuotaStops(t *testing.T) {
+	config := LoadConfig()
+	exhausted := func(context.Context) (*FormattedQuota, error) {
+		return &FormattedQuota{Models: []FormattedModel{{Name: "glm", Percentage: 0}}}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := waitForQuota(ctx, config, exhausted, ProviderGLM, "glm", 30, time.Millisecond, io.Discard); err == nil || !strings.Contains(err.Error(), "below the required 30%") {
+		t.Errorf("Expected the last check's error at the timeout, got %v", err)
+	}
+
+	// Waiting cannot make a missing model appear
+	if err := waitForQuota(context.Background(), config, exhausted, ProviderGLM, "glm:work", 30, time.Hour, io.Discard); err == nil || !strings.Contains(err.Error(), "no model matching") {
+		t.Errorf("Expected an immediate error for a missing model, got %v", err)
+	}
+}
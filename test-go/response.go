@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Largest provider response read, so a gateway returning megabytes of HTML or an endless
+	// stream fails the query instead of growing memory
+	maxResponseSize = 4 << 20
+
+	// Deepest JSON nesting accepted in a provider response
+	maxJSONDepth = 64
+
+	// Error bodies are only quoted in messages, so little of them is read
+	maxErrorBodySize = 4 << 10
+)
+
+var (
+	ErrResponseTooLarge = errors.New("response too large")
+	ErrResponseTooDeep  = errors.New("response nested too deeply")
+)
+
+// pageTitle finds the title of an HTML page
+var pageTitle = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// signInWords mark a page as a sign-in page when they appear in its title or body
+var signInWords = []string{"sign in", "sign-in", "signin", "log in", "login", "sso", "authenticate"}
+
+// GatewayPageError reports a web page or other non-JSON body where a provider should have
+// answered with JSON, typically a gateway's or proxy's sign-in page served with status 200
+type GatewayPageError struct {
+	Host        string
+	ContentType string
+	Title       string
+	SignIn      bool
+}
+
+func (e *GatewayPageError) Error() string {
+	page := "a " + e.ContentType + " response"
+	if e.Title != "" {
+		page += fmt.Sprintf(" (%q)", e.Title)
+	}
+	if e.SignIn {
+		return fmt.Sprintf("%s returned %s instead of JSON: a gateway or proxy is asking to sign in; check its credentials or use a direct base URL", e.Host, page)
+	}
+	return fmt.Sprintf("%s returned %s instead of JSON: check that the base URL points at the API rather than a web page or gateway", e.Host, page)
+}
+
+// checkResponsePage returns a GatewayPageError when a response is an HTML page, or has a
+// content type other than JSON and a body that does not look like JSON
+func checkResponsePage(resp *http.Response, body []byte) error {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	trimmed := bytes.TrimSpace(body)
+	start := strings.ToLower(string(trimmed[:min(len(trimmed), 15)]))
+	isHTML := mediaType == "text/html" || mediaType == "application/xhtml+xml" ||
+		strings.HasPrefix(start, "<!doctype html") || strings.HasPrefix(start, "<html")
+	looksJSON := len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')
+	if !isHTML && (looksJSON || mediaType == "" || strings.Contains(mediaType, "json")) {
+		return nil
+	}
+	if isHTML && mediaType != "application/xhtml+xml" {
+		mediaType = "text/html"
+	}
+
+	err := &GatewayPageError{ContentType: mediaType}
+	if resp.Request != nil {
+		err.Host = resp.Request.URL.Host
+	}
+	if match := pageTitle.FindSubmatch(body); match != nil {
+		err.Title = strings.Join(strings.Fields(html.UnescapeString(string(match[1]))), " ")
+	}
+	lower := strings.ToLower(string(body))
+	for _, word := range signInWords {
+		if strings.Contains(lower, word) {
+			err.SignIn = true
+			break
+		}
+	}
+	return err
+}
+
+// readResponse reads a provider response into buf, failing once it exceeds maxResponseSize,
+// is a web page rather than JSON, or nests JSON deeper than maxJSONDepth
+func readResponse(buf *bytes.Buffer, resp *http.Response) error {
+	if _, err := buf.ReadFrom(io.LimitReader(resp.Body, maxResponseSize+1)); err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if buf.Len() > maxResponseSize {
+		return fmt.Errorf("%w: over %d bytes", ErrResponseTooLarge, maxResponseSize)
+	}
+	if err := checkResponsePage(resp, buf.Bytes()); err != nil {
+		return err
+	}
+	return checkJSONDepth(buf.Bytes())
+}
+
+// decodeResponse reads a provider response within the limits and decodes it into out
+func decodeResponse(resp *http.Response, out interface{}) error {
+	var buf bytes.Buffer
+	if err := readResponse(&buf, resp); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(buf.Bytes(), out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// errorBody returns the start of an error response, for quoting in messages
+func errorBody(r io.Reader) []byte {
+	body, _ := io.ReadAll(io.LimitReader(r, maxErrorBodySize))
+	return body
+}
+
+// checkJSONDepth fails when brackets and braces outside strings nest deeper than
+// maxJSONDepth. It does not validate the JSON, which the decoder does afterwards.
+func checkJSONDepth(body []byte) error {
+	depth := 0
+	inString, escaped := false, false
+	for _, c := range body {
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			switch c {
+			case '\\':
+				escaped = true
+			case '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			if depth++; depth > maxJSONDepth {
+				return fmt.Errorf("%w: over %d levels", ErrResponseTooDeep, maxJSONDepth)
+			}
+		case c == '}' || c == ']':
+			depth--
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckJSONDepth(t *testing.T) {
+	deep := strings.Repeat("[", maxJSONDepth+1) + strings.Repeat("]", maxJSONDepth+1)
+	if err := checkJSONDepth([]byte(deep)); !errors.Is(err, ErrResponseTooDeep) {
+		t.Errorf("Expected %d levels to be rejected, got %v", maxJSONDepth+1, err)
+	}
+
+	limit := strings.Repeat("[", maxJSONDepth) + strings.Repeat("]", maxJSONDepth)
+	if err := checkJSONDepth([]byte(limit)); err != nil {
+		t.Errorf("Expected %d levels to be accepted, got %v", maxJSONDepth, err)
+	}
+
+	// Brackets in strings, including after escaped quotes, do not count
+	quoted := `{"a":"` + strings.Repeat("[", maxJSONDepth+1) + `\"{{{"}`
+	if err := checkJSONDepth([]byte(quoted)); err != nil {
+		t.Errorf("Expected brackets in strings to be ignored, got %v", err)
+	}
+}
+
+func TestDoJSONResponseLimits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html>" + strings.Repeat("x", maxResponseSize) + "</html>"))
+		case "/deep":
+			w.Write([]byte(strings.Repeat(`{"a":`, 1000) + "1" + strings.Repeat("}", 1000)))
+		default:
+			w.Write([]byte(`{"ok":true}`))
+		}
+	}))
+	defer server.Close()
+
+	var out map[string]interface{}
+	if err := doJSON(context.Background(), ProviderLocal, http.MethodGet, server.URL+"/html", nil, nil, &out); !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("Expected an oversized body to fail, got %v", err)
+	}
+	if err := doJSON(context.Background(), ProviderLocal, http.MethodGet, server.URL+"/deep", nil, nil, &out); !errors.Is(err, ErrResponseTooDeep) {
+		t.Errorf("Expected a deeply nested body to fail, got %v", err)
+	}
+	if err := doJSON(context.Background(), ProviderLocal, http.MethodGet, server.URL+"/ok", nil, nil, &out); err != nil || out["ok"] != true {
+		t.Errorf("Expected a normal body to decode, got %v %v", out, err)
+	}
+}
+
+func TestGatewayPageDetection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(`<!DOCTYPE html><html><head><title>Sign in &amp; continue</title></head><body><form action="/sso"></form></body></html>`))
+		case "/untyped":
+			w.Write([]byte("\n <html><head><title>502 Bad Gateway</title></head></html>"))
+		case "/text":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("upstream connect error"))
+		case "/text-json":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(`{"ok":true}`))
+		}
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		path   string
+		class  string
+		title  string
+		signIn bool
+	}{
+		{"/login", "auth", "Sign in & continue", true},
+		{"/untyped", "gateway", "502 Bad Gateway", false},
+		{"/text", "gateway", "", false},
+	}
+	for _, tc := range cases {
+		var out map[string]interface{}
+		err := doJSON(context.Background(), ProviderLocal, http.MethodGet, server.URL+tc.path, nil, nil, &out)
+		var pageErr *GatewayPageError
+		if !errors.As(err, &pageErr) {
+			t.Errorf("%s: expected a GatewayPageError, got %v", tc.path, err)
+			continue
+		}
+		if pageErr.Title != tc.title || pageErr.SignIn != tc.signIn {
+			t.Errorf("%s: expected title %q and sign-in %v, got %+v", tc.path, tc.title, tc.signIn, pageErr)
+		}
+		if class := errorClass(err); class != tc.class {
+			t.Errorf("%s: expected class %s, got %s", tc.path, tc.class, class)
+		}
+		if strings.Contains(err.Error(), "failed to decode") {
+			t.Errorf("%s: expected a page message, got %v", tc.path, err)
+		}
+	}
+
+	// A JSON body is accepted whatever its content type
+	var out map[string]interface{}
+	if err := doJSON(context.Background(), ProviderLocal, http.MethodGet, server.URL+"/text-json", nil, nil, &out); err != nil {
+		t.Errorf("Expected a JSON body with a text content type to decode, got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Viewer roles of the hub and dashboard. Admins see the per-user and per-account breakdown,
+// viewers only aggregates.
+const (
+	roleAdmin  = "admin"
+	roleViewer = "viewer"
+)
+
+// Context key the role of a request is kept under for the handlers
+const roleKey = "role"
+
+// requestRole returns the role the request's token grants: admin for adminToken, viewer for
+// VIEWER_TOKEN, and "" for neither. Without an admin token the endpoint is open and every
+// request is an admin, as before roles existed.
+func requestRole(c *gin.Context, adminToken, viewerToken string) string {
+	switch {
+	case adminToken == "" || tokenMatches(c, adminToken):
+		return roleAdmin
+	case viewerToken != "" && tokenMatches(c, viewerToken):
+		return roleViewer
+	}
+	return ""
+}
+
+// isViewer reports whether the request was authorized with the viewer role
+func isViewer(c *gin.Context) bool {
+	return c.GetString(roleKey) == roleViewer
+}
+
+// redactAccounts drops the models of individual accounts, keeping aggregates such as
+// glm:all, for viewers
+func redactAccounts(quota *FormattedQuota) *FormattedQuota {
+	redacted := *quota
+	redacted.Models = slices.DeleteFunc(slices.Clone(quota.Models), func(model FormattedModel) bool {
+		return model.Account != ""
+	})
+	return &redacted
+}
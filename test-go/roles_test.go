@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	role := func(auth, admin, viewer string) string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		if auth != "" {
+			c.Request.Header.Set("Authorization", "Bearer "+auth)
+		}
+		return requestRole(c, admin, viewer)
+	}
+
+	tests := []struct {
+		name, auth, admin, viewer, want string
+	}{
+		{"open", "", "", "", roleAdmin},
+		{"open ignores the viewer token", "look", "", "look", roleAdmin},
+		{"admin", "boss", "boss", "look", roleAdmin},
+		{"viewer", "look", "boss", "look", roleViewer},
+		{"wrong token", "guess", "boss", "look", ""},
+		{"no viewer token", "", "boss", "", ""},
+	}
+	for _, tt := range tests {
+		if got := role(tt.auth, tt.admin, tt.viewer); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestViewerSeesDashboardWithoutAccounts(t *testing.T) {
+	t.Setenv("SERVER_TOKEN", "boss")
+	t.Setenv("VIEWER_TOKEN", "look")
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	service := setupRoutes(r)
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("/", "look"); w.Code != http.StatusOK {
+		t.Errorf("Expected viewers to get the dashboard page, got %d", w.Code)
+	}
+	if w := get("/quota/glm", "look"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected viewers to be refused outside the dashboard, got %d", w.Code)
+	}
+
+	// Admins see each account's gauge, viewers only the aggregates
+	for token, want := range map[string]int{"boss": 3, "look": 2} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Request.Header.Set("Authorization", "Bearer "+token)
+		service.requireDashboardToken(c)
+		quota := service.applyModelSelection(c, &FormattedQuota{Models: []FormattedModel{
+			{Name: "glm", Percentage: 60},
+			{Name: "glm:work", Percentage: 20, Account: "work"},
+			{Name: "glm:all", Percentage: 40},
+		}})
+		if len(quota.Models) != want {
+			t.Errorf("Expected %d models for %s, got %+v", want, token, quota.Models)
+		}
+	}
+}
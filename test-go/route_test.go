@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRouteScore(t *testing.T) {
+	tests := []struct {
+		name       string
+		pct        float64
+		burnRate   float64
+		untilReset time.Duration
+		hasReset   bool
+		expected   float64
+	}{
+		{"no history", 60, 0, 0, false, 60},
+		{"slow burn", 60, 5, 0, false, 60},
+		{"runs out in 2.5h", 50, 20, 0, false, 25},
+		{"resets before running out", 50, 20, time.Hour, true, 90},
+		{"reset beyond horizon", 40, 0, 10 * time.Hour, true, 40},
+		{"reset due", 10, 0, -time.Minute, true, 100},
+	}
+
+	for _, tt := range tests {
+		if got := routeScore(tt.pct, tt.burnRate, tt.untilReset, tt.hasReset); got != tt.expected {
+			t.Errorf("%s: routeScore = %v, expected %v", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestBurnRegistryRate(t *testing.T) {
+	registry := &burnRegistry{samples: make(map[string][]burnSample)}
+	clk := &fakeClock{t: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	defer setClock(clk)()
+
+	observe := func(pct float64) {
+		registry.record("glm", &FormattedQuota{
+			Models:      []FormattedModel{{Name: "glm", Percentage: pct}},
+			LastUpdated: clk.Now().Unix(),
+		})
+	}
+
+	observe(90)
+	observe(90) // cached data with the same fetch time is ignored
+	clk.Advance(30 * time.Minute)
+	observe(80)
+	if rate := registry.rate("glm", "glm"); rate != 20 {
+		t.Errorf("Expected 20%%/h, got %v", rate)
+	}
+
+	// A rising percentage means the quota was reset
+	clk.Advance(30 * time.Minute)
+	observe(100)
+	if rate := registry.rate("glm", "glm"); rate != 0 {
+		t.Errorf("Expected no rate after reset, got %v", rate)
+	}
+}
+
+func TestRecommendProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/groq"):
+			w.Header().Set("x-ratelimit-limit-requests", "100")
+			w.Header().Set("x-ratelimit-remaining-requests", "30")
+		case strings.HasPrefix(r.URL.Path, "/mistral"):
+			w.Header().Set("x-ratelimit-limit-tokens-minute", "100")
+			w.Header().Set("x-ratelimit-remaining-tokens-minute", "70")
+		}
+		fmt.Fprint(w, `{"data":[]}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("GROQ_API_KEY", "gsk")
+	t.Setenv("GROQ_BASE_URL", server.URL+"/groq")
+	t.Setenv("MISTRAL_API_KEY", "mistral")
+	t.Setenv("MISTRAL_BASE_URL", server.URL+"/mistral")
+	t.Setenv("CURSOR_SESSION_TOKEN", "")
+
+	service := NewQuotaService(NewCloudCodeClient(&Config{QueryDebounce: 1}))
+	result := service.recommendProvider(context.Background(), []string{ProviderGroq, ProviderCursor, ProviderMistral})
+
+	if result.Recommended != ProviderMistral {
+		t.Errorf("Expected mistral, got %q", result.Recommended)
+	}
+	if len(result.Candidates) != 3 || result.Candidates[1].Provider != ProviderGroq || result.Candidates[2].Error == "" {
+		t.Errorf("Expected mistral, groq, then failed cursor, got %+v", result.Candidates)
+	}
+
+	var out bytes.Buffer
+	t.Setenv("GROQ_API_KEY", "")
+	t.Setenv("MISTRAL_API_KEY", "")
+	if err := runRouteCommand([]string{"--providers", "groq,mistral"}, &out); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("Expected ErrNoRoute without credentials, got %v", err)
+	}
+}
+
+func TestFormatEnvExports(t *testing.T) {
+	got := formatEnvExports([]routeEnvVar{
+		{Name: "CLAUDE_CODE_OAUTH_TOKEN", Value: "it's"},
+		{Name: "ANTHROPIC_BASE_URL"},
+		{Name: "ANTHROPIC_AUTH_TOKEN"},
+	})
+	expected := `export CLAUDE_CODE_OAUTH_TOKEN='it'\''s'; unset ANTHROPIC_BASE_URL ANTHROPIC_AUTH_TOKEN`
+	if got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+func TestRouteEmitEnv(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-ratelimit-limit-requests", "100")
+		w.Header().Set("x-ratelimit-remaining-requests", "50")
+		fmt.Fprint(w, `{"data":[]}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("GROQ_API_KEY", "gsk")
+	t.Setenv("GROQ_BASE_URL", server.URL+"/emit")
+
+	// Groq has quota but is not an Anthropic-compatible backend
+	var out bytes.Buffer
+	err := runRouteCommand([]string{"--emit-env", "--providers", "groq"}, &out)
+	if err == nil || !strings.Contains(err.Error(), "no environment to emit") {
+		t.Errorf("Expected unsupported provider error, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no output, got %q", out.String())
+	}
+}
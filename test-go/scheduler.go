@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scheduled tasks for SCHEDULE entries
+const (
+	taskRefresh = "refresh"
+	taskVacuum  = "vacuum"
+	taskSummary = "summary"
+	taskDigest  = "digest"
+)
+
+var scheduleTasks = []string{taskRefresh, taskVacuum, taskSummary, taskDigest}
+
+// Furthest back previousRun looks for an earlier run of a job
+const scheduleLookback = 366 * 24 * time.Hour
+
+// Shorthands accepted in place of the five cron fields
+var cronMacros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// cronField is the set of values one field of a cron expression matches
+type cronField struct {
+	values map[int]bool
+	any    bool
+}
+
+// cronSchedule is a parsed five-field cron expression: minute, hour, day of month, month,
+// and day of week (0 or 7 for Sunday)
+type cronSchedule struct {
+	minute, hour, day, month, weekday cronField
+}
+
+// scheduledJob runs a task whenever its schedule matches
+type scheduledJob struct {
+	spec     string
+	schedule cronSchedule
+	task     string
+}
+
+// parseCronField parses a cron field of *, numbers, a-b ranges, and /step, separated by commas
+func parseCronField(text string, low, high int) (cronField, error) {
+	field := cronField{values: make(map[int]bool), any: strings.HasPrefix(text, "*")}
+	for part := range strings.SplitSeq(text, ",") {
+		rangeText, stepText, stepped := strings.Cut(part, "/")
+		step := 1
+		if stepped {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step < 1 {
+				return cronField{}, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+
+		from, to := low, high
+		if rangeText != "*" {
+			first, last, isRange := strings.Cut(rangeText, "-")
+			var err error
+			if from, err = strconv.Atoi(first); err != nil {
+				return cronField{}, fmt.Errorf("invalid value %q", part)
+			}
+			to = from
+			if isRange {
+				if to, err = strconv.Atoi(last); err != nil {
+					return cronField{}, fmt.Errorf("invalid range %q", part)
+				}
+			} else if stepped {
+				to = high
+			}
+		}
+		if from < low || to > high || from > to {
+			return cronField{}, fmt.Errorf("%q is outside %d-%d", part, low, high)
+		}
+		for value := from; value <= to; value += step {
+			field.values[value] = true
+		}
+	}
+	return field, nil
+}
+
+// parseCron parses a five-field cron expression or one of the @ shorthands
+func parseCron(spec string) (cronSchedule, error) {
+	if expanded, ok := cronMacros[spec]; ok {
+		spec = expanded
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return cronSchedule{}, fmt.Errorf("%q needs 5 fields: minute hour day month weekday", spec)
+	}
+
+	var schedule cronSchedule
+	targets := []*cronField{&schedule.minute, &schedule.hour, &schedule.day, &schedule.month, &schedule.weekday}
+	bounds := [][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	for i, text := range fields {
+		field, err := parseCronField(text, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return cronSchedule{}, fmt.Errorf("%q: %w", spec, err)
+		}
+		*targets[i] = field
+	}
+	if schedule.weekday.values[7] {
+		schedule.weekday.values[0] = true
+	}
+	return schedule, nil
+}
+
+// matches reports whether the schedule fires in the local minute of t. As in cron, when both
+// the day of month and the day of week are restricted, either one matching is enough.
+func (s cronSchedule) matches(t time.Time) bool {
+	t = t.Local()
+	if !s.minute.values[t.Minute()] || !s.hour.values[t.Hour()] || !s.month.values[int(t.Month())] {
+		return false
+	}
+	day, weekday := s.day.values[t.Day()], s.weekday.values[int(t.Weekday())]
+	if !s.day.any && !s.weekday.any {
+		return day || weekday
+	}
+	return day && weekday
+}
+
+// previousRun returns the last minute before t that the schedule fired in, within a year
+func (s cronSchedule) previousRun(t time.Time) (time.Time, bool) {
+	minute := t.Truncate(time.Minute)
+	for at := minute.Add(-time.Minute); minute.Sub(at) <= scheduleLookback; at = at.Add(-time.Minute) {
+		if s.matches(at) {
+			return at, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseSchedule parses SCHEDULE, entries of a cron expression and a task separated by
+// semicolons, such as "0 3 * * * vacuum; */30 * * * * refresh"
+func parseSchedule(value string) ([]scheduledJob, error) {
+	var jobs []scheduledJob
+	for entry := range strings.SplitSeq(trimQuotes(value), ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		task := fields[len(fields)-1]
+		if !slices.Contains(scheduleTasks, task) {
+			return nil, fmt.Errorf("unknown task %q in %q (use %s)", task, strings.TrimSpace(entry), strings.Join(scheduleTasks, ", "))
+		}
+		spec := strings.Join(fields[:len(fields)-1], " ")
+		schedule, err := parseCron(spec)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, scheduledJob{spec: spec, schedule: schedule, task: task})
+	}
+	return jobs, nil
+}
+
+// digestJob turns EMAIL_DIGEST and EMAIL_DIGEST_AT into a digest job: daily at that time, or
+// weekly on Mondays
+func digestJob(config *Config) (scheduledJob, bool) {
+	at, err := time.Parse("15:04", config.EmailDigestAt)
+	if err != nil {
+		return scheduledJob{}, false
+	}
+	spec := fmt.Sprintf("%d %d * * *", at.Minute(), at.Hour())
+	switch config.EmailDigest {
+	case digestDaily:
+	case digestWeekly:
+		spec = fmt.Sprintf("%d %d * * 1", at.Minute(), at.Hour())
+	default:
+		return scheduledJob{}, false
+	}
+	schedule, _ := parseCron(spec)
+	return scheduledJob{spec: spec, schedule: schedule, task: taskDigest}, true
+}
+
+// runScheduler runs the SCHEDULE jobs and the EMAIL_DIGEST job at the start of each minute
+// they match, reading the configuration every minute so reloaded schedules apply
+func (s *QuotaService) runScheduler(ctx context.Context, notifier *emailNotifier) {
+	reported := ""
+	for {
+		now := clockNow()
+		timer := time.NewTimer(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		config := s.client.Config()
+		jobs, err := parseSchedule(config.Schedule)
+		if err != nil {
+			if config.Schedule != reported {
+				log.Printf("Invalid SCHEDULE: %v", err)
+				reported = config.Schedule
+			}
+			jobs = nil
+		}
+		if job, ok := digestJob(config); ok {
+			jobs = append(jobs, job)
+		}
+
+		minute := clockNow().Truncate(time.Minute)
+		for _, job := range jobs {
+			if job.schedule.matches(minute) {
+				go s.runTask(ctx, config, notifier, job, minute)
+			}
+		}
+	}
+}
+
+// runTask runs one scheduled task. Summaries and digests cover the time since the job's
+// previous run.
+func (s *QuotaService) runTask(ctx context.Context, config *Config, notifier *emailNotifier, job scheduledJob, at time.Time) {
+	log.Printf("Running scheduled %s (%s)", job.task, job.spec)
+	since, ok := job.schedule.previousRun(at)
+	if !ok {
+		since = at.Add(-24 * time.Hour)
+	}
+
+	switch job.task {
+	case taskRefresh:
+		s.invalidateCache()
+		for _, provider := range providerNames() {
+			// Providers without credentials fail and are skipped
+			s.fetchQuota(ctx, provider)
+		}
+	case taskVacuum:
+		result, err := quotaHistory.vacuum(rollupRetentionOf(config))
+		if err != nil {
+			log.Printf("Scheduled vacuum failed: %v", err)
+			return
+		}
+		log.Printf("Compacted history: kept %d of %d lines (%d -> %d bytes)", result.Kept, result.Lines, result.Before, result.After)
+	case taskSummary:
+		var summary bytes.Buffer
+		writeSummary(&summary, config, "", quotaHistory.consumed(since, at))
+		for line := range strings.Lines(summary.String()) {
+			log.Printf("Summary since %s: %s", since.Format("Jan 2 15:04"), strings.TrimSpace(line))
+		}
+	case taskDigest:
+		if !emailEnabled(config) {
+			log.Printf("Scheduled digest skipped: SMTP_ADDRESS, EMAIL_FROM, and EMAIL_TO are required")
+			return
+		}
+		subject := localizer(config.Language).Sprintf(msgEmailDigestSubject, since.Format("Jan 2"), at.Format("Jan 2"))
+		if err := notifier.mail(config, subject, renderDigest(config, quotaHistory, since, at)); err != nil {
+			log.Printf("Failed to mail the digest: %v", err)
+		}
+	}
+}
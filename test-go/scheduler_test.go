@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	jobs, err := parseSchedule("0 3 * * * vacuum; */30 9-17 * * 1-5 refresh;; @weekly summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 3 || jobs[0].task != taskVacuum || jobs[1].task != taskRefresh || jobs[2].spec != "@weekly" {
+		t.Fatalf("Unexpected jobs: %+v", jobs)
+	}
+
+	// Thursday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, time.Local)
+	}
+	refresh := jobs[1].schedule
+	for _, tt := range []struct {
+		at   time.Time
+		want bool
+	}{
+		{at(15, 9, 0), true},
+		{at(15, 17, 30), true},
+		{at(15, 18, 0), false},
+		{at(15, 9, 15), false},
+		{at(17, 9, 0), false}, // Saturday
+	} {
+		if got := refresh.matches(tt.at); got != tt.want {
+			t.Errorf("matches(%v) = %v, expected %v", tt.at, got, tt.want)
+		}
+	}
+
+	// Restricting both days matches either, as cron does
+	either, _ := parseCron("0 0 1 * 1")
+	if !either.matches(at(12, 0, 0)) || !either.matches(time.Date(2026, 11, 1, 0, 0, 0, 0, time.Local)) || either.matches(at(15, 0, 0)) {
+		t.Error("Expected the first of the month or a Monday to match")
+	}
+	if previous, ok := jobs[2].schedule.previousRun(at(15, 9, 0)); !ok || !previous.Equal(at(11, 0, 0)) {
+		t.Errorf("Expected the previous weekly run on Sunday, got %v", previous)
+	}
+
+	for _, value := range []string{"0 3 * * * backup", "0 3 * * vacuum", "61 * * * * refresh", "*/0 * * * * refresh", "5-1 * * * * refresh"} {
+		if _, err := parseSchedule(value); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestScheduledDigest(t *testing.T) {
+	defer func(saved *historyStore) { quotaHistory = saved }(quotaHistory)
+	quotaHistory = newHistoryStore()
+	now := time.Date(2026, 10, 12, 8, 0, 0, 0, time.Local)
+	for i, pct := range []float64{90, 60} {
+		at := now.Add(time.Duration(i-2) * 24 * time.Hour).Unix()
+		quotaHistory.record("glm", &FormattedQuota{Models: []FormattedModel{{Name: "glm", Percentage: pct}}, LastUpdated: at})
+	}
+
+	config := &Config{
+		SMTPAddress:   "smtp.example.com:587",
+		EmailFrom:     "quota@example.com",
+		EmailTo:       []string{"me@example.com"},
+		EmailDigest:   digestWeekly,
+		EmailDigestAt: "08:00",
+	}
+	job, ok := digestJob(config)
+	if !ok || job.spec != "0 8 * * 1" || !job.schedule.matches(now) {
+		t.Fatalf("Expected a Monday 08:00 job, got %+v", job)
+	}
+	if _, ok := digestJob(&Config{EmailDigestAt: "08:00"}); ok {
+		t.Error("Expected no digest job without EMAIL_DIGEST")
+	}
+
+	var msg string
+	notifier := newEmailNotifier()
+	notifier.send = func(_ string, _ smtp.Auth, _ string, _ []string, body []byte) error {
+		msg = string(body)
+		return nil
+	}
+	service := NewQuotaService(NewCloudCodeClient(config))
+	service.runTask(context.Background(), config, notifier, job, now)
+	for _, want := range []string{"Subject: Quota digest, Oct 5 - Oct 12", "glm/glm: 30 points used"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Expected %q in the digest covering the week, got:\n%s", want, msg)
+		}
+	}
+}
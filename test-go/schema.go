@@ -0,0 +1,108 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// zaiVariant is a known shape of a Z.ai payload object. Renames maps the variant's field
+// names to the canonical names the parsers read.
+type zaiVariant struct {
+	name    string
+	renames map[string]string
+}
+
+// matches reports whether data uses any of the variant's own field names
+func (v zaiVariant) matches(data map[string]interface{}) bool {
+	for field := range v.renames {
+		if _, ok := data[field]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// zaiSchema lists the canonical fields of a Z.ai payload object and its known variants.
+// Ignored fields are known but unused, so they do not trigger warnings.
+type zaiSchema struct {
+	name     string
+	fields   []string
+	ignored  []string
+	variants []zaiVariant
+}
+
+// zaiLimitSchema describes an entry of the quota limit endpoint's "limits" array
+var zaiLimitSchema = zaiSchema{
+	name:    "quota limit",
+	fields:  []string{"type", "percentage", "currentValue", "usage", "usageDetails"},
+	ignored: []string{"unit", "number", "remaining", "nextResetTime"},
+	variants: []zaiVariant{
+		{name: "current"},
+		{name: "legacy", renames: map[string]string{"currentUsage": "currentValue"}},
+	},
+}
+
+// zaiUsageDetailSchema describes an entry of a limit's "usageDetails" array
+var zaiUsageDetailSchema = zaiSchema{
+	name:   "usage detail",
+	fields: []string{"modelCode", "usage"},
+	variants: []zaiVariant{
+		{name: "current"},
+	},
+}
+
+// zaiModelUsageTotalsSchema describes the "totalUsage" object of the model usage endpoint
+var zaiModelUsageTotalsSchema = zaiSchema{
+	name:   "model usage totals",
+	fields: []string{"totalModelCallCount", "totalTokensUsage"},
+	variants: []zaiVariant{
+		{name: "current"},
+		{name: "short", renames: map[string]string{"totalCalls": "totalModelCallCount", "totalTokens": "totalTokensUsage"}},
+	},
+}
+
+// zaiSchemaWarned remembers the unknown fields already warned about, keyed by schema and field
+var zaiSchemaWarned sync.Map
+
+// normalize detects which known variant data is and returns a copy using canonical field
+// names, with the variant's name. Fields no variant knows are kept, and a warning is logged
+// once per field so payload changes surface instead of silently parsing to zero.
+func (s zaiSchema) normalize(data map[string]interface{}) (map[string]interface{}, string, []string) {
+	variant := s.variants[0]
+	for _, candidate := range s.variants[1:] {
+		if candidate.matches(data) {
+			variant = candidate
+			break
+		}
+	}
+
+	known := make(map[string]bool)
+	for _, field := range s.fields {
+		known[field] = true
+	}
+	for _, field := range s.ignored {
+		known[field] = true
+	}
+
+	normalized := make(map[string]interface{}, len(data))
+	var unknown []string
+	for field, value := range data {
+		if canonical, ok := variant.renames[field]; ok {
+			field = canonical
+		} else if !known[field] {
+			unknown = append(unknown, field)
+		}
+		normalized[field] = value
+	}
+
+	sort.Strings(unknown)
+	for _, field := range unknown {
+		if _, warned := zaiSchemaWarned.LoadOrStore(s.name+"/"+field, true); !warned {
+			log.Printf("Warning: Z.ai %s payload has unknown field %q (known %s variant fields: %s); it is ignored",
+				s.name, field, variant.name, strings.Join(s.fields, ", "))
+		}
+	}
+	return normalized, variant.name, unknown
+}
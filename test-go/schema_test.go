@@ -0,0 +1,43 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestZAISchemaNormalize(t *testing.T) {
+	current := map[string]interface{}{"type": "TIME_LIMIT", "currentValue": float64(5), "remaining": float64(95)}
+	normalized, variant, unknown := zaiLimitSchema.normalize(current)
+	if variant != "current" || len(unknown) != 0 || normalized["currentValue"] != float64(5) {
+		t.Errorf("Unexpected normalization of the current variant: %v %s %v", normalized, variant, unknown)
+	}
+
+	legacy := map[string]interface{}{"type": "TIME_LIMIT", "currentUsage": float64(7), "quotaWindow": "1M"}
+	normalized, variant, unknown = zaiLimitSchema.normalize(legacy)
+	if variant != "legacy" || normalized["currentValue"] != float64(7) {
+		t.Errorf("Expected currentUsage renamed to currentValue, got %v (%s)", normalized, variant)
+	}
+	if _, ok := normalized["currentUsage"]; ok {
+		t.Error("Expected the legacy field name to be dropped")
+	}
+	if !reflect.DeepEqual(unknown, []string{"quotaWindow"}) || normalized["quotaWindow"] != "1M" {
+		t.Errorf("Expected quotaWindow reported as unknown and kept, got %v", unknown)
+	}
+}
+
+func TestProcessQuotaLimitLegacyVariant(t *testing.T) {
+	result := ProcessQuotaLimit(map[string]interface{}{
+		"limits": []interface{}{
+			map[string]interface{}{
+				"type":         "TIME_LIMIT",
+				"percentage":   float64(10),
+				"currentUsage": float64(5),
+				"usage":        float64(100),
+			},
+		},
+	})
+
+	if len(result.Limits) != 1 || result.Limits[0].CurrentUsage != 5 || result.Limits[0].Total != 100 {
+		t.Errorf("Expected the legacy currentUsage field to be read, got %+v", result.Limits)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// writeTestCertificate writes a self-signed certificate for localhost and its key as PEM
+func writeTestCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certFile, keyFile
+}
+
+func TestParseServeArgs(t *testing.T) {
+	t.Setenv("PORT", "9100")
+	t.Setenv("LISTEN_ADDRESS", "")
+	if options, err := parseServeArgs(nil); err != nil || options.listen != ":9100" {
+		t.Errorf("Expected all interfaces on PORT, got %+v, %v", options, err)
+	}
+	if options, err := parseServeArgs([]string{"--listen", "127.0.0.1:8000"}); err != nil || options.listen != "127.0.0.1:8000" {
+		t.Errorf("Expected --listen to win, got %+v, %v", options, err)
+	}
+	t.Setenv("PORT", "http")
+	if _, err := parseServeArgs(nil); err == nil {
+		t.Error("Expected an invalid PORT to fail")
+	}
+
+	for listen, want := range map[string]string{
+		"127.0.0.1:8000":      "127.0.0.1:9000",
+		":8000":               ":9000",
+		"[::1]:8000":          "[::1]:9000",
+		"unix:/run/quota.sck": "127.0.0.1:9000",
+	} {
+		if got := grpcListenAddress(listen, "9000"); got != want {
+			t.Errorf("grpcListenAddress(%q) = %q, want %q", listen, got, want)
+		}
+	}
+}
+
+func TestListenServerUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.sock")
+	for range 2 {
+		// The second run replaces the socket the first one left behind
+		listener, err := listenServer("unix:" + path)
+		if err != nil {
+			t.Fatalf("Failed to listen on the socket: %v", err)
+		}
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("Expected a socket only the user can use, got %v, %v", info.Mode(), err)
+		}
+		if unixListener, ok := listener.(*net.UnixListener); ok {
+			unixListener.SetUnlinkOnClose(false)
+		}
+		listener.Close()
+	}
+}
+
+func TestRequireServerToken(t *testing.T) {
+	t.Setenv("SERVER_TOKEN", "s3cret")
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	service := setupRoutes(r)
+
+	get := func(path, auth string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for _, path := range []string{"/healthz", "/quota", "/metrics", "/badge/glm.svg", "/nothing"} {
+		if code := get(path, ""); code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for %s without the token, got %d", path, code)
+		}
+	}
+	if code := get("/healthz", "Bearer wrong"); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with a wrong token, got %d", code)
+	}
+	if code := get("/healthz", "Bearer s3cret"); code != http.StatusOK {
+		t.Errorf("Expected 200 with the bearer token, got %d", code)
+	}
+	if code := get("/healthz?token=s3cret", ""); code != http.StatusOK {
+		t.Errorf("Expected 200 with a query token, got %d", code)
+	}
+
+	if err := service.grpcAuthorized(context.Background()); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected gRPC calls without the token to be unauthenticated, got %v", err)
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer s3cret"))
+	if err := service.grpcAuthorized(ctx); err != nil {
+		t.Errorf("Expected gRPC calls with the token to pass, got %v", err)
+	}
+}
+
+func TestRequireServerTokenFailsClosed(t *testing.T) {
+	t.Setenv("SERVER_TOKEN", "")
+	t.Setenv("SERVER_TOKEN_CMD", "exit 3")
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	service := setupRoutes(r)
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "SERVER_TOKEN") {
+		t.Errorf("Expected a SERVER_TOKEN that cannot be read to refuse requests, got %d %s", w.Code, w.Body.String())
+	}
+	if err := service.grpcAuthorized(context.Background()); status.Code(err) != codes.Internal {
+		t.Errorf("Expected gRPC calls to be refused as well, got %v", err)
+	}
+}
+
+func TestServerTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir)
+
+	if config, err := serverTLSConfig(serverOptions{}); config != nil || err != nil {
+		t.Errorf("Expected plain HTTP without a certificate, got %v, %v", config, err)
+	}
+	for _, options := range []serverOptions{
+		{certFile: certFile},
+		{clientCA: certFile},
+		{certFile: certFile, keyFile: keyFile, clientCA: keyFile},
+	} {
+		if _, err := serverTLSConfig(options); err == nil {
+			t.Errorf("Expected %+v to be rejected", options)
+		}
+	}
+
+	config, err := serverTLSConfig(serverOptions{certFile: certFile, keyFile: keyFile, clientCA: certFile})
+	if err != nil {
+		t.Fatalf("Failed to load the TLS config: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+	go server.Serve(tls.NewListener(listener, config))
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	pemBytes, _ := os.ReadFile(certFile)
+	roots.AppendCertsFromPEM(pemBytes)
+	url := "https://" + listener.Addr().String()
+
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "localhost"}}}
+	if resp, err := anonymous.Get(url); err == nil {
+		resp.Body.Close()
+		t.Error("Expected a client without a certificate to be refused")
+	}
+
+	certificate, _ := tls.LoadX509KeyPair(certFile, keyFile)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "localhost", Certificates: []tls.Certificate{certificate}}}}
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("Expected a client certificate signed by the CA to be accepted: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestRouterLogRedactsQueryTokens(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(saved io.Writer) { gin.DefaultWriter = saved }(gin.DefaultWriter)
+	gin.DefaultWriter = &buf
+	r := newRouter()
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/?token=s3cret&providers=glm", nil))
+	if log := buf.String(); strings.Contains(log, "s3cret") || !strings.Contains(log, "providers=glm") {
+		t.Errorf("Expected the token redacted and other parameters kept, got %q", log)
+	}
+}
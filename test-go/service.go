@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+)
+
+const (
+	// Name of the systemd unit and launchd label suffix
+	serviceName = "coding-plan-quota-query"
+
+	// launchd agent label
+	launchdLabel = "com.github.hongyanca." + serviceName
+)
+
+var systemdUnitTemplate = template.Must(template.New("systemd").Parse(`[Unit]
+Description=Coding Plan Quota Query API
+After=network-online.target
+
+[Service]
+Type=simple
+WorkingDirectory={{.WorkDir}}
+ExecStart={{.ExecStart}}
+Restart=on-failure
+RestartSec=5
+
+[Install]
+WantedBy=default.target
+`))
+
+var launchdPlistTemplate = template.Must(template.New("launchd").Funcs(template.FuncMap{"xml": xmlEscape}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{{.Label}}</string>
+	<key>ProgramArguments</key>
+	<array>
+{{- range .Command}}
+		<string>{{xml .}}</string>
+{{- end}}
+	</array>
+	<key>WorkingDirectory</key>
+	<string>{{xml .WorkDir}}</string>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<true/>
+	<key>StandardOutPath</key>
+	<string>{{xml .LogFile}}</string>
+	<key>StandardErrorPath</key>
+	<string>{{xml .LogFile}}</string>
+</dict>
+</plist>
+`))
+
+// ServiceSpec describes how the server is installed as a background service
+type ServiceSpec struct {
+	Label      string
+	Executable string
+	// Args follow the executable, e.g. --profile work serve --listen 127.0.0.1:8000
+	Args    []string
+	WorkDir string
+	LogFile string
+}
+
+// Command returns the executable and its arguments
+func (s ServiceSpec) Command() []string {
+	return append([]string{s.Executable}, s.Args...)
+}
+
+// ExecStart returns the systemd command line with every word quoted, so an executable
+// path with spaces stays one word
+func (s ServiceSpec) ExecStart() string {
+	words := s.Command()
+	for i, word := range words {
+		words[i] = systemdQuote(word)
+	}
+	return strings.Join(words, " ")
+}
+
+// systemdQuote double-quotes a word of an ExecStart line, escaping the characters systemd
+// reads as escapes, specifiers, or variable references
+func systemdQuote(word string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%", "$", "$$").Replace(word) + `"`
+}
+
+// xmlEscape escapes text for a plist string element
+func xmlEscape(text string) string {
+	var buf strings.Builder
+	xml.EscapeText(&buf, []byte(text))
+	return buf.String()
+}
+
+// serviceArgs returns the arguments the service runs the binary with: the profile and serve
+// options given to "service install", checked now so a typo fails the install rather than
+// the service
+func serviceArgs(profile string, serveArgs []string) ([]string, error) {
+	if _, err := parseServeArgs(serveArgs); err != nil {
+		return nil, err
+	}
+	var args []string
+	if profile != "" {
+		args = append(args, "--profile", profile)
+	}
+	return append(append(args, "serve"), serveArgs...), nil
+}
+
+// servicePath returns the unit or plist path for the given OS
+func servicePath(goos, homeDir string) (string, error) {
+	switch goos {
+	case "linux":
+		return filepath.Join(homeDir, ".config", "systemd", "user", serviceName+".service"), nil
+	case "darwin":
+		return filepath.Join(homeDir, "Library", "LaunchAgents", launchdLabel+".plist"), nil
+	default:
+		return "", fmt.Errorf("service management is not supported on %s", goos)
+	}
+}
+
+// renderServiceFile renders the systemd unit or launchd plist for the given OS
+func renderServiceFile(goos string, spec ServiceSpec) ([]byte, error) {
+	tmpl := systemdUnitTemplate
+	if goos == "darwin" {
+		tmpl = launchdPlistTemplate
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, spec); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// runCommand runs an external command with output attached to the terminal
+func runCommand(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// runServiceCommand handles "service install [serve options]|uninstall|status"
+func runServiceCommand(args []string) error {
+	if runtime.GOOS == "windows" {
+		return runWindowsServiceCommand(args)
+	}
+
+	if len(args) == 0 || (len(args) > 1 && args[0] != "install") {
+		return fmt.Errorf("expected one of install [serve options], uninstall, status")
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	path, err := servicePath(runtime.GOOS, homeDir)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "install":
+		return installService(path, homeDir, args[1:])
+	case "uninstall":
+		return uninstallService(path)
+	case "status":
+		if runtime.GOOS == "darwin" {
+			return runCommand("launchctl", "list", launchdLabel)
+		}
+		return runCommand("systemctl", "--user", "status", serviceName)
+	default:
+		return fmt.Errorf("unknown service command: %s", args[0])
+	}
+}
+
+// installService writes the service file for the current binary and .env directory, then starts
+// it with the active profile and the given serve options
+func installService(path, homeDir string, serveArgs []string) error {
+	args, err := serviceArgs(activeProfile, serveArgs)
+	if err != nil {
+		return err
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	workDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	spec := ServiceSpec{
+		Label:      launchdLabel,
+		Executable: executable,
+		Args:       args,
+		WorkDir:    workDir,
+		LogFile:    filepath.Join(homeDir, "Library", "Logs", serviceName+".log"),
+	}
+	content, err := renderServiceFile(runtime.GOOS, spec)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", path)
+
+	if runtime.GOOS == "darwin" {
+		return runCommand("launchctl", "load", "-w", path)
+	}
+	if err := runCommand("systemctl", "--user", "daemon-reload"); err != nil {
+		return err
+	}
+	return runCommand("systemctl", "--user", "enable", "--now", serviceName)
+}
+
+// uninstallService stops the service and removes its file
+func uninstallService(path string) error {
+	if runtime.GOOS == "darwin" {
+		if err := runCommand("launchctl", "unload", "-w", path); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: launchctl unload failed: %v\n", err)
+		}
+	} else {
+		if err := runCommand("systemctl", "--user", "disable", "--now", serviceName); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: systemctl disable failed: %v\n", err)
+		}
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	fmt.Printf("Removed %s\n", path)
+
+	if runtime.GOOS == "linux" {
+		return runCommand("systemctl", "--user", "daemon-reload")
+	}
+	return nil
+}
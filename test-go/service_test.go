@@ -0,0 +1,81 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServicePath(t *testing.T) {
+	path, err := servicePath("linux", "/home/user")
+	if err != nil || path != filepath.Join("/home/user", ".config/systemd/user/coding-plan-quota-query.service") {
+		t.Errorf("Unexpected linux service path %s (%v)", path, err)
+	}
+
+	path, err = servicePath("darwin", "/Users/user")
+	if err != nil || path != filepath.Join("/Users/user", "Library/LaunchAgents/com.github.hongyanca.coding-plan-quota-query.plist") {
+		t.Errorf("Unexpected darwin service path %s (%v)", path, err)
+	}
+
+	if _, err := servicePath("plan9", "/home/user"); err == nil {
+		t.Error("Expected error for unsupported OS")
+	}
+}
+
+func TestRenderServiceFile(t *testing.T) {
+	spec := ServiceSpec{
+		Label:      launchdLabel,
+		Executable: "/opt/Quota Tools/coding-plan-quota-query",
+		Args:       []string{"--profile", "work", "serve", "--listen", "127.0.0.1:8000"},
+		WorkDir:    "/home/user/quota & co",
+		LogFile:    "/home/user/Library/Logs/coding-plan-quota-query.log",
+	}
+
+	unit, err := renderServiceFile("linux", spec)
+	if err != nil {
+		t.Fatalf("Failed to render systemd unit: %v", err)
+	}
+	for _, expected := range []string{
+		`ExecStart="/opt/Quota Tools/coding-plan-quota-query" "--profile" "work" "serve" "--listen" "127.0.0.1:8000"`,
+		"WorkingDirectory=/home/user/quota & co",
+		"WantedBy=default.target",
+	} {
+		if !strings.Contains(string(unit), expected) {
+			t.Errorf("Expected systemd unit to contain %q", expected)
+		}
+	}
+
+	plist, err := renderServiceFile("darwin", spec)
+	if err != nil {
+		t.Fatalf("Failed to render launchd plist: %v", err)
+	}
+	for _, expected := range []string{
+		"<string>com.github.hongyanca.coding-plan-quota-query</string>",
+		"<string>/opt/Quota Tools/coding-plan-quota-query</string>\n\t\t<string>--profile</string>\n\t\t<string>work</string>\n\t\t<string>serve</string>",
+		"<string>/home/user/quota &amp; co</string>",
+	} {
+		if !strings.Contains(string(plist), expected) {
+			t.Errorf("Expected launchd plist to contain %q", expected)
+		}
+	}
+}
+
+func TestSystemdQuote(t *testing.T) {
+	if quoted := systemdQuote(`C:\a "b" 100% $HOME`); quoted != `"C:\\a \"b\" 100%% $$HOME"` {
+		t.Errorf("Unexpected quoting %s", quoted)
+	}
+}
+
+func TestServiceArgs(t *testing.T) {
+	t.Setenv("PORT", "8000")
+	args, err := serviceArgs("work", []string{"--listen", "127.0.0.1:9000"})
+	if err != nil || strings.Join(args, " ") != "--profile work serve --listen 127.0.0.1:9000" {
+		t.Errorf("Unexpected service arguments %q (%v)", args, err)
+	}
+	if args, _ := serviceArgs("", nil); strings.Join(args, " ") != "serve" {
+		t.Errorf("Expected serve alone without a profile or options, got %q", args)
+	}
+	if _, err := serviceArgs("", []string{"--lisen", "127.0.0.1:9000"}); err == nil {
+		t.Error("Expected an unknown serve option to fail the install")
+	}
+}
@@ -0,0 +1,171 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/joho/godotenv"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// windowsService runs the server under the Windows service control manager with the
+// serve options given at install time
+type windowsService struct {
+	args []string
+}
+
+// Execute starts the server and drains it on a stop or shutdown request
+func (ws *windowsService) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	stopped := make(chan struct{})
+	go func() {
+		if err := serveUntil(ctx, ws.args); err != nil {
+			log.Fatalf("serve: %v", err)
+		}
+		close(stopped)
+	}()
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for req := range requests {
+		switch req.Cmd {
+		case svc.Interrogate:
+			changes <- req.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			// Drain in-flight requests before reporting the service stopped
+			changes <- svc.Status{State: svc.StopPending}
+			stop()
+			<-stopped
+			return false, 0
+		}
+	}
+	return false, 0
+}
+
+// runWindowsService is the service entry point; it switches to the install directory
+// so .env and the account file resolve as they did at install time, then applies the
+// profile, which is defined in that .env
+func runWindowsService(workDir, profile string, serveArgs []string) error {
+	if err := os.Chdir(workDir); err != nil {
+		return err
+	}
+	if err := godotenv.Load(".env"); err != nil {
+		log.Printf("Warning: .env file not found: %v", err)
+	}
+	if profile != "" {
+		if err := applyProfile(profile); err != nil {
+			return err
+		}
+	}
+
+	logFile, err := os.OpenFile(filepath.Join(workDir, serviceName+".log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err == nil {
+		log.SetOutput(logFile)
+		defer logFile.Close()
+	}
+
+	return svc.Run(serviceName, &windowsService{args: serveArgs})
+}
+
+// runWindowsServiceCommand handles "service install [serve options]|uninstall|status|run" on
+// Windows. The service runs "service run <dir> <profile> [serve options]"; services installed
+// before the profile was recorded run "service run <dir>".
+func runWindowsServiceCommand(args []string) error {
+	if len(args) >= 2 && args[0] == "run" {
+		profile, serveArgs := "", []string(nil)
+		if len(args) > 2 {
+			profile, serveArgs = args[2], args[3:]
+		}
+		return runWindowsService(args[1], profile, serveArgs)
+	}
+	if len(args) == 0 || (len(args) > 1 && args[0] != "install") {
+		return fmt.Errorf("expected one of install [serve options], uninstall, status")
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	switch args[0] {
+	case "install":
+		// The profile is passed on its own, as it can only be applied once .env is loaded
+		if _, err := serviceArgs(activeProfile, args[1:]); err != nil {
+			return err
+		}
+		executable, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		workDir, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		s, err := m.CreateService(serviceName, executable, mgr.Config{
+			DisplayName: "Coding Plan Quota Query API",
+			StartType:   mgr.StartAutomatic,
+		}, append([]string{"service", "run", workDir, activeProfile}, args[1:]...)...)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		fmt.Printf("Installed Windows service %s\n", serviceName)
+		return s.Start()
+	case "uninstall":
+		s, err := m.OpenService(serviceName)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		if _, err := s.Control(svc.Stop); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: stopping service failed: %v\n", err)
+		}
+		if err := s.Delete(); err != nil {
+			return err
+		}
+		fmt.Printf("Removed Windows service %s\n", serviceName)
+		return nil
+	case "status":
+		s, err := m.OpenService(serviceName)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		status, err := s.Query()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: %s\n", serviceName, windowsServiceState(status.State))
+		return nil
+	default:
+		return fmt.Errorf("unknown service command: %s", args[0])
+	}
+}
+
+// windowsServiceState returns a readable name for a service state
+func windowsServiceState(state svc.State) string {
+	switch state {
+	case svc.Stopped:
+		return "stopped"
+	case svc.StartPending:
+		return "starting"
+	case svc.StopPending:
+		return "stopping"
+	case svc.Running:
+		return "running"
+	default:
+		return fmt.Sprintf("state %d", state)
+	}
+}
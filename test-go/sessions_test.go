@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionMarks(t *testing.T) {
+	clk := &fakeClock{t: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	defer setClock(clk)()
+
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	history := newHistoryStore()
+	if err := history.open(&fileHistoryStore{path: path}); err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+
+	if _, err := history.mark(MarkStop, ""); err == nil {
+		t.Error("Expected an error stopping without a running session")
+	}
+	history.mark(MarkStart, "refactor")
+	clk.Advance(time.Hour)
+	// Starting another session stops the running one
+	history.mark(MarkStart, "docs")
+	clk.Advance(30 * time.Minute)
+	if name, err := history.mark(MarkStop, ""); err != nil || name != "docs" {
+		t.Fatalf("Expected docs stopped, got %q %v", name, err)
+	}
+	history.mark(MarkStart, "review")
+
+	// Marks survive a reload, and quota records in the same file are unaffected
+	history.record("glm", &FormattedQuota{LastUpdated: clk.Now().Unix(), Models: []FormattedModel{{Name: "glm", Percentage: 50}}})
+	reloaded := newHistoryStore()
+	if err := reloaded.open(&fileHistoryStore{path: path}); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if records := reloaded.query("glm", "glm", clk.Now(), clk.Now().Add(time.Second)); len(records) != 1 {
+		t.Errorf("Expected the quota record reloaded, got %v", records)
+	}
+
+	sessions := reloaded.sessions(clk.Now().Add(time.Hour))
+	if len(sessions) != 3 {
+		t.Fatalf("Expected 3 sessions, got %+v", sessions)
+	}
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC).Unix()
+	if sessions[0] != (Session{Name: "refactor", Start: start, End: start + 3600}) ||
+		sessions[1] != (Session{Name: "docs", Start: start + 3600, End: start + 5400}) ||
+		!sessions[2].Open || sessions[2].End != start+9000 {
+		t.Errorf("Unexpected sessions: %+v", sessions)
+	}
+}
+
+func TestSummarizeSessions(t *testing.T) {
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	clk := &fakeClock{t: base}
+	defer setClock(clk)()
+
+	history := newHistoryStore()
+	observe := func(minutes int, pct float64) {
+		history.record("glm", &FormattedQuota{LastUpdated: base.Add(time.Duration(minutes) * time.Minute).Unix(), Models: []FormattedModel{{Name: "glm", Percentage: pct}}})
+	}
+	observe(0, 90)
+	history.mark(MarkStart, "refactor")
+	observe(20, 80)
+	// A reset in the middle of the session is not counted as negative use
+	observe(40, 100)
+	observe(60, 95)
+	clk.Advance(time.Hour)
+	history.mark(MarkStop, "")
+	observe(90, 70)
+
+	summaries := summarizeSessions(history, base, base.Add(2*time.Hour))
+	if len(summaries) != 1 || summaries[0].Name != "refactor" {
+		t.Fatalf("Unexpected summaries: %+v", summaries)
+	}
+	if models := summaries[0].Models; len(models) != 1 || models[0].Used != 15 {
+		t.Errorf("Expected 15 points used in the session, got %+v", models)
+	}
+
+	if models := history.consumed(base, base.Add(2*time.Hour)); len(models) != 1 || models[0].Used != 40 {
+		t.Errorf("Expected 40 points used overall, got %+v", models)
+	}
+	if summaries := summarizeSessions(history, base.Add(90*time.Minute), base.Add(2*time.Hour)); len(summaries) != 0 {
+		t.Errorf("Expected sessions ended before since to be skipped, got %+v", summaries)
+	}
+}
+
+func TestPeriodComparisons(t *testing.T) {
+	start := time.Date(2026, 8, 5, 12, 0, 0, 0, time.Local)
+	clk := &fakeClock{t: start}
+	defer setClock(clk)()
+
+	// The pool resets on the 5th, using 4 points a day in September and 3 in October
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	history := newHistoryStore()
+	history.open(&fileHistoryStore{path: path})
+	for day := range 70 {
+		now := start.AddDate(0, 0, day)
+		clk.t = now
+		reset := time.Date(now.Year(), now.Month(), 5, 0, 0, 0, 0, time.Local)
+		if now.Before(reset) {
+			reset = reset.AddDate(0, -1, 0)
+		}
+		rate := 4.0
+		if reset.Month() == time.October {
+			rate = 3
+		}
+		used := rate * float64(int(now.Sub(reset).Hours()/24))
+		history.record("glm", &FormattedQuota{LastUpdated: now.Unix(), Models: []FormattedModel{
+			{Name: "glm-coding-plan-mcp-monthly", Percentage: 100 - used},
+			{Name: "glm", Percentage: 50},
+		}})
+	}
+	now := time.Date(2026, 10, 13, 12, 0, 0, 0, time.Local)
+	clk.t = now
+
+	want := PeriodComparison{Provider: "glm", Model: "glm-coding-plan-mcp-monthly", Day: 9, Used: 24, PreviousUsed: 32,
+		PeriodStart: time.Date(2026, 10, 5, 0, 0, 0, 0, time.Local).Unix()}
+	if got := history.periodComparisons(now); len(got) != 1 || got[0] != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	// Rollups outlive the raw records when the history is loaded again
+	reloaded := newHistoryStore()
+	if err := reloaded.open(&fileHistoryStore{path: path}); err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.periodComparisons(now); len(got) != 1 || got[0] != want {
+		t.Errorf("Expected %+v after reloading, got %+v", want, got)
+	}
+
+	defer func(saved *historyStore) { quotaHistory = saved }(quotaHistory)
+	quotaHistory = newHistoryStore()
+	t.Setenv("HISTORY_FILE", path)
+	var buf bytes.Buffer
+	if err := runSummaryCommand(nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if line := "glm/glm-coding-plan-mcp-monthly: 24% used by day 9; last month you were at 32% by day 9"; !strings.Contains(buf.String(), line) {
+		t.Errorf("Expected %q in the summary, got:\n%s", line, buf.String())
+	}
+
+	// A month without an observation near the same point is not compared
+	if got := history.periodComparisons(time.Date(2026, 11, 20, 12, 0, 0, 0, time.Local)); len(got) != 0 {
+		t.Errorf("Expected no comparison without recent data, got %+v", got)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Seconds in-flight requests get to finish at shutdown unless SHUTDOWN_TIMEOUT is set
+const DefaultShutdownTimeout = 10
+
+// shutdownSignals stop the server gracefully; systemd and launchd stop services with SIGTERM
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// shutdownTimeout returns how long shutdown waits for in-flight requests and background tasks
+func shutdownTimeout() time.Duration {
+	return time.Duration(getEnvAsInt("SHUTDOWN_TIMEOUT", DefaultShutdownTimeout)) * time.Second
+}
+
+// drainServer stops a server whose context is done: it ends streams, stops accepting
+// connections, waits for in-flight HTTP and gRPC requests and the background tasks until
+// the timeout, closing whatever is left after it, then closes the history store. Fetches
+// finished by then have already saved their prompt cache and history.
+func drainServer(timeout time.Duration, server *http.Server, grpcServer *grpc.Server, service *QuotaService, background *sync.WaitGroup) {
+	log.Printf("Shutting down, waiting up to %s for in-flight requests", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	service.drainOnce.Do(func() { close(service.draining) })
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("In-flight requests did not finish in time, closing their connections")
+		server.Close()
+	}
+
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			log.Printf("In-flight gRPC calls did not finish in time, closing them")
+			grpcServer.Stop()
+		}
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		log.Printf("Background tasks did not finish in time")
+	}
+
+	quotaHistory.close()
+	log.Printf("Server stopped")
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startDrainTestServer serves handler on a local port and returns the server and its URL
+func startDrainTestServer(t *testing.T, handler http.HandlerFunc) (*http.Server, string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: handler}
+	go server.Serve(listener)
+	return server, "http://" + listener.Addr().String()
+}
+
+func TestDrainServerFinishesInFlightRequests(t *testing.T) {
+	defer func(saved *historyStore) { quotaHistory = saved }(quotaHistory)
+	quotaHistory = newHistoryStore()
+	quotaHistory.store = &fileHistoryStore{path: filepath.Join(t.TempDir(), "history.jsonl")}
+
+	started := make(chan struct{})
+	server, url := startDrainTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		body <- string(data)
+	}()
+	<-started
+
+	service := NewQuotaService(NewCloudCodeClient(LoadConfig()))
+	watched := make(chan error, 1)
+	go func() {
+		watched <- service.watchQuota(t.Context(), "nope", time.Hour,
+			func(*FormattedQuota) error { return nil },
+			func(error) error { return nil })
+	}()
+	var background sync.WaitGroup
+	background.Go(func() { <-service.draining })
+
+	drainServer(5*time.Second, server, nil, service, &background)
+
+	if got := <-body; got != "done" {
+		t.Errorf("Expected the in-flight request to finish, got %q", got)
+	}
+	select {
+	case <-watched:
+	case <-time.After(time.Second):
+		t.Error("Expected the watch to end on shutdown")
+	}
+	if quotaHistory.store != nil {
+		t.Error("Expected the history store to be closed")
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Error("Expected new connections to be refused")
+	}
+}
+
+func TestDrainServerTimeout(t *testing.T) {
+	defer func(saved *historyStore) { quotaHistory = saved }(quotaHistory)
+	quotaHistory = newHistoryStore()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	server, url := startDrainTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	failed := make(chan error, 1)
+	go func() {
+		_, err := http.Get(url)
+		failed <- err
+	}()
+	<-started
+
+	var background sync.WaitGroup
+	background.Go(func() { <-release })
+	start := time.Now()
+	drainServer(50*time.Millisecond, server, nil, NewQuotaService(NewCloudCodeClient(LoadConfig())), &background)
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected shutdown to stop waiting at the timeout, took %s", elapsed)
+	}
+	if err := <-failed; err == nil {
+		t.Error("Expected the stuck request to be closed")
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigV4SignerVanillaRequest(t *testing.T) {
+	// The get-vanilla case of the AWS Signature Version 4 test suite
+	restore := setClock(&fakeClock{t: time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)})
+	defer restore()
+
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	signer := sigV4Signer(awsCredentials{
+		AccessKey: "AKIDEXAMPLE",
+		SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:    "us-east-1",
+		Service:   "service",
+	})
+	if err := signer.Sign(req); err != nil {
+		t.Fatalf("Expected the request to be signed, got %v", err)
+	}
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	if req.Header.Get("X-Amz-Date") != "20150830T123600Z" {
+		t.Errorf("Expected the signing time in X-Amz-Date, got %q", req.Header.Get("X-Amz-Date"))
+	}
+}
+
+func TestZhipuJWTSigner(t *testing.T) {
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	restore := setClock(&fakeClock{t: now})
+	defer restore()
+
+	req, _ := http.NewRequest("GET", "https://open.bigmodel.cn/api/monitor", nil)
+	req.Header.Set("Authorization", "Bearer key-id.key-secret")
+	if err := zhipuJWTSigner("Authorization").Sign(req); err != nil {
+		t.Fatalf("Expected the key to be signed, got %v", err)
+	}
+
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	parts := strings.Split(token, ".")
+	if !ok || len(parts) != 3 {
+		t.Fatalf("Expected a bearer JWT, got %q", req.Header.Get("Authorization"))
+	}
+	mac := hmac.New(sha256.New, []byte("key-secret"))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if parts[2] != base64.RawURLEncoding.EncodeToString(mac.Sum(nil)) {
+		t.Error("Expected the JWT to be signed with the key secret")
+	}
+	var claims struct {
+		APIKey    string `json:"api_key"`
+		Exp       int64  `json:"exp"`
+		Timestamp int64  `json:"timestamp"`
+	}
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	json.Unmarshal(payload, &claims)
+	if claims.APIKey != "key-id" || claims.Timestamp != now.UnixMilli() || claims.Exp != now.Add(3*time.Minute).UnixMilli() {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+
+	// Plain keys and tokens that are already JWTs are sent as they are
+	for _, value := range []string{"plain-key", token} {
+		req.Header.Set("Authorization", value)
+		zhipuJWTSigner("Authorization").Sign(req)
+		if req.Header.Get("Authorization") != value {
+			t.Errorf("Expected %q to be left alone, got %q", value, req.Header.Get("Authorization"))
+		}
+	}
+}
+
+func TestQueryZAIEndpointSignedRequest(t *testing.T) {
+	restore := setClock(&fakeClock{t: time.Unix(1772355600, 0)})
+	defer restore()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if strings.Count(auth, ".") != 2 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body := sha256.Sum256(nil)
+		message := strings.Join([]string{r.Header.Get("X-Timestamp"), r.Method, r.URL.RequestURI(), hex.EncodeToString(body[:])}, "\n")
+		mac := hmac.New(sha256.New, []byte("gateway-secret"))
+		mac.Write([]byte(message))
+		if r.Header.Get("X-Timestamp") != "1772355600" || r.Header.Get("X-Signature") != hex.EncodeToString(mac.Sum(nil)) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"code":200,"data":{"ok":true}}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("REQUEST_SIGNING_GLM", "zhipu-jwt,hmac")
+	t.Setenv("REQUEST_SIGNING_SECRET_GLM", "gateway-secret")
+	if _, _, err := QueryZAIEndpoint(context.Background(), ProviderGLM, server.URL+"/api/monitor?x=1", "id.secret", ""); err != nil {
+		t.Errorf("Expected the JWT and the HMAC over it to be accepted, got %v", err)
+	}
+}
+
+func TestSignedClientErrors(t *testing.T) {
+	t.Setenv("REQUEST_SIGNING_GROQ", "rot13")
+	t.Setenv("REQUEST_SIGNING_XAI", "hmac")
+	config := LoadConfig()
+
+	for provider, fix := range map[string]string{
+		ProviderGroq: "REQUEST_SIGNING_GROQ to a list of",
+		ProviderXAI:  "REQUEST_SIGNING_SECRET_XAI",
+	} {
+		_, err := signedClient(&http.Client{}, config, provider)
+		if err == nil || !strings.Contains(remediation(provider, err), fix) {
+			t.Errorf("Expected %s to fail with a fix mentioning %s, got %v", provider, fix, err)
+		}
+	}
+
+	client := &http.Client{}
+	if signed, err := signedClient(client, config, ProviderMistral); err != nil || signed != client {
+		t.Errorf("Expected a provider without signers to keep its client, got %v", err)
+	}
+}
+
+func TestDefaultRequestSigners(t *testing.T) {
+	if signers := LoadConfig().RequestSigners[ProviderZhipuBalance]; !slices.Equal(signers, []string{"zhipu-jwt"}) {
+		t.Errorf("Expected zhipu-balance to derive tokens by default, got %v", signers)
+	}
+	t.Setenv("REQUEST_SIGNING_ZHIPU_BALANCE", "zhipu-jwt,hmac")
+	if signers := LoadConfig().RequestSigners[ProviderZhipuBalance]; !slices.Equal(signers, []string{"zhipu-jwt", "hmac"}) {
+		t.Errorf("Expected REQUEST_SIGNING_ZHIPU_BALANCE to replace the default, got %v", signers)
+	}
+	if defaultRequestSigners[ProviderZhipuBalance][0] != "zhipu-jwt" || len(defaultRequestSigners) != 1 {
+		t.Errorf("Expected the defaults to be left alone, got %v", defaultRequestSigners)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"net/smtp"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuietHours(t *testing.T) {
+	ranges := parseQuietHours("22:00-07:00, 12:30-13:00, bogus, 25:00-26:00")
+	if len(ranges) != 2 {
+		t.Fatalf("Expected the invalid ranges to be skipped, got %+v", ranges)
+	}
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 10, 15, hour, minute, 0, 0, time.Local)
+	}
+	config := &Config{QuietHours: ranges, SilenceFile: filepath.Join(t.TempDir(), "silence.json")}
+	for _, tt := range []struct {
+		at   time.Time
+		want bool
+	}{
+		{at(23, 0), true},
+		{at(3, 0), true},
+		{at(7, 0), false},
+		{at(12, 45), true},
+		{at(13, 0), false},
+		{at(18, 0), false},
+	} {
+		if got := alertsSilenced(config, tt.at); got != tt.want {
+			t.Errorf("alertsSilenced(%v) = %v, expected %v", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestSilenceCommand(t *testing.T) {
+	clk := &fakeClock{t: time.Date(2026, 10, 15, 1, 0, 0, 0, time.Local)}
+	defer setClock(clk)()
+	path := filepath.Join(t.TempDir(), "silence.json")
+	t.Setenv("SILENCE_FILE", path)
+	config := &Config{SilenceFile: path}
+
+	var out bytes.Buffer
+	if err := runSilenceCommand([]string{"2h", "nightly", "batch"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "Alerts silenced until Oct 15 03:00 (nightly batch)" {
+		t.Errorf("Unexpected output: %q", got)
+	}
+	if !alertsSilenced(config, clockNow().Add(time.Hour)) || alertsSilenced(config, clockNow().Add(2*time.Hour)) {
+		t.Error("Expected alerts to be silenced for two hours")
+	}
+
+	// Hook commands still run while notifiers hold their alerts
+	runner := NewHookRunner()
+	commands := make(chan string, 1)
+	runner.run = func(command string, event HookEvent) { commands <- command }
+	mailed := make(chan bool, 1)
+	runner.mail.send = func(string, smtp.Auth, string, []string, []byte) error {
+		mailed <- true
+		return nil
+	}
+	hookConfig := &Config{
+		HookThreshold: 10,
+		HookOnBelow:   "pause",
+		SMTPAddress:   "smtp.example.com:587",
+		EmailFrom:     "quota@example.com",
+		EmailTo:       []string{"me@example.com"},
+		EmailAlerts:   []string{HookEventBelow},
+		SilenceFile:   path,
+	}
+	runner.observe(hookConfig, "glm", &FormattedQuota{Models: []FormattedModel{{Name: "glm", Percentage: 5}}})
+	select {
+	case <-commands:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the hook command to run")
+	}
+	select {
+	case <-mailed:
+		t.Error("Expected no mail while silenced")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	out.Reset()
+	if err := runSilenceCommand([]string{"off"}, &out); err != nil || alertsSilenced(config, clockNow()) {
+		t.Errorf("Expected the silence to be lifted, got %v", err)
+	}
+	out.Reset()
+	if err := runSilenceCommand(nil, &out); err != nil || strings.TrimSpace(out.String()) != "Alerts are not silenced" {
+		t.Errorf("Unexpected status %q, %v", out.String(), err)
+	}
+	if err := runSilenceCommand([]string{"soon"}, &out); err == nil {
+		t.Error("Expected an invalid duration to be rejected")
+	}
+}
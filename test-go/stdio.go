@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcProviderError  = -32000
+)
+
+// Longest request line accepted on stdin
+const maxRPCLine = 1 << 20
+
+// rpcRequest is a JSON-RPC 2.0 request, or a notification when it has no ID
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcError is the error member of a JSON-RPC response
+type rpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// rpcQuotaParams are the parameters of getQuota and subscribe. Only and Exclude default to
+// MODEL_ONLY and MODEL_EXCLUDE.
+type rpcQuotaParams struct {
+	Provider        string   `json:"provider"`
+	Only            []string `json:"only"`
+	Exclude         []string `json:"exclude"`
+	IntervalSeconds int      `json:"intervalSeconds"`
+}
+
+// stdioServer answers newline-delimited JSON-RPC 2.0 requests, one per line, and sends quota
+// notifications to subscribers, so editor plugins can run the binary as a child process
+type stdioServer struct {
+	service *QuotaService
+
+	mu  sync.Mutex
+	out *json.Encoder
+
+	subscriptions map[string]context.CancelFunc
+	nextID        int
+}
+
+// runStdioCommand serves JSON-RPC on stdin and stdout until stdin is closed
+func runStdioCommand(stdin io.Reader, stdout io.Writer) error {
+	service := NewQuotaService(NewCloudCodeClient(LoadConfig()))
+	return newStdioServer(service, stdout).serve(context.Background(), stdin)
+}
+
+// newStdioServer creates a server writing responses and notifications to out
+func newStdioServer(service *QuotaService, out io.Writer) *stdioServer {
+	return &stdioServer{
+		service:       service,
+		out:           json.NewEncoder(out),
+		subscriptions: make(map[string]context.CancelFunc),
+	}
+}
+
+// serve reads requests until in is closed, then cancels every subscription
+func (s *stdioServer) serve(ctx context.Context, in io.Reader) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), maxRPCLine)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var req rpcRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			s.reply(json.RawMessage("null"), nil, &rpcError{Code: rpcParseError, Message: err.Error()})
+			continue
+		}
+		if req.JSONRPC != "2.0" || req.Method == "" {
+			s.reply(req.ID, nil, &rpcError{Code: rpcInvalidRequest, Message: "expected a JSON-RPC 2.0 request"})
+			continue
+		}
+		// Requests run concurrently, so a slow provider does not hold up the others
+		go s.handle(ctx, req)
+	}
+	return scanner.Err()
+}
+
+// handle answers one request
+func (s *stdioServer) handle(ctx context.Context, req rpcRequest) {
+	var params rpcQuotaParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			s.reply(req.ID, nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()})
+			return
+		}
+	}
+
+	switch req.Method {
+	case "getQuota":
+		s.service.activity.touch()
+		quota, err := s.service.fetchQuota(ctx, params.Provider)
+		if err != nil {
+			s.reply(req.ID, nil, providerRPCError(params.Provider, err))
+			return
+		}
+		s.reply(req.ID, s.selectModels(quota, params), nil)
+	case "subscribe":
+		// An unknown provider fails the request rather than every notification
+		if _, err := s.service.fetchQuota(ctx, params.Provider); errors.Is(err, ErrUnknownProvider) {
+			s.reply(req.ID, nil, providerRPCError(params.Provider, err))
+			return
+		}
+		id := s.subscribe(ctx, params)
+		s.reply(req.ID, map[string]string{"subscription": id}, nil)
+	case "unsubscribe":
+		var target struct {
+			Subscription string `json:"subscription"`
+		}
+		json.Unmarshal(req.Params, &target)
+		s.mu.Lock()
+		cancel, ok := s.subscriptions[target.Subscription]
+		delete(s.subscriptions, target.Subscription)
+		s.mu.Unlock()
+		if !ok {
+			s.reply(req.ID, nil, &rpcError{Code: rpcInvalidParams, Message: "unknown subscription " + strconv.Quote(target.Subscription)})
+			return
+		}
+		cancel()
+		s.reply(req.ID, true, nil)
+	default:
+		s.reply(req.ID, nil, &rpcError{Code: rpcMethodNotFound, Message: "unknown method " + strconv.Quote(req.Method)})
+	}
+}
+
+// subscribe sends a quota notification now and whenever a model's percentage changes, until
+// the subscription is cancelled
+func (s *stdioServer) subscribe(ctx context.Context, params rpcQuotaParams) string {
+	interval := time.Duration(params.IntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = time.Duration(s.service.client.Config().QueryDebounce) * time.Minute
+	}
+
+	s.mu.Lock()
+	s.nextID++
+	id := strconv.Itoa(s.nextID)
+	ctx, cancel := context.WithCancel(ctx)
+	s.subscriptions[id] = cancel
+	s.mu.Unlock()
+
+	go s.service.watchQuota(ctx, params.Provider, interval,
+		func(quota *FormattedQuota) error {
+			s.notify("quota", map[string]interface{}{"subscription": id, "quota": s.selectModels(quota, params)})
+			return nil
+		},
+		func(err error) error {
+			log.Printf("Subscription %s refresh failed: %v", id, err)
+			return nil
+		},
+	)
+	return id
+}
+
+// selectModels applies the request's only and exclude globs, falling back to config
+func (s *stdioServer) selectModels(quota *FormattedQuota, params rpcQuotaParams) *FormattedQuota {
+	config := s.service.client.Config()
+	only, exclude := config.ModelOnly, config.ModelExclude
+	if params.Only != nil {
+		only = params.Only
+	}
+	if params.Exclude != nil {
+		exclude = params.Exclude
+	}
+	return selectModels(quota, only, exclude)
+}
+
+// providerRPCError maps quota errors to JSON-RPC errors, with the problem and its fix as data
+func providerRPCError(provider string, err error) *rpcError {
+	code := rpcProviderError
+	if errors.Is(err, ErrUnknownProvider) {
+		code = rpcInvalidParams
+	}
+	if provider == "" {
+		provider = ProviderAntigravity
+	}
+	return &rpcError{Code: code, Message: err.Error(), Data: newProblem(provider, err)}
+}
+
+// reply writes a response. Notifications, requests without an ID, get none.
+func (s *stdioServer) reply(id json.RawMessage, result interface{}, rpcErr *rpcError) {
+	if len(id) == 0 {
+		return
+	}
+	response := map[string]interface{}{"jsonrpc": "2.0", "id": id}
+	if rpcErr != nil {
+		response["error"] = rpcErr
+	} else {
+		response["result"] = result
+	}
+	s.write(response)
+}
+
+// notify writes a notification
+func (s *stdioServer) notify(method string, params interface{}) {
+	s.write(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+// write encodes one message per line, serialized across goroutines
+func (s *stdioServer) write(message interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.out.Encode(message); err != nil {
+		log.Printf("Failed to write JSON-RPC message: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStdioServer(t *testing.T) {
+	quotaProviders["stdio-test"] = func(context.Context) (FormattedQuota, error) {
+		return FormattedQuota{Models: []FormattedModel{{Name: "fast", Percentage: 80}, {Name: "slow", Percentage: 50}}}, nil
+	}
+	defer delete(quotaProviders, "stdio-test")
+
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	service := NewQuotaService(NewCloudCodeClient(&Config{QueryDebounce: 1}))
+	done := make(chan error, 1)
+	go func() { done <- newStdioServer(service, outWriter).serve(context.Background(), inReader) }()
+
+	lines := make(chan map[string]interface{}, 8)
+	go func() {
+		scanner := bufio.NewScanner(outReader)
+		for scanner.Scan() {
+			var message map[string]interface{}
+			json.Unmarshal(scanner.Bytes(), &message)
+			lines <- message
+		}
+	}()
+	send := func(request string) {
+		io.WriteString(inWriter, request+"\n")
+	}
+	next := func() map[string]interface{} {
+		select {
+		case message := <-lines:
+			return message
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected a JSON-RPC message")
+			return nil
+		}
+	}
+
+	send(`{"jsonrpc":"2.0","id":1,"method":"getQuota","params":{"provider":"stdio-test","only":["fast"]}}`)
+	response := next()
+	result, _ := response["result"].(map[string]interface{})
+	if models, _ := result["models"].([]interface{}); response["id"] != 1.0 || len(models) != 1 {
+		t.Errorf("Unexpected getQuota response: %v", response)
+	}
+
+	send(`{"jsonrpc":"2.0","id":2,"method":"getQuota","params":{"provider":"nope"}}`)
+	if rpcErr, _ := next()["error"].(map[string]interface{}); rpcErr["code"] != float64(rpcInvalidParams) {
+		t.Errorf("Expected invalid params for an unknown provider, got %v", rpcErr)
+	}
+	send(`{"jsonrpc":"2.0","id":3,"method":"reboot"}`)
+	if rpcErr, _ := next()["error"].(map[string]interface{}); rpcErr["code"] != float64(rpcMethodNotFound) {
+		t.Errorf("Expected method not found, got %v", rpcErr)
+	}
+	send(`not json`)
+	if rpcErr, _ := next()["error"].(map[string]interface{}); rpcErr["code"] != float64(rpcParseError) {
+		t.Errorf("Expected a parse error, got %v", rpcErr)
+	}
+
+	send(`{"jsonrpc":"2.0","id":4,"method":"subscribe","params":{"provider":"stdio-test","intervalSeconds":1}}`)
+	var subscription string
+	for range 2 {
+		message := next()
+		if message["method"] == "quota" {
+			params := message["params"].(map[string]interface{})
+			if params["subscription"] != "1" {
+				t.Errorf("Unexpected notification: %v", message)
+			}
+			continue
+		}
+		result, _ := message["result"].(map[string]interface{})
+		subscription, _ = result["subscription"].(string)
+	}
+	if subscription != "1" {
+		t.Fatalf("Expected subscription 1, got %q", subscription)
+	}
+
+	send(`{"jsonrpc":"2.0","id":5,"method":"unsubscribe","params":{"subscription":"1"}}`)
+	if response := next(); response["result"] != true {
+		t.Errorf("Unexpected unsubscribe response: %v", response)
+	}
+	send(`{"jsonrpc":"2.0","id":6,"method":"unsubscribe","params":{"subscription":"1"}}`)
+	if rpcErr, _ := next()["error"].(map[string]interface{}); !strings.Contains(rpcErr["message"].(string), "unknown subscription") {
+		t.Errorf("Expected an unknown subscription error, got %v", rpcErr)
+	}
+
+	inWriter.Close()
+	if err := <-done; err != nil {
+		t.Errorf("Expected a clean exit on EOF, got %v", err)
+	}
+}
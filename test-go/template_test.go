@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTemplateRenderer(t *testing.T) {
+	defer setClock(&fakeClock{t: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)})()
+
+	quota := FormattedQuota{Models: []FormattedModel{
+		{Name: "glm", Percentage: 64.46, ResetTime: "2026-03-01T14:15:00Z"},
+		{Name: "mcp", Percentage: 15},
+	}}
+
+	renderer, err := newTemplateRenderer(`{{range .Models}}{{.Name}}:{{round .Percentage}}% {{bar .Percentage 4}} {{humanize .ResetTime}}|{{end}}`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	output, err := renderer.Render(quota)
+	if want := "glm:64% ███░ 2h15m|mcp:15% █░░░ |"; err != nil || string(output) != want {
+		t.Errorf("Expected %q, got %q (%v)", want, output, err)
+	}
+
+	renderer, _ = newTemplateRenderer(`{{range .Models}}{{color .Percentage}} {{color .Percentage .Name}} {{end}}`)
+	output, _ = renderer.Render(quota)
+	if want := "\033[32m64.46%\033[0m \033[32mglm\033[0m \033[31m15%\033[0m \033[31mmcp\033[0m "; string(output) != want {
+		t.Errorf("Expected %q, got %q", want, output)
+	}
+
+	if humanized := templateHumanize(int64(1234567)); humanized != "1,234,567" {
+		t.Errorf("Expected locale separators, got %s", humanized)
+	}
+
+	if _, err := newTemplateRenderer(`{{range .Models}`); err == nil {
+		t.Error("Expected a parse error")
+	}
+	if _, err := resolveRenderer(templateFormat, ""); err == nil {
+		t.Error("Expected an error for a missing template")
+	}
+	renderer, _ = newTemplateRenderer(`{{.Missing}}`)
+	if _, err := renderer.Render(quota); err == nil {
+		t.Error("Expected an execution error for an unknown field")
+	}
+}
+
+func TestTemplateFormatQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	service := &QuotaService{client: NewCloudCodeClient(LoadConfig())}
+	router.GET("/quota", func(c *gin.Context) {
+		service.respondQuota(c, &FormattedQuota{Models: []FormattedModel{{Name: "glm", Percentage: 50}}}, nil)
+	})
+
+	query := url.Values{"format": {"template"}, "template": {"{{range .Models}}{{.Name}}={{.Percentage}}{{end}}"}}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/quota?"+query.Encode(), nil))
+	if w.Code != http.StatusOK || w.Body.String() != "glm=50" {
+		t.Errorf("Expected the rendered template, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestRunShowCommandBadFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := runShowCommand([]string{"--format", "template"}, &buf); err == nil || !strings.Contains(err.Error(), "needs a template") {
+		t.Errorf("Expected a missing template error, got %v", err)
+	}
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Longest a token file read, command, or keychain lookup may run
+	tokenCommandTimeout = 10 * time.Second
+
+	// Failed lookups are retried after this interval rather than on every config load
+	tokenRetryInterval = time.Minute
+
+	// A token is read again after a rejected request at most this often, so a token that
+	// stays invalid does not run a password manager on every query
+	tokenRecheckInterval = 10 * time.Second
+
+	// How long a token derived from a Zhipu open-platform key stays valid, and how long
+	// before it expires a new one is derived
+	zhipuJWTTTL    = 3 * time.Minute
+	zhipuJWTMargin = 30 * time.Second
+)
+
+// TokenSource supplies a secret such as an API key or auth token. Besides the variable
+// itself, a secret variable NAME can be given as NAME_FILE (a file holding the token),
+// NAME_CMD (a shell command printing it, e.g. "pass show zai"), or NAME_KEYCHAIN (a service
+// in the macOS keychain or the Secret Service on Linux).
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// envToken reads the token from an environment variable
+type envToken struct {
+	name string
+}
+
+// Token returns the variable's value
+func (s envToken) Token(context.Context) (string, error) {
+	return trimQuotes(os.Getenv(s.name)), nil
+}
+
+// fileToken reads the token from a file; a leading ~ is the home directory
+type fileToken struct {
+	path string
+}
+
+// Token returns the file's contents without surrounding whitespace
+func (s fileToken) Token(context.Context) (string, error) {
+	path := s.path
+	if rest, ok := strings.CutPrefix(path, "~"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, rest)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// commandToken runs a shell command and takes the first line of its output, as password
+// managers such as pass print the secret first and metadata after
+type commandToken struct {
+	command string
+}
+
+// Token runs the command
+func (s commandToken) Token(ctx context.Context) (string, error) {
+	output, err := shellCommand(ctx, s.command).Output()
+	if err != nil {
+		return "", fmt.Errorf("%q failed: %w", s.command, err)
+	}
+	line, _, _ := strings.Cut(string(output), "\n")
+	return strings.TrimSpace(line), nil
+}
+
+// keychainToken looks up a generic password by service name in the macOS keychain or,
+// on Linux, the Secret Service through secret-tool
+type keychainToken struct {
+	service string
+}
+
+// Token runs the platform's keychain tool
+func (s keychainToken) Token(ctx context.Context) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.CommandContext(ctx, "security", "find-generic-password", "-s", s.service, "-w")
+	case "linux":
+		cmd = exec.CommandContext(ctx, "secret-tool", "lookup", "service", s.service)
+	default:
+		return "", fmt.Errorf("no keychain support on %s; use a _CMD variable instead", runtime.GOOS)
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("keychain lookup of %q failed: %w", s.service, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// cachedToken evaluates a source on first use and keeps the token. A failure is kept
+// for tokenRetryInterval, so a broken command does not run on every config load, and is
+// logged once per attempt.
+type cachedToken struct {
+	name   string
+	source TokenSource
+
+	mu        sync.Mutex
+	token     string
+	err       error
+	fetchedAt time.Time
+}
+
+// Token returns the cached token, evaluating the source when needed
+func (c *cachedToken) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.fetchedAt.IsZero() && (c.err == nil || clockNow().Sub(c.fetchedAt) < tokenRetryInterval) {
+		return c.token, c.err
+	}
+	c.evaluate(ctx)
+	return c.token, c.err
+}
+
+// recheck evaluates the source again, as the token may have been rotated, and reports
+// whether it changed. Tokens read within tokenRecheckInterval are kept.
+func (c *cachedToken) recheck(ctx context.Context) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.fetchedAt.IsZero() && clockNow().Sub(c.fetchedAt) < tokenRecheckInterval {
+		return false
+	}
+	previous := c.token
+	c.evaluate(ctx)
+	return c.err == nil && c.token != previous
+}
+
+// evaluate reads the token from the source; the caller holds c.mu
+func (c *cachedToken) evaluate(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, tokenCommandTimeout)
+	defer cancel()
+	c.token, c.err = c.source.Token(ctx)
+	if c.err == nil && c.token == "" {
+		c.err = errors.New("empty token")
+	}
+	if c.err != nil {
+		log.Printf("Warning: could not read %s: %v", c.name, c.err)
+	}
+	c.fetchedAt = clockNow()
+}
+
+// tokenCache holds the cached sources by variable and specification, so a changed
+// specification after a config reload is evaluated again
+var (
+	tokenCacheMu sync.Mutex
+	tokenCache   = make(map[string]*cachedToken)
+)
+
+// secretVariables holds the _FILE, _CMD, and _KEYCHAIN names of each secret variable, so
+// config loads do not build them again
+var secretVariables sync.Map
+
+// secretSource returns the source of a secret variable, or nil when none is configured.
+// The variable itself wins, then NAME_FILE, NAME_CMD, and NAME_KEYCHAIN.
+func secretSource(name string) TokenSource {
+	if os.Getenv(name) != "" {
+		return envToken{name: name}
+	}
+
+	names, ok := secretVariables.Load(name)
+	if !ok {
+		names, _ = secretVariables.LoadOrStore(name, [3]string{name + "_FILE", name + "_CMD", name + "_KEYCHAIN"})
+	}
+	variables := names.([3]string)
+
+	var source TokenSource
+	var kind string
+	switch {
+	case os.Getenv(variables[0]) != "":
+		kind, source = "file", fileToken{path: trimQuotes(os.Getenv(variables[0]))}
+	case os.Getenv(variables[1]) != "":
+		kind, source = "cmd", commandToken{command: trimQuotes(os.Getenv(variables[1]))}
+	case os.Getenv(variables[2]) != "":
+		kind, source = "keychain", keychainToken{service: trimQuotes(os.Getenv(variables[2]))}
+	default:
+		return nil
+	}
+
+	tokenCacheMu.Lock()
+	defer tokenCacheMu.Unlock()
+	key := fmt.Sprintf("%s=%s:%v", name, kind, source)
+	if cached, ok := tokenCache[key]; ok {
+		return cached
+	}
+	cached := &cachedToken{name: name, source: source}
+	tokenCache[key] = cached
+	return cached
+}
+
+// staticToken is a token given directly, such as a plain variable's value
+type staticToken string
+
+// Token returns the token itself
+func (s staticToken) Token(context.Context) (string, error) {
+	return string(s), nil
+}
+
+// secretToken returns the source of a secret variable for the config without reading it, or
+// nil when none is configured. A plain variable's value is kept as it is, so a reload sees
+// it change; file, command, and keychain sources are read when a provider first needs them.
+func secretToken(name string) TokenSource {
+	if value := trimQuotes(os.Getenv(name)); value != "" {
+		return staticToken(value)
+	}
+	return secretSource(name)
+}
+
+// readToken returns the token of a config secret, or "" when none is configured
+func readToken(ctx context.Context, source TokenSource) (string, error) {
+	if source == nil {
+		return "", nil
+	}
+	return source.Token(ctx)
+}
+
+// readTokenMap returns the name=token pairs of a config secret such as HUB_TOKENS, or none
+// when it is not configured
+func readTokenMap(ctx context.Context, source TokenSource) (map[string]string, error) {
+	tokens, err := readToken(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	return parseModelAliases(tokens), nil
+}
+
+// knownToken returns the token of a config secret when it was given directly or has been
+// read already, without reading it
+func knownToken(source TokenSource) string {
+	switch s := source.(type) {
+	case staticToken:
+		return string(s)
+	case *cachedToken:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.err == nil {
+			return s.token
+		}
+	}
+	return ""
+}
+
+// recheckSecrets reads every file, command, and keychain token again after a provider
+// rejected a request, and reports whether any of them was rotated
+func recheckSecrets(ctx context.Context) bool {
+	tokenCacheMu.Lock()
+	cached := make([]*cachedToken, 0, len(tokenCache))
+	for _, token := range tokenCache {
+		cached = append(cached, token)
+	}
+	tokenCacheMu.Unlock()
+
+	rotated := false
+	for _, token := range cached {
+		if token.recheck(ctx) {
+			log.Printf("%s was rotated", token.name)
+			rotated = true
+		}
+	}
+	return rotated
+}
+
+// isUnauthorized reports whether a provider rejected the credentials with a 401
+func isUnauthorized(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusUnauthorized
+	}
+	match := statusCode.FindStringSubmatch(err.Error())
+	return match != nil && match[1] == "401"
+}
+
+// zhipuToken is a token derived from a Zhipu open-platform key and when it expires
+type zhipuToken struct {
+	token   string
+	expires time.Time
+}
+
+// zhipuTokens holds the derived token of each key, so concurrent queries share one until it
+// is about to expire
+var (
+	zhipuTokensMu sync.Mutex
+	zhipuTokens   = make(map[string]zhipuToken)
+)
+
+// zhipuAPIToken returns the short-lived token of a Zhipu open-platform key of the form
+// id.secret, so the raw key can be pasted as is. Keys without exactly one dot, including
+// tokens that are already JWTs, are returned unchanged.
+func zhipuAPIToken(key string) (string, error) {
+	id, secret, ok := strings.Cut(key, ".")
+	if !ok || id == "" || secret == "" || strings.Contains(secret, ".") {
+		return key, nil
+	}
+
+	zhipuTokensMu.Lock()
+	defer zhipuTokensMu.Unlock()
+	now := clockNow()
+	if cached, ok := zhipuTokens[key]; ok && now.Before(cached.expires.Add(-zhipuJWTMargin)) {
+		return cached.token, nil
+	}
+	token, err := zhipuJWT(id, secret, now)
+	if err != nil {
+		return "", fmt.Errorf("failed to derive a token from the Zhipu API key: %w", err)
+	}
+	// Tokens of rotated keys are dropped once they expire
+	for other, cached := range zhipuTokens {
+		if !now.Before(cached.expires) {
+			delete(zhipuTokens, other)
+		}
+	}
+	zhipuTokens[key] = zhipuToken{token: token, expires: now.Add(zhipuJWTTTL)}
+	return token, nil
+}
+
+// zhipuJWT assembles the HS256 token of a Zhipu open-platform key, with the key id and
+// millisecond exp and timestamp claims, signed with the key secret
+func zhipuJWT(id, secret string, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "HS256", "sign_type": "SIGN"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"api_key":   id,
+		"exp":       now.Add(zhipuJWTTTL).UnixMilli(),
+		"timestamp": now.UnixMilli(),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(hmacSHA256([]byte(secret), unsigned)), nil
+}
+
+// shellCommand runs command in the system shell
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
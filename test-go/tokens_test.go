@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readSecret reads a secret variable from its source, or "" when it has none
+func readSecret(name string) string {
+	token, _ := readToken(context.Background(), secretToken(name))
+	return token
+}
+
+func TestSecretSources(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	os.WriteFile(tokenFile, []byte("from-file\n"), 0600)
+
+	t.Setenv("TEST_SECRET", "")
+	t.Setenv("TEST_SECRET_FILE", tokenFile)
+	t.Setenv("TEST_SECRET_CMD", "echo from-command")
+	if token := readSecret("TEST_SECRET"); token != "from-file" {
+		t.Errorf("Expected the file to win over the command, got %q", token)
+	}
+
+	t.Setenv("TEST_SECRET_FILE", "")
+	t.Setenv("TEST_SECRET_CMD", "printf 'from-command\\nlogin: me\\n'")
+	if token := readSecret("TEST_SECRET"); token != "from-command" {
+		t.Errorf("Expected the first line of the command output, got %q", token)
+	}
+
+	t.Setenv("TEST_SECRET", "'from-env'")
+	if token := readSecret("TEST_SECRET"); token != "from-env" {
+		t.Errorf("Expected the variable to win, got %q", token)
+	}
+
+	t.Setenv("TEST_SECRET", "")
+	t.Setenv("TEST_SECRET_CMD", "")
+	if source := secretSource("TEST_SECRET"); source != nil {
+		t.Errorf("Expected no source, got %v", source)
+	}
+}
+
+func TestCachedTokenCommand(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	defer setClock(clock)()
+
+	// The command counts its runs in a file
+	counter := filepath.Join(t.TempDir(), "runs")
+	t.Setenv("TEST_COUNTED_CMD", "echo run >> "+counter+" && echo secret")
+	for i := 0; i < 3; i++ {
+		if token := readSecret("TEST_COUNTED"); token != "secret" {
+			t.Fatalf("Expected the command token, got %q", token)
+		}
+	}
+	if data, _ := os.ReadFile(counter); strings.Count(string(data), "run") != 1 {
+		t.Errorf("Expected the command to run once, ran %d times", strings.Count(string(data), "run"))
+	}
+
+	// Failures are retried after the retry interval
+	failing := &cachedToken{name: "TEST", source: commandToken{command: "exit 3"}}
+	if _, err := failing.Token(context.Background()); err == nil {
+		t.Fatal("Expected a failing command to return an error")
+	}
+	failing.source = commandToken{command: "echo recovered"}
+	if _, err := failing.Token(context.Background()); err == nil {
+		t.Error("Expected the failure to be kept within the retry interval")
+	}
+	clock.t = clock.t.Add(tokenRetryInterval)
+	if token, err := failing.Token(context.Background()); err != nil || token != "recovered" {
+		t.Errorf("Expected a retry after the interval, got %q %v", token, err)
+	}
+}
+
+func TestLoadConfigDefersSecretCommands(t *testing.T) {
+	counter := filepath.Join(t.TempDir(), "runs")
+	t.Setenv("GROQ_API_KEY", "")
+	t.Setenv("GROQ_API_KEY_CMD", "echo groq >> "+counter+" && echo gsk-key")
+	t.Setenv("MISTRAL_API_KEY", "")
+	t.Setenv("MISTRAL_API_KEY_CMD", "echo mistral >> "+counter+" && echo mistral-key")
+	for _, name := range []string{"SMTP_PASSWORD", "SERVER_TOKEN", "HUB_TOKENS", "PAGERDUTY_ROUTING_KEY"} {
+		t.Setenv(name, "")
+		t.Setenv(name+"_CMD", "echo "+name+" >> "+counter+" && echo secret")
+	}
+
+	config := LoadConfig()
+	if _, err := os.Stat(counter); !os.IsNotExist(err) {
+		t.Fatal("Expected loading the config to run no secret command")
+	}
+	if config.GroqAPIKey == nil || config.MistralAPIKey == nil {
+		t.Fatal("Expected the command sources to be configured")
+	}
+
+	if token, err := readToken(context.Background(), config.GroqAPIKey); err != nil || token != "gsk-key" {
+		t.Fatalf("Expected the Groq key from its command, got %q %v", token, err)
+	}
+	if data, _ := os.ReadFile(counter); string(data) != "groq\n" {
+		t.Errorf("Expected only the Groq command to run, got %q", data)
+	}
+}
+
+func TestFetchQuotaRetriesRotatedToken(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	defer setClock(clock)()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "rotated-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"code":200,"data":{"limits":[{"type":"TOKENS_LIMIT","percentage":25}]}}`)
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "zai-token")
+	os.WriteFile(tokenFile, []byte("revoked-token\n"), 0600)
+	t.Setenv("ZAI_AUTH_TOKEN", "")
+	t.Setenv("ZAI_AUTH_TOKEN_FILE", tokenFile)
+	t.Setenv("ZAI_ANTHROPIC_BASE_URL", server.URL+"/api/anthropic")
+	t.Setenv("ZAI_PLATFORM", "ZAI")
+	service := NewQuotaService(NewCloudCodeClient(LoadConfig()))
+
+	if _, err := service.fetchQuota(context.Background(), ProviderGLM); !isUnauthorized(err) {
+		t.Fatalf("Expected a 401 with the revoked token, got %v", err)
+	}
+
+	// The token is rotated in the file; the next 401 reads it again and retries
+	os.WriteFile(tokenFile, []byte("rotated-token\n"), 0600)
+	clock.Advance(tokenRecheckInterval)
+	quota, err := service.fetchQuota(context.Background(), ProviderGLM)
+	if err != nil {
+		t.Fatalf("Expected the retry with the rotated token to succeed, got %v", err)
+	}
+	if len(quota.Models) != 1 || quota.Models[0].Percentage != 75 {
+		t.Errorf("Expected the quota of the rotated token, got %+v", quota.Models)
+	}
+}
+
+func TestCachedTokenRecheck(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	defer setClock(clock)()
+
+	token := &cachedToken{name: "TEST", source: commandToken{command: "echo first"}}
+	token.Token(context.Background())
+	token.source = commandToken{command: "echo second"}
+	if token.recheck(context.Background()) {
+		t.Error("Expected a token read within the recheck interval to be kept")
+	}
+	clock.Advance(tokenRecheckInterval)
+	if !token.recheck(context.Background()) {
+		t.Error("Expected the rotated token to be reported")
+	}
+	if value, _ := token.Token(context.Background()); value != "second" {
+		t.Errorf("Expected the rotated token, got %q", value)
+	}
+	clock.Advance(tokenRecheckInterval)
+	if token.recheck(context.Background()) {
+		t.Error("Expected an unchanged token not to be reported")
+	}
+}
+
+func TestZhipuAPIToken(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 4, 2, 8, 0, 0, 0, time.UTC)}
+	restore := setClock(clock)
+	defer restore()
+
+	first, err := zhipuAPIToken("token-id.token-secret")
+	if err != nil || strings.Count(first, ".") != 2 {
+		t.Fatalf("Expected a JWT for an id.secret key, got %q, %v", first, err)
+	}
+	clock.Advance(2 * time.Minute)
+	if again, _ := zhipuAPIToken("token-id.token-secret"); again != first {
+		t.Error("Expected the token to be reused while it is fresh")
+	}
+	clock.Advance(31 * time.Second)
+	if renewed, _ := zhipuAPIToken("token-id.token-secret"); renewed == first {
+		t.Error("Expected a new token within 30 seconds of expiry")
+	}
+
+	// A second key keeps the first one's token until that expires
+	zhipuAPIToken("other-id.other-secret")
+	if _, ok := zhipuTokens["token-id.token-secret"]; !ok {
+		t.Error("Expected the token of a key in use to be kept")
+	}
+	clock.Advance(zhipuJWTTTL)
+	zhipuAPIToken("other-id.other-secret")
+	if _, ok := zhipuTokens["token-id.token-secret"]; ok {
+		t.Error("Expected the expired token of the first key to be dropped")
+	}
+
+	for _, key := range []string{"plain-key", first} {
+		if got, _ := zhipuAPIToken(key); got != key {
+			t.Errorf("Expected %q to be returned unchanged, got %q", key, got)
+		}
+	}
+}
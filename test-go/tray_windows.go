@@ -0,0 +1,123 @@
+//go:build windows
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"fyne.io/systray"
+)
+
+// runTray shows the worst-model percentage in the system tray until Quit is chosen
+func runTray() error {
+	config := LoadConfig()
+	service := NewQuotaService(NewCloudCodeClient(config))
+	interval := time.Duration(config.QueryDebounce) * time.Minute
+
+	systray.Run(func() { onTrayReady(service, interval) }, func() {})
+	return nil
+}
+
+// onTrayReady builds the tray menu and starts the refresh loop
+func onTrayReady(service *QuotaService, interval time.Duration) {
+	systray.SetIcon(renderTrayIcon(-1))
+	systray.SetTooltip("Coding Plan Quota")
+
+	summary := systray.AddMenuItem("Loading quota...", "")
+	summary.Disable()
+	systray.AddSeparator()
+	refresh := systray.AddMenuItem("Refresh now", "Force a quota refresh")
+	quit := systray.AddMenuItem("Quit", "Exit the tray icon")
+
+	update := func() {
+		var models []FormattedModel
+		for _, provider := range providerNames() {
+			// Providers without credentials are skipped
+			if quota, err := service.fetchQuota(context.Background(), provider); err == nil {
+				models = append(models, quota.Models...)
+			}
+		}
+
+		worst, ok := worstModel(models)
+		if !ok {
+			systray.SetIcon(renderTrayIcon(-1))
+			systray.SetTooltip("Quota unavailable")
+			summary.SetTitle("Quota unavailable")
+			return
+		}
+
+		text := fmt.Sprintf("%s %s%%", worst.Name, formatPercentage(worst.Percentage))
+		systray.SetIcon(renderTrayIcon(worst.Percentage))
+		systray.SetTooltip(text)
+		summary.SetTitle(text)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		update()
+		for {
+			select {
+			case <-ticker.C:
+				update()
+			case <-refresh.ClickedCh:
+				service.invalidateCache()
+				update()
+			case <-quit.ClickedCh:
+				systray.Quit()
+				return
+			}
+		}
+	}()
+}
+
+// renderTrayIcon draws a 16x16 ICO filled circle colored by quota severity; negative means unknown
+func renderTrayIcon(pct float64) []byte {
+	const size = 16
+
+	// BGRA colors matching the terminal status thresholds
+	color := [4]byte{0x80, 0x80, 0x80, 0xff}
+	switch {
+	case pct < 0:
+	case pct >= QuotaGood:
+		color = [4]byte{0x50, 0xaf, 0x4c, 0xff}
+	case pct >= QuotaWarning:
+		color = [4]byte{0x07, 0xc1, 0xff, 0xff}
+	default:
+		color = [4]byte{0x36, 0x43, 0xf4, 0xff}
+	}
+
+	var pixels bytes.Buffer
+	for y := size - 1; y >= 0; y-- {
+		for x := 0; x < size; x++ {
+			dx, dy := float64(x)-7.5, float64(y)-7.5
+			if dx*dx+dy*dy <= 7.5*7.5 {
+				pixels.Write(color[:])
+			} else {
+				pixels.Write([]byte{0, 0, 0, 0})
+			}
+		}
+	}
+	mask := make([]byte, size*4) // 1-bit AND mask, rows padded to 32 bits
+
+	imageSize := 40 + pixels.Len() + len(mask)
+	var ico bytes.Buffer
+	binary.Write(&ico, binary.LittleEndian, []uint16{0, 1, 1})
+	ico.Write([]byte{size, size, 0, 0})
+	binary.Write(&ico, binary.LittleEndian, []uint16{1, 32})
+	binary.Write(&ico, binary.LittleEndian, []uint32{uint32(imageSize), 22})
+
+	// BITMAPINFOHEADER; height covers both the color and mask planes
+	binary.Write(&ico, binary.LittleEndian, []uint32{40, size, size * 2})
+	binary.Write(&ico, binary.LittleEndian, []uint16{1, 32})
+	binary.Write(&ico, binary.LittleEndian, []uint32{0, uint32(pixels.Len() + len(mask)), 0, 0, 0, 0})
+	ico.Write(pixels.Bytes())
+	ico.Write(mask)
+
+	return ico.Bytes()
+}
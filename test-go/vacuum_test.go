@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompactHistory(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.Local)
+	line := func(daysAgo, hour int, model string, pct float64) []byte {
+		at := time.Date(2026, 10, 15-daysAgo, hour, 0, 0, 0, time.Local)
+		data, _ := json.Marshal(HistoryRecord{Time: at.Unix(), Provider: "glm", Model: model, Percentage: pct})
+		return data
+	}
+	mark, _ := json.Marshal(SessionMark{Time: now.AddDate(0, 0, -40).Unix(), Mark: MarkStart, Session: "old"})
+
+	lines := [][]byte{
+		line(40, 9, "glm", 90),
+		line(40, 18, "glm", 70), // last reading of its day
+		line(40, 12, "glm-coding-plan-mcp-monthly", 60),
+		mark,
+		line(10, 9, "glm", 80),
+		line(10, 10, "glm", 75),
+		[]byte("not json"),
+		line(2, 9, "glm", 50), // raw records are all kept
+		line(2, 10, "glm", 40),
+	}
+
+	kept := compactHistory(lines, now, 8*24*time.Hour, 0)
+	want := [][]byte{lines[1], lines[2], lines[3], lines[5], lines[7], lines[8]}
+	if !equalLines(kept, want) {
+		t.Errorf("Expected daily rollups before the retention, got:\n%s", bytes.Join(kept, []byte("\n")))
+	}
+
+	// A rollup retention drops older rollups and marks too
+	kept = compactHistory(lines, now, 8*24*time.Hour, 30*24*time.Hour)
+	want = [][]byte{lines[5], lines[7], lines[8]}
+	if !equalLines(kept, want) {
+		t.Errorf("Expected rollups past 30 days to be dropped, got:\n%s", bytes.Join(kept, []byte("\n")))
+	}
+}
+
+func equalLines(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRunHistoryVacuum(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.Local)
+	defer setClock(&fakeClock{t: now})()
+
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	var content bytes.Buffer
+	for hour := range 24 {
+		old := now.AddDate(0, 0, -20).Add(time.Duration(hour-12) * time.Hour)
+		json.NewEncoder(&content).Encode(HistoryRecord{Time: old.Unix(), Provider: "glm", Model: "glm", Percentage: float64(100 - hour)})
+	}
+	json.NewEncoder(&content).Encode(HistoryRecord{Time: now.Unix(), Provider: "glm", Model: "glm", Percentage: 50})
+	os.WriteFile(path, content.Bytes(), 0600)
+
+	t.Setenv("HISTORY_FILE", path)
+	var buf bytes.Buffer
+	if err := runHistoryCommand([]string{"vacuum"}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "Kept 2 of 25 history lines") {
+		t.Errorf("Unexpected output: %q", buf.String())
+	}
+
+	data, _ := os.ReadFile(path)
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 2 || !strings.Contains(lines[0], `"percentage":77`) {
+		t.Errorf("Expected the old day's last reading and the raw one, got:\n%s", data)
+	}
+
+	if err := runHistoryCommand(nil, &buf); err == nil {
+		t.Error("Expected a usage error without vacuum")
+	}
+}
+
+func TestAppendWaitsForVacuum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	appendHistory(path, HistoryRecord{Time: 1, Provider: "glm", Model: "glm", Percentage: 90})
+
+	// A compaction holds the lock while it replaces the file
+	release, err := acquireFileLock(historyLockPath(path), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appended := make(chan error)
+	go func() { appended <- appendHistory(path, HistoryRecord{Time: 2, Provider: "glm", Model: "glm", Percentage: 80}) }()
+	time.Sleep(50 * time.Millisecond)
+	writeFileAtomic(path, []byte(`{"time":1,"provider":"glm","model":"glm","percentage":90}`+"\n"), 0600)
+	release()
+
+	if err := <-appended; err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); strings.Count(string(data), "\n") != 2 {
+		t.Errorf("Expected the append to land in the compacted file, got:\n%s", data)
+	}
+}
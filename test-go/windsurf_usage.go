@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WindsurfPlanStatus is the credit state of a Windsurf plan
+type WindsurfPlanStatus struct {
+	AvailablePromptCredits float64 `json:"availablePromptCredits"`
+	UsedPromptCredits      float64 `json:"usedPromptCredits"`
+	AvailableFlowCredits   float64 `json:"availableFlowCredits"`
+	UsedFlowCredits        float64 `json:"usedFlowCredits"`
+	PlanEnd                string  `json:"planEnd"`
+}
+
+// WindsurfUserStatus represents the GetUserStatus response
+type WindsurfUserStatus struct {
+	UserStatus struct {
+		PlanStatus WindsurfPlanStatus `json:"planStatus"`
+	} `json:"userStatus"`
+
+	// FetchedAt is when the data was retrieved from the API
+	FetchedAt time.Time `json:"-"`
+}
+
+// windsurfCreditPercentage returns the remaining share of a credit pool, or full when it has no size
+func windsurfCreditPercentage(available, used float64, precision int) float64 {
+	if available <= 0 {
+		return QuotaFull
+	}
+	return roundPercentage(clampPercentage(100-used/available*100), precision)
+}
+
+// FormatWindsurfUsage formats Windsurf plan credits to match antigravity quota format
+func FormatWindsurfUsage(status *WindsurfUserStatus) FormattedQuota {
+	config := LoadConfig()
+	plan := status.UserStatus.PlanStatus
+
+	models := []FormattedModel{
+		{
+			Name:       "windsurf-prompt-credits",
+			Percentage: windsurfCreditPercentage(plan.AvailablePromptCredits, plan.UsedPromptCredits, config.PercentagePrecision),
+			ResetTime:  plan.PlanEnd,
+		},
+		{
+			Name:       "windsurf-flow-credits",
+			Percentage: windsurfCreditPercentage(plan.AvailableFlowCredits, plan.UsedFlowCredits, config.PercentagePrecision),
+			ResetTime:  plan.PlanEnd,
+		},
+	}
+	if plan.PlanEnd != "" {
+		for i := range models {
+			models[i].ResetTimeRelative = formatTimeRemaining(plan.PlanEnd)
+		}
+	}
+
+	models = applyModelAliases(models, config.ModelAliases)
+
+	quota := FormattedQuota{
+		Models:      models,
+		IsForbidden: false,
+	}
+	stampQuotaTime(&quota, status.FetchedAt)
+	return quota
+}
+
+// GetWindsurfUsage gets plan credit usage for the configured Windsurf API key
+func GetWindsurfUsage(ctx context.Context) (FormattedQuota, error) {
+	config := LoadConfig()
+	apiKey, err := readToken(ctx, config.WindsurfAPIKey)
+	if err != nil {
+		return FormattedQuota{}, fmt.Errorf("failed to read WINDSURF_API_KEY: %w", err)
+	}
+	if apiKey == "" {
+		return FormattedQuota{}, fmt.Errorf("WINDSURF_API_KEY environment variable is not set")
+	}
+
+	data, fetchedAt, err := cachedFetch(ctx, providerCache, ProviderWindsurf, responseCacheKey(ProviderWindsurf, "", apiKey, config.WindsurfStatusURL), func() (interface{}, error) {
+		body := map[string]interface{}{
+			"metadata": map[string]string{
+				"apiKey":           apiKey,
+				"ideName":          "windsurf",
+				"ideVersion":       "1.0.0",
+				"extensionName":    "windsurf",
+				"extensionVersion": "1.0.0",
+			},
+		}
+		var status WindsurfUserStatus
+		if err := doJSON(ctx, ProviderWindsurf, "POST", config.WindsurfStatusURL, nil, body, &status); err != nil {
+			return nil, err
+		}
+		return &status, nil
+	})
+	if err != nil {
+		return FormattedQuota{}, err
+	}
+
+	status := *data.(*WindsurfUserStatus)
+	status.FetchedAt = fetchedAt
+	return FormatWindsurfUsage(&status), nil
+}
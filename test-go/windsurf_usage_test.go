@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFormatWindsurfUsage(t *testing.T) {
+	status := &WindsurfUserStatus{}
+	status.UserStatus.PlanStatus = WindsurfPlanStatus{
+		AvailablePromptCredits: 50000,
+		UsedPromptCredits:      12500,
+		AvailableFlowCredits:   0,
+	}
+
+	quota := FormatWindsurfUsage(status)
+	if len(quota.Models) != 2 {
+		t.Fatalf("Expected 2 models, got %d", len(quota.Models))
+	}
+	if quota.Models[0].Name != "windsurf-prompt-credits" || quota.Models[0].Percentage != 75 {
+		t.Errorf("Unexpected prompt credits model: %+v", quota.Models[0])
+	}
+	if quota.Models[1].Percentage != 100 {
+		t.Errorf("Expected flow credits without a pool at 100%%, got %v", quota.Models[1].Percentage)
+	}
+}
+
+func TestGetWindsurfUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Metadata struct {
+				APIKey string `json:"apiKey"`
+			} `json:"metadata"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Metadata.APIKey != "ws-key" {
+			t.Errorf("Unexpected request body (%v): %+v", err, body)
+		}
+		fmt.Fprint(w, `{"userStatus":{"planStatus":{"availablePromptCredits":1000,"usedPromptCredits":1200,"availableFlowCredits":2000,"usedFlowCredits":500}}}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("WINDSURF_API_KEY", "ws-key")
+	t.Setenv("WINDSURF_STATUS_URL", server.URL)
+
+	quota, err := GetWindsurfUsage(context.Background())
+	if err != nil {
+		t.Fatalf("GetWindsurfUsage failed: %v", err)
+	}
+	if quota.Models[0].Percentage != 0 || quota.Models[1].Percentage != 75 {
+		t.Errorf("Expected over-used prompt credits clamped to 0 and flow at 75, got %+v", quota.Models)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetupWizard(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("ACCOUNT_FILE", filepath.Join(home, "missing.json"))
+	for _, name := range []string{
+		"ANTIGRAVITY_TOKEN", "CLAUDE_OAUTH_TOKEN", "CLAUDE_CREDENTIALS_FILE", "ZAI_AUTH_TOKEN", "ZHIPU_AUTH_TOKEN",
+		"ZAI_ANTHROPIC_AUTH_TOKEN", "ANTHROPIC_AUTH_TOKEN", "CURSOR_SESSION_TOKEN", "WINDSURF_API_KEY",
+		"XAI_API_KEY", "GROQ_API_KEY", "MISTRAL_API_KEY",
+	} {
+		t.Setenv(name, "")
+	}
+
+	// Skip antigravity, claude-ai, and cursor; enter a working GLM key and a Groq key that
+	// fails and is dropped; skip the rest; write, install, and pick the zsh segment
+	answers := "n\nn\nn\ny\nzai-key\ny\nbad\nn\nn\nn\nn\nn\n\ny\nzsh\n"
+	envFile := filepath.Join(t.TempDir(), ".env")
+	var out bytes.Buffer
+	wizard := newSetupWizard(strings.NewReader(answers), &out, envFile)
+	var tested []string
+	wizard.fetch = func(_ context.Context, provider string) (*FormattedQuota, error) {
+		tested = append(tested, provider)
+		if provider == ProviderGroq {
+			return nil, errors.New("401 Unauthorized")
+		}
+		return &FormattedQuota{Models: []FormattedModel{{Name: provider, Percentage: 80}}}, nil
+	}
+	installed := false
+	wizard.installService = func() error {
+		installed = true
+		return nil
+	}
+	if err := wizard.run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(tested, ",") != "glm,groq" {
+		t.Errorf("Expected GLM and Groq to be tested, got %v", tested)
+	}
+	data, _ := os.ReadFile(envFile)
+	if !strings.Contains(string(data), `ZAI_AUTH_TOKEN="zai-key"`) || strings.Contains(string(data), "GROQ") {
+		t.Errorf("Unexpected env file %q", data)
+	}
+	if os.Getenv("GROQ_API_KEY") != "" {
+		t.Error("Expected the rejected Groq key to be unset")
+	}
+	if !installed {
+		t.Error("Expected the service to be installed")
+	}
+	if !strings.Contains(out.String(), "glm            not configured") ||
+		!strings.Contains(out.String(), "show --provider glm --format prompt --refresh") {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+}
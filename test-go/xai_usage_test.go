@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetXAIUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/api-key":
+			if r.Header.Get("Authorization") != "Bearer xai-key" {
+				t.Errorf("Unexpected API key header %q", r.Header.Get("Authorization"))
+			}
+			fmt.Fprint(w, `{"team_id":"team-1","api_key_blocked":false,"team_blocked":false}`)
+		case "/v1/models/grok-4":
+			w.Header().Set("x-ratelimit-limit-requests", "100")
+			w.Header().Set("x-ratelimit-remaining-requests", "25")
+			w.Header().Set("x-ratelimit-limit-tokens", "1000")
+			w.Header().Set("x-ratelimit-remaining-tokens", "1000")
+			fmt.Fprint(w, `{"id":"grok-4"}`)
+		case "/v1/billing/teams/team-1/prepaid/balance":
+			if r.Header.Get("Authorization") != "Bearer mgmt-key" {
+				t.Errorf("Unexpected management key header %q", r.Header.Get("Authorization"))
+			}
+			fmt.Fprint(w, `{"total":{"val":"-1250"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("XAI_API_KEY", "xai-key")
+	t.Setenv("XAI_MANAGEMENT_KEY", "mgmt-key")
+	t.Setenv("XAI_BASE_URL", server.URL)
+	t.Setenv("XAI_MANAGEMENT_URL", server.URL)
+
+	quota, err := GetXAIUsage(context.Background())
+	if err != nil {
+		t.Fatalf("GetXAIUsage failed: %v", err)
+	}
+
+	expected := map[string]float64{"xai-credits": 100, "xai-grok-4-rpm": 25, "xai-grok-4-tpm": 100}
+	if len(quota.Models) != len(expected) {
+		t.Fatalf("Expected %d models, got %+v", len(expected), quota.Models)
+	}
+	for _, model := range quota.Models {
+		if pct, ok := expected[model.Name]; !ok || pct != model.Percentage {
+			t.Errorf("Unexpected model %s at %v", model.Name, model.Percentage)
+		}
+	}
+	if quota.Models[0].Balance == nil || quota.Models[0].Balance.Display != "$12.50" {
+		t.Errorf("Expected $12.50 credits, got %+v", quota.Models[0].Balance)
+	}
+}
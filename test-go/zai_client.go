@@ -1,31 +1,23 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"net/url"
-	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 )
 
-// ZAICache holds cached Z.ai API responses
-type ZAICache struct {
-	mu    sync.RWMutex
-	cache map[string]CacheEntry
-}
-
-type CacheEntry struct {
-	Data      interface{}
-	ExpiresAt time.Time
-}
-
-var zaiCache = &ZAICache{
-	cache: make(map[string]CacheEntry),
-}
+// zaiCache holds cached Z.ai API responses
+var zaiCache = NewResponseCache()
 
 // ZAIQuotaLimit represents the quota limit response structure
 type ZAIQuotaLimit struct {
@@ -48,76 +40,248 @@ type ZAIUsageDetail struct {
 // ProcessedZAILimit represents processed quota limit data
 type ProcessedZAILimit struct {
 	Limits []ProcessedLimit `json:"limits"`
+
+	// FetchedAt is when the underlying data was retrieved from Z.ai
+	FetchedAt time.Time `json:"-"`
+}
+
+// LimitType identifies what a Z.ai limit measures, independent of its display label
+type LimitType string
+
+const (
+	LimitTokens5h   LimitType = "tokens_5h"
+	LimitMCPMonthly LimitType = "mcp_monthly"
+	LimitVision     LimitType = "vision"
+	LimitVideo      LimitType = "video"
+	LimitUnknown    LimitType = "unknown"
+)
+
+// zaiLimitTypes maps Z.ai API limit identifiers to limit types
+var zaiLimitTypes = map[string]LimitType{
+	"TOKENS_LIMIT": LimitTokens5h,
+	"TIME_LIMIT":   LimitMCPMonthly,
+	"VISION_LIMIT": LimitVision,
+	"IMAGE_LIMIT":  LimitVision,
+	"VIDEO_LIMIT":  LimitVideo,
+}
+
+// limitLabels maps limit types to their message catalog keys
+var limitLabels = map[LimitType]string{
+	LimitTokens5h:   msgTokenUsage5h,
+	LimitMCPMonthly: msgMCPUsageMonthly,
+	LimitVision:     msgVisionUsage,
+	LimitVideo:      msgVideoUsage,
+}
+
+// zaiPoolModels names the models of the separate generation allowances, which are reported
+// by their remaining share like the token quota
+var zaiPoolModels = map[LimitType]string{
+	LimitVision: "glm-coding-plan-vision",
+	LimitVideo:  "glm-coding-plan-video",
 }
 
+// ProcessedLimit is one Z.ai limit. Type is what it measures and Label its display text in
+// the configured output language; unknown API types keep their identifier as the label.
 type ProcessedLimit struct {
-	Type         string           `json:"type"`
-	Percentage   int              `json:"percentage"`
+	Type         LimitType        `json:"type"`
+	Label        string           `json:"label"`
+	Percentage   float64          `json:"percentage"`
 	CurrentUsage int              `json:"currentUsage,omitempty"`
 	Total        int              `json:"usage,omitempty"`
 	UsageDetails []ZAIUsageDetail `json:"usageDetails,omitempty"`
 }
 
-// QueryZAIEndpoint queries a Z.ai API endpoint with caching
-func QueryZAIEndpoint(ctx context.Context, endpoint, authToken, queryParams string) (interface{}, error) {
-	cacheKey := endpoint + queryParams
+// zaiBodyBuffers are reused to read Z.ai response bodies
+var zaiBodyBuffers = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// Bodies larger than this are not kept in zaiBodyBuffers
+const maxPooledBodySize = 1 << 20
+
+// zaiDecoder turns a Z.ai response body into the value cached for it
+type zaiDecoder func(body []byte) (interface{}, error)
 
-	// Check cache first
-	zaiCache.mu.RLock()
-	if entry, exists := zaiCache.cache[cacheKey]; exists && time.Now().Before(entry.ExpiresAt) {
-		zaiCache.mu.RUnlock()
-		fmt.Println("Returning cached z.ai data")
-		return entry.Data, nil
+// decodeZAIBody decodes a response body into its generic data map
+func decodeZAIBody(body []byte) (interface{}, error) {
+	data, err := decodeZAIResponse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
 	}
-	zaiCache.mu.RUnlock()
+	return data, nil
+}
+
+// QueryZAIEndpoint queries a Z.ai API endpoint with caching, recording health under provider.
+// It also returns when the data was fetched, which is earlier than now for cached entries.
+func QueryZAIEndpoint(ctx context.Context, provider, endpoint, authToken, queryParams string) (interface{}, time.Time, error) {
+	return queryZAIEndpoint(ctx, provider, "", endpoint, authToken, queryParams, "data", decodeZAIBody)
+}
 
-	// Make HTTP request
-	fullURL := endpoint + queryParams
+// queryZAIEndpoint is QueryZAIEndpoint for an account label, "" for the default account, with
+// the decoder that produces the cached value and the kind of value it produces, which are
+// part of the cache key
+func queryZAIEndpoint(ctx context.Context, provider, account, endpoint, authToken, queryParams, kind string, decode zaiDecoder) (interface{}, time.Time, error) {
+	cacheKey := responseCacheKey(provider, account, authToken, kind, endpoint+queryParams)
+
+	// Check cache first; a dry run only records the lookup, and its request is never answered
+	if plan := dryRunOf(ctx); plan != nil {
+		plan.observeCacheKey(cacheKey)
+	} else {
+		if entry, exists := zaiCache.Get(cacheKey); exists {
+			log.Println("Returning cached z.ai data")
+			providerHealth.recordCacheHit(provider)
+			return entry.Data, entry.FetchedAt, nil
+		}
+		if err := authFailures.get(cacheKey); err != nil {
+			return nil, time.Time{}, err
+		}
+	}
+
+	// Make HTTP request, on the next domain of the platform while one answers 404 or 5xx
+	start := time.Now()
+	var resp *http.Response
+	candidates := zaiDomains.candidates(LoadConfig(), endpoint)
+	for i, candidate := range candidates {
+		var err error
+		if resp, err = sendZAIRequest(ctx, provider, candidate+queryParams, authToken); err != nil {
+			return nil, time.Time{}, err
+		}
+		if !shouldFallBack(resp.StatusCode) || i == len(candidates)-1 {
+			if resp.StatusCode == http.StatusOK {
+				zaiDomains.remember(endpoint, candidate)
+			}
+			break
+		}
+		log.Printf("%s answered status %d, trying %s", candidate, resp.StatusCode, candidates[i+1])
+		resp.Body.Close()
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := &APIError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("Z.ai API error: status %d", resp.StatusCode)}
+		authFailures.observe(cacheKey, err)
+		return nil, time.Time{}, err
+	}
+
+	buf := zaiBodyBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxPooledBodySize {
+			zaiBodyBuffers.Put(buf)
+		}
+	}()
+	if err := readResponse(buf, resp); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	result, err := decode(buf.Bytes())
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	providerHealth.recordFetch(provider, time.Since(start))
+	fetchedAt := clockNow()
+
+	// Cache the result
+	config := LoadConfig()
+	expiry := fetchedAt.Add(time.Duration(config.QueryDebounce) * time.Minute)
+	zaiCache.Set(cacheKey, CacheEntry{
+		Data:      result,
+		FetchedAt: fetchedAt,
+		ExpiresAt: expiry,
+	}, config.CacheMaxEntries)
+
+	log.Printf("Cached z.ai data for %d minute(s)", config.QueryDebounce)
+	return result, fetchedAt, nil
+}
+
+// sendZAIRequest sends an authenticated GET request to a Z.ai endpoint URL
+func sendZAIRequest(ctx context.Context, provider, fullURL, authToken string) (*http.Response, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", authToken)
+	config := LoadConfig()
+	req.Header.Set(authHeader(config, provider, authToken))
 	req.Header.Set("Accept-Language", "en-US,en")
 	req.Header.Set("Content-Type", "application/json")
+	if err := setRequestHeaders(req, config, provider); err != nil {
+		return nil, err
+	}
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	client, err := signedClient(&http.Client{Timeout: 10 * time.Second, Transport: transportFor(ctx)}, config, provider)
+	if err != nil {
+		return nil, err
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query Z.ai API: %w", err)
 	}
-	defer resp.Body.Close()
+	return resp, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Z.ai API error: status %d", resp.StatusCode)
+// ZAISchemaError reports a Z.ai response whose shape does not match the expected envelope
+type ZAISchemaError struct {
+	Field    string
+	Expected string
+	Got      string
+	Msg      string
+}
+
+func (e *ZAISchemaError) Error() string {
+	if e.Msg != "" {
+		return fmt.Sprintf("Z.ai response schema error: %q is %s, expected %s (msg: %s)", e.Field, e.Got, e.Expected, e.Msg)
+	}
+	return fmt.Sprintf("Z.ai response schema error: %q is %s, expected %s", e.Field, e.Got, e.Expected)
+}
+
+// jsonTypeName returns the JSON type name of a decoded value
+func jsonTypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return fmt.Sprintf("%T", v)
 	}
+}
 
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+// decodeZAIResponse decodes a Z.ai response body and unwraps the "data" envelope.
+// Responses without an envelope are returned as is.
+func decodeZAIResponse(body io.Reader) (map[string]interface{}, error) {
+	var raw interface{}
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	// Extract data field if present
-	if data, exists := result["data"]; exists {
-		result = data.(map[string]interface{})
+	result, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, &ZAISchemaError{Field: "response", Expected: "object", Got: jsonTypeName(raw)}
 	}
 
-	// Cache the result
-	config := LoadConfig()
-	expiry := time.Now().Add(time.Duration(config.QueryDebounce) * time.Minute)
-	zaiCache.mu.Lock()
-	zaiCache.cache[cacheKey] = CacheEntry{
-		Data:      result,
-		ExpiresAt: expiry,
+	data, exists := result["data"]
+	if !exists {
+		return result, nil
+	}
+
+	dataMap, ok := data.(map[string]interface{})
+	if !ok {
+		// Error payloads carry the reason in "msg" alongside a null or scalar data field
+		msg, _ := result["msg"].(string)
+		return nil, &ZAISchemaError{Field: "data", Expected: "object", Got: jsonTypeName(data), Msg: msg}
 	}
-	zaiCache.mu.Unlock()
 
-	fmt.Printf("Cached z.ai data for %d minute(s)\n", config.QueryDebounce)
-	return result, nil
+	return dataMap, nil
 }
 
-// GetBaseDomain extracts platform and base domain from ANTHROPIC_BASE_URL
+// GetBaseDomain extracts platform and base domain from a Z.ai/ZHIPU base URL
 func GetBaseDomain(baseURL string) (string, string, error) {
 	if strings.Contains(baseURL, "api.z.ai") {
 		return "ZAI", "https://api.z.ai", nil
@@ -129,14 +293,40 @@ func GetBaseDomain(baseURL string) (string, string, error) {
 		}
 		return "ZHIPU", fmt.Sprintf("%s://%s", parsedURL.Scheme, parsedURL.Host), nil
 	}
-	return "", "", fmt.Errorf("unrecognized ANTHROPIC_BASE_URL: %s. Supported: https://api.z.ai/api/anthropic or https://open.bigmodel.cn/api/anthropic", baseURL)
+	return "", "", withFix(fmt.Errorf("unrecognized Z.ai base URL: %s", baseURL),
+		"Set ZAI_ANTHROPIC_BASE_URL to https://api.z.ai/api/anthropic or https://open.bigmodel.cn/api/anthropic, or set ZAI_PLATFORM to ZAI or ZHIPU for a gateway")
+}
+
+// ResolveBaseDomain returns the platform and base domain of baseURL. With an explicit
+// platform (ZAI or ZHIPU) any host is accepted, such as a gateway that forwards to GLM.
+func ResolveBaseDomain(baseURL, platform string) (string, string, error) {
+	if platform == "" {
+		return GetBaseDomain(baseURL)
+	}
+	if platform != "ZAI" && platform != "ZHIPU" {
+		return "", "", withFix(fmt.Errorf("unsupported ZAI_PLATFORM: %s", platform), "Set ZAI_PLATFORM to ZAI or ZHIPU")
+	}
+
+	parsedURL, err := url.Parse(baseURL)
+	if err != nil || parsedURL.Scheme == "" || parsedURL.Host == "" {
+		return "", "", withFix(fmt.Errorf("invalid Z.ai base URL: %s", baseURL),
+			"Set ZAI_ANTHROPIC_BASE_URL to a full URL such as https://gateway.example.com/api/anthropic")
+	}
+	return platform, fmt.Sprintf("%s://%s", parsedURL.Scheme, parsedURL.Host), nil
 }
 
 // BuildTimeQueryParams builds query parameters for time-based endpoints
 func BuildTimeQueryParams() string {
-	now := time.Now().UTC()
-	startDate := time.Date(now.Year(), now.Month(), now.Day()-1, now.Hour(), 0, 0, 0, time.UTC)
-	endDate := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 59, 59, 999999999, time.UTC)
+	now := clockNow()
+	return buildTimeRangeParams(now.Add(-24*time.Hour), now)
+}
+
+// buildTimeRangeParams builds query parameters covering the whole hours from since to until,
+// the granularity of Z.ai usage reports
+func buildTimeRangeParams(since, until time.Time) string {
+	since, until = since.UTC(), until.UTC()
+	startDate := time.Date(since.Year(), since.Month(), since.Day(), since.Hour(), 0, 0, 0, time.UTC)
+	endDate := time.Date(until.Year(), until.Month(), until.Day(), until.Hour(), 59, 59, 999999999, time.UTC)
 
 	startTime := startDate.Format("2006-01-02 15:04:05")
 	endTime := endDate.Format("2006-01-02 15:04:05")
@@ -144,9 +334,10 @@ func BuildTimeQueryParams() string {
 	return fmt.Sprintf("?startTime=%s&endTime=%s", url.QueryEscape(startTime), url.QueryEscape(endTime))
 }
 
-// ProcessQuotaLimit processes quota limit data to transform type names
+// ProcessQuotaLimit processes quota limit data and labels each limit for display
 func ProcessQuotaLimit(data map[string]interface{}) ProcessedZAILimit {
 	result := ProcessedZAILimit{}
+	printer := localizer(LoadConfig().Language)
 
 	limits, exists := data["limits"]
 	if !exists {
@@ -163,39 +354,40 @@ func ProcessQuotaLimit(data map[string]interface{}) ProcessedZAILimit {
 		if !ok {
 			continue
 		}
+		limitMap, _, _ = zaiLimitSchema.normalize(limitMap)
 
 		limitType, _ := limitMap["type"].(string)
 		percentage, _ := limitMap["percentage"].(float64)
 
 		processedLimit := ProcessedLimit{
-			Percentage: int(percentage),
+			Type:       LimitUnknown,
+			Label:      limitType,
+			Percentage: percentage,
+		}
+		if known, ok := zaiLimitTypes[limitType]; ok {
+			processedLimit.Type = known
+			processedLimit.Label = printer.Sprintf(limitLabels[known])
 		}
 
-		switch limitType {
-		case "TOKENS_LIMIT":
-			processedLimit.Type = "Token usage(5 Hour)"
-		case "TIME_LIMIT":
-			processedLimit.Type = "MCP usage(1 Month)"
-			if currentUsage, ok := limitMap["currentValue"].(float64); ok {
-				processedLimit.CurrentUsage = int(currentUsage)
-			}
-			if total, ok := limitMap["usage"].(float64); ok {
-				processedLimit.Total = int(total)
-			}
-			if usageDetails, ok := limitMap["usageDetails"].([]interface{}); ok {
-				for _, detail := range usageDetails {
-					if detailMap, ok := detail.(map[string]interface{}); ok {
-						modelCode, _ := detailMap["modelCode"].(string)
-						usage, _ := detailMap["usage"].(float64)
-						processedLimit.UsageDetails = append(processedLimit.UsageDetails, ZAIUsageDetail{
-							ModelCode: modelCode,
-							Usage:     int(usage),
-						})
-					}
+		// Unknown types keep their counts too, so new limit categories can be rendered
+		if currentUsage, ok := limitMap["currentValue"].(float64); ok {
+			processedLimit.CurrentUsage = int(currentUsage)
+		}
+		if total, ok := limitMap["usage"].(float64); ok {
+			processedLimit.Total = int(total)
+		}
+		if usageDetails, ok := limitMap["usageDetails"].([]interface{}); ok {
+			for _, detail := range usageDetails {
+				if detailMap, ok := detail.(map[string]interface{}); ok {
+					detailMap, _, _ = zaiUsageDetailSchema.normalize(detailMap)
+					modelCode, _ := detailMap["modelCode"].(string)
+					usage, _ := detailMap["usage"].(float64)
+					processedLimit.UsageDetails = append(processedLimit.UsageDetails, ZAIUsageDetail{
+						ModelCode: modelCode,
+						Usage:     int(usage),
+					})
 				}
 			}
-		default:
-			processedLimit.Type = limitType
 		}
 
 		result.Limits = append(result.Limits, processedLimit)
@@ -206,82 +398,156 @@ func ProcessQuotaLimit(data map[string]interface{}) ProcessedZAILimit {
 
 // FormatGLMQuota formats GLM quota limit data to match antigravity quota format
 func FormatGLMQuota(quotaLimitData ProcessedZAILimit) FormattedQuota {
+	config := LoadConfig()
 	models := []FormattedModel{}
 
 	for _, limit := range quotaLimitData.Limits {
 		switch limit.Type {
-		case "Token usage(5 Hour)":
+		case LimitTokens5h:
 			// Token limit: show remaining percentage (100 - used)
 			models = append(models, FormattedModel{
 				Name:       "glm",
-				Percentage: 100 - limit.Percentage,
+				Percentage: roundPercentage(100-limit.Percentage, config.PercentagePrecision),
 			})
-		case "MCP usage(1 Month)":
+		case LimitVision, LimitVideo:
+			models = append(models, FormattedModel{
+				Name:       zaiPoolModels[limit.Type],
+				Percentage: roundPercentage(100-limit.Percentage, config.PercentagePrecision),
+			})
+		case LimitUnknown:
+			// Limit categories added after this release are shown by their API type
+			if name := unknownLimitModel(limit.Label); name != "" {
+				models = append(models, FormattedModel{
+					Name:       name,
+					Percentage: roundPercentage(100-limitUsedPercentage(limit), config.PercentagePrecision),
+				})
+			}
+		case LimitMCPMonthly:
 			// MCP limit: show remaining percentage
 			models = append(models, FormattedModel{
 				Name:       "glm-coding-plan-mcp-monthly",
-				Percentage: 100 - limit.Percentage,
+				Percentage: roundPercentage(100-limit.Percentage, config.PercentagePrecision),
 			})
 
-			// Add individual tool usage details (excluding zread)
+			// Add individual tool usage details (excluding configured tools)
 			for _, detail := range limit.UsageDetails {
-				if detail.ModelCode == "zread" {
+				if containsString(config.ExcludedMCPTools, detail.ModelCode) {
 					continue
 				}
 
-				toolPercentage := 0
+				toolPercentage := 0.0
 				if limit.Total > 0 {
-					toolPercentage = int(float64(detail.Usage) / float64(limit.Total) * 100)
+					toolPercentage = float64(detail.Usage) / float64(limit.Total) * 100
 				}
 
 				models = append(models, FormattedModel{
 					Name:       fmt.Sprintf("glm-coding-plan-%s", detail.ModelCode),
-					Percentage: 100 - toolPercentage,
+					Percentage: roundPercentage(100-toolPercentage, config.PercentagePrecision),
 				})
 			}
 		}
 	}
 
-	return FormattedQuota{
+	models = applyModelAliases(models, config.ModelAliases)
+
+	quota := FormattedQuota{
 		Models:      models,
-		LastUpdated: time.Now().Unix(),
 		IsForbidden: false,
 	}
+	stampQuotaTime(&quota, quotaLimitData.FetchedAt)
+	return quota
 }
 
-// GetGLMQuota gets GLM quota data from Z.ai/ZHIPU API
-func GetGLMQuota(ctx context.Context) (FormattedQuota, error) {
-	baseURL := os.Getenv("ANTHROPIC_BASE_URL")
-	authToken := os.Getenv("ANTHROPIC_AUTH_TOKEN")
-
-	if authToken == "" {
-		return FormattedQuota{}, fmt.Errorf("ANTHROPIC_AUTH_TOKEN environment variable is not set")
+// containsString reports whether items contains value
+func containsString(items []string, value string) bool {
+	for _, item := range items {
+		if item == value {
+			return true
+		}
 	}
+	return false
+}
 
-	if baseURL == "" {
-		return FormattedQuota{}, fmt.Errorf("ANTHROPIC_BASE_URL environment variable is not set. Set it to https://api.z.ai/api/anthropic or https://open.bigmodel.cn/api/anthropic")
+// zaiCredentials returns the platform, base domain, and auth token for Z.ai/ZHIPU queries,
+// reading the token from its source
+func zaiCredentials(ctx context.Context) (string, string, string, error) {
+	config := LoadConfig()
+	if config.ZAIAuthToken == nil {
+		return "", "", "", withFix(errors.New("Z.ai auth token is not set"),
+			"Set ZAI_AUTH_TOKEN for Z.ai or ZHIPU_AUTH_TOKEN for ZHIPU, or ZAI_ANTHROPIC_AUTH_TOKEN")
 	}
 
 	// Get platform and base domain
-	_, baseDomain, err := GetBaseDomain(baseURL)
+	platform, baseDomain, err := ResolveBaseDomain(config.ZAIBaseURL, config.ZAIPlatform)
 	if err != nil {
-		return FormattedQuota{}, err
+		return "", "", "", err
+	}
+	token, err := config.ZAIAuthToken.Token(ctx)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to read %s: %w", config.ZAITokenSource, err)
 	}
+	return platform, baseDomain, token, nil
+}
 
-	// Query quota limit endpoint
-	quotaLimitURL := baseDomain + "/api/monitor/usage/quota/limit"
-	quotaLimitRaw, err := QueryZAIEndpoint(ctx, quotaLimitURL, authToken, "")
+// GetGLMQuota gets GLM quota data from Z.ai/ZHIPU API
+func GetGLMQuota(ctx context.Context) (FormattedQuota, error) {
+	_, baseDomain, authToken, err := zaiCredentials(ctx)
 	if err != nil {
 		return FormattedQuota{}, err
 	}
 
-	quotaLimitMap, ok := quotaLimitRaw.(map[string]interface{})
-	if !ok {
-		return FormattedQuota{}, fmt.Errorf("invalid quota limit response format")
+	return fetchGLMEndpoints(ctx, LoadConfig(), baseDomain, authToken)
+}
+
+// unknownLimitModel names the model of an unknown limit type, e.g. glm-requests for
+// REQUESTS_LIMIT, or returns "" for an entry without a type
+func unknownLimitModel(apiType string) string {
+	name := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(apiType)), "_limit")
+	if name == "" {
+		return ""
+	}
+	return "glm-" + strings.ReplaceAll(name, "_", "-")
+}
+
+// limitUsedPercentage returns a limit's used percentage, derived from its counts when Z.ai
+// reports none
+func limitUsedPercentage(limit ProcessedLimit) float64 {
+	if limit.Percentage == 0 && limit.Total > 0 {
+		return clampPercentage(float64(limit.CurrentUsage) / float64(limit.Total) * 100)
+	}
+	return limit.Percentage
+}
+
+// validateGLMLimits reports a schema error when a non-empty payload parses to no limits, so a
+// changed payload shows as an error instead of a plan with no models. Limits of unknown
+// types are usable, as they are rendered by name.
+func validateGLMLimits(data map[string]interface{}, processed ProcessedZAILimit) error {
+	if len(data) == 0 {
+		return nil
 	}
 
-	quotaLimitProcessed := ProcessQuotaLimit(quotaLimitMap)
+	if len(processed.Limits) == 0 {
+		keys := make([]string, 0, len(data))
+		for key := range data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return &ZAISchemaError{
+			Field:    "limits",
+			Expected: "a non-empty array of limits",
+			Got:      fmt.Sprintf("%s in a payload with keys [%s]", describeLimits(data["limits"]), strings.Join(keys, ", ")),
+		}
+	}
+	return nil
+}
 
-	// Format to match antigravity quota format
-	return FormatGLMQuota(quotaLimitProcessed), nil
+// describeLimits names what a payload has in place of the limits array
+func describeLimits(limits interface{}) string {
+	if limits == nil {
+		return "missing"
+	}
+	if array, ok := limits.([]interface{}); ok && len(array) == 0 {
+		return "an empty array"
+	}
+	return jsonTypeName(limits) + " without limit objects"
 }
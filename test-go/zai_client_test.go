@@ -1,7 +1,16 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
+
+	"golang.org/x/text/language"
 )
 
 func TestProcessQuotaLimit(t *testing.T) {
@@ -37,14 +46,78 @@ func TestProcessQuotaLimit(t *testing.T) {
 		t.Errorf("Expected 2 limits, got %d", len(result.Limits))
 	}
 
-	// Check token limit transformation
-	if result.Limits[0].Type != "Token usage(5 Hour)" {
-		t.Errorf("Expected 'Token usage(5 Hour)', got '%s'", result.Limits[0].Type)
+	// Check token limit type and display label
+	if result.Limits[0].Type != LimitTokens5h || result.Limits[0].Label != "Token usage(5 Hour)" {
+		t.Errorf("Expected tokens_5h labelled 'Token usage(5 Hour)', got %q %q", result.Limits[0].Type, result.Limits[0].Label)
+	}
+
+	// Check MCP limit
+	if result.Limits[1].Type != LimitMCPMonthly || result.Limits[1].Label != "MCP usage(1 Month)" {
+		t.Errorf("Expected mcp_monthly labelled 'MCP usage(1 Month)', got %q %q", result.Limits[1].Type, result.Limits[1].Label)
+	}
+}
+
+func TestProcessQuotaLimitUnknownType(t *testing.T) {
+	result := ProcessQuotaLimit(map[string]interface{}{
+		"limits": []interface{}{
+			map[string]interface{}{"type": "REQUESTS_LIMIT", "percentage": float64(5), "currentValue": float64(50), "usage": float64(1000),
+				"usageDetails": []interface{}{map[string]interface{}{"modelCode": "glm-4.6", "usage": float64(50)}}},
+			map[string]interface{}{"type": "AUDIO_MINUTES_LIMIT", "currentValue": float64(30), "usage": float64(120)},
+			map[string]interface{}{"percentage": float64(1)},
+		},
+	})
+
+	if len(result.Limits) != 3 || result.Limits[0].Type != LimitUnknown || result.Limits[0].Label != "REQUESTS_LIMIT" {
+		t.Fatalf("Expected unknown limits labelled with their API type, got %+v", result.Limits)
+	}
+	if limit := result.Limits[0]; limit.CurrentUsage != 50 || limit.Total != 1000 || len(limit.UsageDetails) != 1 {
+		t.Errorf("Expected the counts of an unknown limit to be kept, got %+v", limit)
+	}
+
+	// Unknown types are rendered by name, with the percentage derived from counts when missing
+	want := map[string]float64{"glm-requests": 95, "glm-audio-minutes": 75}
+	models := FormatGLMQuota(result).Models
+	if len(models) != len(want) {
+		t.Fatalf("Expected %d models, got %+v", len(want), models)
+	}
+	for _, model := range models {
+		if percentage, ok := want[model.Name]; !ok || model.Percentage != percentage {
+			t.Errorf("Unexpected model %s at %v", model.Name, model.Percentage)
+		}
+	}
+}
+
+func TestValidateGLMLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]interface{}
+		wantErr string
+	}{
+		{"empty payload", map[string]interface{}{}, ""},
+		{"renamed array", map[string]interface{}{"quotaLimits": []interface{}{}}, `"limits" is missing in a payload with keys [quotaLimits]`},
+		{"empty array", map[string]interface{}{"limits": []interface{}{}}, `"limits" is an empty array`},
+		{"unknown types only", map[string]interface{}{"limits": []interface{}{
+			map[string]interface{}{"type": "REQUESTS_LIMIT"},
+			map[string]interface{}{"type": "AUDIO_MINUTES_LIMIT"},
+		}}, ""},
+		{"known type", map[string]interface{}{"limits": []interface{}{
+			map[string]interface{}{"type": "REQUESTS_LIMIT"},
+			map[string]interface{}{"type": "TOKENS_LIMIT"},
+		}}, ""},
 	}
 
-	// Check MCP limit transformation
-	if result.Limits[1].Type != "MCP usage(1 Month)" {
-		t.Errorf("Expected 'MCP usage(1 Month)', got '%s'", result.Limits[1].Type)
+	for _, tt := range tests {
+		err := validateGLMLimits(tt.data, ProcessQuotaLimit(tt.data))
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tt.name, err)
+			}
+			continue
+		}
+		var schemaErr *ZAISchemaError
+		if !errors.As(err, &schemaErr) || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: expected schema error containing %q, got %v", tt.name, tt.wantErr, err)
+		}
 	}
 }
 
@@ -52,11 +125,11 @@ func TestFormatGLMQuota(t *testing.T) {
 	processedData := ProcessedZAILimit{
 		Limits: []ProcessedLimit{
 			{
-				Type:       "Token usage(5 Hour)",
+				Type:       LimitTokens5h,
 				Percentage: 25,
 			},
 			{
-				Type:         "MCP usage(1 Month)",
+				Type:         LimitMCPMonthly,
 				Percentage:   10,
 				CurrentUsage: 5,
 				Total:        100,
@@ -78,7 +151,7 @@ func TestFormatGLMQuota(t *testing.T) {
 	// Check GLM token quota (100 - 25 = 75)
 	glmModel := result.Models[0]
 	if glmModel.Name != "glm" || glmModel.Percentage != 75 {
-		t.Errorf("Expected glm model with 75%%, got %s with %d%%", glmModel.Name, glmModel.Percentage)
+		t.Errorf("Expected glm model with 75%%, got %s with %v%%", glmModel.Name, glmModel.Percentage)
 	}
 
 	// Check that zread is excluded
@@ -89,6 +162,104 @@ func TestFormatGLMQuota(t *testing.T) {
 	}
 }
 
+func TestFormatGLMQuotaPools(t *testing.T) {
+	data := map[string]interface{}{
+		"limits": []interface{}{
+			map[string]interface{}{"type": "TOKENS_LIMIT", "percentage": float64(25)},
+			map[string]interface{}{"type": "IMAGE_LIMIT", "percentage": float64(40), "currentValue": float64(20), "usage": float64(50)},
+			map[string]interface{}{"type": "VIDEO_LIMIT", "percentage": float64(90)},
+		},
+	}
+
+	processed := ProcessQuotaLimit(data)
+	if processed.Limits[1].Type != LimitVision || processed.Limits[1].Label != "Vision usage" || processed.Limits[1].Total != 50 {
+		t.Errorf("Expected IMAGE_LIMIT to be the vision pool, got %+v", processed.Limits[1])
+	}
+
+	want := map[string]float64{"glm": 75, "glm-coding-plan-vision": 60, "glm-coding-plan-video": 10}
+	models := FormatGLMQuota(processed).Models
+	if len(models) != len(want) {
+		t.Fatalf("Expected %d models, got %+v", len(want), models)
+	}
+	for _, model := range models {
+		if percentage, ok := want[model.Name]; !ok || model.Percentage != percentage {
+			t.Errorf("Unexpected model %s at %v", model.Name, model.Percentage)
+		}
+	}
+}
+
+func TestFormatGLMQuotaExcludedTools(t *testing.T) {
+	processedData := ProcessedZAILimit{
+		Limits: []ProcessedLimit{
+			{
+				Type:       LimitMCPMonthly,
+				Percentage: 10,
+				Total:      100,
+				UsageDetails: []ZAIUsageDetail{
+					{ModelCode: "search-prime", Usage: 3},
+					{ModelCode: "zread", Usage: 5},
+				},
+			},
+		},
+	}
+
+	hasModel := func(quota FormattedQuota, name string) bool {
+		for _, model := range quota.Models {
+			if model.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Empty list shows every tool
+	t.Setenv("ZAI_EXCLUDED_TOOLS", "")
+	result := FormatGLMQuota(processedData)
+	if !hasModel(result, "glm-coding-plan-zread") || !hasModel(result, "glm-coding-plan-search-prime") {
+		t.Errorf("Expected all tools with empty exclusion list, got %v", result.Models)
+	}
+
+	// Custom list replaces the zread default
+	t.Setenv("ZAI_EXCLUDED_TOOLS", "search-prime")
+	result = FormatGLMQuota(processedData)
+	if !hasModel(result, "glm-coding-plan-zread") || hasModel(result, "glm-coding-plan-search-prime") {
+		t.Errorf("Expected only search-prime excluded, got %v", result.Models)
+	}
+}
+
+func TestFormatGLMQuotaPrecision(t *testing.T) {
+	processedData := ProcessedZAILimit{
+		Limits: []ProcessedLimit{
+			{
+				Type:       LimitMCPMonthly,
+				Percentage: 0.3,
+				Total:      1000,
+				UsageDetails: []ZAIUsageDetail{
+					{ModelCode: "search-prime", Usage: 3},
+				},
+			},
+		},
+	}
+
+	t.Setenv("PERCENTAGE_PRECISION", "1")
+	result := FormatGLMQuota(processedData)
+
+	// 3 of 1000 calls used leaves 99.7% instead of truncating the usage to 0%
+	for _, model := range result.Models {
+		if model.Percentage != 99.7 {
+			t.Errorf("Expected %s at 99.7%%, got %v%%", model.Name, model.Percentage)
+		}
+	}
+
+	t.Setenv("PERCENTAGE_PRECISION", "0")
+	result = FormatGLMQuota(processedData)
+	for _, model := range result.Models {
+		if model.Percentage != 100 {
+			t.Errorf("Expected %s rounded to 100%%, got %v%%", model.Name, model.Percentage)
+		}
+	}
+}
+
 func TestGetBaseDomain(t *testing.T) {
 	tests := []struct {
 		baseURL  string
@@ -136,3 +307,271 @@ func TestGetBaseDomain(t *testing.T) {
 		}
 	}
 }
+
+func TestResolveBaseDomain(t *testing.T) {
+	platform, domain, err := ResolveBaseDomain("https://llm.corp.example:8443/glm/api/anthropic", "ZHIPU")
+	if err != nil || platform != "ZHIPU" || domain != "https://llm.corp.example:8443" {
+		t.Errorf("Expected gateway domain, got %s %s %v", platform, domain, err)
+	}
+
+	platform, domain, err = ResolveBaseDomain("https://api.z.ai/api/anthropic", "")
+	if err != nil || platform != "ZAI" || domain != "https://api.z.ai" {
+		t.Errorf("Expected detected Z.ai domain, got %s %s %v", platform, domain, err)
+	}
+
+	if _, _, err := ResolveBaseDomain("https://llm.corp.example/api", "OPENAI"); err == nil {
+		t.Error("Expected error for unsupported platform")
+	}
+	if _, _, err := ResolveBaseDomain("llm.corp.example", "ZAI"); err == nil {
+		t.Error("Expected error for URL without scheme")
+	}
+}
+
+func TestResolveZAICredentials(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		wantToken  string
+		wantURL    string
+		wantSource string
+	}{
+		{
+			name:       "provider token wins over the generic pair",
+			env:        map[string]string{"ZAI_AUTH_TOKEN": "zai", "ANTHROPIC_AUTH_TOKEN": "sk-ant", "ANTHROPIC_BASE_URL": "https://api.anthropic.com"},
+			wantToken:  "zai",
+			wantURL:    DefaultZAIBaseURL,
+			wantSource: "ZAI_AUTH_TOKEN",
+		},
+		{
+			name:       "ZHIPU token defaults to bigmodel",
+			env:        map[string]string{"ZHIPU_AUTH_TOKEN": "zhipu", "ZAI_ANTHROPIC_AUTH_TOKEN": "legacy"},
+			wantToken:  "zhipu",
+			wantURL:    DefaultZhipuBaseURL,
+			wantSource: "ZHIPU_AUTH_TOKEN",
+		},
+		{
+			name:       "ZAI_ANTHROPIC_BASE_URL overrides the default",
+			env:        map[string]string{"ZAI_ANTHROPIC_AUTH_TOKEN": "legacy", "ZAI_ANTHROPIC_BASE_URL": "https://dev.bigmodel.cn/api/anthropic"},
+			wantToken:  "legacy",
+			wantURL:    "https://dev.bigmodel.cn/api/anthropic",
+			wantSource: "ZAI_ANTHROPIC_AUTH_TOKEN",
+		},
+		{
+			name:       "generic pair with its base URL",
+			env:        map[string]string{"ANTHROPIC_AUTH_TOKEN": "glm", "ANTHROPIC_BASE_URL": "https://open.bigmodel.cn/api/anthropic"},
+			wantToken:  "glm",
+			wantURL:    "https://open.bigmodel.cn/api/anthropic",
+			wantSource: "ANTHROPIC_AUTH_TOKEN",
+		},
+		{
+			name: "generic token alone is not used",
+			env:  map[string]string{"ANTHROPIC_AUTH_TOKEN": "sk-ant"},
+		},
+	}
+
+	variables := []string{"ZAI_AUTH_TOKEN", "ZHIPU_AUTH_TOKEN", "ZAI_ANTHROPIC_AUTH_TOKEN", "ZAI_ANTHROPIC_BASE_URL", "ANTHROPIC_AUTH_TOKEN", "ANTHROPIC_BASE_URL"}
+	for _, test := range tests {
+		for _, name := range variables {
+			t.Setenv(name, test.env[name])
+		}
+		tokenSource, baseURL, source := resolveZAICredentials()
+		token, _ := readToken(context.Background(), tokenSource)
+		if token != test.wantToken || baseURL != test.wantURL || source != test.wantSource {
+			t.Errorf("%s: expected %q %q %q, got %q %q %q", test.name, test.wantToken, test.wantURL, test.wantSource, token, baseURL, source)
+		}
+	}
+
+	// Loading the config leaves the generic pair alone
+	if LoadConfig(); os.Getenv("ANTHROPIC_BASE_URL") != "" {
+		t.Errorf("Expected ANTHROPIC_BASE_URL to stay unset, got %s", os.Getenv("ANTHROPIC_BASE_URL"))
+	}
+}
+
+func TestDecodeZAIResponse(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantKey   string
+		wantField string
+		wantGot   string
+		wantMsg   string
+	}{
+		{
+			name:    "data object",
+			body:    `{"code":200,"msg":"Operation successful","data":{"limits":[]},"success":true}`,
+			wantKey: "limits",
+		},
+		{
+			name:    "no envelope",
+			body:    `{"limits":[]}`,
+			wantKey: "limits",
+		},
+		{
+			name:      "data null with error message",
+			body:      `{"code":1001,"msg":"Authorization Token Missing","data":null,"success":false}`,
+			wantField: "data",
+			wantGot:   "null",
+			wantMsg:   "Authorization Token Missing",
+		},
+		{
+			name:      "data array",
+			body:      `{"code":200,"data":[{"type":"TOKENS_LIMIT"}]}`,
+			wantField: "data",
+			wantGot:   "array",
+		},
+		{
+			name:      "data string",
+			body:      `{"code":401,"msg":"token expired","data":"unauthorized"}`,
+			wantField: "data",
+			wantGot:   "string",
+			wantMsg:   "token expired",
+		},
+		{
+			name:      "top-level array",
+			body:      `[]`,
+			wantField: "response",
+			wantGot:   "array",
+		},
+		{
+			name:      "top-level null",
+			body:      `null`,
+			wantField: "response",
+			wantGot:   "null",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := decodeZAIResponse(strings.NewReader(tt.body))
+
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if _, ok := result[tt.wantKey]; !ok {
+					t.Errorf("Expected key %q in result, got %v", tt.wantKey, result)
+				}
+				return
+			}
+
+			var schemaErr *ZAISchemaError
+			if !errors.As(err, &schemaErr) {
+				t.Fatalf("Expected ZAISchemaError, got %v", err)
+			}
+			if schemaErr.Field != tt.wantField || schemaErr.Got != tt.wantGot || schemaErr.Msg != tt.wantMsg {
+				t.Errorf("Expected field=%s got=%s msg=%q, got field=%s got=%s msg=%q",
+					tt.wantField, tt.wantGot, tt.wantMsg, schemaErr.Field, schemaErr.Got, schemaErr.Msg)
+			}
+		})
+	}
+
+	// Malformed JSON is a decode error, not a schema error
+	_, err := decodeZAIResponse(strings.NewReader(`{"data":`))
+	var schemaErr *ZAISchemaError
+	if err == nil || errors.As(err, &schemaErr) {
+		t.Errorf("Expected plain decode error for malformed JSON, got %v", err)
+	}
+}
+
+func FuzzProcessQuotaLimit(f *testing.F) {
+	seeds := []string{
+		`{"limits":[{"type":"TOKENS_LIMIT","percentage":25}]}`,
+		`{"limits":[{"type":"TIME_LIMIT","percentage":10,"currentValue":5,"usage":100,"usageDetails":[{"modelCode":"zread","usage":5}]}]}`,
+		`{"limits":null}`,
+		`{"limits":"TOKENS_LIMIT"}`,
+		`{"limits":[null,1,"x",[]]}`,
+		`{"limits":[{"type":1,"percentage":"25","usageDetails":[null,{"modelCode":7,"usage":"3"}]}]}`,
+		`{"limits":[{"type":"TIME_LIMIT","percentage":1e308,"usage":-1e308,"currentValue":1e400}]}`,
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, payload string) {
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(payload), &data); err != nil {
+			return
+		}
+
+		processed := ProcessQuotaLimit(data)
+		FormatGLMQuota(processed)
+	})
+}
+
+func FuzzDecodeZAIResponse(f *testing.F) {
+	seeds := []string{
+		`{"code":200,"msg":"ok","data":{"limits":[]},"success":true}`,
+		`{"code":500,"msg":"error","data":null,"success":false}`,
+		`{"data":[]}`,
+		`{"data":"unauthorized"}`,
+		`{"data":42}`,
+		`{"limits":[{"type":"TOKENS_LIMIT","percentage":25}]}`,
+		`[]`,
+		`null`,
+		`"data"`,
+		``,
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, body string) {
+		result, err := decodeZAIResponse(strings.NewReader(body))
+		if err != nil {
+			return
+		}
+
+		processed := ProcessQuotaLimit(result)
+		FormatGLMQuota(processed)
+	})
+}
+
+func FuzzDecodeQuotaLimitBody(f *testing.F) {
+	seeds := []string{
+		`{"code":200,"msg":"ok","success":true,"data":{"limits":[{"type":"TOKENS_LIMIT","percentage":25}]}}`,
+		`{"code":200,"data":{"limits":[{"type":"TIME_LIMIT","percentage":10,"currentValue":5,"usage":100,"usageDetails":[{"modelCode":"zread","usage":5},null]}]}}`,
+		`{"data":{"limits":[null,{"type":"TOKENS_LIMIT","unit":3,"number":5,"remaining":1,"nextResetTime":1770000000000}]}}`,
+		`{"data":{"limits":[{"type":"REQUESTS_LIMIT","percentage":40}]}}`,
+		`{"data":{"limits":[{"type":"TOKENS_LIMIT","currentUsage":5}]}}`,
+		`{"data":{"limits":[{"type":"TOKENS_LIMIT","percentage":"25"}]}}`,
+		`{"data":{"limits":[]}}`,
+		`{"data":null}`,
+		`{"data":{"limits":[{"type":"TOKENS_LIMIT"}]}}{}`,
+		`null`,
+		``,
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, body string) {
+		result, err := decodeQuotaLimitBody([]byte(body))
+		if err != nil {
+			return
+		}
+		decoded := result.(quotaLimitResult)
+		FormatGLMQuota(ProcessedZAILimit{Limits: localizeLimits(decoded.limits, language.English)})
+
+		// The typed decoder is a shortcut, so it must agree with the generic path
+		limits, typed := decodeQuotaLimitTyped([]byte(body))
+		if !typed {
+			return
+		}
+		data, err := decodeZAIResponse(strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Expected a body the typed decoder accepts to decode, got %v", err)
+		}
+		processed := ProcessQuotaLimit(data)
+		limits = localizeLimits(limits, language.English)
+		same := len(processed.Limits) == len(limits)
+		for i := 0; same && i < len(limits); i++ {
+			typedLimit, genericLimit := limits[i], processed.Limits[i]
+			same = slices.Equal(typedLimit.UsageDetails, genericLimit.UsageDetails)
+			typedLimit.UsageDetails, genericLimit.UsageDetails = nil, nil
+			same = same && reflect.DeepEqual(typedLimit, genericLimit)
+		}
+		if !same {
+			t.Fatalf("Expected the typed limits %+v to match the generic ones %+v", limits, processed.Limits)
+		}
+	})
+}
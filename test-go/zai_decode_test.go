@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+func TestDecodeQuotaLimitTypedMatchesGeneric(t *testing.T) {
+	bodies := []string{
+		benchQuotaLimitBody,
+		`{"data":{"limits":[{"type":"TOKENS_LIMIT","percentage":12.5},{"type":"REQUESTS_LIMIT","percentage":4,"currentValue":40,"usage":1000}]}}`,
+		`{"data":{"limits":[null,{"type":"TIME_LIMIT","usage":100,"currentValue":7,"percentage":7,"usageDetails":[]}]}}`,
+	}
+	for _, body := range bodies {
+		typed, ok := decodeQuotaLimitTyped([]byte(body))
+		if !ok {
+			t.Errorf("Expected the typed decoder to accept %s", body)
+			continue
+		}
+		data, err := decodeZAIResponse(bytes.NewReader([]byte(body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		generic := ProcessQuotaLimit(data).Limits
+		if got := localizeLimits(typed, language.English); !reflect.DeepEqual(got, generic) {
+			t.Errorf("Typed and generic decoding differ for %s:\n%+v\n%+v", body, got, generic)
+		}
+	}
+}
+
+func TestDecodeQuotaLimitTypedFallsBack(t *testing.T) {
+	for _, body := range []string{
+		`{"data":{"limits":[{"type":"TOKENS_LIMIT","percentage":1,"currentUsage":5}]}}`,
+		`{"data":{"limits":[{"type":"TOKENS_LIMIT","percentage":1,"window":"5h"}]}}`,
+		`{"data":{"limits":[{"type":"REQUESTS_LIMIT","percentage":1}]}}`,
+		`{"limits":[{"type":"TOKENS_LIMIT","percentage":1}]}`,
+	} {
+		if _, ok := decodeQuotaLimitTyped([]byte(body)); ok {
+			t.Errorf("Expected %s to use the generic decoder", body)
+		}
+	}
+}
+
+func TestDecodeQuotaLimitBodyLegacy(t *testing.T) {
+	raw, err := decodeQuotaLimitBody([]byte(`{"data":{"limits":[{"type":"TIME_LIMIT","percentage":5,"usage":100,"currentUsage":5}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := raw.(quotaLimitResult)
+	if result.err != nil || len(result.limits) != 1 || result.limits[0].CurrentUsage != 5 {
+		t.Errorf("Expected the legacy currentUsage through the generic path, got %+v", result)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestZAIDomainFallback(t *testing.T) {
+	defer func(saved *domainFallback) { zaiDomains = saved }(zaiDomains)
+	zaiDomains = &domainFallback{working: make(map[string]string)}
+	zaiCache.Clear()
+	defer zaiCache.Clear()
+
+	var moved, serving []string
+	movedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		moved = append(moved, r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer movedServer.Close()
+	downServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer downServer.Close()
+	servingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serving = append(serving, r.URL.Path)
+		fmt.Fprint(w, `{"code":200,"data":{"limits":[{"type":"TOKENS_LIMIT","percentage":25}]}}`)
+	}))
+	defer servingServer.Close()
+
+	t.Setenv("ZAI_AUTH_TOKEN", "token")
+	t.Setenv("ZAI_ANTHROPIC_BASE_URL", movedServer.URL+"/api/anthropic")
+	t.Setenv("ZAI_PLATFORM", "ZHIPU")
+	t.Setenv("ZHIPU_DOMAINS", movedServer.URL+","+downServer.URL+","+servingServer.URL)
+	t.Setenv("QUERY_DEBOUNCE", "0")
+
+	quota, err := GetGLMQuota(context.Background())
+	if err != nil || len(quota.Models) != 1 || quota.Models[0].Percentage != 75 {
+		t.Fatalf("Expected the quota from the last domain, got %+v, %v", quota.Models, err)
+	}
+	if len(moved) != 1 || len(serving) != 1 {
+		t.Fatalf("Expected one request per domain, got %v and %v", moved, serving)
+	}
+
+	// The domain that answered is tried first from now on
+	if _, err := GetGLMQuota(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(moved) != 1 || len(serving) != 2 {
+		t.Errorf("Expected the remembered domain to be queried directly, got %v and %v", moved, serving)
+	}
+}
+
+func TestZAIDomainCandidates(t *testing.T) {
+	config := &Config{ZAIDomains: []string{"https://api.z.ai"}, ZhipuDomains: []string{"https://open.bigmodel.cn", "https://dev.bigmodel.cn/"}}
+	fallback := &domainFallback{working: make(map[string]string)}
+
+	got := fallback.candidates(config, "https://dev.bigmodel.cn/api/monitor/usage/quota/limit")
+	want := []string{"https://dev.bigmodel.cn/api/monitor/usage/quota/limit", "https://open.bigmodel.cn/api/monitor/usage/quota/limit"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected the endpoint's own domain first, got %v", got)
+	}
+	// A gateway is never swapped for another host
+	if got := fallback.candidates(config, "https://gateway.example.com/api/monitor/usage/quota/limit"); len(got) != 1 {
+		t.Errorf("Expected only the gateway, got %v", got)
+	}
+}
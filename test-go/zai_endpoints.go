@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+// Optional GLM endpoints, named in GLM_ENDPOINTS
+const (
+	glmEndpointUsage        = "usage"
+	glmEndpointSubscription = "subscription"
+)
+
+// QuotaUsage is the token and call usage a provider reports over a window
+type QuotaUsage struct {
+	Tokens int64 `json:"tokens"`
+	Calls  int64 `json:"calls"`
+	Since  int64 `json:"since"`
+	Until  int64 `json:"until"`
+}
+
+// zaiSubscription is an entry of the subscription endpoint's data array
+type zaiSubscription struct {
+	ProductName string `json:"productName"`
+	Status      string `json:"status"`
+}
+
+// glmLimitsResult is the outcome of a GLM limits query
+type glmLimitsResult struct {
+	limits ProcessedZAILimit
+	err    error
+}
+
+// queryGLMUsageTotals returns the totalUsage object of the model usage endpoint for the
+// hours from since to until
+func queryGLMUsageTotals(ctx context.Context, baseDomain, authToken string, since, until time.Time) (map[string]interface{}, error) {
+	usageURL := baseDomain + LoadConfig().ZAIMonitorPrefix + "/model-usage"
+	usageRaw, _, err := QueryZAIEndpoint(ctx, ProviderGLM, usageURL, authToken, buildTimeRangeParams(since, until))
+	if err != nil {
+		return nil, err
+	}
+
+	usageMap, ok := usageRaw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid model usage response format")
+	}
+	totals, _ := usageMap["totalUsage"].(map[string]interface{})
+	totals, _, _ = zaiModelUsageTotalsSchema.normalize(totals)
+	return totals, nil
+}
+
+// fetchGLMUsage gets the tokens and calls of the last 24 hours
+func fetchGLMUsage(ctx context.Context, baseDomain, authToken string) (*QuotaUsage, error) {
+	now := clockNow()
+	since := now.Add(-24 * time.Hour)
+	totals, err := queryGLMUsageTotals(ctx, baseDomain, authToken, since, now)
+	if err != nil {
+		return nil, err
+	}
+	return &QuotaUsage{
+		Tokens: int64(firstNumber(totals, "totalTokensUsage")),
+		Calls:  int64(firstNumber(totals, "totalModelCallCount")),
+		Since:  since.Unix(),
+		Until:  now.Unix(),
+	}, nil
+}
+
+// decodeSubscriptionBody decodes a subscription list into the name of the active plan,
+// or the first plan when none is marked valid
+func decodeSubscriptionBody(body []byte) (interface{}, error) {
+	var envelope struct {
+		Msg  string          `json:"msg"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	var subscriptions []zaiSubscription
+	if err := json.Unmarshal(envelope.Data, &subscriptions); err != nil || len(subscriptions) == 0 {
+		var data interface{}
+		json.Unmarshal(envelope.Data, &data)
+		return nil, &ZAISchemaError{Field: "data", Expected: "a non-empty array of subscriptions", Got: jsonTypeName(data), Msg: envelope.Msg}
+	}
+	for _, subscription := range subscriptions {
+		if subscription.Status == "VALID" {
+			return subscription.ProductName, nil
+		}
+	}
+	return subscriptions[0].ProductName, nil
+}
+
+// fetchGLMPlan gets the name of the coding plan the token is subscribed to
+func fetchGLMPlan(ctx context.Context, baseDomain, authToken string) (string, error) {
+	subscriptionURL := baseDomain + LoadConfig().ZAISubscriptionPath
+	plan, _, err := queryZAIEndpoint(ctx, ProviderGLM, "", subscriptionURL, authToken, "", "plan", decodeSubscriptionBody)
+	if err != nil {
+		return "", err
+	}
+	return plan.(string), nil
+}
+
+// fetchGLMEndpoints queries the quota limits of the primary token and the additional
+// accounts, the limits at ZAI_LIMIT_PATHS, and the optional endpoints in GLM_ENDPOINTS,
+// running up to FETCH_CONCURRENCY queries at once so adding endpoints or accounts does not
+// add their latencies. Only the primary limits are required; the other queries are logged
+// and left out when they fail.
+func fetchGLMEndpoints(ctx context.Context, config *Config, baseDomain, authToken string) (FormattedQuota, error) {
+	var (
+		primary    ProcessedZAILimit
+		primaryErr error
+		usage      *QuotaUsage
+		plan       string
+	)
+	accounts := make([]glmLimitsResult, len(config.ZAIAccounts))
+	pools := make([]glmLimitsResult, len(config.ZAILimitPaths))
+
+	jobs := []func(){func() {
+		primary, primaryErr = fetchGLMLimits(ctx, baseDomain, "", authToken)
+	}}
+	for i, account := range config.ZAIAccounts {
+		jobs = append(jobs, func() {
+			accounts[i].limits, accounts[i].err = fetchGLMLimits(ctx, baseDomain, account.Name, account.Token)
+		})
+	}
+	for i, path := range config.ZAILimitPaths {
+		jobs = append(jobs, func() {
+			pools[i].limits, pools[i].err = fetchGLMLimitsAt(ctx, baseDomain, "", authToken, path)
+		})
+	}
+	if slices.Contains(config.GLMEndpoints, glmEndpointUsage) {
+		jobs = append(jobs, func() {
+			var err error
+			if usage, err = fetchGLMUsage(ctx, baseDomain, authToken); err != nil {
+				log.Printf("Warning: GLM model usage: %v", err)
+			}
+		})
+	}
+	if slices.Contains(config.GLMEndpoints, glmEndpointSubscription) {
+		jobs = append(jobs, func() {
+			var err error
+			if plan, err = fetchGLMPlan(ctx, baseDomain, authToken); err != nil {
+				log.Printf("Warning: GLM subscription: %v", err)
+			}
+		})
+	}
+	runConcurrently(config.FetchConcurrency, jobs)
+
+	if primaryErr != nil {
+		return FormattedQuota{}, primaryErr
+	}
+	for i, pool := range pools {
+		if pool.err != nil {
+			log.Printf("Warning: GLM limits at %s: %v", config.ZAILimitPaths[i], pool.err)
+			continue
+		}
+		primary.Limits = append(primary.Limits, pool.limits.Limits...)
+	}
+	quota := FormatGLMQuota(primary)
+	if len(config.ZAIAccounts) > 0 {
+		quota.Models = append(quota.Models, accountModels(config, primary, accounts)...)
+	}
+	quota.Usage = usage
+	quota.Plan = plan
+	return quota, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunConcurrently(t *testing.T) {
+	var running, peak atomic.Int32
+	var done sync.Map
+	jobs := make([]func(), 10)
+	for i := range jobs {
+		jobs[i] = func() {
+			if n := running.Add(1); n > peak.Load() {
+				peak.Store(n)
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+			done.Store(i, true)
+		}
+	}
+	runConcurrently(3, jobs)
+
+	for i := range jobs {
+		if _, ok := done.Load(i); !ok {
+			t.Errorf("Job %d did not run", i)
+		}
+	}
+	if peak.Load() > 3 || peak.Load() < 2 {
+		t.Errorf("Expected up to 3 jobs at once, got %d", peak.Load())
+	}
+}
+
+func TestGetGLMQuotaEndpoints(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		switch r.URL.Path {
+		case "/api/monitor/usage/quota/limit":
+			fmt.Fprint(w, `{"code":200,"data":{"limits":[{"type":"TOKENS_LIMIT","percentage":25}]}}`)
+		case "/api/monitor/usage/model-usage":
+			fmt.Fprint(w, `{"code":200,"data":{"totalUsage":{"totalModelCallCount":42,"totalTokensUsage":123456}}}`)
+		case "/api/biz/subscription/list":
+			fmt.Fprint(w, `{"code":200,"data":[{"productName":"GLM Coding Lite","status":"EXPIRED"},{"productName":"GLM Coding Pro","status":"VALID"}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("ZAI_AUTH_TOKEN", "endpoints-token")
+	t.Setenv("ZAI_ANTHROPIC_BASE_URL", server.URL+"/api/anthropic")
+	t.Setenv("ZAI_PLATFORM", "ZAI")
+	t.Setenv("GLM_ENDPOINTS", "usage,subscription")
+
+	start := time.Now()
+	quota, err := GetGLMQuota(context.Background())
+	if err != nil {
+		t.Fatalf("GetGLMQuota failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 3*delay {
+		t.Errorf("Expected the endpoints to be queried concurrently, took %v", elapsed)
+	}
+
+	if len(quota.Models) != 1 || quota.Models[0].Percentage != 75 {
+		t.Errorf("Unexpected models: %+v", quota.Models)
+	}
+	if quota.Usage == nil || quota.Usage.Tokens != 123456 || quota.Usage.Calls != 42 {
+		t.Errorf("Unexpected usage: %+v", quota.Usage)
+	}
+	if quota.Plan != "GLM Coding Pro" {
+		t.Errorf("Expected the valid plan, got %q", quota.Plan)
+	}
+}
+
+func TestGetGLMQuotaOptionalEndpointFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/monitor/usage/quota/limit" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, `{"code":200,"data":{"limits":[{"type":"TOKENS_LIMIT","percentage":25}]}}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("ZAI_AUTH_TOKEN", "optional-fails-token")
+	t.Setenv("ZAI_ANTHROPIC_BASE_URL", server.URL+"/api/anthropic")
+	t.Setenv("ZAI_PLATFORM", "ZAI")
+	t.Setenv("GLM_ENDPOINTS", "usage,subscription")
+
+	quota, err := GetGLMQuota(context.Background())
+	if err != nil {
+		t.Fatalf("Expected failing optional endpoints to be left out, got %v", err)
+	}
+	if len(quota.Models) != 1 || quota.Usage != nil || quota.Plan != "" {
+		t.Errorf("Expected only the quota limits, got %+v", quota)
+	}
+}
+
+func TestGetGLMQuotaLimitPaths(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/monitor/usage/quota/limit":
+			fmt.Fprint(w, `{"code":200,"data":{"limits":[{"type":"TOKENS_LIMIT","percentage":25}]}}`)
+		case "/api/monitor/usage/quota/vision":
+			fmt.Fprint(w, `{"code":200,"data":{"limits":[{"type":"VISION_LIMIT","percentage":30}]}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("ZAI_AUTH_TOKEN", "limit-paths-token")
+	t.Setenv("ZAI_ANTHROPIC_BASE_URL", server.URL+"/api/anthropic")
+	t.Setenv("ZAI_PLATFORM", "ZAI")
+	t.Setenv("ZAI_LIMIT_PATHS", "/quota/vision,/quota/missing")
+
+	quota, err := GetGLMQuota(context.Background())
+	if err != nil {
+		t.Fatalf("GetGLMQuota failed: %v", err)
+	}
+	if len(quota.Models) != 2 || quota.Models[1].Name != "glm-coding-plan-vision" || quota.Models[1].Percentage != 70 {
+		t.Errorf("Expected the vision pool from the extra path, got %+v", quota.Models)
+	}
+}
+
+func TestGetGLMQuotaOnlyUnknownTypes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/monitor/usage/quota/limit" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{"code":200,"data":{"limits":[{"type":"REQUESTS_LIMIT","percentage":40},{"type":"AUDIO_MINUTES_LIMIT","currentValue":30,"usage":120}]}}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("ZAI_AUTH_TOKEN", "unknown-types-token")
+	t.Setenv("ZAI_ANTHROPIC_BASE_URL", server.URL+"/api/anthropic")
+	t.Setenv("ZAI_PLATFORM", "ZAI")
+
+	quota, err := GetGLMQuota(context.Background())
+	if err != nil {
+		t.Fatalf("Expected limits of unknown types to be rendered, got %v", err)
+	}
+	want := map[string]float64{"glm-requests": 60, "glm-audio-minutes": 75}
+	if len(quota.Models) != len(want) {
+		t.Fatalf("Expected %d models, got %+v", len(want), quota.Models)
+	}
+	for _, model := range quota.Models {
+		if percentage, ok := want[model.Name]; !ok || model.Percentage != percentage {
+			t.Errorf("Unexpected model %s at %v", model.Name, model.Percentage)
+		}
+	}
+}
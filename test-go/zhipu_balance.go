@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Balance is a monetary amount attached to a formatted model
+type Balance struct {
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+	Display  string  `json:"display"`
+}
+
+// ZhipuBalance represents the account balance of a pay-as-you-go Zhipu/Z.ai API key
+type ZhipuBalance struct {
+	Available float64
+	Granted   float64
+	Currency  string
+
+	// FetchedAt is when the underlying data was retrieved
+	FetchedAt time.Time
+}
+
+// defaultCurrency returns the billing currency of a platform reported by GetBaseDomain
+func defaultCurrency(platform string) string {
+	if platform == "ZHIPU" {
+		return "CNY"
+	}
+	return "USD"
+}
+
+// firstNumber returns the first of keys present in data as a number, accepting numeric strings
+func firstNumber(data map[string]interface{}, keys ...string) float64 {
+	for _, key := range keys {
+		switch v := data[key].(type) {
+		case float64:
+			return v
+		case string:
+			if n, err := strconv.ParseFloat(v, 64); err == nil {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+// ParseZhipuBalance extracts the available and granted balance from an account report
+func ParseZhipuBalance(data map[string]interface{}, platform string) ZhipuBalance {
+	balance := ZhipuBalance{
+		Available: firstNumber(data, "availableBalance", "balance", "cashBalance"),
+		Granted:   firstNumber(data, "giftBalance", "grantBalance", "giveAmount", "voucherBalance"),
+		Currency:  defaultCurrency(platform),
+	}
+	if currency, ok := data["currency"].(string); ok && currency != "" {
+		balance.Currency = strings.ToUpper(currency)
+	}
+	return balance
+}
+
+// FormatZhipuBalance formats a balance to match antigravity quota format.
+// Percentage is 100 while money remains and 0 once it is used up.
+func FormatZhipuBalance(balance ZhipuBalance) FormattedQuota {
+	config := LoadConfig()
+
+	entries := []struct {
+		name   string
+		amount float64
+	}{
+		{"zhipu-balance", balance.Available},
+		{"zhipu-granted-credits", balance.Granted},
+	}
+
+	var models []FormattedModel
+	for _, entry := range entries {
+		pct := 0.0
+		if entry.amount > 0 {
+			pct = QuotaFull
+		}
+		models = append(models, FormattedModel{
+			Name:       entry.name,
+			Percentage: pct,
+			Balance: &Balance{
+				Amount:   entry.amount,
+				Currency: balance.Currency,
+				Display:  formatCurrency(config.NumberLocale, entry.amount, balance.Currency),
+			},
+		})
+	}
+
+	models = applyModelAliases(models, config.ModelAliases)
+
+	quota := FormattedQuota{
+		Models:      models,
+		IsForbidden: false,
+	}
+	stampQuotaTime(&quota, balance.FetchedAt)
+	return quota
+}
+
+// GetZhipuBalance gets the pay-as-you-go account balance from the Zhipu/Z.ai open platform
+func GetZhipuBalance(ctx context.Context) (FormattedQuota, error) {
+	platform, baseDomain, authToken, err := zaiCredentials(ctx)
+	if err != nil {
+		return FormattedQuota{}, err
+	}
+
+	balanceURL := baseDomain + LoadConfig().ZhipuBalancePath
+	balanceRaw, fetchedAt, err := QueryZAIEndpoint(ctx, ProviderZhipuBalance, balanceURL, authToken, "")
+	if err != nil {
+		return FormattedQuota{}, err
+	}
+
+	balanceMap, ok := balanceRaw.(map[string]interface{})
+	if !ok {
+		return FormattedQuota{}, fmt.Errorf("invalid balance response format")
+	}
+
+	balance := ParseZhipuBalance(balanceMap, platform)
+	balance.FetchedAt = fetchedAt
+	return FormatZhipuBalance(balance), nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseZhipuBalance(t *testing.T) {
+	balance := ParseZhipuBalance(map[string]interface{}{
+		"availableBalance": float64(42.1),
+		"giftBalance":      "8.5",
+	}, "ZHIPU")
+
+	if balance.Available != 42.1 || balance.Granted != 8.5 {
+		t.Errorf("Expected available 42.1 and granted 8.5, got %v and %v", balance.Available, balance.Granted)
+	}
+	if balance.Currency != "CNY" {
+		t.Errorf("Expected CNY for ZHIPU, got %s", balance.Currency)
+	}
+
+	if zai := ParseZhipuBalance(map[string]interface{}{"currency": "eur"}, "ZAI"); zai.Currency != "EUR" {
+		t.Errorf("Expected reported currency EUR, got %s", zai.Currency)
+	}
+}
+
+func TestFormatZhipuBalance(t *testing.T) {
+	quota := FormatZhipuBalance(ZhipuBalance{Available: 10, Granted: 0, Currency: "USD"})
+
+	if len(quota.Models) != 2 {
+		t.Fatalf("Expected 2 models, got %d", len(quota.Models))
+	}
+	if quota.Models[0].Percentage != 100 || quota.Models[0].Balance.Display != "$10.00" {
+		t.Errorf("Unexpected balance model: %+v", quota.Models[0])
+	}
+	if quota.Models[1].Percentage != 0 {
+		t.Errorf("Expected empty granted credits at 0%%, got %v", quota.Models[1].Percentage)
+	}
+}
+
+func TestQueryZhipuBalance(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/balance" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		fmt.Fprint(w, `{"code":200,"msg":"ok","data":{"availableBalance":5,"giftBalance":2},"success":true}`)
+	}))
+	defer server.Close()
+
+	result, _, err := QueryZAIEndpoint(context.Background(), ProviderZhipuBalance, server.URL+"/balance", "token", "")
+	if err != nil {
+		t.Fatalf("QueryZAIEndpoint failed: %v", err)
+	}
+
+	balance := ParseZhipuBalance(result.(map[string]interface{}), "ZHIPU")
+	quota := FormatZhipuBalance(balance)
+	if quota.Models[0].Balance.Display != "¥5.00" || quota.Models[1].Balance.Display != "¥2.00" {
+		t.Errorf("Unexpected balances: %+v, %+v", quota.Models[0].Balance, quota.Models[1].Balance)
+	}
+	if health := providerHealth.get(ProviderZhipuBalance); health.LastSuccess == 0 {
+		t.Errorf("Expected health recorded for %s", ProviderZhipuBalance)
+	}
+}
+
+func TestQueryZhipuBalanceDerivesToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); strings.Count(auth, ".") != 2 {
+			t.Errorf("Expected a JWT derived from the key, got %q", auth)
+		}
+		fmt.Fprint(w, `{"code":200,"data":{"availableBalance":1}}`)
+	}))
+	defer server.Close()
+
+	if _, _, err := QueryZAIEndpoint(context.Background(), ProviderZhipuBalance, server.URL+"/balance", "balance-id.balance-secret", ""); err != nil {
+		t.Fatalf("QueryZAIEndpoint failed: %v", err)
+	}
+}